@@ -2,68 +2,79 @@ package main
 
 import (
 	"context"
-	"io"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"clinical-backend/internal/api"
-
-	"github.com/aws/aws-lambda-go/events"
+	"clinical-backend/internal/config"
 )
 
-func runLocalHTTP(router *api.Router) error {
-	port := os.Getenv("LOCAL_HTTP_PORT")
-	if port == "" {
-		port = "3000"
+// runLocalHTTP serves the router with net/http (LOCAL_HTTP=true) until
+// SIGINT/SIGTERM, then stops accepting connections and lets the requests in
+// progress finish (HTTP_SHUTDOWN_TIMEOUT_SECONDS).
+func runLocalHTTP(router *api.Router, cfg config.Config) error {
+	certFile, keyFile := strings.TrimSpace(cfg.HTTPTLSCertFile), strings.TrimSpace(cfg.HTTPTLSKeyFile)
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together")
 	}
 
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		body, _ := io.ReadAll(r.Body)
-		defer r.Body.Close()
-
-		headers := map[string]string{}
-		for key, values := range r.Header {
-			if len(values) > 0 {
-				headers[key] = values[0]
-			}
-		}
+	var origins []string
+	if cfg.HTTPCORSOrigins != "" {
+		origins = strings.Split(cfg.HTTPCORSOrigins, ",")
+	}
+	srv := &http.Server{
+		Addr:              ":" + cfg.HTTPPort,
+		Handler:           api.NewHTTPHandler(router, api.WithMaxBodyBytes(cfg.HTTPMaxBodyBytes), api.WithCORSOrigins(origins)),
+		ReadHeaderTimeout: seconds(cfg.HTTPReadTimeoutSeconds),
+		ReadTimeout:       seconds(cfg.HTTPReadTimeoutSeconds),
+		WriteTimeout:      seconds(cfg.HTTPWriteTimeoutSeconds),
+		IdleTimeout:       seconds(cfg.HTTPIdleTimeoutSeconds),
+		MaxHeaderBytes:    1 << 20,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+	}
 
-		query := map[string]string{}
-		for key, values := range r.URL.Query() {
-			if len(values) > 0 {
-				query[key] = values[0]
-			}
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		lambdaReq := events.APIGatewayV2HTTPRequest{
-			RawPath:               r.URL.Path,
-			Body:                  string(body),
-			Headers:               headers,
-			QueryStringParameters: query,
-			RequestContext: events.APIGatewayV2HTTPRequestContext{
-				HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
-					Method: r.Method,
-					Path:   r.URL.Path,
-				},
-			},
-		}
-
-		resp, err := router.Handle(context.Background(), lambdaReq)
-		if err != nil {
-			log.Printf("local request failed: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = w.Write([]byte(`{"error":"internal_error"}`))
+	errc := make(chan error, 1)
+	go func() {
+		if certFile != "" {
+			log.Printf("http server listening on :%s (TLS)", cfg.HTTPPort)
+			errc <- srv.ListenAndServeTLS(certFile, keyFile)
 			return
 		}
+		log.Printf("http server listening on :%s", cfg.HTTPPort)
+		errc <- srv.ListenAndServe()
+	}()
 
-		for key, value := range resp.Headers {
-			w.Header().Set(key, value)
-		}
-		w.WriteHeader(resp.StatusCode)
-		_, _ = w.Write([]byte(resp.Body))
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := seconds(cfg.HTTPShutdownSeconds)
+	log.Printf("shutting down http server (waiting up to %s for requests in progress)", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+	if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	log.Printf("http server stopped")
+	return nil
+}
 
-	log.Printf("local http server listening on :%s", port)
-	return http.ListenAndServe(":"+port, http.HandlerFunc(handler))
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"log"
 	"os"
@@ -11,8 +12,10 @@ import (
 	"clinical-backend/internal/bedrock"
 	"clinical-backend/internal/config"
 	"clinical-backend/internal/notifications"
+	"clinical-backend/internal/scheduler"
 	"clinical-backend/internal/service"
 	"clinical-backend/internal/store"
+	"clinical-backend/internal/store/postgres"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-lambda-go/events"
@@ -22,6 +25,10 @@ import (
 func main() {
 	cfg := config.Load()
 	notifier := notifications.NewRouter(cfg)
+	tenancy := store.WithStrictTenancy(cfg.StrictTenancy)
+	if cfg.StrictTenancy {
+		log.Printf("Strict tenant isolation enabled")
+	}
 
 	// Initialize repositories based on environment
 	var repos struct {
@@ -34,9 +41,57 @@ func main() {
 		TreatmentPlans   store.TreatmentPlanRepository
 		Payments         store.PaymentRepository
 		Budgets          store.BudgetRepository
+		Waitlist         store.WaitlistRepository
+		Resources        store.ResourceRepository
+		AppointmentTypes store.AppointmentTypeRepository
 	}
 
-	if cfg.ShouldUseDynamoDB() {
+	if cfg.ShouldUsePostgres() {
+		log.Printf("Initializing PostgreSQL repositories (environment: %s, driver: %s)", cfg.Environment, cfg.PostgresDriver)
+
+		// Sin respaldo in-memory: con POSTGRES_DSN configurado, un error de
+		// conexión o de migración debe impedir el arranque.
+		db, err := sql.Open(cfg.PostgresDriver, cfg.PostgresDSN)
+		if err != nil {
+			log.Fatalf("Failed to open PostgreSQL (is the %q driver registered?): %v", cfg.PostgresDriver, err)
+		}
+		pgRepos, err := postgres.NewRepositories(context.Background(), db, tenancy)
+		if err != nil {
+			log.Fatalf("Failed to initialize PostgreSQL repositories: %v", err)
+		}
+		repos.Patients = pgRepos.Patients
+		repos.Appointments = pgRepos.Appointments
+		repos.Consents = pgRepos.Consents
+		repos.ConsentTemplates = pgRepos.ConsentTemplates
+		repos.Users = pgRepos.Users
+		repos.Odontograms = pgRepos.Odontograms
+		repos.TreatmentPlans = pgRepos.TreatmentPlans
+		repos.Payments = pgRepos.Payments
+		repos.Budgets = pgRepos.Budgets
+		repos.Waitlist = pgRepos.Waitlist
+		repos.Resources = pgRepos.Resources
+		repos.AppointmentTypes = pgRepos.AppointmentTypes
+	} else if cfg.ShouldUseFileStore() {
+		log.Printf("Initializing file-backed repositories (environment: %s, file: %s)", cfg.Environment, cfg.DataFile)
+
+		// Igual que con PostgreSQL: un archivo ilegible no debe arrancar vacío.
+		fileRepos, err := store.NewFileRepositories(cfg.DataFile, tenancy)
+		if err != nil {
+			log.Fatalf("Failed to open data file: %v", err)
+		}
+		repos.Patients = fileRepos.Patients
+		repos.Appointments = fileRepos.Appointments
+		repos.Consents = fileRepos.Consents
+		repos.ConsentTemplates = fileRepos.ConsentTemplates
+		repos.Users = fileRepos.Users
+		repos.Odontograms = fileRepos.Odontograms
+		repos.TreatmentPlans = fileRepos.TreatmentPlans
+		repos.Payments = fileRepos.Payments
+		repos.Budgets = fileRepos.Budgets
+		repos.Waitlist = fileRepos.Waitlist
+		repos.Resources = fileRepos.Resources
+		repos.AppointmentTypes = fileRepos.AppointmentTypes
+	} else if cfg.ShouldUseDynamoDB() {
 		log.Printf("Initializing DynamoDB repositories (environment: %s, lambda: %t)", cfg.Environment, cfg.IsLambda)
 
 		dynamoConfig := store.DynamoDBConfig{
@@ -49,15 +104,19 @@ func main() {
 			TreatmentPlanTableName:   cfg.TreatmentPlanTable,
 			PaymentTableName:         cfg.PaymentTable,
 			BudgetTableName:          cfg.BudgetTable,
+			WaitlistTableName:        cfg.WaitlistTable,
+			ResourceTableName:        cfg.ResourceTable,
+			AppointmentTypeTableName: cfg.AppointmentTypeTable,
 			UseLocalProfile:          cfg.IsLocal(),
 			ProfileName:              cfg.AWSProfile,
+			Endpoint:                 cfg.DynamoDBEndpoint,
 		}
 
-		dynamoRepos, err := store.NewDynamoDBRepositories(context.Background(), dynamoConfig)
+		dynamoRepos, err := store.NewDynamoDBRepositories(context.Background(), dynamoConfig, tenancy)
 		if err != nil {
 			log.Printf("Failed to initialize DynamoDB repositories: %v", err)
 			log.Printf("Falling back to in-memory repositories for development")
-			memRepos := store.NewInMemoryRepositories()
+			memRepos := store.NewInMemoryRepositories(tenancy)
 			repos.Patients = memRepos.Patients
 			repos.Appointments = memRepos.Appointments
 			repos.Consents = memRepos.Consents
@@ -67,6 +126,9 @@ func main() {
 			repos.TreatmentPlans = memRepos.TreatmentPlans
 			repos.Payments = memRepos.Payments
 			repos.Budgets = memRepos.Budgets
+			repos.Waitlist = memRepos.Waitlist
+			repos.Resources = memRepos.Resources
+			repos.AppointmentTypes = memRepos.AppointmentTypes
 		} else {
 			repos.Patients = dynamoRepos.Patients
 			repos.Appointments = dynamoRepos.Appointments
@@ -77,10 +139,13 @@ func main() {
 			repos.TreatmentPlans = dynamoRepos.TreatmentPlans
 			repos.Payments = dynamoRepos.Payments
 			repos.Budgets = dynamoRepos.Budgets
+			repos.Waitlist = dynamoRepos.Waitlist
+			repos.Resources = dynamoRepos.Resources
+			repos.AppointmentTypes = dynamoRepos.AppointmentTypes
 		}
 	} else {
 		log.Printf("Using in-memory repositories (local development)")
-		memRepos := store.NewInMemoryRepositories()
+		memRepos := store.NewInMemoryRepositories(tenancy)
 		repos.Patients = memRepos.Patients
 		repos.Appointments = memRepos.Appointments
 		repos.Consents = memRepos.Consents
@@ -90,25 +155,55 @@ func main() {
 		repos.TreatmentPlans = memRepos.TreatmentPlans
 		repos.Payments = memRepos.Payments
 		repos.Budgets = memRepos.Budgets
+		repos.Waitlist = memRepos.Waitlist
+		repos.Resources = memRepos.Resources
+		repos.AppointmentTypes = memRepos.AppointmentTypes
 	}
 
 	consents := service.NewConsentService(repos.Consents, repos.ConsentTemplates, notifier)
+	clinicHours, err := service.ParseClinicHours(cfg.ClinicDays, cfg.ClinicHours)
+	if err != nil {
+		log.Printf("Warning: invalid CLINIC_DAYS/CLINIC_HOURS, patients will only see doctors with their own schedule: %v", err)
+	}
+	// Zona horaria por organización (Organization.Timezone); CLINIC_TZ queda
+	// como zona por defecto para orgs sin zona configurada.
+	timezones := service.NewTimezoneResolver(repos.Users, service.WithFallbackTimezone(cfg.ClinicTZ))
+	// Política de org "cada doctor solo ve sus pacientes", compartida por los servicios.
+	access := service.NewPatientAccess(repos.Patients, repos.Appointments)
 	appointments := service.NewAppointmentService(repos.Appointments, notifier,
+		service.WithTimezones(timezones),
 		service.WithPatientRepo(repos.Patients),
 		service.WithAuthRepo(repos.Users),
 		service.WithConsentService(consents),
+		service.WithPublicPolicy(time.Duration(cfg.PublicMinNoticeHours)*time.Hour, clinicHours),
+		service.WithWaitlistRepo(repos.Waitlist),
+		service.WithWaitlistPolicy(time.Duration(cfg.WaitlistOfferTTLMinutes)*time.Minute, cfg.WaitlistMaxOffers),
+		service.WithResourceRepo(repos.Resources),
+		service.WithAppointmentTypeRepo(repos.AppointmentTypes),
+		service.WithAppointmentAccess(access),
 	)
-	patients := service.NewPatientService(repos.Patients)
+	patients := service.NewPatientService(repos.Patients, service.WithPatientAccess(access))
 	auth := service.NewAuthService(repos.Users,
 		service.WithNotifier(notifier),
 		service.WithFrontendBaseURL(cfg.FrontendBaseURL),
 		service.WithAuthPatientRepo(repos.Patients),
 		service.WithAuthAppointmentRepo(repos.Appointments),
+		service.WithAuthTimezones(timezones),
+		service.WithStrictTenancy(cfg.StrictTenancy),
+		service.WithSessionTTLs(time.Duration(cfg.SessionAccessTTLMinutes)*time.Minute, time.Duration(cfg.SessionRefreshTTLDays)*24*time.Hour),
+		service.WithThrottlePolicy(service.ThrottlePolicy{
+			MaxEmailFailures: cfg.LoginMaxFailures,
+			MaxIPFailures:    cfg.LoginMaxFailuresPerIP,
+			Lockout:          time.Duration(cfg.LoginLockoutMinutes) * time.Minute,
+			MaxResetRequests: cfg.PasswordResetMaxPerHour,
+		}),
 	)
 
 	// Create odontogram services
-	odontogramService := service.NewOdontogramService(repos.Odontograms, repos.Patients, repos.TreatmentPlans)
-	treatmentPlanService := service.NewTreatmentPlanService(repos.TreatmentPlans, repos.Odontograms, repos.Patients)
+	odontogramService := service.NewOdontogramService(repos.Odontograms, repos.Patients, repos.TreatmentPlans,
+		service.WithOdontogramAccess(access))
+	treatmentPlanService := service.NewTreatmentPlanService(repos.TreatmentPlans, repos.Odontograms, repos.Patients,
+		service.WithTreatmentPlanAccess(access))
 
 	// Create odontogram handler
 	odontogramHandler := api.NewOdontogramHandler(odontogramService, treatmentPlanService)
@@ -117,16 +212,11 @@ func main() {
 	paymentService := service.NewPaymentService(repos.Payments,
 		service.WithPaymentPatientRepo(repos.Patients),
 		service.WithPaymentUserRepo(repos.Users),
+		service.WithPaymentAccess(access),
 	)
-	budgetService := service.NewBudgetService(repos.Budgets)
+	budgetService := service.NewBudgetService(repos.Budgets, service.WithBudgetAccess(access))
 
 	// Initialize Bedrock client and chat service
-	clinicTZ, err := time.LoadLocation(cfg.ClinicTZ)
-	if err != nil {
-		log.Printf("Warning: could not load timezone %q, using UTC: %v", cfg.ClinicTZ, err)
-		clinicTZ = time.UTC
-	}
-
 	var chatService *service.ChatService
 	if cfg.DoccoEnabled {
 		awsCfg, bedrockErr := awsconfig.LoadDefaultConfig(context.Background())
@@ -134,15 +224,18 @@ func main() {
 			log.Printf("Warning: could not load AWS config for Bedrock: %v — Docco disabled", bedrockErr)
 		} else {
 			bedrockClient := bedrock.NewClient(awsCfg, cfg.BedrockModelID)
-			chatService = service.NewChatService(repos.Appointments, repos.Patients, repos.Payments, bedrockClient, clinicTZ)
+			chatService = service.NewChatService(repos.Appointments, repos.Patients, repos.Payments, bedrockClient, timezones,
+				service.WithChatAccess(access))
 			log.Printf("Docco chat service initialized (model: %s)", cfg.BedrockModelID)
 		}
 	}
 
+	jobs := scheduler.NewReminderJobs(appointments, repos.Appointments, repos.Users, scheduler.WithTimezones(timezones))
+
 	router := api.NewRouter(appointments, patients, consents, auth, odontogramHandler, paymentService, budgetService, chatService)
 
-	if os.Getenv("LOCAL_HTTP") == "true" {
-		if err := runLocalHTTP(router); err != nil {
+	if cfg.LocalHTTP {
+		if err := runLocalHTTP(router, cfg); err != nil {
 			log.Fatalf("local http failed: %v", err)
 		}
 		return
@@ -210,11 +303,11 @@ func main() {
 				if functionName != "" && (functionName == "clinical-backend-Reminder24hFunction" ||
 					len(functionName) > 19 && functionName[len(functionName)-19:] == "Reminder24hFunction") {
 					log.Printf("Processing 24h reminders")
-					return handleReminder24h(ctx, repos.Appointments, repos.Patients, notifier)
+					return handleReminder24h(ctx, jobs)
 				}
 
 				log.Printf("Processing end of day")
-				return handleEndOfDay(ctx, repos.Appointments, repos.Patients, notifier)
+				return handleEndOfDay(ctx, jobs)
 			}
 		}
 
@@ -224,35 +317,25 @@ func main() {
 }
 
 // handleReminder24h processes 24h reminder notifications
-func handleReminder24h(ctx context.Context, appointments store.AppointmentRepository, patients store.PatientRepository, notifier *notifications.Router) (map[string]interface{}, error) {
+func handleReminder24h(ctx context.Context, jobs *scheduler.ReminderJobs) (scheduler.RunReport, error) {
 	log.Printf("Starting 24h reminder processing")
-
-	// Mock implementation for pipeline testing - add real logic later
-	result := map[string]interface{}{
-		"function": "reminder24h",
-		"status":   "success",
-		"message":  "24h reminders processed successfully",
-		"version":  "1.0.1",
-		"updated":  "2026-02-18T23:53:00Z",
+	report, err := jobs.Run24hReminders(ctx)
+	if err != nil {
+		log.Printf("24h reminders failed: %v", err)
+		return report, err
 	}
-
-	log.Printf("24h reminders completed: %+v", result)
-	return result, nil
+	log.Printf("24h reminders completed: %d orgs", len(report.Orgs))
+	return report, nil
 }
 
 // handleEndOfDay processes end of day notifications and summaries
-func handleEndOfDay(ctx context.Context, appointments store.AppointmentRepository, patients store.PatientRepository, notifier *notifications.Router) (map[string]interface{}, error) {
+func handleEndOfDay(ctx context.Context, jobs *scheduler.ReminderJobs) (scheduler.RunReport, error) {
 	log.Printf("Starting end of day processing")
-
-	// Mock implementation for pipeline testing - add real logic later
-	result := map[string]interface{}{
-		"function": "endOfDay",
-		"status":   "success",
-		"message":  "End of day processing completed successfully",
-		"version":  "1.0.1",
-		"updated":  "2026-02-18T23:53:00Z",
+	report, err := jobs.RunEndOfDay(ctx)
+	if err != nil {
+		log.Printf("End of day processing failed: %v", err)
+		return report, err
 	}
-
-	log.Printf("End of day processing completed: %+v", result)
-	return result, nil
+	log.Printf("End of day processing completed: %d orgs", len(report.Orgs))
+	return report, nil
 }
@@ -0,0 +1,4 @@
+package main
+
+// Registra el driver "pgx" de database/sql para POSTGRES_DSN.
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,42 @@
+// Command backup copies the data file of the file-backed store (DATA_FILE).
+//
+//	DATA_FILE=/var/lib/clinical/data.json go run ./cmd/backup -out /backups/clinical-$(date +%F).json
+//
+// It can run while the API is up: the API replaces the data file atomically,
+// so the copy is always a complete state. The file is checked before copying.
+// To restore, stop the API and put the backup in place of DATA_FILE.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/config"
+	"clinical-backend/internal/store"
+)
+
+func main() {
+	cfg := config.Load()
+	src := flag.String("data", cfg.DataFile, "data file to back up (DATA_FILE)")
+	out := flag.String("out", "", "backup file (default: <data>.<timestamp>.bak next to the data file)")
+	flag.Parse()
+
+	if strings.TrimSpace(*src) == "" {
+		log.Fatalf("no data file: set DATA_FILE or -data")
+	}
+	dst := *out
+	if dst == "" {
+		dst = fmt.Sprintf("%s.%s.bak", *src, time.Now().UTC().Format("20060102T150405Z"))
+	}
+	if filepath.Clean(dst) == filepath.Clean(*src) {
+		log.Fatalf("backup file must differ from the data file")
+	}
+	if err := store.BackupFile(*src, dst); err != nil {
+		log.Fatalf("backup failed: %v", err)
+	}
+	log.Printf("backup written to %s", dst)
+}
@@ -0,0 +1,47 @@
+// Command migrate runs one-off DynamoDB data migrations.
+//
+//	AWS_PROFILE=... go run ./cmd/migrate
+//
+// Currently: backfills the appointment GSI keys and creates the missing
+// appointment indexes (DoctorDayIndex, OrgDayIndex, PatientIndex,
+// ConfirmTokenIndex). Safe to re-run.
+package main
+
+import (
+	"context"
+	"log"
+
+	"clinical-backend/internal/config"
+	"clinical-backend/internal/store"
+)
+
+func main() {
+	cfg := config.Load()
+	ctx := context.Background()
+
+	repos, err := store.NewDynamoDBRepositories(ctx, store.DynamoDBConfig{
+		PatientTableName:         cfg.PatientTable,
+		AppointmentTableName:     cfg.AppointmentTable,
+		ConsentTableName:         cfg.ConsentTable,
+		ConsentTemplateTableName: cfg.ConsentTemplateTable,
+		UserTableName:            cfg.UserTable,
+		OdontogramTableName:      cfg.OdontogramTable,
+		TreatmentPlanTableName:   cfg.TreatmentPlanTable,
+		PaymentTableName:         cfg.PaymentTable,
+		BudgetTableName:          cfg.BudgetTable,
+		WaitlistTableName:        cfg.WaitlistTable,
+		ResourceTableName:        cfg.ResourceTable,
+		AppointmentTypeTableName: cfg.AppointmentTypeTable,
+		UseLocalProfile:          cfg.IsLocal(),
+		ProfileName:              cfg.AWSProfile,
+	})
+	if err != nil {
+		log.Fatalf("init dynamodb: %v", err)
+	}
+
+	n, err := repos.MigrateAppointmentIndexes(ctx)
+	if err != nil {
+		log.Fatalf("appointment index migration failed after %d items: %v", n, err)
+	}
+	log.Printf("appointment index migration done (%d items updated)", n)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GET /org/access-policy
+func (r *Router) getAccessPolicy(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	policy, err := r.auth.GetOrgAccessPolicy(ctx, auth.User.OrgID)
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, policy)
+}
+
+// PUT /org/access-policy {"doctorsOwnPatientsOnly": true}
+func (r *Router) updateAccessPolicy(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.AccessPolicyInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	policy, err := r.auth.SetOrgAccessPolicy(ctx, auth.User.OrgID, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, policy)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GET /appointment-types
+func (r *Router) listAppointmentTypes(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	items, err := r.appointments.ListAppointmentTypes(ctx, auth.User.OrgID)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]any{"items": items})
+}
+
+func (r *Router) createAppointmentType(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	var in service.AppointmentTypeInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	t, err := r.appointments.CreateAppointmentType(ctx, auth.User.OrgID, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(201, t)
+}
+
+func (r *Router) updateAppointmentType(ctx context.Context, id string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	var in service.AppointmentTypeInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	t, err := r.appointments.UpdateAppointmentType(ctx, auth.User.OrgID, id, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, t)
+}
+
+func (r *Router) deleteAppointmentType(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	if err := r.appointments.DeleteAppointmentType(ctx, auth.User.OrgID, id); err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]string{"status": "deleted"})
+}
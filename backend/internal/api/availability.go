@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GET /availability?doctorId=&from=YYYY-MM-DD&to=YYYY-MM-DD&duration=30
+func (r *Router) getAvailability(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	q := service.AvailabilityQuery{
+		DoctorID: req.QueryStringParameters["doctorId"],
+		From:     req.QueryStringParameters["from"],
+		To:       req.QueryStringParameters["to"],
+	}
+	if v := req.QueryStringParameters["duration"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return response(400, map[string]string{"error": "invalid duration"})
+		}
+		q.DurationMinutes = n
+	}
+	res, err := r.appointments.FindFreeSlots(ctx, q)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, res)
+}
+
+func (r *Router) getDoctorAvailability(ctx context.Context, doctorID string) (events.APIGatewayV2HTTPResponse, error) {
+	av, err := r.appointments.GetDoctorAvailability(ctx, doctorID)
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, av)
+}
+
+// updateDoctorAvailability: los admins y asistentes editan cualquier doctor de
+// su org; un doctor (service.IsDoctorRole) solo su propio horario.
+func (r *Router) updateDoctorAvailability(ctx context.Context, doctorID string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	if r.auth.IsDoctor(ctx, auth.User) && auth.User.ID != doctorID {
+		return response(403, map[string]string{"error": "forbidden"})
+	}
+	var in domain.DoctorAvailability
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	av, err := r.appointments.SetDoctorAvailability(ctx, doctorID, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, av)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// calendarFeedResponse adds the subscription URL (webcal-friendly, ending in
+// .ics) to the feed info.
+func calendarFeedResponse(req events.APIGatewayV2HTTPRequest, info service.CalendarFeedInfo) map[string]any {
+	out := map[string]any{"doctorId": info.DoctorID, "enabled": info.Enabled}
+	if info.Token != "" {
+		path := "/public/calendar/" + info.Token + ".ics"
+		if host := req.RequestContext.DomainName; host != "" {
+			path = "https://" + host + path
+		}
+		out["url"] = path
+	}
+	return out
+}
+
+// calendarFeedOwner: los admins gestionan el feed de cualquier doctor de su
+// org; un doctor (service.IsDoctorRole) solo el suyo.
+func (r *Router) calendarFeedOwner(ctx context.Context, doctorID string) bool {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	return !r.auth.IsDoctor(ctx, auth.User) || auth.User.ID == doctorID
+}
+
+// GET /doctors/{doctorId}/calendar-feed
+func (r *Router) getCalendarFeed(ctx context.Context, doctorID string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if !r.calendarFeedOwner(ctx, doctorID) {
+		return response(403, map[string]string{"error": "forbidden"})
+	}
+	info, err := r.appointments.GetCalendarFeed(ctx, doctorID)
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, calendarFeedResponse(req, info))
+}
+
+// POST /doctors/{doctorId}/calendar-feed — activa el feed o rota el token.
+func (r *Router) rotateCalendarFeed(ctx context.Context, doctorID string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if !r.calendarFeedOwner(ctx, doctorID) {
+		return response(403, map[string]string{"error": "forbidden"})
+	}
+	info, err := r.appointments.RotateCalendarFeed(ctx, doctorID)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, calendarFeedResponse(req, info))
+}
+
+// DELETE /doctors/{doctorId}/calendar-feed
+func (r *Router) disableCalendarFeed(ctx context.Context, doctorID string) (events.APIGatewayV2HTTPResponse, error) {
+	if !r.calendarFeedOwner(ctx, doctorID) {
+		return response(403, map[string]string{"error": "forbidden"})
+	}
+	if err := r.appointments.DisableCalendarFeed(ctx, doctorID); err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]string{"status": "disabled"})
+}
+
+// GET /public/calendar/{token}.ics — consultado por Google Calendar/Outlook.
+func (r *Router) publicCalendarFeed(ctx context.Context, token string) (events.APIGatewayV2HTTPResponse, error) {
+	body, err := r.appointments.CalendarFeed(ctx, strings.TrimSuffix(token, ".ics"))
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: 200,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type":                "text/calendar; charset=utf-8",
+			"Content-Disposition":         `inline; filename="agenda.ics"`,
+			"Cache-Control":               "private, max-age=300",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
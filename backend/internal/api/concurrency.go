@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"clinical-backend/internal/service"
+	"clinical-backend/internal/store"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Concurrencia optimista: los GET/PUT de citas, pacientes, presupuestos,
+// odontogramas y planes devuelven ETag: "<version>". El cliente lo reenvía en
+// If-Match (o "version" en el body) y si otro usuario guardó antes recibe 409.
+
+// ifMatchVersion returns the version in the If-Match header ("3" or W/"3").
+// A missing header or "*" returns 0, which skips the check.
+func ifMatchVersion(req events.APIGatewayV2HTTPRequest) (int, error) {
+	v := req.Headers["if-match"]
+	if v == "" {
+		v = req.Headers["If-Match"]
+	}
+	v = strings.TrimSpace(v)
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+	v = strings.Trim(strings.TrimPrefix(v, "W/"), `"`)
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid If-Match header")
+	}
+	return n, nil
+}
+
+// applyIfMatch overrides *version with If-Match when the header is present.
+func applyIfMatch(req events.APIGatewayV2HTTPRequest, version *int) error {
+	n, err := ifMatchVersion(req)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		*version = n
+	}
+	return nil
+}
+
+// versioned is response plus the ETag of an entity with the given version.
+func versioned(code int, payload any, version int) (events.APIGatewayV2HTTPResponse, error) {
+	resp, err := response(code, payload)
+	if err == nil && version > 0 {
+		resp.Headers["ETag"] = fmt.Sprintf(`"%d"`, version)
+	}
+	return resp, err
+}
+
+// errorStatus maps store.ErrConflict to 409, service.ErrAccessDenied and
+// service.ErrForeignOrg to 403 and anything else to code.
+func errorStatus(err error, code int) int {
+	switch {
+	case errors.Is(err, store.ErrConflict):
+		return 409
+	case errors.Is(err, service.ErrAccessDenied), errors.Is(err, service.ErrForeignOrg):
+		return 403
+	}
+	return code
+}
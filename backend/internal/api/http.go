@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// DefaultMaxBodyBytes es el límite de payload de Lambda: con el mismo límite
+// una petición se comporta igual en Lambda y en un servidor propio.
+const DefaultMaxBodyBytes = 6 << 20
+
+// HTTPHandler serves the Router over net/http, for self-hosted installs
+// (LOCAL_HTTP=true). Each request becomes the APIGatewayV2HTTPRequest that
+// API Gateway (payload 2.0) would send: header names in lower case, repeated
+// headers and query params joined with commas, cookies apart and the request
+// context filled in. The request context is passed to the router, so a
+// client that disconnects cancels the work in progress.
+type HTTPHandler struct {
+	router       *Router
+	maxBodyBytes int64
+	corsOrigins  []string // nil: el Access-Control-Allow-Origin del router ("*")
+}
+
+// WithMaxBodyBytes limits the request body; bigger requests get a 413.
+// n <= 0 keeps DefaultMaxBodyBytes.
+func WithMaxBodyBytes(n int64) func(*HTTPHandler) {
+	return func(h *HTTPHandler) {
+		if n > 0 {
+			h.maxBodyBytes = n
+		}
+	}
+}
+
+// WithCORSOrigins restricts Access-Control-Allow-Origin to the given
+// origins ("*" allows any). Without it every origin is allowed, like in
+// Lambda.
+func WithCORSOrigins(origins []string) func(*HTTPHandler) {
+	return func(h *HTTPHandler) {
+		for _, o := range origins {
+			if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+				h.corsOrigins = append(h.corsOrigins, o)
+			}
+		}
+	}
+}
+
+func NewHTTPHandler(router *Router, opts ...func(*HTTPHandler)) *HTTPHandler {
+	h := &HTTPHandler{router: router, maxBodyBytes: DefaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			resp, _ := response(413, map[string]string{"error": "request_too_large"})
+			h.write(w, r, resp)
+			return
+		}
+		resp, _ := response(400, map[string]string{"error": "invalid_body"})
+		h.write(w, r, resp)
+		return
+	}
+
+	resp, err := h.router.Handle(r.Context(), httpRequestEvent(r, body))
+	if err != nil {
+		log.Printf("[http] %s %s failed: %v", r.Method, r.URL.Path, err)
+		resp = events.APIGatewayV2HTTPResponse{StatusCode: 500, Body: `{"error":"internal_error"}`}
+	}
+	if r.Context().Err() != nil {
+		log.Printf("[http] %s %s: client went away", r.Method, r.URL.Path)
+		return
+	}
+	h.write(w, r, resp)
+}
+
+// httpRequestEvent converts r the way API Gateway builds a payload 2.0
+// event. El body va como texto: el router no decodifica base64.
+func httpRequestEvent(r *http.Request, body []byte) events.APIGatewayV2HTTPRequest {
+	headers := make(map[string]string, len(r.Header)+1)
+	var cookies []string
+	for key, values := range r.Header {
+		name := strings.ToLower(key)
+		if name == "cookie" {
+			for _, v := range values {
+				for _, c := range strings.Split(v, ";") {
+					if c = strings.TrimSpace(c); c != "" {
+						cookies = append(cookies, c)
+					}
+				}
+			}
+			continue
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+	if r.Host != "" {
+		headers["host"] = r.Host
+	}
+
+	var query map[string]string
+	if values := r.URL.Query(); len(values) > 0 {
+		query = make(map[string]string, len(values))
+		for key, vs := range values {
+			query[key] = strings.Join(vs, ",")
+		}
+	}
+
+	sourceIP := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		sourceIP = host
+	}
+	now := time.Now().UTC()
+	domain := r.Host
+	if host, _, err := net.SplitHostPort(domain); err == nil && host != "" {
+		domain = host
+	}
+
+	return events.APIGatewayV2HTTPRequest{
+		Version:               "2.0",
+		RouteKey:              "$default",
+		RawPath:               r.URL.EscapedPath(),
+		RawQueryString:        r.URL.RawQuery,
+		Cookies:               cookies,
+		Headers:               headers,
+		QueryStringParameters: query,
+		Body:                  string(body),
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RouteKey:     "$default",
+			Stage:        "$default",
+			RequestID:    uuid.NewString(),
+			DomainName:   domain,
+			DomainPrefix: strings.SplitN(domain, ".", 2)[0],
+			Time:         now.Format("02/Jan/2006:15:04:05 -0700"),
+			TimeEpoch:    now.UnixMilli(),
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Protocol:  r.Proto,
+				SourceIP:  sourceIP,
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+}
+
+func (h *HTTPHandler) write(w http.ResponseWriter, r *http.Request, resp events.APIGatewayV2HTTPResponse) {
+	header := w.Header()
+	for key, value := range resp.Headers {
+		header.Set(key, value)
+	}
+	for key, values := range resp.MultiValueHeaders {
+		for _, v := range values {
+			header.Add(key, v)
+		}
+	}
+	for _, c := range resp.Cookies {
+		header.Add("Set-Cookie", c)
+	}
+	if h.corsOrigins != nil {
+		header.Del("Access-Control-Allow-Origin")
+		header.Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); origin != "" && h.allowOrigin(origin) {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+	}
+
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			log.Printf("[http] %s %s: invalid base64 body: %v", r.Method, r.URL.Path, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"internal_error"}`))
+			return
+		}
+		body = decoded
+	}
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK // API Gateway asume 200 sin statusCode
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+func (h *HTTPHandler) allowOrigin(origin string) bool {
+	for _, o := range h.corsOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
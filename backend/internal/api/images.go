@@ -35,6 +35,10 @@ func (r *Router) getAppointmentUploadURL(ctx context.Context, appointmentID stri
 		return response(500, map[string]string{"error": "images bucket not configured"})
 	}
 
+	if _, err := r.appointments.GetByID(ctx, appointmentID); err != nil {
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
+	}
+
 	filename := req.QueryStringParameters["filename"]
 	if filename == "" {
 		filename = "image.jpg"
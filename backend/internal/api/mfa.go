@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// POST /auth/mfa/verify — segundo paso del login (mfaToken + code o recoveryCode).
+func (r *Router) verifyMFA(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.VerifyMFAInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	in.UserAgent, in.IP = req.RequestContext.HTTP.UserAgent, req.RequestContext.HTTP.SourceIP
+	out, err := r.auth.VerifyMFA(ctx, in)
+	if err != nil {
+		return authError(err, 401)
+	}
+	return response(200, out)
+}
+
+// POST /auth/mfa/enroll — inscripción obligatoria durante el login (mfaEnrollmentRequired).
+func (r *Router) enrollMFAForLogin(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in struct {
+		MFAToken string `json:"mfaToken"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	out, err := r.auth.StartMFAEnrollmentForLogin(ctx, in.MFAToken, req.RequestContext.HTTP.SourceIP)
+	if err != nil {
+		return response(401, map[string]string{"error": err.Error()})
+	}
+	return response(200, out)
+}
+
+// POST /users/me/mfa/enroll
+func (r *Router) enrollMyMFA(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	out, err := r.auth.StartMFAEnrollment(ctx, auth.User.ID)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, out)
+}
+
+// POST /users/me/mfa/confirm — activa el MFA con un código de la app; devuelve los códigos de recuperación.
+func (r *Router) confirmMyMFA(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.MFACodeInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	out, err := r.auth.ConfirmMFAEnrollment(ctx, auth.User.ID, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, out)
+}
+
+// POST /users/me/mfa/recovery-codes
+func (r *Router) regenerateMyRecoveryCodes(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.MFACodeInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	out, err := r.auth.RegenerateRecoveryCodes(ctx, auth.User.ID, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, out)
+}
+
+// POST /users/me/mfa/disable
+func (r *Router) disableMyMFA(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.MFACodeInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	if err := r.auth.DisableMFA(ctx, auth.User.ID, in); err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]bool{"mfaEnabled": false})
+}
+
+// DELETE /orgs/{orgId}/users/{userId}/mfa — el admin quita el MFA de un usuario.
+func (r *Router) resetOrgUserMFA(ctx context.Context, orgID, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	if err := r.auth.ResetOrgUserMFA(ctx, orgID, userID); err != nil {
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]string{"mfaReset": userID})
+}
+
+// GET /org/mfa-policy
+func (r *Router) getMFAPolicy(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	policy, err := r.auth.GetOrgMFAPolicy(ctx, auth.User.OrgID)
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, policy)
+}
+
+// PUT /org/mfa-policy
+func (r *Router) updateMFAPolicy(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.MFAPolicyInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	policy, err := r.auth.SetOrgMFAPolicy(ctx, auth.User.OrgID, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, policy)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GET /org/no-show-policy
+func (r *Router) getNoShowPolicy(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	policy, err := r.appointments.GetNoShowPolicy(ctx, auth.User.OrgID)
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, policy)
+}
+
+// PUT /org/no-show-policy
+func (r *Router) updateNoShowPolicy(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	var in domain.NoShowPolicy
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	policy, err := r.appointments.SetNoShowPolicy(ctx, auth.User.OrgID, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, policy)
+}
+
+// GET /patients/{id}/booking-policy?doctorId= — aviso/depósito antes de agendar.
+func (r *Router) getBookingPolicy(ctx context.Context, patientID string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	notice, err := r.appointments.BookingPolicy(ctx, patientID, req.QueryStringParameters["doctorId"])
+	if err != nil {
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
+	}
+	return response(200, notice)
+}
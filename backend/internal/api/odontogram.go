@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -47,7 +48,7 @@ func (h *OdontogramHandler) CreateOdontogram(ctx context.Context, request events
 
 	odontogram, err := h.odontogramService.CreateOdontogram(ctx, req.PatientID, req.DoctorID)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusCreated, odontogram)
@@ -66,10 +67,10 @@ func (h *OdontogramHandler) GetOdontogramByPatient(ctx context.Context, request
 		if strings.Contains(err.Error(), "not found") {
 			return response(http.StatusNotFound, map[string]string{"error": "Odontogram not found for patient"})
 		}
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
-	return response(http.StatusOK, odontogram)
+	return versioned(http.StatusOK, odontogram, odontogram.Version)
 }
 
 // UpdateOdontogram updates the full odontogram (all teeth) in one call
@@ -84,61 +85,67 @@ func (h *OdontogramHandler) UpdateOdontogram(ctx context.Context, request events
 	}
 
 	var req struct {
-		Teeth []domain.ToothInfo `json:"teeth"`
+		Teeth   []domain.ToothInfo `json:"teeth"`
+		Version int                `json:"version,omitempty"`
 	}
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
 		return response(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
+	if err := applyIfMatch(request, &req.Version); err != nil {
+		return response(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	odn, err := h.odontogramService.GetOdontogramByID(ctx, odontogramID)
+	if errors.Is(err, service.ErrAccessDenied) {
+		return response(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
 	if err != nil {
 		return response(http.StatusNotFound, map[string]string{"error": "Odontogram not found"})
 	}
 
 	odn.Teeth = req.Teeth
+	if req.Version > 0 {
+		odn.Version = req.Version // el repo rechaza si ya no es la guardada
+	}
 	updated, err := h.odontogramService.UpdateOdontogram(ctx, odn)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
-	return response(http.StatusOK, updated)
+	return versioned(http.StatusOK, updated, updated.Version)
 }
 
 // UpdateToothCondition updates the condition of specific tooth surfaces
-// PUT /odontogram/{odontogramId}/tooth/{toothNumber}
+// PUT /odontograms/{odontogramId}/tooth-condition
 func (h *OdontogramHandler) UpdateToothCondition(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	odontogramID, ok := request.PathParameters["odontogramId"]
 	if !ok || odontogramID == "" {
 		return response(http.StatusBadRequest, map[string]string{"error": "odontogramId is required"})
 	}
 
-	toothNumberStr, ok := request.PathParameters["toothNumber"]
-	if !ok || toothNumberStr == "" {
-		return response(http.StatusBadRequest, map[string]string{"error": "toothNumber is required"})
-	}
-
-	toothNumberInt, err := strconv.Atoi(toothNumberStr)
-	if err != nil {
-		return response(http.StatusBadRequest, map[string]string{"error": "Invalid tooth number"})
-	}
-	toothNumber := domain.ToothNumber(toothNumberInt)
-
 	var req struct {
-		DoctorID string                         `json:"doctorId"`
-		Surfaces []domain.ToothSurfaceCondition `json:"surfaces"`
+		DoctorID    string                         `json:"doctorId"`
+		ToothNumber int                            `json:"toothNumber"`
+		Surfaces    []domain.ToothSurfaceCondition `json:"surfaces"`
 	}
 
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
 		return response(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
+	// La ruta no lleva el diente: viene en el body.
+	if req.ToothNumber == 0 {
+		return response(http.StatusBadRequest, map[string]string{"error": "toothNumber is required"})
+	}
+	toothNumber := domain.ToothNumber(req.ToothNumber)
+
 	if req.DoctorID == "" {
 		return response(http.StatusBadRequest, map[string]string{"error": "doctorId is required"})
 	}
 
-	err = h.odontogramService.UpdateToothCondition(ctx, odontogramID, toothNumber, req.Surfaces, req.DoctorID)
+	err := h.odontogramService.UpdateToothCondition(ctx, odontogramID, toothNumber, req.Surfaces, req.DoctorID)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusOK, map[string]string{"message": "Tooth condition updated successfully"})
@@ -160,7 +167,7 @@ func (h *OdontogramHandler) RecordTreatment(ctx context.Context, request events.
 
 	err := h.odontogramService.RecordTreatment(ctx, treatment)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusCreated, map[string]string{"message": "Treatment recorded successfully"})
@@ -184,7 +191,7 @@ func (h *OdontogramHandler) GetTreatmentHistory(ctx context.Context, request eve
 
 	treatments, err := h.odontogramService.GetTreatmentHistory(ctx, patientID, limit)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusOK, map[string]interface{}{
@@ -214,7 +221,7 @@ func (h *OdontogramHandler) GetToothHistory(ctx context.Context, request events.
 
 	treatments, err := h.odontogramService.GetToothHistory(ctx, patientID, toothNumber)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusOK, map[string]interface{}{
@@ -243,6 +250,9 @@ func (h *OdontogramHandler) CreateTreatmentPlan(ctx context.Context, request eve
 	// If odontogramId is not provided, try to find the patient's odontogram
 	if plan.OdontogramID == "" {
 		odontogram, err := h.odontogramService.GetOdontogramByPatient(ctx, plan.PatientID)
+		if errors.Is(err, service.ErrAccessDenied) {
+			return response(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
 		if err != nil {
 			return response(http.StatusBadRequest, map[string]string{"error": "No odontogram found for patient. Create an odontogram first or provide odontogramId"})
 		}
@@ -251,7 +261,7 @@ func (h *OdontogramHandler) CreateTreatmentPlan(ctx context.Context, request eve
 
 	createdPlan, err := h.treatmentPlanService.CreateTreatmentPlan(ctx, plan)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusCreated, createdPlan)
@@ -270,10 +280,10 @@ func (h *OdontogramHandler) GetTreatmentPlan(ctx context.Context, request events
 		if strings.Contains(err.Error(), "not found") {
 			return response(http.StatusNotFound, map[string]string{"error": "Treatment plan not found"})
 		}
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
-	return response(http.StatusOK, plan)
+	return versioned(http.StatusOK, plan, plan.Version)
 }
 
 // GetPatientTreatmentPlans retrieves all treatment plans for a patient
@@ -286,7 +296,7 @@ func (h *OdontogramHandler) GetPatientTreatmentPlans(ctx context.Context, reques
 
 	plans, err := h.treatmentPlanService.GetPatientTreatmentPlans(ctx, patientID)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusOK, map[string]interface{}{
@@ -309,13 +319,19 @@ func (h *OdontogramHandler) UpdateTreatmentPlan(ctx context.Context, request eve
 	}
 
 	plan.ID = planID // Ensure ID matches path parameter
+	if err := applyIfMatch(request, &plan.Version); err != nil {
+		return response(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	updatedPlan, err := h.treatmentPlanService.UpdateTreatmentPlan(ctx, plan)
 	if err != nil {
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		if strings.Contains(err.Error(), "not found") {
+			return response(http.StatusNotFound, map[string]string{"error": "Treatment plan not found"})
+		}
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
-	return response(http.StatusOK, updatedPlan)
+	return versioned(http.StatusOK, updatedPlan, updatedPlan.Version)
 }
 
 // ApproveTreatmentPlan approves a treatment plan
@@ -331,7 +347,7 @@ func (h *OdontogramHandler) ApproveTreatmentPlan(ctx context.Context, request ev
 		if strings.Contains(err.Error(), "not found") {
 			return response(http.StatusNotFound, map[string]string{"error": "Treatment plan not found"})
 		}
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusOK, map[string]string{"message": "Treatment plan approved successfully"})
@@ -350,7 +366,7 @@ func (h *OdontogramHandler) StartTreatmentPlan(ctx context.Context, request even
 		if strings.Contains(err.Error(), "not found") {
 			return response(http.StatusNotFound, map[string]string{"error": "Treatment plan not found"})
 		}
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusOK, map[string]string{"message": "Treatment plan started successfully"})
@@ -394,7 +410,7 @@ func (h *OdontogramHandler) MarkTreatmentCompleted(ctx context.Context, request
 		if strings.Contains(err.Error(), "invalid") {
 			return response(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusOK, map[string]string{"message": "Treatment marked as completed"})
@@ -425,7 +441,7 @@ func (h *OdontogramHandler) GenerateTreatmentPlanFromOdontogram(ctx context.Cont
 		if strings.Contains(err.Error(), "no treatments needed") {
 			return response(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusCreated, plan)
@@ -467,7 +483,7 @@ func (h *OdontogramHandler) GenerateInitialAssessment(ctx context.Context, reque
 		if strings.Contains(err.Error(), "not found") {
 			return response(http.StatusNotFound, map[string]string{"error": "Odontogram not found"})
 		}
-		return response(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, http.StatusInternalServerError), map[string]string{"error": err.Error()})
 	}
 
 	return response(http.StatusOK, map[string]string{"message": "Initial assessment completed successfully"})
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Portal del paciente: endpoints públicos autenticados solo por el token del
+// enlace enviado por email.
+
+// GET /public/appointments/{token}
+func (r *Router) publicGetAppointment(ctx context.Context, token string) (events.APIGatewayV2HTTPResponse, error) {
+	view, err := r.appointments.GetByToken(ctx, token)
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, view)
+}
+
+// POST /public/appointments/{token}/cancel {"reason": "..."}
+func (r *Router) publicCancelAppointment(ctx context.Context, token string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in struct {
+		Reason string `json:"reason"`
+	}
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+			return response(400, map[string]string{"error": "invalid_json"})
+		}
+	}
+	appt, err := r.appointments.CancelByToken(ctx, token, in.Reason)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]interface{}{
+		"status":        appt.Status,
+		"appointmentId": appt.ID,
+		"startAt":       appt.StartAt,
+	})
+}
+
+// GET /public/appointments/{token}/slots?from=YYYY-MM-DD&to=YYYY-MM-DD
+func (r *Router) publicAppointmentSlots(ctx context.Context, token string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	res, err := r.appointments.PublicFreeSlots(ctx, token, req.QueryStringParameters["from"], req.QueryStringParameters["to"])
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, res)
+}
+
+// POST /public/appointments/{token}/reschedule {"startAt": RFC3339, "reason": "..."}
+// El enlace anterior deja de ser válido: se devuelve el nuevo token.
+func (r *Router) publicRescheduleAppointment(ctx context.Context, token string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in struct {
+		StartAt string `json:"startAt"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	appt, err := r.appointments.RescheduleByToken(ctx, token, in.StartAt, in.Reason)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]interface{}{
+		"status":        appt.Status,
+		"appointmentId": appt.ID,
+		"startAt":       appt.StartAt,
+		"endAt":         appt.EndAt,
+		"token":         appt.ConfirmToken,
+	})
+}
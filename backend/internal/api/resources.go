@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GET /resources
+func (r *Router) listResources(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	items, err := r.appointments.ListResources(ctx, auth.User.OrgID)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]any{"items": items})
+}
+
+func (r *Router) createResource(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	var in service.ResourceInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	res, err := r.appointments.CreateResource(ctx, auth.User.OrgID, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(201, res)
+}
+
+func (r *Router) updateResource(ctx context.Context, id string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	var in service.ResourceInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	res, err := r.appointments.UpdateResource(ctx, auth.User.OrgID, id, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, res)
+}
+
+func (r *Router) deleteResource(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	if err := r.appointments.DeleteResource(ctx, auth.User.OrgID, id); err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]string{"status": "deleted"})
+}
+
+// GET /resources/schedule?date=YYYY-MM-DD — agenda del día agrupada por recurso.
+func (r *Router) getResourceSchedule(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+	schedule, err := r.appointments.GetResourceSchedule(ctx, auth.User.OrgID, req.QueryStringParameters["date"])
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, schedule)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GET /org/permissions — catálogo de permisos asignables.
+func (r *Router) listPermissions() (events.APIGatewayV2HTTPResponse, error) {
+	return response(200, r.auth.Permissions())
+}
+
+// GET /org/roles — matriz de roles de la org.
+func (r *Router) listOrgRoles(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	roles, err := r.auth.ListOrgRoles(ctx, auth.User.OrgID)
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, roles)
+}
+
+// PUT /org/roles/{role} — crear un rol o reemplazar sus permisos.
+func (r *Router) saveOrgRole(ctx context.Context, name string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.RoleInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	role, err := r.auth.SaveOrgRole(ctx, auth.User.OrgID, name, in)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, role)
+}
+
+// DELETE /org/roles/{role} — borrar un rol propio o restaurar uno predeterminado.
+func (r *Router) deleteOrgRole(ctx context.Context, name string) (events.APIGatewayV2HTTPResponse, error) {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	if err := r.auth.DeleteOrgRole(ctx, auth.User.OrgID, name); err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]string{"status": "deleted"})
+}
@@ -19,52 +19,28 @@ type authCtxKey string
 
 const ctxAuthKey authCtxKey = "auth"
 
+// permission names a permission of the org's role matrix (see
+// service.AuthService.HasPermission).
 type permission string
 
 const (
-	permPlatformManage     permission = "platform.manage"
-	permUsersManage        permission = "users.manage"
-	permPatientsView       permission = "patients.view"
-	permPatientsWrite      permission = "patients.write"
-	permPatientsDelete     permission = "patients.delete"
-	permAppointmentsWrite  permission = "appointments.write"
-	permAppointmentsDelete permission = "appointments.delete"
-	permTreatmentsManage   permission = "treatments.manage"
+	permPlatformManage         permission = service.PermPlatformManage
+	permUsersManage            permission = service.PermUsersManage
+	permPatientsView           permission = service.PermPatientsView
+	permPatientsWrite          permission = service.PermPatientsWrite
+	permPatientsDelete         permission = service.PermPatientsDelete
+	permAppointmentsWrite      permission = service.PermAppointmentsWrite
+	permAppointmentsDelete     permission = service.PermAppointmentsDelete
+	permTreatmentsManage       permission = service.PermTreatmentsManage
+	permFinancesView           permission = service.PermFinancesView
+	permFinancesManage         permission = service.PermFinancesManage
+	permConsentTemplatesManage permission = service.PermConsentTemplatesManage
+	permChatUse                permission = service.PermChatUse
+	// permAuthenticated: cualquier usuario con sesión (su perfil, sus
+	// sesiones, su MFA), tenga el rol que tenga.
+	permAuthenticated permission = "authenticated"
 )
 
-// hasPermission enforces the RBAC matrix:
-//
-//	platform_admin : everything
-//	admin          : users, patients (view+write+delete), appointments (write+delete), treatments
-//	doctor         : patients (view+write), appointments (write), treatments
-//	assistant      : patients (view only), appointments (write — create/edit/cancel/confirm)
-func hasPermission(role string, p permission) bool {
-	r := strings.ToLower(strings.TrimSpace(role))
-	if r == "platform_admin" {
-		return true
-	}
-	switch p {
-	case permPlatformManage:
-		return false
-	case permUsersManage:
-		return r == "admin"
-	case permPatientsView:
-		return r == "admin" || r == "doctor" || r == "assistant"
-	case permPatientsWrite:
-		return r == "admin" || r == "doctor"
-	case permPatientsDelete:
-		return r == "admin"
-	case permAppointmentsWrite:
-		return r == "admin" || r == "doctor" || r == "assistant"
-	case permAppointmentsDelete:
-		return r == "admin"
-	case permTreatmentsManage:
-		return r == "admin" || r == "doctor"
-	default:
-		return false
-	}
-}
-
 func bearerToken(req events.APIGatewayV2HTTPRequest) string {
 	authz := req.Headers["authorization"]
 	if authz == "" {
@@ -80,25 +56,6 @@ func bearerToken(req events.APIGatewayV2HTTPRequest) string {
 	return authz
 }
 
-func isPublicEndpoint(method, path string) bool {
-	if method == "GET" && path == "/health" {
-		return true
-	}
-	if method == "POST" && strings.HasPrefix(path, "/auth/") {
-		return true
-	}
-	if method == "POST" && path == "/platform/bootstrap" {
-		return true
-	}
-	if method == "POST" && strings.HasPrefix(path, "/public/consents/") && strings.HasSuffix(path, "/accept") {
-		return true
-	}
-	if method == "POST" && strings.HasPrefix(path, "/public/appointments/") && strings.HasSuffix(path, "/confirm") {
-		return true
-	}
-	return false
-}
-
 func (r *Router) require(ctx context.Context, req events.APIGatewayV2HTTPRequest, p permission) (context.Context, events.APIGatewayV2HTTPResponse, bool) {
 	token := bearerToken(req)
 	auth, err := r.auth.Authenticate(ctx, token)
@@ -106,11 +63,18 @@ func (r *Router) require(ctx context.Context, req events.APIGatewayV2HTTPRequest
 		resp, _ := response(401, map[string]string{"error": err.Error()})
 		return ctx, resp, false
 	}
-	if !hasPermission(auth.User.Role, p) {
+	if p != permAuthenticated && !r.auth.HasPermission(ctx, auth.User, string(p)) {
 		resp, _ := response(403, map[string]string{"error": "forbidden"})
 		return ctx, resp, false
 	}
 	ctx = store.ContextWithOrgID(ctx, auth.User.OrgID)
+	if strings.ToLower(strings.TrimSpace(auth.User.Role)) == "platform_admin" {
+		// platform_admin opera sobre todas las orgs (estadísticas, usuarios de
+		// otra org): en modo estricto necesita un contexto de sistema.
+		ctx = store.SystemContext(ctx)
+	}
+	// Los servicios leen al usuario con service.AuthFromContext (acceso por doctor).
+	ctx = service.ContextWithAuth(ctx, auth)
 	return context.WithValue(ctx, ctxAuthKey, auth), events.APIGatewayV2HTTPResponse{}, true
 }
 
@@ -138,7 +102,7 @@ func (r *Router) resendAppointmentConfirmation(ctx context.Context, id string, r
 		in.Channel = "email"
 	}
 	if err := r.appointments.SendReminderAnytime(ctx, id, in.Channel); err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(200, map[string]string{"status": "reminder_resent"})
 }
@@ -150,7 +114,7 @@ func (r *Router) registerPayment(ctx context.Context, id string, req events.APIG
 	}
 	appt, err := r.appointments.RegisterPayment(ctx, id, in)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(200, appt)
 }
@@ -159,23 +123,6 @@ func NewRouter(appointments *service.AppointmentService, patients *service.Patie
 	return &Router{appointments: appointments, patients: patients, consents: consents, auth: auth, odontogram: odontogram, payments: payments, budgets: budgets, chat: chat}
 }
 
-func (r *Router) isPublicConsentAccept(method, path string) bool {
-	return method == "POST" && strings.HasPrefix(path, "/public/consents/") && strings.HasSuffix(path, "/accept")
-}
-
-// logResponse logs the response details and returns the response
-func (r *Router) logResponse(endpoint string, startTime time.Time, resp events.APIGatewayV2HTTPResponse, err error) (events.APIGatewayV2HTTPResponse, error) {
-	duration := time.Since(startTime)
-
-	if err != nil {
-		log.Printf("[ERROR] %s - Duration: %v, Error: %v", endpoint, duration, err)
-		return events.APIGatewayV2HTTPResponse{StatusCode: 500, Body: `{"message": "Internal server error"}`}, nil
-	}
-
-	log.Printf("[RESPONSE] %s - Status: %d, Duration: %v, Body: %s", endpoint, resp.StatusCode, duration, resp.Body)
-	return resp, err
-}
-
 func (r *Router) Handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	startTime := time.Now()
 	method := strings.ToUpper(req.RequestContext.HTTP.Method)
@@ -192,402 +139,25 @@ func (r *Router) Handle(ctx context.Context, req events.APIGatewayV2HTTPRequest)
 
 	if method == "OPTIONS" {
 		resp, err = response(204, map[string]string{"status": "ok"})
-	} else {
-		switch {
-		case method == "GET" && path == "/health":
-			resp, err = response(200, map[string]string{"status": "ok", "message": "Clinical API is running", "version": "1.0.1", "updated": "2026-02-18"})
-		case method == "POST" && path == "/platform/bootstrap":
-			resp, err = r.platformBootstrap(ctx, req)
-		case method == "POST" && path == "/platform/orgs":
-			if actx, deny, ok := r.require(ctx, req, permPlatformManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.createOrganization(actx, req)
-			}
-		case method == "GET" && path == "/platform/stats":
-			if actx, deny, ok := r.require(ctx, req, permPlatformManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.getPlatformStats(actx)
-			}
-		case method == "GET" && path == "/org/stats":
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				auth := actx.Value(ctxAuthKey).(service.Authenticated)
-				resp, err = r.getOrgStats(actx, auth.User.OrgID)
-			}
-		case method == "GET" && path == "/platform/orgs":
-			if actx, deny, ok := r.require(ctx, req, permPlatformManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.listOrganizations(actx)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/platform/orgs/") && !strings.Contains(path[len("/platform/orgs/"):], "/"):
-			if actx, deny, ok := r.require(ctx, req, permPlatformManage); !ok {
-				resp, err = deny, nil
-			} else {
-				orgID := strings.TrimPrefix(path, "/platform/orgs/")
-				resp, err = r.getOrganization(actx, orgID)
-			}
-		case method == "PUT" && strings.HasPrefix(path, "/platform/orgs/") && !strings.Contains(path[len("/platform/orgs/"):], "/"):
-			if actx, deny, ok := r.require(ctx, req, permPlatformManage); !ok {
-				resp, err = deny, nil
-			} else {
-				orgID := strings.TrimPrefix(path, "/platform/orgs/")
-				resp, err = r.updateOrganization(actx, orgID, req)
-			}
-		case method == "DELETE" && strings.HasPrefix(path, "/platform/orgs/") && !strings.Contains(path[len("/platform/orgs/"):], "/"):
-			if actx, deny, ok := r.require(ctx, req, permPlatformManage); !ok {
-				resp, err = deny, nil
-			} else {
-				orgID := strings.TrimPrefix(path, "/platform/orgs/")
-				resp, err = r.deleteOrganization(actx, orgID)
-			}
-		case method == "POST" && strings.HasPrefix(path, "/platform/orgs/") && strings.HasSuffix(path, "/admins"):
-			if actx, deny, ok := r.require(ctx, req, permPlatformManage); !ok {
-				resp, err = deny, nil
-			} else {
-				orgID := strings.TrimSuffix(strings.TrimPrefix(path, "/platform/orgs/"), "/admins")
-				resp, err = r.createOrgAdmin(actx, orgID, req)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/orgs/") && strings.HasSuffix(path, "/stats"):
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				orgID := strings.TrimSuffix(strings.TrimPrefix(path, "/orgs/"), "/stats")
-				resp, err = r.getOrgStats(actx, orgID)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/orgs/") && strings.HasSuffix(path, "/users"):
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				orgID := strings.TrimSuffix(strings.TrimPrefix(path, "/orgs/"), "/users")
-				resp, err = r.listOrgUsers(actx, orgID)
-			}
-		case method == "POST" && strings.HasPrefix(path, "/orgs/") && strings.HasSuffix(path, "/users"):
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				orgID := strings.TrimSuffix(strings.TrimPrefix(path, "/orgs/"), "/users")
-				resp, err = r.createOrgUser(actx, orgID, req)
-			}
-		case method == "PATCH" && strings.Contains(path, "/users/") && strings.HasPrefix(path, "/orgs/"):
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				parts := strings.Split(strings.TrimPrefix(path, "/orgs/"), "/users/")
-				if len(parts) == 2 {
-					resp, err = r.updateOrgUser(actx, parts[0], parts[1], req)
-				} else {
-					resp, err = response(400, map[string]string{"error": "invalid path"})
-				}
-			}
-		case method == "DELETE" && strings.Contains(path, "/users/") && strings.HasPrefix(path, "/orgs/"):
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				parts := strings.Split(strings.TrimPrefix(path, "/orgs/"), "/users/")
-				if len(parts) == 2 {
-					resp, err = r.deleteOrgUser(actx, parts[0], parts[1])
-				} else {
-					resp, err = response(400, map[string]string{"error": "invalid path"})
-				}
-			}
-		case method == "POST" && strings.HasPrefix(path, "/orgs/") && strings.HasSuffix(path, "/invitations"):
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				orgID := strings.TrimSuffix(strings.TrimPrefix(path, "/orgs/"), "/invitations")
-				resp, err = r.inviteUser(actx, orgID, req)
-			}
-		case method == "GET" && path == "/users/me":
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.getUserProfile(actx)
-			}
-		case method == "POST" && path == "/users/me/change-password":
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.changePassword(actx, req)
-			}
-		case method == "POST" && path == "/auth/accept-invitation":
-			resp, err = r.acceptInvitation(ctx, req)
-		case method == "POST" && path == "/auth/register":
-			resp, err = r.register(ctx, req)
-		case method == "POST" && path == "/auth/login":
-			resp, err = r.login(ctx, req)
-		case method == "POST" && path == "/auth/forgot-password":
-			resp, err = r.forgotPassword(ctx, req)
-		case method == "POST" && path == "/auth/reset-password":
-			resp, err = r.resetPassword(ctx, req)
-		case method == "POST" && path == "/patients/onboard":
-			if actx, deny, ok := r.require(ctx, req, permPatientsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.onboardPatient(actx, req)
-			}
-		case method == "GET" && path == "/patients":
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.listPatients(actx, req)
-			}
-		case method == "GET" && path == "/patients/search":
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.searchPatients(actx, req)
-			}
-		case method == "GET" && strings.HasSuffix(path, "/payments") && strings.HasPrefix(path, "/patients/"):
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				patientID := strings.TrimSuffix(strings.TrimPrefix(path, "/patients/"), "/payments")
-				resp, err = r.listPatientPayments(actx, patientID)
-			}
-		case method == "GET" && strings.HasSuffix(path, "/budgets") && strings.HasPrefix(path, "/patients/"):
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				patientID := strings.TrimSuffix(strings.TrimPrefix(path, "/patients/"), "/budgets")
-				resp, err = r.listPatientBudgets(actx, patientID)
-			}
-		case method == "POST" && strings.HasSuffix(path, "/budgets") && strings.HasPrefix(path, "/patients/"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				patientID := strings.TrimSuffix(strings.TrimPrefix(path, "/patients/"), "/budgets")
-				resp, err = r.createBudget(actx, patientID, req)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/patients/"):
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.getPatient(actx, strings.TrimPrefix(path, "/patients/"))
-			}
-		case method == "PUT" && strings.HasPrefix(path, "/patients/"):
-			if actx, deny, ok := r.require(ctx, req, permPatientsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/patients/")
-				resp, err = r.updatePatient(actx, id, req)
-			}
-		case method == "DELETE" && strings.HasPrefix(path, "/patients/"):
-			if actx, deny, ok := r.require(ctx, req, permPatientsDelete); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/patients/")
-				resp, err = r.deletePatient(actx, id)
-			}
-		case method == "POST" && path == "/appointments":
-			if actx, deny, ok := r.require(ctx, req, permAppointmentsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.createAppointment(actx, req)
-			}
-		case method == "GET" && path == "/appointments":
-			if actx, deny, ok := r.require(ctx, req, permAppointmentsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.listAppointments(actx, req)
-			}
-		case method == "POST" && strings.HasSuffix(path, "/upload-url") && strings.HasPrefix(path, "/appointments/"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimSuffix(strings.TrimPrefix(path, "/appointments/"), "/upload-url")
-				resp, err = r.getAppointmentUploadURL(actx, id, req)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/appointments/"):
-			if actx, deny, ok := r.require(ctx, req, permAppointmentsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/appointments/")
-				resp, err = r.getAppointment(actx, id)
-			}
-		case method == "POST" && strings.HasSuffix(path, "/confirm") && strings.HasPrefix(path, "/appointments/"):
-			if actx, deny, ok := r.require(ctx, req, permAppointmentsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimSuffix(strings.TrimPrefix(path, "/appointments/"), "/confirm")
-				resp, err = r.confirmAppointment(actx, id)
-			}
-		case method == "POST" && strings.HasSuffix(path, "/close-day") && strings.HasPrefix(path, "/appointments/"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				date := strings.TrimSuffix(strings.TrimPrefix(path, "/appointments/"), "/close-day")
-				resp, err = r.closeAppointmentDay(actx, date, req)
-			}
-		case method == "POST" && strings.HasSuffix(path, "/resend-confirmation") && strings.HasPrefix(path, "/appointments/"):
-			if actx, deny, ok := r.require(ctx, req, permAppointmentsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimSuffix(strings.TrimPrefix(path, "/appointments/"), "/resend-confirmation")
-				resp, err = r.resendAppointmentConfirmation(actx, id, req)
-			}
-		case method == "DELETE" && strings.HasPrefix(path, "/appointments/"):
-			if actx, deny, ok := r.require(ctx, req, permAppointmentsDelete); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/appointments/")
-				resp, err = r.deleteAppointment(actx, id)
-			}
-		case method == "PATCH" && strings.HasSuffix(path, "/payment") && strings.HasPrefix(path, "/appointments/"):
-			if actx, deny, ok := r.require(ctx, req, permAppointmentsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimSuffix(strings.TrimPrefix(path, "/appointments/"), "/payment")
-				resp, err = r.registerPayment(actx, id, req)
-			}
-		case method == "PUT" && strings.HasPrefix(path, "/appointments/"):
-			if actx, deny, ok := r.require(ctx, req, permAppointmentsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/appointments/")
-				resp, err = r.updateAppointment(actx, id, req)
-			}
-		case method == "POST" && path == "/consents":
-			if actx, deny, ok := r.require(ctx, req, permPatientsWrite); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.createConsent(actx, req)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/consents/verify/"):
-			token := strings.TrimPrefix(path, "/consents/verify/")
-			resp, err = r.acceptConsent(ctx, token)
-		// Public appointment confirm endpoint (no API key required — called from email link)
-		case method == "POST" && strings.HasPrefix(path, "/public/appointments/") && strings.HasSuffix(path, "/confirm"):
-			token := strings.TrimSuffix(strings.TrimPrefix(path, "/public/appointments/"), "/confirm")
-			resp, err = r.publicConfirmAppointment(ctx, token)
-		// Public consent accept endpoint (no API key required — called from email link)
-		case method == "POST" && strings.HasPrefix(path, "/public/consents/") && strings.HasSuffix(path, "/accept"):
-			token := strings.TrimSuffix(strings.TrimPrefix(path, "/public/consents/"), "/accept")
-			resp, err = r.publicAcceptConsent(ctx, token)
-		// Consent template endpoints (admin only)
-		case method == "GET" && path == "/consent-templates":
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.listConsentTemplates(actx, req)
-			}
-		case method == "POST" && path == "/consent-templates":
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.createConsentTemplate(actx, req)
-			}
-		case method == "PUT" && strings.HasPrefix(path, "/consent-templates/"):
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/consent-templates/")
-				resp, err = r.updateConsentTemplate(actx, id, req)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/consent-templates/"):
-			if actx, deny, ok := r.require(ctx, req, permUsersManage); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/consent-templates/")
-				resp, err = r.getConsentTemplate(actx, id)
-			}
-		case method == "POST" && strings.HasPrefix(path, "/odontograms"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.odontogram.CreateOdontogram(actx, req)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/odontograms/patient/"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				patientId := strings.TrimPrefix(path, "/odontograms/patient/")
-				if req.PathParameters == nil {
-					req.PathParameters = make(map[string]string)
-				}
-				req.PathParameters["patientId"] = patientId
-				resp, err = r.odontogram.GetOdontogramByPatient(actx, req)
-			}
-		case method == "PUT" && strings.HasPrefix(path, "/odontograms/"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/odontograms/")
-				if req.PathParameters == nil {
-					req.PathParameters = make(map[string]string)
-				}
-				if strings.HasSuffix(id, "/tooth-condition") {
-					req.PathParameters["odontogramId"] = strings.TrimSuffix(id, "/tooth-condition")
-					resp, err = r.odontogram.UpdateToothCondition(actx, req)
-				} else {
-					req.PathParameters["odontogramId"] = id
-					resp, err = r.odontogram.UpdateOdontogram(actx, req)
-				}
-			}
-		case method == "POST" && strings.Contains(path, "/treatment-plans"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.odontogram.CreateTreatmentPlan(actx, req)
-			}
-		case method == "GET" && strings.Contains(path, "/treatment-plans"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.odontogram.GetTreatmentPlan(actx, req)
-			}
-		case method == "PUT" && strings.Contains(path, "/treatment-plans"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.odontogram.UpdateTreatmentPlan(actx, req)
-			}
-		// Payment routes (Feature 1)
-		case method == "GET" && path == "/payments":
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.listPayments(actx, req)
-			}
-		case method == "POST" && path == "/payments":
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.createPayment(actx, req)
-			}
-		case method == "GET" && strings.HasPrefix(path, "/budgets/"):
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/budgets/")
-				resp, err = r.getBudget(actx, id)
-			}
-		case method == "PUT" && strings.HasPrefix(path, "/budgets/"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/budgets/")
-				resp, err = r.updateBudget(actx, id, req)
-			}
-		case method == "DELETE" && strings.HasPrefix(path, "/budgets/"):
-			if actx, deny, ok := r.require(ctx, req, permTreatmentsManage); !ok {
-				resp, err = deny, nil
-			} else {
-				id := strings.TrimPrefix(path, "/budgets/")
-				resp, err = r.deleteBudget(actx, id)
-			}
-		case method == "POST" && path == "/chat":
-			if actx, deny, ok := r.require(ctx, req, permPatientsView); !ok {
-				resp, err = deny, nil
-			} else {
-				resp, err = r.handleChat(actx, req)
-			}
-		default:
-			resp, err = response(404, map[string]string{"error": "endpoint_not_found", "message": "The requested endpoint was not found"})
+	} else if rt, params, allowed := lookupRoute(method, path); rt != nil {
+		if req.PathParameters == nil {
+			req.PathParameters = make(map[string]string, len(params))
 		}
+		for k, v := range params {
+			req.PathParameters[k] = v
+		}
+		if rt.Permission == permPublic {
+			resp, err = rt.handler(r, ctx, req, params)
+		} else if actx, deny, ok := r.require(ctx, req, rt.Permission); !ok {
+			resp, err = deny, nil
+		} else {
+			resp, err = rt.handler(r, actx, req, params)
+		}
+	} else if len(allowed) > 0 {
+		resp, err = response(405, map[string]string{"error": "method_not_allowed", "message": "Method " + method + " is not allowed for this endpoint"})
+		resp.Headers["Allow"] = strings.Join(append(allowed, "OPTIONS"), ", ")
+	} else {
+		resp, err = response(404, map[string]string{"error": "endpoint_not_found", "message": "The requested endpoint was not found"})
 	}
 
 	// Log response
@@ -597,7 +167,9 @@ func (r *Router) Handle(ctx context.Context, req events.APIGatewayV2HTTPRequest)
 		return events.APIGatewayV2HTTPResponse{StatusCode: 500, Body: `{"message": "Internal server error"}`}, nil
 	}
 
-	log.Printf("[RESPONSE] %s - Status: %d, Duration: %v, Body: %s", endpoint, resp.StatusCode, duration, resp.Body)
+	// Sin el body: lleva tokens de sesión, secretos y códigos MFA y datos de
+	// pacientes, y este log termina en CloudWatch.
+	log.Printf("[RESPONSE] %s - Status: %d, Duration: %v", endpoint, resp.StatusCode, duration)
 	return resp, nil
 }
 
@@ -608,7 +180,7 @@ func (r *Router) onboardPatient(ctx context.Context, req events.APIGatewayV2HTTP
 	}
 	patient, err := r.patients.Onboard(ctx, in)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(201, patient)
 }
@@ -630,7 +202,21 @@ func (r *Router) login(ctx context.Context, req events.APIGatewayV2HTTPRequest)
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
 		return response(400, map[string]string{"error": "invalid_json"})
 	}
+	in.UserAgent, in.IP = req.RequestContext.HTTP.UserAgent, req.RequestContext.HTTP.SourceIP
 	out, err := r.auth.Login(ctx, in)
+	if err != nil {
+		return authError(err, 401)
+	}
+	return response(200, out)
+}
+
+func (r *Router) refreshSession(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.RefreshInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	in.UserAgent, in.IP = req.RequestContext.HTTP.UserAgent, req.RequestContext.HTTP.SourceIP
+	out, err := r.auth.Refresh(ctx, in)
 	if err != nil {
 		return response(401, map[string]string{"error": err.Error()})
 	}
@@ -683,6 +269,14 @@ func (r *Router) deleteOrgUser(ctx context.Context, orgID, userID string) (event
 	return response(200, map[string]string{"deleted": userID})
 }
 
+func (r *Router) revokeOrgUserSessions(ctx context.Context, orgID, userID string) (events.APIGatewayV2HTTPResponse, error) {
+	n, err := r.auth.RevokeOrgUserSessions(ctx, orgID, userID)
+	if err != nil {
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]int{"revoked": n})
+}
+
 func (r *Router) changePassword(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	var in service.ChangePasswordInput
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
@@ -696,6 +290,23 @@ func (r *Router) changePassword(ctx context.Context, req events.APIGatewayV2HTTP
 	return response(200, map[string]string{"ok": "password changed"})
 }
 
+func (r *Router) listMySessions(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	sessions, err := r.auth.ListSessions(ctx, auth.User.ID, auth.Session.ID)
+	if err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]any{"items": sessions, "total": len(sessions)})
+}
+
+func (r *Router) revokeMySession(ctx context.Context, sessionID string) (events.APIGatewayV2HTTPResponse, error) {
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	if err := r.auth.RevokeSession(ctx, auth.User.ID, sessionID); err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]string{"revoked": sessionID})
+}
+
 func (r *Router) inviteUser(ctx context.Context, orgID string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	var in service.InviteUserInput
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
@@ -718,6 +329,7 @@ func (r *Router) acceptInvitation(ctx context.Context, req events.APIGatewayV2HT
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
 		return response(400, map[string]string{"error": "invalid_json"})
 	}
+	in.UserAgent, in.IP = req.RequestContext.HTTP.UserAgent, req.RequestContext.HTTP.SourceIP
 	out, err := r.auth.AcceptInvitation(ctx, in)
 	if err != nil {
 		return response(400, map[string]string{"error": err.Error()})
@@ -832,9 +444,10 @@ func (r *Router) forgotPassword(ctx context.Context, req events.APIGatewayV2HTTP
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
 		return response(400, map[string]string{"error": "invalid_json"})
 	}
+	in.IP = req.RequestContext.HTTP.SourceIP
 	out, err := r.auth.ForgotPassword(ctx, in)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return authError(err, 400)
 	}
 	return response(200, out)
 }
@@ -844,8 +457,9 @@ func (r *Router) resetPassword(ctx context.Context, req events.APIGatewayV2HTTPR
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
 		return response(400, map[string]string{"error": "invalid_json"})
 	}
+	in.IP = req.RequestContext.HTTP.SourceIP
 	if err := r.auth.ResetPassword(ctx, in); err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return authError(err, 400)
 	}
 	return response(200, map[string]string{"status": "password_reset"})
 }
@@ -853,16 +467,16 @@ func (r *Router) resetPassword(ctx context.Context, req events.APIGatewayV2HTTPR
 func (r *Router) getPatient(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
 	patient, err := r.patients.GetByID(ctx, id)
 	if err != nil {
-		return response(404, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
 	}
-	return response(200, patient)
+	return versioned(200, patient, patient.Version)
 }
 
 func (r *Router) listPatients(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	doctorID := req.QueryStringParameters["doctorId"]
 	patients, err := r.patients.ListByDoctor(ctx, doctorID)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	if patients == nil {
 		patients = []domain.Patient{}
@@ -878,7 +492,7 @@ func (r *Router) searchPatients(ctx context.Context, req events.APIGatewayV2HTTP
 	}
 	patients, err := r.patients.Search(ctx, doctorID, q)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	if patients == nil {
 		patients = []domain.Patient{}
@@ -891,16 +505,19 @@ func (r *Router) updatePatient(ctx context.Context, id string, req events.APIGat
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
 		return response(400, map[string]string{"error": "invalid_json"})
 	}
+	if err := applyIfMatch(req, &in.Version); err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
 	patient, err := r.patients.Update(ctx, id, in)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
-	return response(200, patient)
+	return versioned(200, patient, patient.Version)
 }
 
 func (r *Router) deletePatient(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
 	if err := r.patients.Delete(ctx, id); err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(200, map[string]string{"status": "deleted"})
 }
@@ -910,9 +527,16 @@ func (r *Router) createAppointment(ctx context.Context, req events.APIGatewayV2H
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
 		return response(400, map[string]string{"error": "invalid_json"})
 	}
+	if in.Recurrence != nil {
+		series, err := r.appointments.CreateSeries(ctx, in)
+		if err != nil {
+			return response(errorStatus(err, 400), map[string]any{"error": err.Error(), "conflicts": series.Conflicts})
+		}
+		return response(201, series)
+	}
 	appointment, err := r.appointments.Create(ctx, in)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(201, appointment)
 }
@@ -929,7 +553,7 @@ func (r *Router) listAppointments(ctx context.Context, req events.APIGatewayV2HT
 		items, err = r.appointments.ListByDoctorAndDate(ctx, doctorID, date)
 	}
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	if items == nil {
 		items = []domain.Appointment{}
@@ -949,14 +573,14 @@ func (r *Router) listAppointments(ctx context.Context, req events.APIGatewayV2HT
 func (r *Router) getAppointment(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
 	item, err := r.appointments.GetByID(ctx, id)
 	if err != nil {
-		return response(404, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
 	}
-	return response(200, item)
+	return versioned(200, item, item.Version)
 }
 
 func (r *Router) deleteAppointment(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
 	if err := r.appointments.Delete(ctx, id); err != nil {
-		return response(404, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
 	}
 	return response(200, map[string]string{"status": "deleted"})
 }
@@ -966,17 +590,69 @@ func (r *Router) updateAppointment(ctx context.Context, id string, req events.AP
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
 		return response(400, map[string]string{"error": "invalid_json"})
 	}
+	if err := applyIfMatch(req, &in.Version); err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	if scope := req.QueryStringParameters["scope"]; scope != "" && scope != service.SeriesScopeThis {
+		series, err := r.appointments.UpdateSeries(ctx, id, scope, in)
+		if err != nil {
+			return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
+		}
+		return response(200, series)
+	}
 	appt, err := r.appointments.UpdateAppointment(ctx, id, in)
 	if err != nil {
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
+	}
+	return versioned(200, appt, appt.Version)
+}
+
+// POST /appointments/{id}/cancel {"scope": "this|following|all"}
+func (r *Router) cancelAppointment(ctx context.Context, id string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in struct {
+		Scope string `json:"scope"`
+	}
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+			return response(400, map[string]string{"error": "invalid_json"})
+		}
+	}
+	series, err := r.appointments.CancelSeries(ctx, id, in.Scope)
+	if err != nil {
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
+	}
+	return response(200, series)
+}
+
+func (r *Router) listAppointmentSeries(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
+	items, err := r.appointments.ListSeries(ctx, id)
+	if err != nil {
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]any{"items": items})
+}
+
+func (r *Router) rescheduleAppointment(ctx context.Context, id string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.RescheduleAppointmentInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	if err := applyIfMatch(req, &in.Version); err != nil {
 		return response(400, map[string]string{"error": err.Error()})
 	}
-	return response(200, appt)
+	auth, _ := ctx.Value(ctxAuthKey).(service.Authenticated)
+	in.RescheduledBy = auth.User.ID
+	appt, err := r.appointments.Reschedule(ctx, id, in)
+	if err != nil {
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
+	}
+	return versioned(200, appt, appt.Version)
 }
 
 func (r *Router) confirmAppointment(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
 	item, err := r.appointments.Confirm(ctx, id)
 	if err != nil {
-		return response(404, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
 	}
 	return response(200, item)
 }
@@ -993,7 +669,7 @@ func (r *Router) closeAppointmentDay(ctx context.Context, id string, req events.
 	}
 	item, err := r.appointments.CloseDayForAppointment(ctx, id, in.EvolutionNotes, in.PaymentAmount, in.PaymentMethod, in.TreatmentPlan)
 	if err != nil {
-		return response(404, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
 	}
 	return response(200, item)
 }
@@ -1011,7 +687,7 @@ func (r *Router) sendAppointmentReminder(ctx context.Context, id string, req eve
 		in.Channel = "email"
 	}
 	if err := r.appointments.Send24hReminder(ctx, id, in.Channel); err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(200, map[string]string{"status": "reminder_sent"})
 }
@@ -1141,7 +817,7 @@ func (r *Router) listPayments(ctx context.Context, req events.APIGatewayV2HTTPRe
 	}
 	payments, err := r.payments.ListPaymentsByOrg(ctx, 500)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(200, map[string]interface{}{"items": payments, "total": len(payments)})
 }
@@ -1156,7 +832,7 @@ func (r *Router) createPayment(ctx context.Context, req events.APIGatewayV2HTTPR
 	}
 	p, err := r.payments.CreatePayment(ctx, in)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(201, p)
 }
@@ -1167,7 +843,7 @@ func (r *Router) listPatientPayments(ctx context.Context, patientID string) (eve
 	}
 	payments, err := r.payments.ListPaymentsByPatient(ctx, patientID)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(200, map[string]interface{}{"items": payments, "total": len(payments)})
 }
@@ -1182,7 +858,7 @@ func (r *Router) listPatientBudgets(ctx context.Context, patientID string) (even
 	}
 	budgets, err := r.budgets.ListBudgetsByPatient(ctx, patientID)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(200, map[string]interface{}{"items": budgets, "total": len(budgets)})
 }
@@ -1198,7 +874,7 @@ func (r *Router) createBudget(ctx context.Context, patientID string, req events.
 	in.PatientID = patientID
 	b, err := r.budgets.CreateBudget(ctx, in)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(201, b)
 }
@@ -1209,9 +885,9 @@ func (r *Router) getBudget(ctx context.Context, id string) (events.APIGatewayV2H
 	}
 	b, err := r.budgets.GetBudget(ctx, id)
 	if err != nil {
-		return response(404, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
 	}
-	return response(200, b)
+	return versioned(200, b, b.Version)
 }
 
 func (r *Router) updateBudget(ctx context.Context, id string, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
@@ -1222,11 +898,14 @@ func (r *Router) updateBudget(ctx context.Context, id string, req events.APIGate
 	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
 		return response(400, map[string]string{"error": "invalid_json"})
 	}
+	if err := applyIfMatch(req, &in.Version); err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
 	b, err := r.budgets.UpdateBudget(ctx, id, in)
 	if err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
-	return response(200, b)
+	return versioned(200, b, b.Version)
 }
 
 func (r *Router) deleteBudget(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
@@ -1234,7 +913,7 @@ func (r *Router) deleteBudget(ctx context.Context, id string) (events.APIGateway
 		return response(503, map[string]string{"error": "budget service not available"})
 	}
 	if err := r.budgets.DeleteBudget(ctx, id); err != nil {
-		return response(400, map[string]string{"error": err.Error()})
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
 	}
 	return response(200, map[string]string{"status": "deleted"})
 }
@@ -1248,10 +927,11 @@ func response(code int, payload any) (events.APIGatewayV2HTTPResponse, error) {
 		StatusCode: code,
 		Body:       string(body),
 		Headers: map[string]string{
-			"Content-Type":                 "application/json",
-			"Access-Control-Allow-Origin":  "*",
-			"Access-Control-Allow-Headers": "content-type,authorization",
-			"Access-Control-Allow-Methods": "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+			"Content-Type":                  "application/json",
+			"Access-Control-Allow-Origin":   "*",
+			"Access-Control-Allow-Headers":  "content-type,authorization,if-match",
+			"Access-Control-Allow-Methods":  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+			"Access-Control-Expose-Headers": "ETag,Retry-After",
 		},
 	}, nil
 }
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// permPublic marks a route that does not require a session.
+const permPublic permission = ""
+
+type routeHandler func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error)
+
+// Adapters from the handler signatures used in this package to routeHandler,
+// so the table can reference methods directly: withParam("id", (*Router).getPatient).
+func withReq(h func(*Router, context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error)) routeHandler {
+	return func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, _ map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+		return h(r, ctx, req)
+	}
+}
+
+func withCtx(h func(*Router, context.Context) (events.APIGatewayV2HTTPResponse, error)) routeHandler {
+	return func(r *Router, ctx context.Context, _ events.APIGatewayV2HTTPRequest, _ map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+		return h(r, ctx)
+	}
+}
+
+func withParam(name string, h func(*Router, context.Context, string) (events.APIGatewayV2HTTPResponse, error)) routeHandler {
+	return func(r *Router, ctx context.Context, _ events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+		return h(r, ctx, p[name])
+	}
+}
+
+func withParamReq(name string, h func(*Router, context.Context, string, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error)) routeHandler {
+	return func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+		return h(r, ctx, p[name], req)
+	}
+}
+
+func withParams(a, b string, h func(*Router, context.Context, string, string) (events.APIGatewayV2HTTPResponse, error)) routeHandler {
+	return func(r *Router, ctx context.Context, _ events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+		return h(r, ctx, p[a], p[b])
+	}
+}
+
+func withParamsReq(a, b string, h func(*Router, context.Context, string, string, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error)) routeHandler {
+	return func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+		return h(r, ctx, p[a], p[b], req)
+	}
+}
+
+// withOdontogram routes to the OdontogramHandler, which reads its path
+// parameters from req.PathParameters.
+func withOdontogram(h func(*OdontogramHandler, context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error)) routeHandler {
+	return func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, _ map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+		return h(r.odontogram, ctx, req)
+	}
+}
+
+// route is one entry of the routing table. Pattern segments wrapped in braces
+// ("{id}") are path parameters; they are exposed both in the handler map and
+// in req.PathParameters.
+type route struct {
+	Method     string
+	Pattern    string
+	Permission permission
+	handler    routeHandler
+	segments   []string
+}
+
+// RouteInfo describes a registered route (see Routes).
+type RouteInfo struct {
+	Method     string `json:"method"`
+	Pattern    string `json:"pattern"`
+	Permission string `json:"permission,omitempty"`
+	Public     bool   `json:"public"`
+}
+
+var routeTable []route
+
+func init() {
+	routeTable = buildRoutes()
+	for i := range routeTable {
+		routeTable[i].segments = splitPath(routeTable[i].Pattern)
+	}
+}
+
+func buildRoutes() []route {
+	return []route{
+		// Public
+		{"GET", "/health", permPublic, func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+			return response(200, map[string]string{"status": "ok", "message": "Clinical API is running", "version": "1.0.1", "updated": "2026-02-18"})
+		}, nil},
+		{"POST", "/platform/bootstrap", permPublic, withReq((*Router).platformBootstrap), nil},
+		{"POST", "/auth/accept-invitation", permPublic, withReq((*Router).acceptInvitation), nil},
+		{"POST", "/auth/register", permPublic, withReq((*Router).register), nil},
+		{"POST", "/auth/login", permPublic, withReq((*Router).login), nil},
+		{"POST", "/auth/refresh", permPublic, withReq((*Router).refreshSession), nil},
+		{"POST", "/auth/mfa/verify", permPublic, withReq((*Router).verifyMFA), nil},
+		{"POST", "/auth/mfa/enroll", permPublic, withReq((*Router).enrollMFAForLogin), nil},
+		{"POST", "/auth/forgot-password", permPublic, withReq((*Router).forgotPassword), nil},
+		{"POST", "/auth/reset-password", permPublic, withReq((*Router).resetPassword), nil},
+		{"POST", "/auth/unlock", permPublic, withReq((*Router).unlockAccount), nil},
+		{"GET", "/consents/verify/{token}", permPublic, withParam("token", (*Router).acceptConsent), nil},
+		// Called from email links (no API key / session)
+		{"POST", "/public/appointments/{token}/confirm", permPublic, withParam("token", (*Router).publicConfirmAppointment), nil},
+		{"GET", "/public/appointments/{token}", permPublic, withParam("token", (*Router).publicGetAppointment), nil},
+		{"POST", "/public/appointments/{token}/cancel", permPublic, withParamReq("token", (*Router).publicCancelAppointment), nil},
+		{"GET", "/public/appointments/{token}/slots", permPublic, withParamReq("token", (*Router).publicAppointmentSlots), nil},
+		{"POST", "/public/appointments/{token}/reschedule", permPublic, withParamReq("token", (*Router).publicRescheduleAppointment), nil},
+		{"GET", "/public/waitlist-offers/{token}", permPublic, withParam("token", (*Router).publicGetWaitlistOffer), nil},
+		{"POST", "/public/waitlist-offers/{token}/accept", permPublic, withParam("token", (*Router).publicAcceptWaitlistOffer), nil},
+		{"GET", "/public/calendar/{token}", permPublic, withParam("token", (*Router).publicCalendarFeed), nil},
+		{"POST", "/public/consents/{token}/accept", permPublic, withParam("token", (*Router).publicAcceptConsent), nil},
+
+		// Platform
+		{"GET", "/platform/routes", permPlatformManage, func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+			return response(200, map[string]interface{}{"items": Routes()})
+		}, nil},
+		{"POST", "/platform/orgs", permPlatformManage, withReq((*Router).createOrganization), nil},
+		{"GET", "/platform/orgs", permPlatformManage, withCtx((*Router).listOrganizations), nil},
+		{"GET", "/platform/stats", permPlatformManage, withCtx((*Router).getPlatformStats), nil},
+		{"GET", "/platform/orgs/{orgId}", permPlatformManage, withParam("orgId", (*Router).getOrganization), nil},
+		{"PUT", "/platform/orgs/{orgId}", permPlatformManage, withParamReq("orgId", (*Router).updateOrganization), nil},
+		{"DELETE", "/platform/orgs/{orgId}", permPlatformManage, withParam("orgId", (*Router).deleteOrganization), nil},
+		{"POST", "/platform/orgs/{orgId}/admins", permPlatformManage, withParamReq("orgId", (*Router).createOrgAdmin), nil},
+
+		// Org administration
+		{"GET", "/org/stats", permUsersManage, func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+			auth := ctx.Value(ctxAuthKey).(service.Authenticated)
+			return r.getOrgStats(ctx, auth.User.OrgID)
+		}, nil},
+		{"GET", "/org/no-show-policy", permUsersManage, withCtx((*Router).getNoShowPolicy), nil},
+		{"PUT", "/org/no-show-policy", permUsersManage, withReq((*Router).updateNoShowPolicy), nil},
+		{"GET", "/org/mfa-policy", permUsersManage, withCtx((*Router).getMFAPolicy), nil},
+		{"PUT", "/org/mfa-policy", permUsersManage, withReq((*Router).updateMFAPolicy), nil},
+		{"GET", "/org/access-policy", permUsersManage, withCtx((*Router).getAccessPolicy), nil},
+		{"PUT", "/org/access-policy", permUsersManage, withReq((*Router).updateAccessPolicy), nil},
+		{"GET", "/org/permissions", permUsersManage, func(r *Router, ctx context.Context, req events.APIGatewayV2HTTPRequest, p map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+			return r.listPermissions()
+		}, nil},
+		{"GET", "/org/roles", permUsersManage, withCtx((*Router).listOrgRoles), nil},
+		{"PUT", "/org/roles/{role}", permUsersManage, withParamReq("role", (*Router).saveOrgRole), nil},
+		{"DELETE", "/org/roles/{role}", permUsersManage, withParam("role", (*Router).deleteOrgRole), nil},
+		{"GET", "/orgs/{orgId}/stats", permUsersManage, withParam("orgId", (*Router).getOrgStats), nil},
+		{"GET", "/orgs/{orgId}/users", permUsersManage, withParam("orgId", (*Router).listOrgUsers), nil},
+		{"POST", "/orgs/{orgId}/users", permUsersManage, withParamReq("orgId", (*Router).createOrgUser), nil},
+		{"PATCH", "/orgs/{orgId}/users/{userId}", permUsersManage, withParamsReq("orgId", "userId", (*Router).updateOrgUser), nil},
+		{"DELETE", "/orgs/{orgId}/users/{userId}", permUsersManage, withParams("orgId", "userId", (*Router).deleteOrgUser), nil},
+		{"DELETE", "/orgs/{orgId}/users/{userId}/sessions", permUsersManage, withParams("orgId", "userId", (*Router).revokeOrgUserSessions), nil},
+		{"DELETE", "/orgs/{orgId}/users/{userId}/mfa", permUsersManage, withParams("orgId", "userId", (*Router).resetOrgUserMFA), nil},
+		{"POST", "/orgs/{orgId}/invitations", permUsersManage, withParamReq("orgId", (*Router).inviteUser), nil},
+
+		// Current user
+		{"GET", "/users/me", permAuthenticated, withCtx((*Router).getUserProfile), nil},
+		{"POST", "/users/me/change-password", permAuthenticated, withReq((*Router).changePassword), nil},
+		{"GET", "/users/me/sessions", permAuthenticated, withCtx((*Router).listMySessions), nil},
+		{"DELETE", "/users/me/sessions/{sessionId}", permAuthenticated, withParam("sessionId", (*Router).revokeMySession), nil},
+		{"POST", "/users/me/mfa/enroll", permAuthenticated, withCtx((*Router).enrollMyMFA), nil},
+		{"POST", "/users/me/mfa/confirm", permAuthenticated, withReq((*Router).confirmMyMFA), nil},
+		{"POST", "/users/me/mfa/recovery-codes", permAuthenticated, withReq((*Router).regenerateMyRecoveryCodes), nil},
+		{"POST", "/users/me/mfa/disable", permAuthenticated, withReq((*Router).disableMyMFA), nil},
+
+		// Patients
+		{"POST", "/patients/onboard", permPatientsWrite, withReq((*Router).onboardPatient), nil},
+		{"GET", "/patients", permPatientsView, withReq((*Router).listPatients), nil},
+		{"GET", "/patients/search", permPatientsView, withReq((*Router).searchPatients), nil},
+		{"GET", "/patients/{id}", permPatientsView, withParam("id", (*Router).getPatient), nil},
+		{"PUT", "/patients/{id}", permPatientsWrite, withParamReq("id", (*Router).updatePatient), nil},
+		{"DELETE", "/patients/{id}", permPatientsDelete, withParam("id", (*Router).deletePatient), nil},
+		{"GET", "/patients/{id}/payments", permFinancesView, withParam("id", (*Router).listPatientPayments), nil},
+		{"GET", "/patients/{id}/budgets", permFinancesView, withParam("id", (*Router).listPatientBudgets), nil},
+		{"POST", "/patients/{id}/budgets", permFinancesManage, withParamReq("id", (*Router).createBudget), nil},
+		{"GET", "/patients/{id}/booking-policy", permAppointmentsWrite, withParamReq("id", (*Router).getBookingPolicy), nil},
+
+		// Appointments
+		{"POST", "/appointments", permAppointmentsWrite, withReq((*Router).createAppointment), nil},
+		{"GET", "/appointments", permAppointmentsWrite, withReq((*Router).listAppointments), nil},
+		{"GET", "/appointments/{id}", permAppointmentsWrite, withParam("id", (*Router).getAppointment), nil},
+		{"PUT", "/appointments/{id}", permAppointmentsWrite, withParamReq("id", (*Router).updateAppointment), nil},
+		{"DELETE", "/appointments/{id}", permAppointmentsDelete, withParam("id", (*Router).deleteAppointment), nil},
+		{"POST", "/appointments/{id}/upload-url", permTreatmentsManage, withParamReq("id", (*Router).getAppointmentUploadURL), nil},
+		{"POST", "/appointments/{id}/confirm", permAppointmentsWrite, withParam("id", (*Router).confirmAppointment), nil},
+		{"POST", "/appointments/{id}/cancel", permAppointmentsWrite, withParamReq("id", (*Router).cancelAppointment), nil},
+		{"GET", "/appointments/{id}/series", permAppointmentsWrite, withParam("id", (*Router).listAppointmentSeries), nil},
+		{"POST", "/appointments/{id}/reschedule", permAppointmentsWrite, withParamReq("id", (*Router).rescheduleAppointment), nil},
+		{"POST", "/appointments/{id}/close-day", permTreatmentsManage, withParamReq("id", (*Router).closeAppointmentDay), nil},
+		{"POST", "/appointments/{id}/resend-confirmation", permAppointmentsWrite, withParamReq("id", (*Router).resendAppointmentConfirmation), nil},
+		{"PATCH", "/appointments/{id}/payment", permAppointmentsWrite, withParamReq("id", (*Router).registerPayment), nil},
+
+		// Doctor availability
+		{"GET", "/availability", permAppointmentsWrite, withReq((*Router).getAvailability), nil},
+		{"GET", "/doctors/{doctorId}/availability", permAppointmentsWrite, withParam("doctorId", (*Router).getDoctorAvailability), nil},
+		{"PUT", "/doctors/{doctorId}/availability", permTreatmentsManage, withParamReq("doctorId", (*Router).updateDoctorAvailability), nil},
+		{"GET", "/doctors/{doctorId}/calendar-feed", permTreatmentsManage, withParamReq("doctorId", (*Router).getCalendarFeed), nil},
+		{"POST", "/doctors/{doctorId}/calendar-feed", permTreatmentsManage, withParamReq("doctorId", (*Router).rotateCalendarFeed), nil},
+		{"DELETE", "/doctors/{doctorId}/calendar-feed", permTreatmentsManage, withParam("doctorId", (*Router).disableCalendarFeed), nil},
+
+		// Waitlist
+		{"GET", "/waitlist", permAppointmentsWrite, withReq((*Router).listWaitlist), nil},
+		{"POST", "/waitlist", permAppointmentsWrite, withReq((*Router).addToWaitlist), nil},
+		{"DELETE", "/waitlist/{id}", permAppointmentsWrite, withParam("id", (*Router).removeFromWaitlist), nil},
+
+		// Appointment types (catálogo de la org)
+		{"GET", "/appointment-types", permAppointmentsWrite, withCtx((*Router).listAppointmentTypes), nil},
+		{"POST", "/appointment-types", permUsersManage, withReq((*Router).createAppointmentType), nil},
+		{"PUT", "/appointment-types/{id}", permUsersManage, withParamReq("id", (*Router).updateAppointmentType), nil},
+		{"DELETE", "/appointment-types/{id}", permUsersManage, withParam("id", (*Router).deleteAppointmentType), nil},
+
+		// Resources (sillones, salas, equipos)
+		{"GET", "/resources", permAppointmentsWrite, withCtx((*Router).listResources), nil},
+		{"POST", "/resources", permUsersManage, withReq((*Router).createResource), nil},
+		{"GET", "/resources/schedule", permAppointmentsWrite, withReq((*Router).getResourceSchedule), nil},
+		{"PUT", "/resources/{id}", permUsersManage, withParamReq("id", (*Router).updateResource), nil},
+		{"DELETE", "/resources/{id}", permUsersManage, withParam("id", (*Router).deleteResource), nil},
+
+		// Consents
+		{"POST", "/consents", permPatientsWrite, withReq((*Router).createConsent), nil},
+		{"GET", "/consent-templates", permConsentTemplatesManage, withReq((*Router).listConsentTemplates), nil},
+		{"POST", "/consent-templates", permConsentTemplatesManage, withReq((*Router).createConsentTemplate), nil},
+		{"GET", "/consent-templates/{id}", permConsentTemplatesManage, withParam("id", (*Router).getConsentTemplate), nil},
+		{"PUT", "/consent-templates/{id}", permConsentTemplatesManage, withParamReq("id", (*Router).updateConsentTemplate), nil},
+
+		// Odontograms & treatment plans (handlers read req.PathParameters)
+		{"POST", "/odontograms", permTreatmentsManage, withOdontogram((*OdontogramHandler).CreateOdontogram), nil},
+		{"GET", "/odontograms/patient/{patientId}", permTreatmentsManage, withOdontogram((*OdontogramHandler).GetOdontogramByPatient), nil},
+		{"PUT", "/odontograms/{odontogramId}", permTreatmentsManage, withOdontogram((*OdontogramHandler).UpdateOdontogram), nil},
+		{"PUT", "/odontograms/{odontogramId}/tooth-condition", permTreatmentsManage, withOdontogram((*OdontogramHandler).UpdateToothCondition), nil},
+		{"POST", "/treatment-plans", permTreatmentsManage, withOdontogram((*OdontogramHandler).CreateTreatmentPlan), nil},
+		{"GET", "/treatment-plans/patient/{patientId}", permTreatmentsManage, withOdontogram((*OdontogramHandler).GetPatientTreatmentPlans), nil},
+		{"GET", "/treatment-plans/{planId}", permTreatmentsManage, withOdontogram((*OdontogramHandler).GetTreatmentPlan), nil},
+		{"PUT", "/treatment-plans/{planId}", permTreatmentsManage, withOdontogram((*OdontogramHandler).UpdateTreatmentPlan), nil},
+
+		// Payments & budgets
+		{"GET", "/payments", permFinancesManage, withReq((*Router).listPayments), nil},
+		{"POST", "/payments", permFinancesManage, withReq((*Router).createPayment), nil},
+		{"GET", "/budgets/{id}", permFinancesView, withParam("id", (*Router).getBudget), nil},
+		{"PUT", "/budgets/{id}", permFinancesManage, withParamReq("id", (*Router).updateBudget), nil},
+		{"DELETE", "/budgets/{id}", permFinancesManage, withParam("id", (*Router).deleteBudget), nil},
+
+		// Docco
+		{"POST", "/chat", permChatUse, withReq((*Router).handleChat), nil},
+	}
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// match returns the path params and the number of literal segments matched
+// (used to prefer /patients/search over /patients/{id}).
+func (rt route) match(segments []string) (map[string]string, int, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, 0, false
+	}
+	params := map[string]string{}
+	literals := 0
+	for i, seg := range rt.segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if segments[i] == "" {
+				return nil, 0, false
+			}
+			params[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return params, literals, true
+}
+
+// lookupRoute finds the most specific route for method+path. When the path
+// exists only for other methods, allowed lists them (405).
+func lookupRoute(method, path string) (rt *route, params map[string]string, allowed []string) {
+	segments := splitPath(path)
+	best := -1
+	seen := map[string]bool{}
+	for i := range routeTable {
+		p, literals, ok := routeTable[i].match(segments)
+		if !ok {
+			continue
+		}
+		if routeTable[i].Method != method {
+			if !seen[routeTable[i].Method] {
+				seen[routeTable[i].Method] = true
+				allowed = append(allowed, routeTable[i].Method)
+			}
+			continue
+		}
+		if literals > best {
+			best, rt, params = literals, &routeTable[i], p
+		}
+	}
+	sort.Strings(allowed)
+	return rt, params, allowed
+}
+
+// Routes lists every registered route in declaration order.
+func Routes() []RouteInfo {
+	out := make([]RouteInfo, len(routeTable))
+	for i, rt := range routeTable {
+		out[i] = RouteInfo{Method: rt.Method, Pattern: rt.Pattern, Permission: string(rt.Permission), Public: rt.Permission == permPublic}
+	}
+	return out
+}
+
+func isPublicEndpoint(method, path string) bool {
+	rt, _, _ := lookupRoute(method, path)
+	return rt != nil && rt.Permission == permPublic
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// authError answers a failed public auth call: attempts rejected by the
+// brute-force protection get 429 with Retry-After (seconds); anything else
+// gets code.
+func authError(err error, code int) (events.APIGatewayV2HTTPResponse, error) {
+	var throttled *service.ThrottledError
+	if !errors.As(err, &throttled) {
+		return response(code, map[string]string{"error": err.Error()})
+	}
+	seconds := max(int(math.Ceil(throttled.RetryAfter.Seconds())), 1)
+	resp, rerr := response(429, map[string]any{"error": err.Error(), "retryAfter": seconds, "locked": throttled.Locked})
+	if rerr == nil {
+		resp.Headers["Retry-After"] = strconv.Itoa(seconds)
+	}
+	return resp, rerr
+}
+
+// POST /auth/unlock — enlace del email de cuenta bloqueada.
+func (r *Router) unlockAccount(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.UnlockAccountInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	in.IP = req.RequestContext.HTTP.SourceIP
+	if err := r.auth.UnlockAccount(ctx, in); err != nil {
+		return response(400, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]string{"status": "unlocked"})
+}
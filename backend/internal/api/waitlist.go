@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"clinical-backend/internal/service"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GET /waitlist?doctorId=
+func (r *Router) listWaitlist(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	items, err := r.appointments.ListWaitlist(ctx, req.QueryStringParameters["doctorId"])
+	if err != nil {
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]any{"items": items})
+}
+
+func (r *Router) addToWaitlist(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var in service.WaitlistInput
+	if err := json.Unmarshal([]byte(req.Body), &in); err != nil {
+		return response(400, map[string]string{"error": "invalid_json"})
+	}
+	entry, err := r.appointments.AddToWaitlist(ctx, in)
+	if err != nil {
+		return response(errorStatus(err, 400), map[string]string{"error": err.Error()})
+	}
+	return response(201, entry)
+}
+
+func (r *Router) removeFromWaitlist(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
+	if err := r.appointments.RemoveFromWaitlist(ctx, id); err != nil {
+		return response(errorStatus(err, 404), map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]string{"status": "deleted"})
+}
+
+// GET /public/waitlist-offers/{token}
+func (r *Router) publicGetWaitlistOffer(ctx context.Context, token string) (events.APIGatewayV2HTTPResponse, error) {
+	view, err := r.appointments.GetWaitlistOffer(ctx, token)
+	if err != nil {
+		return response(404, map[string]string{"error": err.Error()})
+	}
+	return response(200, view)
+}
+
+// POST /public/waitlist-offers/{token}/accept — el primero que acepta se queda
+// con el horario; los demás reciben 409.
+func (r *Router) publicAcceptWaitlistOffer(ctx context.Context, token string) (events.APIGatewayV2HTTPResponse, error) {
+	appt, err := r.appointments.AcceptWaitlistOffer(ctx, token)
+	if err != nil {
+		return response(409, map[string]string{"error": err.Error()})
+	}
+	return response(200, map[string]interface{}{
+		"status":        appt.Status,
+		"appointmentId": appt.ID,
+		"startAt":       appt.StartAt,
+		"endAt":         appt.EndAt,
+	})
+}
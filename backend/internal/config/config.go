@@ -1,6 +1,10 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+)
 
 type Config struct {
 	Environment          string
@@ -13,6 +17,9 @@ type Config struct {
 	TreatmentPlanTable   string
 	PaymentTable         string
 	BudgetTable          string
+	WaitlistTable        string
+	ResourceTable        string
+	AppointmentTypeTable string
 	PlatformAdminEmail   string
 	BootstrapSecret      string
 	FrontendBaseURL      string
@@ -24,7 +31,45 @@ type Config struct {
 	IsLambda             bool
 	BedrockModelID       string
 	DoccoEnabled         bool
-	ClinicTZ             string
+	ClinicTZ             string // zona por defecto de las orgs sin Timezone propio
+	// Portal público del paciente (cancelar / reprogramar con el enlace del email).
+	PublicMinNoticeHours int
+	ClinicDays           string // días de atención por defecto, ej. "1-5" (0 = domingo)
+	ClinicHours          string // franjas por defecto, ej. "08:00-12:00,14:00-18:00"
+	// Lista de espera: vigencia de cada oferta y a cuántos pacientes se ofrece un hueco.
+	WaitlistOfferTTLMinutes int
+	WaitlistMaxOffers       int
+	// PostgreSQL: con PostgresDSN tiene prioridad sobre DynamoDB e in-memory.
+	PostgresDSN    string
+	PostgresDriver string // nombre del driver database/sql registrado (pgx por defecto)
+	// DynamoDB Local (desarrollo y tests): endpoint alternativo al de AWS.
+	DynamoDBEndpoint string
+	// Archivo local de datos (instalaciones on-prem de una sola clínica); con
+	// DataFile tiene prioridad sobre DynamoDB e in-memory, no sobre PostgreSQL.
+	DataFile string
+	// Servidor HTTP propio (LOCAL_HTTP=true), para correr fuera de Lambda.
+	LocalHTTP               bool
+	HTTPPort                string
+	HTTPReadTimeoutSeconds  int
+	HTTPWriteTimeoutSeconds int // incluye el tiempo del handler (Docco puede tardar)
+	HTTPIdleTimeoutSeconds  int
+	HTTPShutdownSeconds     int    // espera de las peticiones en curso tras SIGTERM
+	HTTPMaxBodyBytes        int64  // 0 = límite de Lambda (6 MB)
+	HTTPCORSOrigins         string // orígenes permitidos separados por coma ("" = todos)
+	HTTPTLSCertFile         string // con HTTPTLSKeyFile sirve HTTPS
+	HTTPTLSKeyFile          string
+	// Aislamiento estricto por organización: sin org en el contexto los
+	// repositorios fallan en lugar de usar la org "default".
+	StrictTenancy bool
+	// Sesiones: vida del access token y del refresh token (se renueva al usarlo).
+	SessionAccessTTLMinutes int
+	SessionRefreshTTLDays   int
+	// Fuerza bruta: fallos de login antes del bloqueo, duración del bloqueo
+	// y pedidos de forgot-password por email y hora.
+	LoginMaxFailures        int
+	LoginMaxFailuresPerIP   int
+	LoginLockoutMinutes     int
+	PasswordResetMaxPerHour int
 }
 
 func Load() Config {
@@ -50,6 +95,9 @@ func Load() Config {
 		TreatmentPlanTable:   getEnv("TREATMENT_PLAN_TABLE", "clinical-treatment-plans"),
 		PaymentTable:         getEnv("PAYMENT_TABLE", "clinical-payments"),
 		BudgetTable:          getEnv("BUDGET_TABLE", "clinical-budgets"),
+		WaitlistTable:        getEnv("WAITLIST_TABLE", "clinical-waitlist"),
+		ResourceTable:        getEnv("RESOURCE_TABLE", "clinical-resources"),
+		AppointmentTypeTable: getEnv("APPOINTMENT_TYPE_TABLE", "clinical-appointment-types"),
 		PlatformAdminEmail:   getEnv("PLATFORM_ADMIN_EMAIL", ""),
 		BootstrapSecret:      getEnv("BOOTSTRAP_SECRET", ""),
 		FrontendBaseURL:      getEnv("FRONTEND_BASE_URL", "https://localhost:5173"),
@@ -62,6 +110,40 @@ func Load() Config {
 		BedrockModelID:       getEnv("BEDROCK_MODEL_ID", "amazon.nova-lite-v1:0"),
 		DoccoEnabled:         getEnv("DOCCO_ENABLED", "true") == "true",
 		ClinicTZ:             getEnv("CLINIC_TZ", "America/Caracas"),
+		PublicMinNoticeHours: getEnvInt("PUBLIC_MIN_NOTICE_HOURS", 24),
+		ClinicDays:           getEnv("CLINIC_DAYS", "1-5"),
+		ClinicHours:          getEnv("CLINIC_HOURS", "08:00-18:00"),
+
+		WaitlistOfferTTLMinutes: getEnvInt("WAITLIST_OFFER_TTL_MINUTES", 120),
+		WaitlistMaxOffers:       getEnvInt("WAITLIST_MAX_OFFERS", 3),
+
+		PostgresDSN:    getEnv("POSTGRES_DSN", ""),
+		PostgresDriver: getEnv("POSTGRES_DRIVER", "pgx"),
+
+		DynamoDBEndpoint: getEnv("DYNAMODB_ENDPOINT", ""),
+
+		DataFile: getEnv("DATA_FILE", ""),
+
+		LocalHTTP:               getEnv("LOCAL_HTTP", "false") == "true",
+		HTTPPort:                getEnv("LOCAL_HTTP_PORT", "3000"),
+		HTTPReadTimeoutSeconds:  getEnvInt("HTTP_READ_TIMEOUT_SECONDS", 15),
+		HTTPWriteTimeoutSeconds: getEnvInt("HTTP_WRITE_TIMEOUT_SECONDS", 60),
+		HTTPIdleTimeoutSeconds:  getEnvInt("HTTP_IDLE_TIMEOUT_SECONDS", 120),
+		HTTPShutdownSeconds:     getEnvInt("HTTP_SHUTDOWN_TIMEOUT_SECONDS", 30),
+		HTTPMaxBodyBytes:        int64(getEnvInt("HTTP_MAX_BODY_BYTES", 0)),
+		HTTPCORSOrigins:         getEnv("HTTP_CORS_ORIGINS", ""),
+		HTTPTLSCertFile:         getEnv("HTTP_TLS_CERT_FILE", ""),
+		HTTPTLSKeyFile:          getEnv("HTTP_TLS_KEY_FILE", ""),
+
+		StrictTenancy: getEnv("STRICT_TENANCY", "false") == "true",
+
+		SessionAccessTTLMinutes: getEnvInt("SESSION_ACCESS_TTL_MINUTES", 15),
+		SessionRefreshTTLDays:   getEnvInt("SESSION_REFRESH_TTL_DAYS", 30),
+
+		LoginMaxFailures:        getEnvInt("LOGIN_MAX_FAILURES", 10),
+		LoginMaxFailuresPerIP:   getEnvInt("LOGIN_MAX_FAILURES_PER_IP", 50),
+		LoginLockoutMinutes:     getEnvInt("LOGIN_LOCKOUT_MINUTES", 15),
+		PasswordResetMaxPerHour: getEnvInt("PASSWORD_RESET_MAX_PER_HOUR", 3),
 	}
 }
 
@@ -73,9 +155,28 @@ func (c Config) ShouldUseDynamoDB() bool {
 	return !c.UseInMemory
 }
 
+// ShouldUsePostgres reports whether POSTGRES_DSN selects the PostgreSQL store.
+func (c Config) ShouldUsePostgres() bool {
+	return strings.TrimSpace(c.PostgresDSN) != ""
+}
+
+// ShouldUseFileStore reports whether DATA_FILE selects the file-backed store.
+func (c Config) ShouldUseFileStore() bool {
+	return strings.TrimSpace(c.DataFile) != ""
+}
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
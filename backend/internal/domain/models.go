@@ -24,6 +24,11 @@ type Patient struct {
 	ImageKeys          []string            `json:"imageKeys"`
 	CreatedAt          time.Time           `json:"createdAt"`
 	UpdatedAt          *time.Time          `json:"updatedAt,omitempty"`
+	// Version se incrementa en cada escritura (control de concurrencia optimista).
+	Version int `json:"version"`
+	// Inasistencias acumuladas (las mantiene AppointmentService al pasar citas a no_show).
+	NoShowCount  int        `json:"noShowCount,omitempty"`
+	LastNoShowAt *time.Time `json:"lastNoShowAt,omitempty"`
 	// Extended fields (Feature 3)
 	DocumentType            string  `json:"documentType,omitempty"`
 	SecondName              string  `json:"secondName,omitempty"`
@@ -78,8 +83,57 @@ type Appointment struct {
 	ReminderSentAt      *time.Time `json:"reminderSentAt,omitempty"`
 	PatientConfirmedAt  *time.Time `json:"patientConfirmedAt,omitempty"`
 	DoctorDailyClosedAt *time.Time `json:"doctorDailyClosedAt,omitempty"`
+	// Auditoría de cancelación: CancelledBy es el ID del usuario o "patient" (portal público).
+	CancelledAt  *time.Time `json:"cancelledAt,omitempty"`
+	CancelledBy  string     `json:"cancelledBy,omitempty"`
+	CancelReason string     `json:"cancelReason,omitempty"`
+	// SeriesID agrupa las citas creadas por una regla de recurrencia; SeriesIndex es la posición (1..n).
+	SeriesID    string `json:"seriesId,omitempty"`
+	SeriesIndex int    `json:"seriesIndex,omitempty"`
+	// RescheduleHistory guarda los horarios anteriores (más antiguo primero).
+	RescheduleHistory []RescheduleEntry `json:"rescheduleHistory,omitempty"`
+	// NoShowAt se fija al pasar a no_show (job de fin de día o manual).
+	NoShowAt *time.Time `json:"noShowAt,omitempty"`
+	// DepositAmount es el depósito exigido por la política de inasistencias al agendar.
+	DepositAmount float64 `json:"depositAmount,omitempty"`
+	// ResourceIDs son los recursos de la org (sillón, sala, equipo) que ocupa la cita.
+	ResourceIDs []string `json:"resourceIds,omitempty"`
+	// AppointmentTypeID es el tipo del catálogo de la org (limpieza, extracción…).
+	AppointmentTypeID string `json:"appointmentTypeId,omitempty"`
+	// Version se incrementa en cada escritura (control de concurrencia optimista).
+	Version int `json:"version"`
 	// ConsentSummary se rellena en listados (no se persiste en DB).
 	ConsentSummary *ConsentSummary `json:"consentSummary,omitempty"`
+	// BookingNotice se devuelve al agendar si aplica la política de inasistencias (no se persiste).
+	BookingNotice *BookingNotice `json:"bookingNotice,omitempty"`
+}
+
+// RescheduleEntry records one move of an appointment: the previous and new
+// slot, who moved it and why.
+type RescheduleEntry struct {
+	PreviousStartAt time.Time `json:"previousStartAt"`
+	PreviousEndAt   time.Time `json:"previousEndAt"`
+	NewStartAt      time.Time `json:"newStartAt"`
+	NewEndAt        time.Time `json:"newEndAt"`
+	RescheduledBy   string    `json:"rescheduledBy,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+	RescheduledAt   time.Time `json:"rescheduledAt"`
+}
+
+// NoShowPolicy is the org rule applied when booking patients with repeated
+// no-shows. A zero threshold disables that action.
+type NoShowPolicy struct {
+	WarnAfter     int     `json:"warnAfter"`               // avisar a partir de N inasistencias
+	DepositAfter  int     `json:"depositAfter"`            // exigir depósito a partir de N
+	DepositAmount float64 `json:"depositAmount,omitempty"` // monto del depósito
+}
+
+// BookingNotice tells the front desk how the no-show policy applies to a patient.
+type BookingNotice struct {
+	NoShowCount   int     `json:"noShowCount"`
+	Action        string  `json:"action"` // none, warn, deposit
+	DepositAmount float64 `json:"depositAmount,omitempty"`
+	Message       string  `json:"message,omitempty"`
 }
 
 // ConsentSummary is attached to appointment list responses (no persist).
@@ -252,6 +306,7 @@ type ToothTreatment struct {
 // Odontograma completo de un paciente
 type Odontogram struct {
 	ID               string           `json:"id"`
+	OrgID            string           `json:"orgId,omitempty"`
 	PatientID        string           `json:"patientId"`
 	DoctorID         string           `json:"doctorId"`
 	Teeth            []ToothInfo      `json:"teeth"`                  // Estado actual de todos los dientes
@@ -261,11 +316,13 @@ type Odontogram struct {
 	NextExamDate     *time.Time       `json:"nextExamDate,omitempty"` // Próxima revisión
 	CreatedAt        time.Time        `json:"createdAt"`
 	UpdatedAt        time.Time        `json:"updatedAt"`
+	Version          int              `json:"version"`
 }
 
 // Plan de tratamiento odontológico
 type TreatmentPlan struct {
 	ID            string              `json:"id"`
+	OrgID         string              `json:"orgId,omitempty"`
 	PatientID     string              `json:"patientId"`
 	DoctorID      string              `json:"doctorId"`
 	OdontogramID  string              `json:"odontogramId"`
@@ -281,6 +338,7 @@ type TreatmentPlan struct {
 	Notes         string              `json:"notes"`
 	CreatedAt     time.Time           `json:"createdAt"`
 	UpdatedAt     time.Time           `json:"updatedAt"`
+	Version       int                 `json:"version"`
 }
 
 type PlannedTreatment struct {
@@ -358,6 +416,7 @@ type Budget struct {
 	ValidUntil  *time.Time   `json:"validUntil,omitempty"`
 	CreatedAt   time.Time    `json:"createdAt"`
 	UpdatedAt   time.Time    `json:"updatedAt"`
+	Version     int          `json:"version"`
 }
 
 // BudgetItem is a line item in a budget.
@@ -370,3 +429,89 @@ type BudgetItem struct {
 	Total       float64 `json:"total"`
 	Status      string  `json:"status"` // pending, paid
 }
+
+// DoctorAvailability is a doctor's bookable schedule: recurring weekly hours
+// (in the org timezone) minus time-off blocks (lunch, holidays, vacations).
+// An empty Weekly list means the doctor has no fixed schedule.
+type DoctorAvailability struct {
+	Weekly    []WeeklyHours  `json:"weekly"`
+	TimeOff   []TimeOffBlock `json:"timeOff,omitempty"`
+	UpdatedAt *time.Time     `json:"updatedAt,omitempty"`
+}
+
+// WeeklyHours is one working window, e.g. Monday 09:00–13:00. A day may have
+// several windows (split shift around lunch).
+type WeeklyHours struct {
+	Weekday time.Weekday `json:"weekday"` // 0 = domingo
+	Start   string       `json:"start"`   // "HH:MM"
+	End     string       `json:"end"`     // "HH:MM"
+}
+
+// TimeOffBlock blocks [StartAt, EndAt) regardless of the weekly schedule.
+type TimeOffBlock struct {
+	ID      string    `json:"id"`
+	StartAt time.Time `json:"startAt"`
+	EndAt   time.Time `json:"endAt"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// WaitlistEntry is a patient waiting for an earlier slot with a doctor. When an
+// appointment of that doctor is cancelled or deleted the freed slot is offered
+// to matching entries; the first one to accept books it.
+type WaitlistEntry struct {
+	ID            string         `json:"id"`
+	OrgID         string         `json:"orgId"`
+	PatientID     string         `json:"patientId"`
+	DoctorID      string         `json:"doctorId"`
+	PreferredDays []time.Weekday `json:"preferredDays,omitempty"` // vacío = cualquier día
+	PreferredFrom string         `json:"preferredFrom,omitempty"` // "HH:MM"
+	PreferredTo   string         `json:"preferredTo,omitempty"`   // "HH:MM"
+	Urgency       string         `json:"urgency"`                 // low, normal, high
+	Notes         string         `json:"notes,omitempty"`
+	Status        string         `json:"status"` // waiting, offered, booked
+	Offer         *WaitlistOffer `json:"offer,omitempty"`
+	AppointmentID string         `json:"appointmentId,omitempty"` // cita creada al aceptar
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+}
+
+// WaitlistOffer is a freed slot offered to a waitlist entry.
+type WaitlistOffer struct {
+	Token              string    `json:"-"` // enlace público de aceptación
+	StartAt            time.Time `json:"startAt"`
+	EndAt              time.Time `json:"endAt"`
+	FreedAppointmentID string    `json:"freedAppointmentId"`
+	OfferedAt          time.Time `json:"offeredAt"`
+	ExpiresAt          time.Time `json:"expiresAt"`
+}
+
+// Resource is something of the org that appointments reserve besides the
+// doctor: a chair (sillón), a room or a piece of equipment such as the X-ray
+// unit. Two open appointments cannot hold the same resource at once.
+type Resource struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"orgId"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"` // chair, room, equipment
+	Notes     string    `json:"notes,omitempty"`
+	Active    bool      `json:"active"` // inactivo: no se puede asignar a nuevas citas
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// AppointmentType is an entry of the org's catalog of appointment types
+// (limpieza, extracción, sesión de endodoncia, evaluación…). It provides the
+// defaults of a new appointment and the consents the patient must sign.
+type AppointmentType struct {
+	ID              string  `json:"id"`
+	OrgID           string  `json:"orgId"`
+	Name            string  `json:"name"`
+	DurationMinutes int     `json:"durationMinutes"`
+	Color           string  `json:"color,omitempty"` // "#RRGGBB" para la agenda
+	DefaultPrice    float64 `json:"defaultPrice"`
+	// ConsentTemplateIDs son las plantillas que se envían con las citas de este tipo.
+	ConsentTemplateIDs []string  `json:"consentTemplateIds"`
+	Active             bool      `json:"active"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,37 @@
+package ical
+
+import (
+	"time"
+
+	"clinical-backend/internal/domain"
+)
+
+// AppointmentEvent maps an appointment to a VEVENT. The UID is stable, so a
+// reschedule, confirmation or cancellation updates the same calendar entry;
+// SEQUENCE grows with each of those changes.
+func AppointmentEvent(appt domain.Appointment, loc *time.Location, summary string) Event {
+	if loc == nil {
+		loc = time.UTC
+	}
+	end := appt.EndAt
+	if end.IsZero() && appt.DurationMinutes > 0 {
+		end = appt.StartAt.Add(time.Duration(appt.DurationMinutes) * time.Minute)
+	}
+	ev := Event{
+		UID:      appt.ID + "@clinisense",
+		Summary:  summary,
+		Start:    appt.StartAt.In(loc),
+		End:      end.In(loc),
+		Status:   StatusConfirmed,
+		Sequence: 2*len(appt.RescheduleHistory) + 1,
+	}
+	switch appt.Status {
+	case "cancelled":
+		ev.Status = StatusCancelled
+		ev.Sequence++
+	case "", "scheduled":
+		ev.Status = StatusTentative // pendiente de confirmación del paciente
+		ev.Sequence--
+	}
+	return ev
+}
@@ -0,0 +1,226 @@
+// Package ical writes iCalendar (RFC 5545) documents: the doctors' agenda
+// feed and the invites attached to the appointment emails.
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const prodID = "-//CliniSense//Agenda//ES"
+
+// Event statuses (RFC 5545 §3.8.1.11).
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Event is one VEVENT. Start/End are written in their own location, so pass
+// them already converted to the org timezone.
+type Event struct {
+	UID            string
+	Summary        string
+	Description    string
+	Location       string
+	URL            string
+	Start          time.Time
+	End            time.Time
+	Status         string
+	Sequence       int
+	OrganizerName  string
+	OrganizerEmail string
+}
+
+// Calendar is a VCALENDAR. Method is the iTIP method (PUBLISH for email
+// invites); leave it empty for subscription feeds.
+type Calendar struct {
+	Name     string
+	Method   string
+	Location *time.Location
+	Events   []Event
+	// Now is used for DTSTAMP; zero means time.Now.
+	Now time.Time
+}
+
+// Bytes renders the calendar with CRLF line endings and folded lines.
+func (c Calendar) Bytes() []byte {
+	now := c.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	loc := c.Location
+	if loc == nil || loc.String() == "Local" || loc.String() == "UTC" {
+		loc = time.UTC // sin TZID de Olson: horas en UTC
+	}
+
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + prodID)
+	w.line("CALSCALE:GREGORIAN")
+	if c.Method != "" {
+		w.line("METHOD:" + c.Method)
+	}
+	if c.Name != "" {
+		w.line("X-WR-CALNAME:" + escapeText(c.Name))
+	}
+	if loc != time.UTC {
+		w.line("X-WR-TIMEZONE:" + loc.String())
+		if from, to, ok := eventRange(c.Events); ok {
+			writeTimezone(w, loc, from, to)
+		}
+	}
+	for _, ev := range c.Events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + ev.UID)
+		w.line("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+		w.line(dateTime("DTSTART", ev.Start.In(loc), loc))
+		w.line(dateTime("DTEND", ev.End.In(loc), loc))
+		if ev.Summary != "" {
+			w.line("SUMMARY:" + escapeText(ev.Summary))
+		}
+		if ev.Description != "" {
+			w.line("DESCRIPTION:" + escapeText(ev.Description))
+		}
+		if ev.Location != "" {
+			w.line("LOCATION:" + escapeText(ev.Location))
+		}
+		if ev.URL != "" {
+			w.line("URL:" + ev.URL)
+		}
+		if ev.OrganizerEmail != "" {
+			organizer := "ORGANIZER"
+			if ev.OrganizerName != "" {
+				organizer += fmt.Sprintf(";CN=%q", strings.ReplaceAll(ev.OrganizerName, `"`, "'"))
+			}
+			w.line(organizer + ":mailto:" + ev.OrganizerEmail)
+		}
+		if ev.Status != "" {
+			w.line("STATUS:" + ev.Status)
+		}
+		w.line(fmt.Sprintf("SEQUENCE:%d", ev.Sequence))
+		w.line("END:VEVENT")
+	}
+	w.line("END:VCALENDAR")
+	return []byte(w.b.String())
+}
+
+func dateTime(name string, t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return name + ":" + t.UTC().Format("20060102T150405Z")
+	}
+	return name + ";TZID=" + loc.String() + ":" + t.Format("20060102T150405")
+}
+
+func eventRange(events []Event) (from, to time.Time, ok bool) {
+	for i, ev := range events {
+		if i == 0 || ev.Start.Before(from) {
+			from = ev.Start
+		}
+		if i == 0 || ev.End.After(to) {
+			to = ev.End
+		}
+	}
+	return from, to, len(events) > 0
+}
+
+// writeTimezone emits a VTIMEZONE covering [from, to]: the offset in force at
+// from plus every transition in between, found by scanning the Go tz data.
+// Explicit onsets (no RRULE) keep it valid for any zone.
+func writeTimezone(w *writer, loc *time.Location, from, to time.Time) {
+	type onset struct {
+		at         time.Time
+		offsetFrom int
+		offsetTo   int
+	}
+	start := from.In(loc).AddDate(0, 0, -1)
+	_, prevOffset := start.Zone()
+	onsets := []onset{{at: start, offsetFrom: prevOffset, offsetTo: prevOffset}}
+	for day := start; day.Before(to); {
+		next := day.Add(24 * time.Hour)
+		if _, off := next.Zone(); off != prevOffset {
+			// Acotar la transición al segundo.
+			lo, hi := day, next
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if _, o := mid.Zone(); o == prevOffset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			onsets = append(onsets, onset{at: hi, offsetFrom: prevOffset, offsetTo: off})
+			prevOffset = off
+		}
+		day = next
+	}
+
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+	for _, o := range onsets {
+		at := o.at.In(loc)
+		kind := "STANDARD"
+		if at.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		name, _ := at.Zone()
+		w.line("BEGIN:" + kind)
+		// DTSTART de un cambio se expresa en la hora local previa al cambio.
+		w.line("DTSTART:" + at.UTC().Add(time.Duration(o.offsetFrom)*time.Second).Format("20060102T150405"))
+		w.line("TZOFFSETFROM:" + formatOffset(o.offsetFrom))
+		w.line("TZOFFSETTO:" + formatOffset(o.offsetTo))
+		if name != "" {
+			w.line("TZNAME:" + escapeText(name))
+		}
+		w.line("END:" + kind)
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// formatOffset renders seconds east of UTC as ±hhmm[ss].
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	out := fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if s := seconds % 60; s != 0 {
+		out += fmt.Sprintf("%02d", s)
+	}
+	return out
+}
+
+// escapeText escapes a TEXT value (RFC 5545 §3.3.11).
+func escapeText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+type writer struct{ b strings.Builder }
+
+// line writes a content line folded at 75 octets without splitting UTF-8
+// sequences (RFC 5545 §3.1).
+func (w *writer) line(s string) {
+	const limit = 75
+	width := limit
+	for len(s) > width {
+		cut := width
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.b.WriteString(s[:cut])
+		w.b.WriteString("\r\n ")
+		s = s[cut:]
+		width = limit - 1 // la continuación empieza con un espacio
+	}
+	w.b.WriteString(s)
+	w.b.WriteString("\r\n")
+}
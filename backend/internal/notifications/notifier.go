@@ -12,6 +12,7 @@ import (
 
 	"clinical-backend/internal/config"
 	"clinical-backend/internal/domain"
+	"clinical-backend/internal/ical"
 	"clinical-backend/internal/store"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -32,11 +33,16 @@ type Notifier interface {
 	SendDoctorDailySummary(ctx context.Context, doctorID, channel, message string) error
 	SendInvitation(ctx context.Context, toEmail, inviteURL, role, tempPassword string) error
 	SendWelcome(ctx context.Context, toEmail, name, role, password, loginURL string) error
-	SendAppointmentEvent(ctx context.Context, toEmail, patientName, eventType string, startAt, endAt time.Time) error
+	SendAppointmentEvent(ctx context.Context, toEmail, patientName, eventType string, appt domain.Appointment) error
+	SendAppointmentRescheduled(ctx context.Context, toEmail, patientName, confirmToken string, prevStart, prevEnd, startAt, endAt time.Time) error
+	SendDoctorAppointmentChange(ctx context.Context, toEmail, doctorName, patientName, eventType string, prevStart, startAt time.Time, reason string) error
 	SendAppointmentCreated(ctx context.Context, toEmail, patientName string, appt domain.Appointment, consentLinks []ConsentLink) error
 	SendAppointmentCreatedSMS(ctx context.Context, toPhone, patientName string, appt domain.Appointment) error
+	SendWaitlistOffer(ctx context.Context, toEmail, patientName, offerToken string, startAt, endAt, expiresAt time.Time) error
+	SendWaitlistOfferSMS(ctx context.Context, toPhone, patientName, offerToken string, startAt, expiresAt time.Time) error
 	SendOrgCreated(ctx context.Context, toEmail, orgName, adminName string) error
 	SendTreatmentPlanSummary(ctx context.Context, toEmail, patientName, treatmentPlan string, consultDate time.Time) error
+	SendAccountLocked(ctx context.Context, toEmail, unlockURL string, lockedUntil time.Time) error
 }
 
 // ConsentLink is a consent title and its public accept token for the email body.
@@ -330,6 +336,7 @@ func (r *Router) SendAppointmentCreated(ctx context.Context, toEmail, patientNam
 				Html: &sestypes.Content{Data: aws.String(buildHTMLEmail(subject, htmlBody, ""))},
 				Text: &sestypes.Content{Data: aws.String(body)},
 			},
+			Attachments: appointmentInvite(appt, sender, "Confirma tu cita: "+confirmURL),
 		}},
 	})
 	if err != nil {
@@ -397,7 +404,10 @@ func (r *Router) SendDoctorDailySummary(_ context.Context, doctorID, channel, me
 	return nil
 }
 
-func (r *Router) SendAppointmentEvent(ctx context.Context, toEmail, patientName, eventType string, startAt, endAt time.Time) error {
+// SendAppointmentEvent notifies a status change. appt times must already be in
+// the org timezone; they are used for the body and the .ics attachment.
+func (r *Router) SendAppointmentEvent(ctx context.Context, toEmail, patientName, eventType string, appt domain.Appointment) error {
+	startAt, endAt := appt.StartAt, appt.EndAt
 	titles := map[string]string{
 		"created":   "Cita agendada",
 		"moved":     "Cita reprogramada",
@@ -538,6 +548,10 @@ func (r *Router) SendAppointmentEvent(ctx context.Context, toEmail, patientName,
 	if sender == "" {
 		sender = "no-reply@clinisense.aski-tech.net"
 	}
+	var attachments []sestypes.Attachment
+	if eventType != "completed" { // la consulta ya pasó: no hay nada que agendar
+		attachments = appointmentInvite(appt, sender, "")
+	}
 	_, err := r.ses.SendEmail(ctx, &sesv2.SendEmailInput{
 		FromEmailAddress: aws.String(sender),
 		Destination:      &sestypes.Destination{ToAddresses: []string{toEmail}},
@@ -547,6 +561,7 @@ func (r *Router) SendAppointmentEvent(ctx context.Context, toEmail, patientName,
 				Html: &sestypes.Content{Data: aws.String(buildHTMLEmail(subject, htmlBody, ""))},
 				Text: &sestypes.Content{Data: aws.String(body)},
 			},
+			Attachments: attachments,
 		}},
 	})
 	if err != nil {
@@ -557,6 +572,260 @@ func (r *Router) SendAppointmentEvent(ctx context.Context, toEmail, patientName,
 	return nil
 }
 
+// appointmentInvite builds the .ics attached to appointment emails. appt times
+// must be in the org timezone: their location becomes the event TZID.
+func appointmentInvite(appt domain.Appointment, sender, description string) []sestypes.Attachment {
+	loc := appt.StartAt.Location()
+	ev := ical.AppointmentEvent(appt, loc, "Cita médica — CliniSense")
+	ev.Description = description
+	ev.OrganizerName = "CliniSense"
+	ev.OrganizerEmail = sender
+	cal := ical.Calendar{Method: "PUBLISH", Location: loc, Events: []ical.Event{ev}}
+	return []sestypes.Attachment{{
+		FileName:           aws.String("cita.ics"),
+		RawContent:         cal.Bytes(),
+		ContentType:        aws.String("text/calendar; charset=UTF-8; method=PUBLISH"),
+		ContentDisposition: sestypes.AttachmentContentDispositionAttachment,
+	}}
+}
+
+// SendAppointmentRescheduled notifies the patient that the appointment was
+// moved, showing the previous and the new slot plus the new confirmation link.
+func (r *Router) SendAppointmentRescheduled(ctx context.Context, toEmail, patientName, confirmToken string, prevStart, prevEnd, startAt, endAt time.Time) error {
+	frontendBase := os.Getenv("FRONTEND_BASE_URL")
+	if frontendBase == "" {
+		frontendBase = "https://clinisense.aski-tech.net"
+	}
+	confirmURL := fmt.Sprintf("%s/confirm-appointment?token=%s", frontendBase, confirmToken)
+	subject := "CliniSense — Cita reprogramada"
+
+	prevStr := fmt.Sprintf("%s de %s a %s", prevStart.Format("02/01/2006"), prevStart.Format("15:04"), prevEnd.Format("15:04"))
+	newStr := fmt.Sprintf("%s de %s a %s", startAt.Format("02/01/2006"), startAt.Format("15:04"), endAt.Format("15:04"))
+
+	body := fmt.Sprintf(
+		"Hola %s,\n\n"+
+			"Tu cita ha sido REPROGRAMADA.\n\n"+
+			"Antes: %s\n"+
+			"Ahora: %s\n\n"+
+			"Por favor confirma el nuevo horario aquí:\n\n"+
+			"   %s\n\n"+
+			"Si tienes dudas, contáctanos.\n\n"+
+			"CliniSense",
+		patientName, prevStr, newStr, confirmURL,
+	)
+	htmlBody := fmt.Sprintf(`
+<p style="margin:0 0 6px;font-size:16px;font-weight:700;color:#1e293b;">Hola %s,</p>
+%s
+%s
+%s
+%s
+<p style="margin:16px 0 0;font-size:13px;color:#94a3b8;">
+  Si no puedes hacer clic en el botón, copia y pega este enlace:<br>
+  <a href="%s" style="color:#0ea5e9;word-break:break-all;font-size:12px;">%s</a>
+</p>`,
+		html.EscapeString(patientName),
+		htmlStatusBadge("REPROGRAMADA", "#fef3c7", "#d97706"),
+		htmlInfoBox(
+			fmt.Sprintf("<strong>Antes:</strong> <s>%s</s>", prevStr),
+			fmt.Sprintf("<strong>Nuevo horario:</strong> %s", newStr),
+		),
+		htmlDivider(),
+		htmlCTAButton("Confirmar nuevo horario", confirmURL),
+		html.EscapeString(confirmURL), html.EscapeString(confirmURL),
+	)
+
+	log.Printf("[notify:appointment-moved] to=%s prev=%s new=%s", toEmail, prevStart, startAt)
+	if !r.sendEmail || r.ses == nil {
+		return nil
+	}
+	sender := r.cfg.SESSenderEmail
+	if sender == "" {
+		sender = os.Getenv("SES_SENDER_EMAIL")
+	}
+	if sender == "" {
+		sender = "no-reply@clinisense.aski-tech.net"
+	}
+	_, err := r.ses.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(sender),
+		Destination:      &sestypes.Destination{ToAddresses: []string{toEmail}},
+		Content: &sestypes.EmailContent{Simple: &sestypes.Message{
+			Subject: &sestypes.Content{Data: aws.String(subject)},
+			Body: &sestypes.Body{
+				Html: &sestypes.Content{Data: aws.String(buildHTMLEmail(subject, htmlBody, ""))},
+				Text: &sestypes.Content{Data: aws.String(body)},
+			},
+		}},
+	})
+	if err != nil {
+		log.Printf("[notify:appointment-moved] ses send failed: %v", err)
+	}
+	return err
+}
+
+// SendDoctorAppointmentChange tells the doctor that a patient cancelled
+// ("cancelled") or moved ("moved") an appointment from the public portal.
+// startAt is ignored for cancellations.
+func (r *Router) SendDoctorAppointmentChange(ctx context.Context, toEmail, doctorName, patientName, eventType string, prevStart, startAt time.Time, reason string) error {
+	var subject, line string
+	prevStr := fmt.Sprintf("%s a las %s", prevStart.Format("02/01/2006"), prevStart.Format("15:04"))
+	switch eventType {
+	case "cancelled":
+		subject = "CliniSense — Un paciente canceló su cita"
+		line = fmt.Sprintf("%s canceló su cita del %s.", patientName, prevStr)
+	default:
+		subject = "CliniSense — Un paciente reprogramó su cita"
+		line = fmt.Sprintf("%s movió su cita del %s al %s a las %s.", patientName, prevStr, startAt.Format("02/01/2006"), startAt.Format("15:04"))
+	}
+	body := fmt.Sprintf("Hola %s,\n\n%s\n", doctorName, line)
+	infoLines := []string{html.EscapeString(line)}
+	if strings.TrimSpace(reason) != "" {
+		body += fmt.Sprintf("Motivo: %s\n", reason)
+		infoLines = append(infoLines, fmt.Sprintf("<strong>Motivo:</strong> %s", html.EscapeString(reason)))
+	}
+	body += "\nCliniSense"
+	htmlBody := fmt.Sprintf(`
+<p style="margin:0 0 6px;font-size:16px;font-weight:700;color:#1e293b;">Hola %s,</p>
+%s
+%s`,
+		html.EscapeString(doctorName),
+		htmlInfoBox(infoLines...),
+		htmlDivider(),
+	)
+
+	log.Printf("[notify:doctor-appointment] to=%s event=%s prev=%s", toEmail, eventType, prevStart)
+	if !r.sendEmail || r.ses == nil {
+		return nil
+	}
+	sender := r.cfg.SESSenderEmail
+	if sender == "" {
+		sender = os.Getenv("SES_SENDER_EMAIL")
+	}
+	if sender == "" {
+		sender = "no-reply@clinisense.aski-tech.net"
+	}
+	_, err := r.ses.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(sender),
+		Destination:      &sestypes.Destination{ToAddresses: []string{toEmail}},
+		Content: &sestypes.EmailContent{Simple: &sestypes.Message{
+			Subject: &sestypes.Content{Data: aws.String(subject)},
+			Body: &sestypes.Body{
+				Html: &sestypes.Content{Data: aws.String(buildHTMLEmail(subject, htmlBody, ""))},
+				Text: &sestypes.Content{Data: aws.String(body)},
+			},
+		}},
+	})
+	if err != nil {
+		log.Printf("[notify:doctor-appointment] ses send failed: %v", err)
+	}
+	return err
+}
+
+// waitlistOfferURL is the public page where the patient accepts a freed slot.
+func waitlistOfferURL(offerToken string) string {
+	frontendBase := os.Getenv("FRONTEND_BASE_URL")
+	if frontendBase == "" {
+		frontendBase = "https://clinisense.aski-tech.net"
+	}
+	return fmt.Sprintf("%s/waitlist-offer?token=%s", frontendBase, offerToken)
+}
+
+// SendWaitlistOffer offers a freed slot to a waitlisted patient. The slot goes
+// to the first patient who accepts before expiresAt.
+func (r *Router) SendWaitlistOffer(ctx context.Context, toEmail, patientName, offerToken string, startAt, endAt, expiresAt time.Time) error {
+	acceptURL := waitlistOfferURL(offerToken)
+	subject := "CliniSense — Se liberó un horario para tu cita"
+	slotStr := fmt.Sprintf("%s de %s a %s", startAt.Format("02/01/2006"), startAt.Format("15:04"), endAt.Format("15:04"))
+	expiresStr := fmt.Sprintf("%s a las %s", expiresAt.Format("02/01/2006"), expiresAt.Format("15:04"))
+
+	body := fmt.Sprintf(
+		"Hola %s,\n\n"+
+			"Estás en nuestra lista de espera y se liberó un horario:\n\n"+
+			"   %s\n\n"+
+			"Si te interesa, resérvalo aquí antes del %s:\n\n"+
+			"   %s\n\n"+
+			"El horario se asigna al primer paciente que lo acepte.\n\n"+
+			"CliniSense",
+		patientName, slotStr, expiresStr, acceptURL,
+	)
+	htmlBody := fmt.Sprintf(`
+<p style="margin:0 0 6px;font-size:16px;font-weight:700;color:#1e293b;">Hola %s,</p>
+%s
+%s
+%s
+%s
+<p style="margin:16px 0 0;font-size:13px;color:#94a3b8;">
+  El horario se asigna al primer paciente que lo acepte.<br>
+  <a href="%s" style="color:#0ea5e9;word-break:break-all;font-size:12px;">%s</a>
+</p>`,
+		html.EscapeString(patientName),
+		htmlParagraph("Estás en nuestra lista de espera y se liberó un horario con tu doctor."),
+		htmlInfoBox(
+			fmt.Sprintf("<strong>Horario:</strong> %s", slotStr),
+			fmt.Sprintf("<strong>Disponible hasta:</strong> %s", expiresStr),
+		),
+		htmlDivider(),
+		htmlCTAButton("Reservar este horario", acceptURL),
+		html.EscapeString(acceptURL), html.EscapeString(acceptURL),
+	)
+
+	log.Printf("[notify:waitlist-offer] to=%s slot=%s expires=%s", toEmail, startAt, expiresAt)
+	if !r.sendEmail || r.ses == nil {
+		return nil
+	}
+	sender := r.cfg.SESSenderEmail
+	if sender == "" {
+		sender = os.Getenv("SES_SENDER_EMAIL")
+	}
+	if sender == "" {
+		sender = "no-reply@clinisense.aski-tech.net"
+	}
+	_, err := r.ses.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(sender),
+		Destination:      &sestypes.Destination{ToAddresses: []string{toEmail}},
+		Content: &sestypes.EmailContent{Simple: &sestypes.Message{
+			Subject: &sestypes.Content{Data: aws.String(subject)},
+			Body: &sestypes.Body{
+				Html: &sestypes.Content{Data: aws.String(buildHTMLEmail(subject, htmlBody, ""))},
+				Text: &sestypes.Content{Data: aws.String(body)},
+			},
+		}},
+	})
+	if err != nil {
+		log.Printf("[notify:waitlist-offer] ses send failed: %v", err)
+	}
+	return err
+}
+
+func (r *Router) SendWaitlistOfferSMS(ctx context.Context, toPhone, patientName, offerToken string, startAt, expiresAt time.Time) error {
+	e164 := normalizePhoneForSMS(toPhone)
+	if e164 == "" {
+		log.Printf("[notify:sms] skip: paciente sin teléfono válido (patientName=%s)", patientName)
+		return nil
+	}
+	if !r.sendSMS || r.sns == nil {
+		log.Printf("[notify:sms] skip: SMS deshabilitado o cliente nil")
+		return nil
+	}
+	msg := fmt.Sprintf("CliniSense: Se liberó un horario el %s a las %s. Resérvalo antes de las %s: %s",
+		startAt.Format("02/01/2006"), startAt.Format("15:04"), expiresAt.Format("15:04"), waitlistOfferURL(offerToken))
+	_, err := r.sns.Publish(ctx, &sns.PublishInput{
+		PhoneNumber: aws.String(e164),
+		Message:     aws.String(msg),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"AWS.SNS.SMS.SMSType": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("Transactional"),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("[notify:sms] send failed to %s: %v", e164, err)
+		return err
+	}
+	log.Printf("[notify:sms] waitlist offer sent to %s", e164)
+	return nil
+}
+
 func (r *Router) SendTreatmentPlanSummary(ctx context.Context, toEmail, patientName, treatmentPlan string, consultDate time.Time) error {
 	subject := "CliniSense — Plan de tratamiento de tu consulta"
 	dateStr := consultDate.Format("02/01/2006")
@@ -810,6 +1079,60 @@ func (r *Router) SendInvitation(ctx context.Context, toEmail, inviteURL, role, t
 	return err
 }
 
+// SendAccountLocked avisa que el acceso quedó bloqueado por intentos fallidos;
+// unlockURL lo desbloquea sin esperar a lockedUntil.
+func (r *Router) SendAccountLocked(ctx context.Context, toEmail, unlockURL string, lockedUntil time.Time) error {
+	subject := "CliniSense — Acceso bloqueado temporalmente"
+	untilStr := lockedUntil.UTC().Format("02/01/2006 15:04") + " UTC"
+	body := fmt.Sprintf(
+		"Detectamos demasiados intentos fallidos de inicio de sesión en tu cuenta de CliniSense.\n\n"+
+			"Por seguridad, el acceso quedó bloqueado hasta el %s.\n\n"+
+			"Si fuiste tú, puedes desbloquearlo ahora con el siguiente enlace:\n\n"+
+			"%s\n\n"+
+			"Si no fuiste tú, te recomendamos cambiar tu contraseña y activar la verificación en dos pasos.",
+		untilStr, unlockURL,
+	)
+	htmlBody := fmt.Sprintf(`
+%s
+%s
+%s
+%s
+<p style="margin:0;font-size:12px;color:#94a3b8;">
+  Si no fuiste tú, te recomendamos cambiar tu contraseña y activar la verificación en dos pasos.
+</p>`,
+		htmlParagraph("Detectamos demasiados intentos fallidos de inicio de sesión en tu cuenta de CliniSense."),
+		htmlParagraph(fmt.Sprintf("Por seguridad, el acceso quedó bloqueado hasta el <strong>%s</strong>.", html.EscapeString(untilStr))),
+		htmlCTAButton("Desbloquear mi cuenta", unlockURL),
+		htmlDivider(),
+	)
+	log.Printf("[notify:account-locked] to=%s until=%s", toEmail, lockedUntil.UTC().Format(time.RFC3339))
+	if !r.sendEmail || r.ses == nil {
+		return nil
+	}
+	sender := r.cfg.SESSenderEmail
+	if sender == "" {
+		sender = os.Getenv("SES_SENDER_EMAIL")
+	}
+	if sender == "" {
+		sender = "no-reply@clinisense.aski-tech.net"
+	}
+	_, err := r.ses.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(sender),
+		Destination:      &sestypes.Destination{ToAddresses: []string{toEmail}},
+		Content: &sestypes.EmailContent{Simple: &sestypes.Message{
+			Subject: &sestypes.Content{Data: aws.String(subject)},
+			Body: &sestypes.Body{
+				Html: &sestypes.Content{Data: aws.String(buildHTMLEmail(subject, htmlBody, ""))},
+				Text: &sestypes.Content{Data: aws.String(body)},
+			},
+		}},
+	})
+	if err != nil {
+		log.Printf("[notify:account-locked] ses send failed: %v", err)
+	}
+	return err
+}
+
 func (r *Router) SendConsentWithAppointment(ctx context.Context, toEmail, patientName string, consent domain.Consent, startAt time.Time) error {
 	frontendBase := os.Getenv("FRONTEND_BASE_URL")
 	if frontendBase == "" {
@@ -2,18 +2,79 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"clinical-backend/internal/service"
+	"clinical-backend/internal/store"
 )
 
 type ReminderJobs struct {
 	appointments *service.AppointmentService
+	repo         store.AppointmentRepository
+	users        store.AuthRepository
+	loc          *time.Location
+	timezones    *service.TimezoneResolver
+	now          func() time.Time
 }
 
-func NewReminderJobs(appointments *service.AppointmentService) *ReminderJobs {
-	return &ReminderJobs{appointments: appointments}
+func NewReminderJobs(appointments *service.AppointmentService, repo store.AppointmentRepository, users store.AuthRepository, opts ...func(*ReminderJobs)) *ReminderJobs {
+	j := &ReminderJobs{appointments: appointments, repo: repo, users: users, loc: time.UTC, now: time.Now}
+	for _, o := range opts {
+		o(j)
+	}
+	return j
+}
+
+// WithClock overrides the job clock. The same clock should be given to the
+// AppointmentService (service.WithAppointmentClock) so both agree on the window.
+func WithClock(now func() time.Time) func(*ReminderJobs) {
+	return func(j *ReminderJobs) { j.now = now }
+}
+
+// WithLocation sets the timezone used to decide what "today" is for the
+// end-of-day job when no TimezoneResolver is configured.
+func WithLocation(loc *time.Location) func(*ReminderJobs) {
+	return func(j *ReminderJobs) {
+		if loc != nil {
+			j.loc = loc
+		}
+	}
+}
+
+// WithTimezones resolves "today" for the end-of-day job in each org's own
+// timezone.
+func WithTimezones(r *service.TimezoneResolver) func(*ReminderJobs) {
+	return func(j *ReminderJobs) { j.timezones = r }
+}
+
+// location returns the org timezone, or the job default.
+func (j *ReminderJobs) location(org store.Organization) *time.Location {
+	if j.timezones != nil {
+		return j.timezones.FromOrg(org)
+	}
+	return j.loc
+}
+
+// OrgReport summarises one job run for a single organization.
+type OrgReport struct {
+	OrgID   string   `json:"orgId"`
+	Scanned int      `json:"scanned"`
+	Sent    int      `json:"sent"`
+	Skipped int      `json:"skipped"`
+	Failed  int      `json:"failed"`
+	NoShows int      `json:"noShows,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// RunReport is returned by the EventBridge handlers.
+type RunReport struct {
+	Job   string      `json:"job"`
+	RanAt time.Time   `json:"ranAt"`
+	Orgs  []OrgReport `json:"orgs"`
 }
 
 func (j *ReminderJobs) Run24hReminder(ctx context.Context, appointmentID, channel string) error {
@@ -24,9 +85,153 @@ func (j *ReminderJobs) RunDoctorEndOfDayReminder(ctx context.Context, doctorID,
 	return j.appointments.SendDoctorCloseDayReminder(ctx, doctorID, channel)
 }
 
+// Run24hReminders sends the 24h reminder for every appointment of every active
+// org that starts ~24h from now. Appointments that already have ReminderSentAt
+// are skipped, and Send24hReminder claims the reminder before sending, so
+// overlapping runs never notify twice.
+func (j *ReminderJobs) Run24hReminders(ctx context.Context) (RunReport, error) {
+	now := j.now().UTC()
+	report := RunReport{Job: "reminder24h", RanAt: now}
+	target := now.Add(24 * time.Hour)
+
+	err := j.forEachOrg(ctx, &report, func(ctx context.Context, org store.Organization, doctors []store.AuthUser, rep *OrgReport) {
+		for _, doc := range doctors {
+			appts, err := service.ListByDoctorRange(ctx, j.repo, doc.ID, target.Add(-time.Hour), target.Add(time.Hour))
+			if err != nil {
+				rep.Failed++
+				rep.Errors = append(rep.Errors, fmt.Sprintf("doctor %s: %v", doc.ID, err))
+				continue
+			}
+			for _, a := range appts {
+				rep.Scanned++
+				if a.ReminderSentAt != nil || !service.IsOpenStatus(a.Status) || !ShouldTrigger24hReminder(a.StartAt, now) {
+					rep.Skipped++
+					continue
+				}
+				err := j.Run24hReminder(ctx, a.ID, "email")
+				if errors.Is(err, service.ErrReminderSent) {
+					// Otra corrida lo reclamó entre el listado y el envío.
+					rep.Skipped++
+					continue
+				}
+				if err != nil {
+					rep.Failed++
+					rep.Errors = append(rep.Errors, fmt.Sprintf("appointment %s: %v", a.ID, err))
+					continue
+				}
+				rep.Sent++
+			}
+		}
+	})
+	return report, err
+}
+
+// noShowLookbackDays bounds how far back RunEndOfDay looks for unclosed
+// appointments to mark as no_show.
+const noShowLookbackDays = 7
+
+// RunEndOfDay reminds every doctor with appointments still open today (org
+// timezone) to close their agenda, and marks as no_show the appointments of
+// previous days that were never closed (today's stay open so the doctor can
+// still close them).
+func (j *ReminderJobs) RunEndOfDay(ctx context.Context) (RunReport, error) {
+	now := j.now()
+	report := RunReport{Job: "endOfDay", RanAt: now.UTC()}
+
+	err := j.forEachOrg(ctx, &report, func(ctx context.Context, org store.Organization, doctors []store.AuthUser, rep *OrgReport) {
+		loc := j.location(org)
+		local := now.In(loc)
+		dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		for _, doc := range doctors {
+			j.markNoShows(ctx, doc.ID, dayStart.AddDate(0, 0, -noShowLookbackDays), dayStart, rep)
+			appts, err := service.ListByDoctorRange(ctx, j.repo, doc.ID, dayStart, dayEnd)
+			if err != nil {
+				rep.Failed++
+				rep.Errors = append(rep.Errors, fmt.Sprintf("doctor %s: %v", doc.ID, err))
+				continue
+			}
+			rep.Scanned += len(appts)
+			pending := 0
+			for _, a := range appts {
+				if service.IsOpenStatus(a.Status) {
+					pending++
+				}
+			}
+			if pending == 0 {
+				rep.Skipped++
+				continue
+			}
+			if err := j.RunDoctorEndOfDayReminder(ctx, doc.ID, "email"); err != nil {
+				rep.Failed++
+				rep.Errors = append(rep.Errors, fmt.Sprintf("doctor %s: %v", doc.ID, err))
+				continue
+			}
+			rep.Sent++
+		}
+	})
+	return report, err
+}
+
+// markNoShows flags the doctor's open appointments in [from, to) as no_show.
+func (j *ReminderJobs) markNoShows(ctx context.Context, doctorID string, from, to time.Time, rep *OrgReport) {
+	appts, err := service.ListByDoctorRange(ctx, j.repo, doctorID, from, to)
+	if err != nil {
+		rep.Failed++
+		rep.Errors = append(rep.Errors, fmt.Sprintf("doctor %s: %v", doctorID, err))
+		return
+	}
+	now := j.now()
+	for _, a := range appts {
+		if !service.IsOpenStatus(a.Status) || a.EndAt.After(now) {
+			continue
+		}
+		if _, err := j.appointments.MarkNoShow(ctx, a.ID); err != nil {
+			rep.Failed++
+			rep.Errors = append(rep.Errors, fmt.Sprintf("appointment %s: %v", a.ID, err))
+			continue
+		}
+		rep.NoShows++
+	}
+}
+
+// forEachOrg runs fn for every active organization with its org-scoped context
+// and its active doctors (service.IsDoctorRole). One failing org does not stop
+// the run.
+func (j *ReminderJobs) forEachOrg(ctx context.Context, report *RunReport, fn func(context.Context, store.Organization, []store.AuthUser, *OrgReport)) error {
+	orgs, err := j.users.ListOrganizations(ctx)
+	if err != nil {
+		return fmt.Errorf("list organizations: %w", err)
+	}
+	for _, org := range orgs {
+		if org.Status != "" && org.Status != "active" {
+			continue
+		}
+		orgCtx := store.ContextWithOrgID(ctx, org.ID)
+		rep := OrgReport{OrgID: org.ID}
+		users, err := j.users.ListUsersByOrg(orgCtx, org.ID)
+		if err != nil {
+			rep.Failed++
+			rep.Errors = append(rep.Errors, fmt.Sprintf("list users: %v", err))
+			report.Orgs = append(report.Orgs, rep)
+			continue
+		}
+		var doctors []store.AuthUser
+		for _, u := range users {
+			if !service.IsDoctorRole(org, u.Role) || strings.ToLower(strings.TrimSpace(u.Status)) == "disabled" {
+				continue
+			}
+			doctors = append(doctors, u)
+		}
+		fn(orgCtx, org, doctors, &rep)
+		log.Printf("[schedule:%s] org=%s scanned=%d sent=%d skipped=%d failed=%d noShows=%d", report.Job, rep.OrgID, rep.Scanned, rep.Sent, rep.Skipped, rep.Failed, rep.NoShows)
+		report.Orgs = append(report.Orgs, rep)
+	}
+	return nil
+}
+
 func ShouldTrigger24hReminder(startAt time.Time, now time.Time) bool {
-	hours := startAt.Sub(now).Hours()
-	return hours >= 23.9 && hours <= 24.1
+	return service.In24hReminderWindow(startAt, now)
 }
 
 func LogScheduleEvent(name string, payload map[string]string) {
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// Acceso por doctor. Con la política de la org DoctorsOwnPatientsOnly, cada
+// usuario con rol de doctor (IsDoctorRole: doctor o un rol propio con
+// treatments.manage) solo ve a sus pacientes: los asignados
+// (Patient.DoctorID) y los que tienen alguna cita con él. Los servicios
+// aplican la regla con el Authenticated que el router deja en el contexto;
+// sin él (jobs, enlaces públicos) no hay restricción. admin, assistant y los
+// roles propios sin treatments.manage ven toda la org.
+
+// ErrAccessDenied is returned when the caller may not see a patient or
+// something that belongs to one.
+var ErrAccessDenied = errors.New("access denied: patient not assigned to you")
+
+type authCtxKey struct{}
+
+// requestAuth is what ContextWithAuth stores: the caller plus the patients of
+// a restricted doctor, loaded on first use and reused for the whole request.
+type requestAuth struct {
+	auth     Authenticated
+	mu       sync.Mutex
+	patients map[string]bool
+}
+
+// ContextWithAuth stores the caller of the request in ctx.
+func ContextWithAuth(ctx context.Context, auth Authenticated) context.Context {
+	return context.WithValue(ctx, authCtxKey{}, &requestAuth{auth: auth})
+}
+
+// AuthFromContext returns the caller stored by ContextWithAuth.
+func AuthFromContext(ctx context.Context) (Authenticated, bool) {
+	req, ok := ctx.Value(authCtxKey{}).(*requestAuth)
+	if !ok {
+		return Authenticated{}, false
+	}
+	return req.auth, true
+}
+
+// restrictedDoctor returns the caller's user ID when they may only see their
+// own patients.
+func restrictedDoctor(ctx context.Context) (string, bool) {
+	auth, ok := AuthFromContext(ctx)
+	if !ok || !auth.OwnPatientsOnly {
+		return "", false
+	}
+	return auth.User.ID, true
+}
+
+// checkDoctor fails when a restricted doctor acts on another doctor's
+// agenda. An empty doctorID means "every doctor" and is refused too.
+func checkDoctor(ctx context.Context, doctorID string) error {
+	if self, ok := restrictedDoctor(ctx); ok && doctorID != self {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// ownDoctorID resolves the doctorID filter of a listing: a restricted doctor
+// always gets their own, whatever the client asked for ("" included).
+func ownDoctorID(ctx context.Context, doctorID string) (string, error) {
+	self, ok := restrictedDoctor(ctx)
+	if !ok {
+		return doctorID, nil
+	}
+	if doctorID != "" && doctorID != self {
+		return "", ErrAccessDenied
+	}
+	return self, nil
+}
+
+// PatientAccess answers whether a restricted doctor may see a patient. The
+// services share one instance; a service without it denies everything to
+// restricted doctors rather than leak.
+type PatientAccess struct {
+	patients     store.PatientRepository
+	appointments store.AppointmentRepository
+}
+
+func NewPatientAccess(patients store.PatientRepository, appointments store.AppointmentRepository) *PatientAccess {
+	return &PatientAccess{patients: patients, appointments: appointments}
+}
+
+// checkPatient fails with ErrAccessDenied when the caller is a restricted
+// doctor and patientID is not theirs.
+func (a *PatientAccess) checkPatient(ctx context.Context, patientID string) error {
+	visible := a.filter(ctx)
+	if visible == nil || visible(patientID) {
+		return nil
+	}
+	logAccessDenied(ctx, "patient", patientID)
+	return ErrAccessDenied
+}
+
+// checkAppointment lets a restricted doctor see their own appointments and
+// those of their patients.
+func (a *PatientAccess) checkAppointment(ctx context.Context, appt domain.Appointment) error {
+	if self, ok := restrictedDoctor(ctx); !ok || appt.DoctorID == self {
+		return nil
+	}
+	return a.checkPatient(ctx, appt.PatientID)
+}
+
+// filter returns a predicate over patient IDs for listings, or nil when the
+// caller sees everything. The doctor's patients are read once per request.
+func (a *PatientAccess) filter(ctx context.Context) func(patientID string) bool {
+	self, ok := restrictedDoctor(ctx)
+	if !ok {
+		return nil
+	}
+	owned := a.patientsOf(ctx, self)
+	return func(patientID string) bool {
+		return patientID != "" && owned[patientID]
+	}
+}
+
+// patientsOf returns the patients assigned to doctorID plus those with an
+// appointment with them, cached in the request context. A failed read denies
+// everything and is retried on the next call.
+func (a *PatientAccess) patientsOf(ctx context.Context, doctorID string) map[string]bool {
+	req, _ := ctx.Value(authCtxKey{}).(*requestAuth)
+	if req != nil {
+		req.mu.Lock()
+		defer req.mu.Unlock()
+		if req.patients != nil {
+			return req.patients
+		}
+	}
+	owned, err := a.loadPatients(ctx, doctorID)
+	if err != nil {
+		log.Printf("[access] patients of doctor %s: %v", doctorID, err)
+		return nil
+	}
+	if req != nil {
+		req.patients = owned
+	}
+	return owned
+}
+
+func (a *PatientAccess) loadPatients(ctx context.Context, doctorID string) (map[string]bool, error) {
+	owned := map[string]bool{}
+	if a == nil || a.patients == nil {
+		return owned, nil
+	}
+	patients, err := a.patients.ListByDoctor(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range patients {
+		owned[p.ID] = true
+	}
+	if a.appointments == nil {
+		return owned, nil
+	}
+	ids, err := a.appointments.ListPatientIDsByDoctor(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		owned[id] = true
+	}
+	return owned, nil
+}
+
+// filterByPatient keeps the items whose patient the caller may see.
+func filterByPatient[T any](items []T, visible func(string) bool, patientID func(T) string) []T {
+	if visible == nil {
+		return items
+	}
+	out := items[:0:0]
+	for _, it := range items {
+		if visible(patientID(it)) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func logAccessDenied(ctx context.Context, kind, id string) {
+	auth, _ := AuthFromContext(ctx)
+	log.Printf("[access] denied: doctor %s (org %s) -> %s %s", auth.User.ID, auth.User.OrgID, kind, id)
+}
+
+type AccessPolicyInput struct {
+	DoctorsOwnPatientsOnly bool `json:"doctorsOwnPatientsOnly"`
+}
+
+// GetOrgAccessPolicy returns whether the org's doctors only see their own
+// patients.
+func (s *AuthService) GetOrgAccessPolicy(ctx context.Context, orgID string) (AccessPolicyInput, error) {
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return AccessPolicyInput{}, fmt.Errorf("organization not found")
+	}
+	return AccessPolicyInput{DoctorsOwnPatientsOnly: org.DoctorsOwnPatientsOnly}, nil
+}
+
+// SetOrgAccessPolicy turns the "doctors only see their own patients" policy
+// on or off. It applies to the doctors' next request (within the org cache
+// TTL on other instances).
+func (s *AuthService) SetOrgAccessPolicy(ctx context.Context, orgID string, in AccessPolicyInput) (AccessPolicyInput, error) {
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return AccessPolicyInput{}, fmt.Errorf("organization not found")
+	}
+	org.DoctorsOwnPatientsOnly = in.DoctorsOwnPatientsOnly
+	now := time.Now().UTC()
+	org.UpdatedAt = &now
+	if _, err := s.repo.UpdateOrganization(ctx, org); err != nil {
+		return AccessPolicyInput{}, err
+	}
+	s.forgetOrg(orgID)
+	log.Printf("[auth] org %s: doctorsOwnPatientsOnly=%t", orgID, org.DoctorsOwnPatientsOnly)
+	return AccessPolicyInput{DoctorsOwnPatientsOnly: org.DoctorsOwnPatientsOnly}, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/ical"
+	"clinical-backend/internal/store"
+)
+
+// Ventana del feed ICS: los calendarios lo consultan periódicamente, así que
+// se limita a la agenda reciente y próxima.
+const (
+	calendarFeedPastDays  = 30
+	calendarFeedAheadDays = 180
+)
+
+// CalendarFeedInfo is returned when a doctor enables or rotates the feed.
+type CalendarFeedInfo struct {
+	DoctorID string `json:"doctorId"`
+	Token    string `json:"token,omitempty"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// GetCalendarFeed reports whether the doctor has a feed and its token.
+func (s *AppointmentService) GetCalendarFeed(ctx context.Context, doctorID string) (CalendarFeedInfo, error) {
+	u, err := s.doctorUser(ctx, doctorID)
+	if err != nil {
+		return CalendarFeedInfo{}, err
+	}
+	return CalendarFeedInfo{DoctorID: u.ID, Token: u.CalendarToken, Enabled: u.CalendarToken != ""}, nil
+}
+
+// RotateCalendarFeed issues a new secret token for the doctor's ICS feed; the
+// previous URL stops working.
+func (s *AppointmentService) RotateCalendarFeed(ctx context.Context, doctorID string) (CalendarFeedInfo, error) {
+	u, err := s.doctorUser(ctx, doctorID)
+	if err != nil {
+		return CalendarFeedInfo{}, err
+	}
+	token, err := generateAppointmentToken()
+	if err != nil {
+		return CalendarFeedInfo{}, fmt.Errorf("could not generate token")
+	}
+	if err := s.authRepo.SetCalendarToken(ctx, u.ID, token); err != nil {
+		return CalendarFeedInfo{}, err
+	}
+	return CalendarFeedInfo{DoctorID: u.ID, Token: token, Enabled: true}, nil
+}
+
+// DisableCalendarFeed revokes the doctor's feed token.
+func (s *AppointmentService) DisableCalendarFeed(ctx context.Context, doctorID string) error {
+	u, err := s.doctorUser(ctx, doctorID)
+	if err != nil {
+		return err
+	}
+	return s.authRepo.SetCalendarToken(ctx, u.ID, "")
+}
+
+// CalendarFeed renders the agenda of the doctor owning token as an iCalendar
+// document in the org timezone. Cancelled appointments are kept with
+// STATUS:CANCELLED so subscribed calendars drop them.
+func (s *AppointmentService) CalendarFeed(ctx context.Context, token string) ([]byte, error) {
+	token = strings.TrimSpace(token)
+	if s.authRepo == nil || token == "" {
+		return nil, fmt.Errorf("calendar feed not found")
+	}
+	doctor, err := s.authRepo.GetUserByCalendarToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("calendar feed not found")
+	}
+	if doctor.OrgID != "" {
+		ctx = store.ContextWithOrgID(ctx, doctor.OrgID)
+	}
+	loc := s.orgLocation(ctx, doctor.OrgID)
+
+	now := s.now()
+	appts, err := s.listDoctorDays(ctx, doctor.ID, now.AddDate(0, 0, -calendarFeedPastDays), now.AddDate(0, 0, calendarFeedAheadDays))
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	cal := ical.Calendar{Name: "Agenda " + strings.TrimSpace(doctor.Name), Location: loc, Now: now}
+	for _, a := range appts {
+		name, ok := names[a.PatientID]
+		if !ok {
+			_, name = s.patientEmail(ctx, a.PatientID)
+			name = strings.TrimSpace(name)
+			names[a.PatientID] = name
+		}
+		summary := "Cita"
+		if name != "" {
+			summary += ": " + name
+		}
+		ev := ical.AppointmentEvent(a, loc, summary)
+		ev.Description = appointmentDescription(a)
+		cal.Events = append(cal.Events, ev)
+	}
+	return cal.Bytes(), nil
+}
+
+func appointmentDescription(a domain.Appointment) string {
+	var lines []string
+	if a.Reason != "" {
+		lines = append(lines, "Motivo: "+a.Reason)
+	}
+	if a.TreatmentPlan != "" {
+		lines = append(lines, "Plan: "+a.TreatmentPlan)
+	}
+	if a.Status == "cancelled" && a.CancelReason != "" {
+		lines = append(lines, "Cancelada: "+a.CancelReason)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// localized returns a copy of appt with its times in loc, for notifications.
+func localized(appt domain.Appointment, loc *time.Location) domain.Appointment {
+	appt.StartAt = appt.StartAt.In(loc)
+	appt.EndAt = appt.EndAt.In(loc)
+	return appt
+}
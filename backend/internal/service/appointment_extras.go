@@ -13,7 +13,7 @@ const reminderCooldown = 3 * time.Minute
 // SendReminderAnytime resends the appointment confirmation email (with confirm + consent links).
 // Rate-limited to once every 3 minutes using ReminderSentAt.
 func (s *AppointmentService) SendReminderAnytime(ctx context.Context, appointmentID, channel string) error {
-	item, err := s.repo.GetByID(ctx, appointmentID)
+	item, err := s.getAppointment(ctx, appointmentID)
 	if err != nil {
 		return err
 	}
@@ -38,7 +38,7 @@ func (s *AppointmentService) SendReminderAnytime(ctx context.Context, appointmen
 		ctx, orgID := s.ensureOrgContext(ctx, item.DoctorID)
 		var consentLinks []notifications.ConsentLink
 		if s.consents != nil && orgID != "" {
-			if list, cerr := s.consents.CreateConsentsForAppointment(ctx, item.ID, orgID, item.PatientID, item.DoctorID, email, name, item.StartAt); cerr == nil {
+			if list, cerr := s.consents.CreateConsentsForAppointment(ctx, item.ID, orgID, item.PatientID, item.DoctorID, email, name, item.StartAt.In(s.doctorLocation(ctx, item.DoctorID)), s.consentTemplateIDs(ctx, item)); cerr == nil {
 				for _, c := range list {
 					if c.AcceptToken != "" {
 						consentLinks = append(consentLinks, notifications.ConsentLink{Title: c.Title, Token: c.AcceptToken})
@@ -46,11 +46,12 @@ func (s *AppointmentService) SendReminderAnytime(ctx context.Context, appointmen
 				}
 			}
 		}
-		if err := s.notifier.SendAppointmentCreated(ctx, email, name, item, consentLinks); err != nil {
+		local := localized(item, s.doctorLocation(ctx, item.DoctorID))
+		if err := s.notifier.SendAppointmentCreated(ctx, email, name, local, consentLinks); err != nil {
 			return err
 		}
 		if phone := s.patientPhone(ctx, item.PatientID); phone != "" {
-			_ = s.notifier.SendAppointmentCreatedSMS(ctx, phone, name, item)
+			_ = s.notifier.SendAppointmentCreatedSMS(ctx, phone, name, local)
 		}
 	}
 
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// publicActor is recorded as CancelledBy / RescheduledBy for changes made by
+// the patient through the public token link.
+const publicActor = "patient"
+
+// WithPublicPolicy configures the patient portal: minimum notice for
+// cancelling/rescheduling and the clinic hours used to offer slots when the
+// doctor has no weekly schedule of their own.
+func WithPublicPolicy(minNotice time.Duration, clinicHours []domain.WeeklyHours) func(*AppointmentService) {
+	return func(s *AppointmentService) {
+		s.publicMinNotice = minNotice
+		s.clinicHours = clinicHours
+	}
+}
+
+// ParseClinicHours parses CLINIC_DAYS ("1-5" or "1,3,5"; 0 = domingo) and
+// CLINIC_HOURS ("08:00-12:00,14:00-18:00") into weekly windows.
+func ParseClinicHours(days, hours string) ([]domain.WeeklyHours, error) {
+	var weekdays []time.Weekday
+	for _, part := range strings.Split(days, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid clinic days %q", days)
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				return nil, fmt.Errorf("invalid clinic days %q", days)
+			}
+		}
+		if a < 0 || b > 6 || a > b {
+			return nil, fmt.Errorf("invalid clinic days %q", days)
+		}
+		for d := a; d <= b; d++ {
+			weekdays = append(weekdays, time.Weekday(d))
+		}
+	}
+	var out []domain.WeeklyHours
+	for _, part := range strings.Split(hours, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		start, end, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid clinic hours %q", hours)
+		}
+		for _, d := range weekdays {
+			out = append(out, domain.WeeklyHours{Weekday: d, Start: strings.TrimSpace(start), End: strings.TrimSpace(end)})
+		}
+	}
+	if err := validateAvailability(domain.DoctorAvailability{Weekly: out}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PublicAppointmentView is what the patient sees through the token link.
+type PublicAppointmentView struct {
+	ID              string    `json:"id"`
+	Status          string    `json:"status"`
+	StartAt         time.Time `json:"startAt"`
+	EndAt           time.Time `json:"endAt"`
+	DurationMinutes int       `json:"durationMinutes"`
+	DoctorName      string    `json:"doctorName,omitempty"`
+	Timezone        string    `json:"timezone"`
+	CanChange       bool      `json:"canChange"` // cancelar / reprogramar desde el portal
+	MinNoticeHours  int       `json:"minNoticeHours"`
+}
+
+// appointmentByToken resolves the token and returns ctx scoped to the
+// appointment's org (public requests carry no session).
+func (s *AppointmentService) appointmentByToken(ctx context.Context, token string) (context.Context, domain.Appointment, error) {
+	appt, err := s.repo.GetByConfirmToken(ctx, strings.TrimSpace(token))
+	if err != nil {
+		return ctx, domain.Appointment{}, fmt.Errorf("enlace inválido o expirado")
+	}
+	if appt.OrgID != "" {
+		ctx = store.ContextWithOrgID(ctx, appt.OrgID)
+	}
+	return ctx, appt, nil
+}
+
+// checkPublicChange enforces the portal policy: only open appointments, and
+// not later than the minimum notice.
+func (s *AppointmentService) checkPublicChange(appt domain.Appointment) error {
+	if !IsOpenStatus(appt.Status) {
+		return fmt.Errorf("la cita ya no puede modificarse (estado %s)", appt.Status)
+	}
+	if appt.StartAt.Sub(s.now()) < s.publicMinNotice {
+		return fmt.Errorf("los cambios requieren al menos %d horas de anticipación; por favor contacta a la clínica", int(s.publicMinNotice.Hours()))
+	}
+	return nil
+}
+
+func (s *AppointmentService) GetByToken(ctx context.Context, token string) (PublicAppointmentView, error) {
+	ctx, appt, err := s.appointmentByToken(ctx, token)
+	if err != nil {
+		return PublicAppointmentView{}, err
+	}
+	loc := s.doctorLocation(ctx, appt.DoctorID)
+	view := PublicAppointmentView{
+		ID:              appt.ID,
+		Status:          appt.Status,
+		StartAt:         appt.StartAt.In(loc),
+		EndAt:           appt.EndAt.In(loc),
+		DurationMinutes: appt.DurationMinutes,
+		Timezone:        loc.String(),
+		CanChange:       s.checkPublicChange(appt) == nil,
+		MinNoticeHours:  int(s.publicMinNotice.Hours()),
+	}
+	if s.authRepo != nil {
+		if u, err := s.authRepo.GetUserByID(ctx, appt.DoctorID); err == nil {
+			view.DoctorName = u.Name
+		}
+	}
+	return view, nil
+}
+
+// CancelByToken lets the patient cancel from the email link.
+func (s *AppointmentService) CancelByToken(ctx context.Context, token, reason string) (domain.Appointment, error) {
+	ctx, appt, err := s.appointmentByToken(ctx, token)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if err := s.checkPublicChange(appt); err != nil {
+		return domain.Appointment{}, err
+	}
+	if err := ValidateStatusTransition(appt.Status, StatusCancelled); err != nil {
+		return domain.Appointment{}, err
+	}
+	now := s.now().UTC()
+	appt.Status = StatusCancelled
+	appt.CancelledAt = &now
+	appt.CancelledBy = publicActor
+	appt.CancelReason = strings.TrimSpace(reason)
+	updated, err := s.repo.Update(ctx, appt)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	log.Printf("[audit] appointment %s cancelled by %s (patient %s) reason=%q", updated.ID, publicActor, updated.PatientID, updated.CancelReason)
+	s.offerFreedSlot(ctx, updated)
+
+	loc := s.doctorLocation(ctx, updated.DoctorID)
+	if s.notifier != nil {
+		if email, name := s.patientEmail(ctx, updated.PatientID); email != "" {
+			_ = s.notifier.SendAppointmentEvent(ctx, email, name, "cancelled", localized(updated, loc))
+		}
+	}
+	s.notifyDoctorChange(ctx, updated, "cancelled", updated.StartAt.In(loc), loc)
+	return updated, nil
+}
+
+// publicAvailability is the doctor's schedule, or the clinic hours (plus the
+// doctor's time-off) when the doctor has no weekly schedule.
+func (s *AppointmentService) publicAvailability(ctx context.Context, doctorID string) domain.DoctorAvailability {
+	var av domain.DoctorAvailability
+	if s.authRepo != nil {
+		if u, err := s.authRepo.GetUserByID(ctx, doctorID); err == nil && u.Availability != nil {
+			av = *u.Availability
+		}
+	}
+	if len(av.Weekly) == 0 {
+		av.Weekly = s.clinicHours
+	}
+	return av
+}
+
+// PublicFreeSlots lists the slots the patient can move the appointment to
+// (same duration, respecting the minimum notice).
+func (s *AppointmentService) PublicFreeSlots(ctx context.Context, token, from, to string) (AvailabilityResult, error) {
+	ctx, appt, err := s.appointmentByToken(ctx, token)
+	if err != nil {
+		return AvailabilityResult{}, err
+	}
+	if err := s.checkPublicChange(appt); err != nil {
+		return AvailabilityResult{}, err
+	}
+	q := AvailabilityQuery{DoctorID: appt.DoctorID, From: from, To: to, DurationMinutes: appointmentMinutes(appt)}
+	return s.freeSlots(ctx, q, s.publicAvailability(ctx, appt.DoctorID), s.doctorLocation(ctx, appt.DoctorID), s.now().Add(s.publicMinNotice), appt.ID)
+}
+
+// RescheduleByToken moves the appointment to one of the slots offered by
+// PublicFreeSlots. The patient receives a new confirmation link.
+func (s *AppointmentService) RescheduleByToken(ctx context.Context, token, startAt, reason string) (domain.Appointment, error) {
+	ctx, appt, err := s.appointmentByToken(ctx, token)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if err := s.checkPublicChange(appt); err != nil {
+		return domain.Appointment{}, err
+	}
+	start, err := time.Parse(time.RFC3339, startAt)
+	if err != nil {
+		return domain.Appointment{}, fmt.Errorf("invalid startAt")
+	}
+	loc := s.doctorLocation(ctx, appt.DoctorID)
+	day := start.In(loc).Format("2006-01-02")
+	slots, err := s.freeSlots(ctx, AvailabilityQuery{DoctorID: appt.DoctorID, From: day, To: day, DurationMinutes: appointmentMinutes(appt)},
+		s.publicAvailability(ctx, appt.DoctorID), loc, s.now().Add(s.publicMinNotice), appt.ID)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	offered := false
+	for _, slot := range slots.Slots {
+		if slot.StartAt.Equal(start) {
+			offered = true
+			break
+		}
+	}
+	if !offered {
+		return domain.Appointment{}, fmt.Errorf("el horario seleccionado ya no está disponible")
+	}
+
+	prevStart := appt.StartAt
+	updated, err := s.Reschedule(ctx, appt.ID, RescheduleAppointmentInput{
+		StartAt:         start.Format(time.RFC3339),
+		DurationMinutes: appointmentMinutes(appt),
+		Reason:          reason,
+		RescheduledBy:   publicActor,
+	})
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	log.Printf("[audit] appointment %s rescheduled by %s (patient %s) %s -> %s", updated.ID, publicActor, updated.PatientID, prevStart.Format(time.RFC3339), updated.StartAt.Format(time.RFC3339))
+	s.notifyDoctorChange(ctx, updated, "moved", prevStart.In(loc), loc)
+	return updated, nil
+}
+
+func (s *AppointmentService) notifyDoctorChange(ctx context.Context, appt domain.Appointment, eventType string, prevStart time.Time, loc *time.Location) {
+	if s.notifier == nil || s.authRepo == nil {
+		return
+	}
+	doctor, err := s.authRepo.GetUserByID(ctx, appt.DoctorID)
+	if err != nil || doctor.Email == "" {
+		return
+	}
+	_, patientName := s.patientEmail(ctx, appt.PatientID)
+	reason := appt.CancelReason
+	if eventType == "moved" && len(appt.RescheduleHistory) > 0 {
+		reason = appt.RescheduleHistory[len(appt.RescheduleHistory)-1].Reason
+	}
+	_ = s.notifier.SendDoctorAppointmentChange(ctx, doctor.Email, doctor.Name, strings.TrimSpace(patientName), eventType, prevStart, appt.StartAt.In(loc), reason)
+}
+
+func appointmentMinutes(appt domain.Appointment) int {
+	if appt.DurationMinutes > 0 {
+		return appt.DurationMinutes
+	}
+	return int(appt.EndAt.Sub(appt.StartAt).Minutes())
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// Tipos de recurso de la org.
+const (
+	ResourceChair     = "chair"
+	ResourceRoom      = "room"
+	ResourceEquipment = "equipment"
+)
+
+// WithResourceRepo enables chairs/rooms/equipment on appointments.
+func WithResourceRepo(r store.ResourceRepository) func(*AppointmentService) {
+	return func(s *AppointmentService) { s.resourceRepo = r }
+}
+
+// ResourceInput creates or updates a resource. On update, empty fields keep
+// their value and Active (if set) enables or retires the resource.
+type ResourceInput struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Notes  string `json:"notes"`
+	Active *bool  `json:"active,omitempty"`
+}
+
+func normalizeResourceKind(kind string) (string, error) {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	switch kind {
+	case ResourceChair, ResourceRoom, ResourceEquipment:
+		return kind, nil
+	}
+	return "", fmt.Errorf("invalid kind %q (chair, room, equipment)", kind)
+}
+
+// ListResources returns the org's resources sorted by kind and name.
+func (s *AppointmentService) ListResources(ctx context.Context, orgID string) ([]domain.Resource, error) {
+	if s.resourceRepo == nil {
+		return nil, fmt.Errorf("resources not configured")
+	}
+	items, err := s.resourceRepo.ListByOrg(store.ContextWithOrgID(ctx, orgID), orgID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind < items[j].Kind
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
+
+// CreateResource adds a chair, room or piece of equipment to the org.
+func (s *AppointmentService) CreateResource(ctx context.Context, orgID string, in ResourceInput) (domain.Resource, error) {
+	if s.resourceRepo == nil {
+		return domain.Resource{}, fmt.Errorf("resources not configured")
+	}
+	if strings.TrimSpace(orgID) == "" {
+		return domain.Resource{}, fmt.Errorf("organization not found")
+	}
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return domain.Resource{}, fmt.Errorf("name is required")
+	}
+	kind, err := normalizeResourceKind(in.Kind)
+	if err != nil {
+		return domain.Resource{}, err
+	}
+	now := s.now().UTC()
+	res := domain.Resource{
+		ID:        buildID("res"),
+		OrgID:     orgID,
+		Name:      name,
+		Kind:      kind,
+		Notes:     strings.TrimSpace(in.Notes),
+		Active:    in.Active == nil || *in.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return s.resourceRepo.Create(store.ContextWithOrgID(ctx, orgID), res)
+}
+
+// UpdateResource renames, reclassifies or (de)activates a resource. Retiring
+// a resource does not touch the appointments that already hold it.
+func (s *AppointmentService) UpdateResource(ctx context.Context, orgID, id string, in ResourceInput) (domain.Resource, error) {
+	res, err := s.orgResource(ctx, orgID, id)
+	if err != nil {
+		return domain.Resource{}, err
+	}
+	if name := strings.TrimSpace(in.Name); name != "" {
+		res.Name = name
+	}
+	if in.Kind != "" {
+		if res.Kind, err = normalizeResourceKind(in.Kind); err != nil {
+			return domain.Resource{}, err
+		}
+	}
+	if in.Notes != "" {
+		res.Notes = strings.TrimSpace(in.Notes)
+	}
+	if in.Active != nil {
+		res.Active = *in.Active
+	}
+	res.UpdatedAt = s.now().UTC()
+	return s.resourceRepo.Update(store.ContextWithOrgID(ctx, orgID), res)
+}
+
+// DeleteResource removes a resource. Appointments that referenced it keep the
+// ID but it no longer shows in the day view; prefer retiring it (active=false)
+// while it still has upcoming appointments.
+func (s *AppointmentService) DeleteResource(ctx context.Context, orgID, id string) error {
+	if _, err := s.orgResource(ctx, orgID, id); err != nil {
+		return err
+	}
+	return s.resourceRepo.Delete(store.ContextWithOrgID(ctx, orgID), id)
+}
+
+func (s *AppointmentService) orgResource(ctx context.Context, orgID, id string) (domain.Resource, error) {
+	if s.resourceRepo == nil {
+		return domain.Resource{}, fmt.Errorf("resources not configured")
+	}
+	res, err := s.resourceRepo.GetByID(store.ContextWithOrgID(ctx, orgID), id)
+	if err != nil || res.OrgID != orgID {
+		return domain.Resource{}, fmt.Errorf("resource not found")
+	}
+	return res, nil
+}
+
+// resolveResources validates the resources requested for an appointment of
+// doctorID: they must exist in the doctor's org and be active. Duplicates are
+// dropped; nil/empty means no resources.
+func (s *AppointmentService) resolveResources(ctx context.Context, doctorID string, ids []string) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	if s.resourceRepo == nil {
+		return nil, fmt.Errorf("resources not configured")
+	}
+	ctx, orgID := s.ensureOrgContext(ctx, doctorID)
+	for _, id := range out {
+		res, err := s.resourceRepo.GetByID(ctx, id)
+		if err != nil || (orgID != "" && res.OrgID != orgID) {
+			return nil, fmt.Errorf("resource %s not found", id)
+		}
+		if !res.Active {
+			return nil, fmt.Errorf("el recurso %s no está activo", res.Name)
+		}
+	}
+	return out, nil
+}
+
+// checkResourceOverlap fails if another non-cancelled appointment of the org,
+// of any doctor, holds one of appt's resources during [StartAt, EndAt).
+func (s *AppointmentService) checkResourceOverlap(ctx context.Context, appt domain.Appointment, loc *time.Location) error {
+	if len(appt.ResourceIDs) == 0 {
+		return nil
+	}
+	ctx, _ = s.ensureOrgContext(ctx, appt.DoctorID)
+	// doctorID vacío: todas las citas de la org.
+	existing, err := ListByDoctorRange(ctx, s.repo, "", appt.StartAt.Add(-24*time.Hour), appt.EndAt)
+	if err != nil {
+		return fmt.Errorf("could not verify existing appointments: %w", err)
+	}
+	wanted := map[string]bool{}
+	for _, id := range appt.ResourceIDs {
+		wanted[id] = true
+	}
+	for _, other := range existing {
+		if other.Status == StatusCancelled || other.ID == appt.ID {
+			continue
+		}
+		if !appt.StartAt.Before(other.EndAt) || !appt.EndAt.After(other.StartAt) {
+			continue
+		}
+		for _, id := range other.ResourceIDs {
+			if !wanted[id] {
+				continue
+			}
+			name := id
+			if res, err := s.resourceRepo.GetByID(ctx, id); err == nil {
+				name = res.Name
+			}
+			return fmt.Errorf("el recurso %s ya está ocupado de %s a %s", name,
+				other.StartAt.In(loc).Format("15:04"), other.EndAt.In(loc).Format("15:04"))
+		}
+	}
+	return nil
+}
+
+// ResourceSchedule is the org's agenda of one day grouped by resource.
+type ResourceSchedule struct {
+	Date       string                `json:"date"`
+	Timezone   string                `json:"timezone"`
+	Resources  []ResourceScheduleRow `json:"resources"`
+	Unassigned []domain.Appointment  `json:"unassigned"` // citas sin recurso
+}
+
+// ResourceScheduleRow lists the appointments holding one resource.
+type ResourceScheduleRow struct {
+	Resource     domain.Resource      `json:"resource"`
+	Appointments []domain.Appointment `json:"appointments"`
+}
+
+// GetResourceSchedule returns the non-cancelled appointments of the org for
+// date (YYYY-MM-DD in the org timezone, today if empty) grouped by resource.
+// An appointment holding several resources appears under each of them. A
+// restricted doctor only gets the appointments checkAppointment would allow.
+func (s *AppointmentService) GetResourceSchedule(ctx context.Context, orgID, date string) (ResourceSchedule, error) {
+	resources, err := s.ListResources(ctx, orgID)
+	if err != nil {
+		return ResourceSchedule{}, err
+	}
+	ctx = store.ContextWithOrgID(ctx, orgID)
+	loc := s.orgLocation(ctx, orgID)
+	day := s.now().In(loc)
+	if date != "" {
+		if day, err = time.ParseInLocation("2006-01-02", date, loc); err != nil {
+			return ResourceSchedule{}, fmt.Errorf("invalid date format use YYYY-MM-DD")
+		}
+	}
+	from, to := dayBounds(day, loc)
+	appts, err := ListByDoctorRange(ctx, s.repo, "", from, to)
+	if err != nil {
+		return ResourceSchedule{}, err
+	}
+	// Un doctor restringido ve sus citas y las de sus pacientes.
+	if self, ok := restrictedDoctor(ctx); ok {
+		visible := s.access.filter(ctx)
+		appts = slices.DeleteFunc(appts, func(a domain.Appointment) bool {
+			return a.DoctorID != self && !visible(a.PatientID)
+		})
+	}
+
+	out := ResourceSchedule{Date: from.Format("2006-01-02"), Timezone: loc.String(), Resources: []ResourceScheduleRow{}, Unassigned: []domain.Appointment{}}
+	rows := map[string]int{}
+	for _, res := range resources {
+		rows[res.ID] = len(out.Resources)
+		out.Resources = append(out.Resources, ResourceScheduleRow{Resource: res, Appointments: []domain.Appointment{}})
+	}
+	for _, a := range appts {
+		if a.Status == StatusCancelled {
+			continue
+		}
+		assigned := false
+		for _, id := range a.ResourceIDs {
+			if i, ok := rows[id]; ok {
+				out.Resources[i].Appointments = append(out.Resources[i].Appointments, a)
+				assigned = true
+			}
+		}
+		if !assigned {
+			out.Unassigned = append(out.Unassigned, a)
+		}
+	}
+	return out, nil
+}
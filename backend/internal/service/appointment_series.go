@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/domain"
+)
+
+// maxSeriesOccurrences caps a recurrence (one year of weekly visits).
+const maxSeriesOccurrences = 52
+
+// RecurrenceRule describes a repeating appointment. Exactly one of Count or
+// Until is required.
+type RecurrenceRule struct {
+	Frequency string `json:"frequency"`       // weekly | biweekly | monthly
+	Count     int    `json:"count,omitempty"` // total de citas, incluida la primera
+	Until     string `json:"until,omitempty"` // YYYY-MM-DD inclusive (zona de la org)
+}
+
+// Series scopes for edit/cancel operations.
+const (
+	SeriesScopeThis      = "this"
+	SeriesScopeFollowing = "following"
+	SeriesScopeAll       = "all"
+)
+
+// SeriesConflict is an occurrence that could not be created or moved.
+type SeriesConflict struct {
+	AppointmentID string    `json:"appointmentId,omitempty"`
+	StartAt       time.Time `json:"startAt"`
+	Error         string    `json:"error"`
+}
+
+type SeriesResult struct {
+	SeriesID     string               `json:"seriesId,omitempty"`
+	Appointments []domain.Appointment `json:"appointments"`
+	Conflicts    []SeriesConflict     `json:"conflicts"`
+}
+
+// occurrenceStarts expands rule from start keeping the local wall-clock time
+// (so visits stay at 10:00 across DST changes). Monthly dates past the end of
+// the month are clamped (31 ene → 28 feb).
+func occurrenceStarts(start time.Time, rule RecurrenceRule, loc *time.Location) ([]time.Time, error) {
+	if (rule.Count > 0) == (rule.Until != "") {
+		return nil, fmt.Errorf("recurrence requires either count or until")
+	}
+	if rule.Count > maxSeriesOccurrences {
+		return nil, fmt.Errorf("recurrence count too large (max %d)", maxSeriesOccurrences)
+	}
+	var until time.Time
+	if rule.Until != "" {
+		d, err := time.ParseInLocation("2006-01-02", rule.Until, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recurrence until, use YYYY-MM-DD")
+		}
+		until = d.AddDate(0, 0, 1)
+	}
+
+	local := start.In(loc)
+	y, m, d := local.Date()
+	hh, mm, ss := local.Clock()
+	var out []time.Time
+	for i := 0; ; i++ {
+		var t time.Time
+		switch strings.ToLower(rule.Frequency) {
+		case "weekly":
+			t = time.Date(y, m, d+7*i, hh, mm, ss, 0, loc)
+		case "biweekly":
+			t = time.Date(y, m, d+14*i, hh, mm, ss, 0, loc)
+		case "monthly":
+			first := time.Date(y, m+time.Month(i), 1, hh, mm, ss, 0, loc)
+			last := first.AddDate(0, 1, -1).Day()
+			t = time.Date(first.Year(), first.Month(), min(d, last), hh, mm, ss, 0, loc)
+		default:
+			return nil, fmt.Errorf("invalid recurrence frequency %q (weekly, biweekly, monthly)", rule.Frequency)
+		}
+		if rule.Count > 0 && i >= rule.Count {
+			break
+		}
+		if rule.Until != "" && !t.Before(until) {
+			break
+		}
+		if len(out) == maxSeriesOccurrences {
+			return nil, fmt.Errorf("recurrence too long (max %d occurrences)", maxSeriesOccurrences)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// CreateSeries creates one appointment per occurrence of in.Recurrence, linked
+// by SeriesID. Occurrences that overlap another appointment or fall outside the
+// doctor's availability are skipped and reported as conflicts. Only the first
+// created occurrence notifies the patient; the rest go out with the 24h
+// reminder.
+func (s *AppointmentService) CreateSeries(ctx context.Context, in CreateAppointmentInput) (SeriesResult, error) {
+	if in.Recurrence == nil {
+		return SeriesResult{}, fmt.Errorf("recurrence is required")
+	}
+	base, loc, err := s.newAppointment(ctx, in)
+	if err != nil {
+		return SeriesResult{}, err
+	}
+	notice, err := s.checkBookingPolicy(ctx, in.PatientID, in.DoctorID, in.DepositAmount)
+	if err != nil {
+		return SeriesResult{}, err
+	}
+	starts, err := occurrenceStarts(base.StartAt, *in.Recurrence, s.doctorLocation(ctx, in.DoctorID))
+	if err != nil {
+		return SeriesResult{}, err
+	}
+	duration := base.EndAt.Sub(base.StartAt)
+	res := SeriesResult{SeriesID: buildID("ser"), Appointments: []domain.Appointment{}, Conflicts: []SeriesConflict{}}
+	for i, start := range starts {
+		appt := base
+		appt.ID = buildID("apt")
+		appt.ConfirmToken, _ = generateAppointmentToken()
+		appt.SeriesID = res.SeriesID
+		appt.SeriesIndex = i + 1
+		appt.StartAt = start.UTC()
+		appt.EndAt = start.Add(duration).UTC()
+		if err := s.checkSlot(ctx, appt, loc); err != nil {
+			res.Conflicts = append(res.Conflicts, SeriesConflict{StartAt: appt.StartAt, Error: err.Error()})
+			continue
+		}
+		created, err := s.persistAppointment(ctx, appt, loc, len(res.Appointments) == 0)
+		if err != nil {
+			return res, err
+		}
+		created.BookingNotice = notice
+		res.Appointments = append(res.Appointments, created)
+	}
+	if len(res.Appointments) == 0 {
+		return res, fmt.Errorf("ninguna cita de la serie pudo agendarse")
+	}
+	return res, nil
+}
+
+// ListSeries returns every occurrence of the series the appointment belongs
+// to, ordered by date.
+func (s *AppointmentService) ListSeries(ctx context.Context, appointmentID string) ([]domain.Appointment, error) {
+	anchor, err := s.getAppointment(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if anchor.SeriesID == "" {
+		return []domain.Appointment{anchor}, nil
+	}
+	return s.seriesMembers(ctx, anchor)
+}
+
+// seriesMembers lists the series through the patient (a series always belongs
+// to a single patient).
+func (s *AppointmentService) seriesMembers(ctx context.Context, anchor domain.Appointment) ([]domain.Appointment, error) {
+	items, err := s.repo.ListByPatient(ctx, anchor.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	var out []domain.Appointment
+	for _, a := range items {
+		if a.SeriesID == anchor.SeriesID {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartAt.Before(out[j].StartAt) })
+	return out, nil
+}
+
+// scopeMembers resolves which occurrences an edit/cancel applies to. Closed
+// occurrences (completed, cancelled, no_show) are left untouched unless
+// targeted directly.
+func (s *AppointmentService) scopeMembers(ctx context.Context, anchor domain.Appointment, scope string) ([]domain.Appointment, error) {
+	switch scope {
+	case "", SeriesScopeThis:
+		return []domain.Appointment{anchor}, nil
+	case SeriesScopeFollowing, SeriesScopeAll:
+	default:
+		return nil, fmt.Errorf("invalid scope %q (this, following, all)", scope)
+	}
+	if anchor.SeriesID == "" {
+		return []domain.Appointment{anchor}, nil
+	}
+	members, err := s.seriesMembers(ctx, anchor)
+	if err != nil {
+		return nil, err
+	}
+	var out []domain.Appointment
+	for _, m := range members {
+		if m.ID != anchor.ID {
+			if !IsOpenStatus(m.Status) {
+				continue
+			}
+			if scope == SeriesScopeFollowing && m.StartAt.Before(anchor.StartAt) {
+				continue
+			}
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// UpdateSeries applies in to the appointment and, depending on scope, to the
+// following occurrences or the whole series. A new StartAt is applied as a
+// shift: the same number of days and the new local time-of-day on every
+// occurrence. Moved occurrences are validated like new ones; those that
+// conflict are skipped and reported. Only the targeted appointment notifies
+// the patient.
+func (s *AppointmentService) UpdateSeries(ctx context.Context, id, scope string, in UpdateAppointmentInput) (SeriesResult, error) {
+	anchor, err := s.getAppointment(ctx, id)
+	if err != nil {
+		return SeriesResult{}, err
+	}
+	// La versión del cliente es la de la cita abierta, no la del resto de la serie.
+	if err := checkClientVersion(anchor.Version, in.Version); err != nil {
+		return SeriesResult{}, err
+	}
+	members, err := s.scopeMembers(ctx, anchor, scope)
+	if err != nil {
+		return SeriesResult{}, err
+	}
+	if len(members) == 1 {
+		updated, err := s.UpdateAppointment(ctx, id, in)
+		if err != nil {
+			return SeriesResult{}, err
+		}
+		return SeriesResult{SeriesID: updated.SeriesID, Appointments: []domain.Appointment{updated}, Conflicts: []SeriesConflict{}}, nil
+	}
+
+	loc := s.doctorLocation(ctx, anchor.DoctorID)
+	var newStart, newEnd time.Time
+	if in.StartAt != "" {
+		if newStart, err = time.Parse(time.RFC3339, in.StartAt); err != nil {
+			return SeriesResult{}, fmt.Errorf("invalid startAt")
+		}
+	}
+	if in.EndAt != "" {
+		if newEnd, err = time.Parse(time.RFC3339, in.EndAt); err != nil {
+			return SeriesResult{}, fmt.Errorf("invalid endAt")
+		}
+	}
+	moving := !newStart.IsZero() || !newEnd.IsZero()
+	if newStart.IsZero() {
+		newStart = anchor.StartAt
+	}
+	duration := time.Duration(0) // 0 = conservar la duración de cada cita
+	if !newEnd.IsZero() {
+		if !newEnd.After(newStart) {
+			return SeriesResult{}, fmt.Errorf("endAt must be after startAt")
+		}
+		duration = newEnd.Sub(newStart)
+	}
+	anchorLocal, targetLocal := anchor.StartAt.In(loc), newStart.In(loc)
+	dayShift := int(civilDay(targetLocal).Sub(civilDay(anchorLocal)).Hours() / 24)
+	hh, mm, ss := targetLocal.Clock()
+
+	res := SeriesResult{SeriesID: anchor.SeriesID, Appointments: []domain.Appointment{}, Conflicts: []SeriesConflict{}}
+	for _, m := range members {
+		mi := in
+		mi.StartAt, mi.EndAt = "", ""
+		if m.ID != anchor.ID {
+			mi.Version = 0
+		}
+		if moving {
+			ml := m.StartAt.In(loc)
+			start := time.Date(ml.Year(), ml.Month(), ml.Day()+dayShift, hh, mm, ss, 0, loc)
+			d := duration
+			if d == 0 {
+				d = m.EndAt.Sub(m.StartAt)
+			}
+			end := start.Add(d)
+			moved := m
+			moved.StartAt, moved.EndAt = start.UTC(), end.UTC()
+			if in.Status != "cancelled" {
+				if err := s.checkSlot(ctx, moved, loc); err != nil {
+					res.Conflicts = append(res.Conflicts, SeriesConflict{AppointmentID: m.ID, StartAt: moved.StartAt, Error: err.Error()})
+					continue
+				}
+			}
+			mi.StartAt, mi.EndAt = start.Format(time.RFC3339), end.Format(time.RFC3339)
+		}
+		updated, err := s.updateAppointment(ctx, m.ID, mi, m.ID == anchor.ID)
+		if err != nil {
+			res.Conflicts = append(res.Conflicts, SeriesConflict{AppointmentID: m.ID, StartAt: m.StartAt, Error: err.Error()})
+			continue
+		}
+		res.Appointments = append(res.Appointments, updated)
+	}
+	return res, nil
+}
+
+// CancelSeries cancels the appointment and, depending on scope, the following
+// occurrences or the whole series.
+func (s *AppointmentService) CancelSeries(ctx context.Context, id, scope string) (SeriesResult, error) {
+	return s.UpdateSeries(ctx, id, scope, UpdateAppointmentInput{Status: "cancelled"})
+}
+
+// civilDay returns t's local calendar date as UTC midnight, for day arithmetic.
+func civilDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -4,9 +4,10 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -21,16 +22,47 @@ type AppointmentService struct {
 	authRepo    store.AuthRepository
 	notifier    notifications.Notifier
 	consents    *ConsentService
+	now         func() time.Time
+
+	// Portal del paciente (enlace público): anticipación mínima y horario de
+	// la clínica para ofrecer huecos cuando el doctor no tiene horario propio.
+	publicMinNotice time.Duration
+	clinicHours     []domain.WeeklyHours
+
+	// Lista de espera: los huecos liberados se ofrecen a los pacientes en espera.
+	waitlistRepo      store.WaitlistRepository
+	waitlistOfferTTL  time.Duration
+	waitlistMaxOffers int
+
+	// timezones resuelve la zona horaria de cada org (con caché).
+	timezones *TimezoneResolver
+
+	// resourceRepo guarda los sillones, salas y equipos que reservan las citas.
+	resourceRepo store.ResourceRepository
+	// typeRepo es el catálogo de tipos de cita (duración, precio y consentimientos).
+	typeRepo store.AppointmentTypeRepository
+
+	// access aplica la política "cada doctor solo ve sus pacientes".
+	access *PatientAccess
 }
 
 func NewAppointmentService(repo store.AppointmentRepository, notifier notifications.Notifier, opts ...func(*AppointmentService)) *AppointmentService {
-	svc := &AppointmentService{repo: repo, notifier: notifier}
+	svc := &AppointmentService{repo: repo, notifier: notifier, now: time.Now, publicMinNotice: 24 * time.Hour,
+		waitlistOfferTTL: 2 * time.Hour, waitlistMaxOffers: 3}
 	for _, o := range opts {
 		o(svc)
 	}
+	if svc.timezones == nil {
+		svc.timezones = NewTimezoneResolver(svc.authRepo)
+	}
 	return svc
 }
 
+// WithTimezones shares a TimezoneResolver (and its cache) with other services.
+func WithTimezones(r *TimezoneResolver) func(*AppointmentService) {
+	return func(s *AppointmentService) { s.timezones = r }
+}
+
 func WithPatientRepo(r store.PatientRepository) func(*AppointmentService) {
 	return func(s *AppointmentService) { s.patientRepo = r }
 }
@@ -43,6 +75,27 @@ func WithConsentService(cs *ConsentService) func(*AppointmentService) {
 	return func(s *AppointmentService) { s.consents = cs }
 }
 
+// WithAppointmentAccess enables the org policy "doctors only see their own patients".
+func WithAppointmentAccess(a *PatientAccess) func(*AppointmentService) {
+	return func(s *AppointmentService) { s.access = a }
+}
+
+// WithAppointmentClock overrides the clock used for reminder windows (tests / jobs).
+func WithAppointmentClock(now func() time.Time) func(*AppointmentService) {
+	return func(s *AppointmentService) { s.now = now }
+}
+
+// reminderWindowSlack is half the width of the 24h reminder window. The
+// Reminder24h job runs every 15 minutes, so ±7.5 min guarantees every
+// appointment falls inside at least one run; ReminderSentAt dedupes overlaps.
+const reminderWindowSlack = 7*time.Minute + 30*time.Second
+
+// In24hReminderWindow reports whether startAt is ~24h after now.
+func In24hReminderWindow(startAt, now time.Time) bool {
+	until := startAt.Sub(now)
+	return until >= 24*time.Hour-reminderWindowSlack && until <= 24*time.Hour+reminderWindowSlack
+}
+
 func (s *AppointmentService) patientEmail(ctx context.Context, patientID string) (email, name string) {
 	if s.patientRepo == nil {
 		return "", ""
@@ -98,15 +151,68 @@ type CreateAppointmentInput struct {
 	PaymentAmount   float64 `json:"paymentAmount"`
 	PaymentMethod   string  `json:"paymentMethod"`
 	Reason          string  `json:"reason"`
+	// DepositAmount cubre el depósito que exige la política de inasistencias de la org.
+	DepositAmount float64 `json:"depositAmount,omitempty"`
+	// Recurrence crea una serie (ver CreateSeries); Create la ignora.
+	Recurrence *RecurrenceRule `json:"recurrence,omitempty"`
+	// ResourceIDs: sillón/sala/equipo que ocupa la cita (opcional).
+	ResourceIDs []string `json:"resourceIds,omitempty"`
+	// AppointmentTypeID toma del catálogo la duración, el precio y el motivo
+	// cuando no se indican, y decide qué consentimientos se envían.
+	AppointmentTypeID string `json:"appointmentTypeId,omitempty"`
 }
 
 func (s *AppointmentService) Create(ctx context.Context, in CreateAppointmentInput) (domain.Appointment, error) {
+	appt, loc, err := s.newAppointment(ctx, in)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if err := s.checkSlot(ctx, appt, loc); err != nil {
+		return domain.Appointment{}, err
+	}
+	notice, err := s.checkBookingPolicy(ctx, in.PatientID, in.DoctorID, in.DepositAmount)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	created, err := s.persistAppointment(ctx, appt, loc, true)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	created.BookingNotice = notice
+	return created, nil
+}
+
+// newAppointment validates the input and builds the appointment (not yet
+// persisted). loc is the clinic timezone for human-friendly messages.
+func (s *AppointmentService) newAppointment(ctx context.Context, in CreateAppointmentInput) (domain.Appointment, *time.Location, error) {
 	if in.DoctorID == "" || in.PatientID == "" || in.StartAt == "" {
-		return domain.Appointment{}, fmt.Errorf("doctorId, patientId and startAt are required")
+		return domain.Appointment{}, nil, fmt.Errorf("doctorId, patientId and startAt are required")
+	}
+	// Un doctor restringido solo agenda en su agenda y a sus pacientes.
+	if err := checkDoctor(ctx, in.DoctorID); err != nil {
+		return domain.Appointment{}, nil, err
+	}
+	if err := s.access.checkPatient(ctx, in.PatientID); err != nil {
+		return domain.Appointment{}, nil, err
 	}
 	startAt, err := time.Parse(time.RFC3339, in.StartAt)
 	if err != nil {
-		return domain.Appointment{}, fmt.Errorf("invalid startAt")
+		return domain.Appointment{}, nil, fmt.Errorf("invalid startAt")
+	}
+	if in.AppointmentTypeID != "" {
+		t, err := s.appointmentType(ctx, in.DoctorID, in.AppointmentTypeID)
+		if err != nil {
+			return domain.Appointment{}, nil, err
+		}
+		if in.DurationMinutes <= 0 {
+			in.DurationMinutes = t.DurationMinutes
+		}
+		if in.PaymentAmount == 0 {
+			in.PaymentAmount = t.DefaultPrice
+		}
+		if strings.TrimSpace(in.Reason) == "" {
+			in.Reason = t.Name
+		}
 	}
 	// Determine duration: prefer DurationMinutes, fallback to EndAt, default 30 min
 	durationMinutes := in.DurationMinutes
@@ -117,61 +223,68 @@ func (s *AppointmentService) Create(ctx context.Context, in CreateAppointmentInp
 	if in.EndAt != "" {
 		parsed, err := time.Parse(time.RFC3339, in.EndAt)
 		if err != nil {
-			return domain.Appointment{}, fmt.Errorf("invalid endAt")
+			return domain.Appointment{}, nil, fmt.Errorf("invalid endAt")
 		}
 		endAt = parsed
 		durationMinutes = int(endAt.Sub(startAt).Minutes())
 	} else {
 		endAt = startAt.Add(time.Duration(durationMinutes) * time.Minute)
 	}
-	// Resolve clinic timezone for human-friendly messages
-	loc := time.Local
-	if tz := os.Getenv("CLINIC_TZ"); tz != "" {
-		if l, lerr := time.LoadLocation(tz); lerr == nil {
-			loc = l
-		}
-	}
-
-	// Validate for overlapping appointments
-	existingAppointments, err := s.repo.ListByDoctorAndDay(ctx, in.DoctorID, startAt)
+	// Resolve the org timezone for human-friendly messages
+	loc := s.doctorLocation(ctx, in.DoctorID)
+	resourceIDs, err := s.resolveResources(ctx, in.DoctorID, in.ResourceIDs)
 	if err != nil {
-		return domain.Appointment{}, fmt.Errorf("could not verify existing appointments: %w", err)
-	}
-
-	for _, existing := range existingAppointments {
-		if existing.Status == "cancelled" {
-			continue
-		}
-		if startAt.Before(existing.EndAt) && endAt.After(existing.StartAt) {
-			return domain.Appointment{}, fmt.Errorf("el horario de %s a %s ya está ocupado", startAt.In(loc).Format("15:04"), endAt.In(loc).Format("15:04"))
-		}
+		return domain.Appointment{}, nil, err
 	}
 
 	confirmToken, _ := generateAppointmentToken()
-	appt := domain.Appointment{
-		ID:              buildID("apt"),
-		DoctorID:        in.DoctorID,
-		PatientID:       in.PatientID,
-		StartAt:         startAt.UTC(),
-		EndAt:           endAt.UTC(),
-		DurationMinutes: durationMinutes,
-		Status:          "scheduled",
-		TreatmentPlan:   in.TreatmentPlan,
-		PaymentAmount:   in.PaymentAmount,
-		PaymentMethod:   in.PaymentMethod,
-		Reason:          in.Reason,
-		ConfirmToken:    confirmToken,
+	return domain.Appointment{
+		ID:                buildID("apt"),
+		DoctorID:          in.DoctorID,
+		PatientID:         in.PatientID,
+		StartAt:           startAt.UTC(),
+		EndAt:             endAt.UTC(),
+		DurationMinutes:   durationMinutes,
+		Status:            "scheduled",
+		TreatmentPlan:     in.TreatmentPlan,
+		PaymentAmount:     in.PaymentAmount,
+		PaymentMethod:     in.PaymentMethod,
+		Reason:            in.Reason,
+		DepositAmount:     in.DepositAmount,
+		ResourceIDs:       resourceIDs,
+		ConfirmToken:      confirmToken,
+		AppointmentTypeID: in.AppointmentTypeID,
+	}, loc, nil
+}
+
+// checkSlot validates overlap with other appointments (of the doctor and of
+// the resources it holds) and the doctor's availability.
+func (s *AppointmentService) checkSlot(ctx context.Context, appt domain.Appointment, loc *time.Location) error {
+	if err := s.checkOverlap(ctx, appt.DoctorID, appt.ID, appt.StartAt, appt.EndAt, loc); err != nil {
+		return err
 	}
+	if err := s.checkResourceOverlap(ctx, appt, loc); err != nil {
+		return err
+	}
+	return s.checkAvailability(ctx, appt.DoctorID, appt.StartAt, appt.EndAt)
+}
+
+// persistAppointment stores a new appointment and, if notify is set, sends the
+// confirmation email/SMS with the consent links.
+func (s *AppointmentService) persistAppointment(ctx context.Context, appt domain.Appointment, loc *time.Location, notify bool) (domain.Appointment, error) {
 	created, err := s.repo.Create(ctx, appt)
 	if err != nil {
 		return domain.Appointment{}, err
 	}
+	if !notify {
+		return created, nil
+	}
 	if email, name := s.patientEmail(ctx, created.PatientID); email != "" {
 		if s.notifier != nil {
 			ctx, orgID := s.ensureOrgContext(ctx, created.DoctorID)
 			var consentLinks []notifications.ConsentLink
 			if s.consents != nil && orgID != "" {
-				if list, cerr := s.consents.CreateConsentsForAppointment(ctx, created.ID, orgID, created.PatientID, created.DoctorID, email, name, created.StartAt.In(loc)); cerr == nil {
+				if list, cerr := s.consents.CreateConsentsForAppointment(ctx, created.ID, orgID, created.PatientID, created.DoctorID, email, name, created.StartAt.In(loc), s.consentTemplateIDs(ctx, created)); cerr == nil {
 					for _, c := range list {
 						if c.AcceptToken != "" {
 							consentLinks = append(consentLinks, notifications.ConsentLink{Title: c.Title, Token: c.AcceptToken})
@@ -179,71 +292,124 @@ func (s *AppointmentService) Create(ctx context.Context, in CreateAppointmentInp
 					}
 				}
 			}
-			if len(consentLinks) == 0 && orgID != "" {
+			if len(consentLinks) == 0 && orgID != "" && created.AppointmentTypeID == "" {
 				log.Printf("[appointment] create: no consent templates active for org %s — configure plantillas de consentimiento (asistencia + tratamiento)", orgID)
 			}
-			_ = s.notifier.SendAppointmentCreated(ctx, email, name, created, consentLinks)
+			_ = s.notifier.SendAppointmentCreated(ctx, email, name, localized(created, loc), consentLinks)
 			if phone := s.patientPhone(ctx, created.PatientID); phone != "" {
-				_ = s.notifier.SendAppointmentCreatedSMS(ctx, phone, name, created)
+				_ = s.notifier.SendAppointmentCreatedSMS(ctx, phone, name, localized(created, loc))
 			}
 		}
 	}
 	return created, nil
 }
 
+// checkOverlap fails if the doctor already has a non-cancelled appointment
+// overlapping [startAt, endAt). excludeID skips the appointment being moved.
+func (s *AppointmentService) checkOverlap(ctx context.Context, doctorID, excludeID string, startAt, endAt time.Time, loc *time.Location) error {
+	// Se lee desde 24h antes: una cita que empezó el día (UTC) anterior puede
+	// seguir en curso.
+	existingAppointments, err := ListByDoctorRange(ctx, s.repo, doctorID, startAt.Add(-24*time.Hour), endAt)
+	if err != nil {
+		return fmt.Errorf("could not verify existing appointments: %w", err)
+	}
+	for _, existing := range existingAppointments {
+		if existing.Status == "cancelled" || existing.ID == excludeID {
+			continue
+		}
+		if startAt.Before(existing.EndAt) && endAt.After(existing.StartAt) {
+			return fmt.Errorf("el horario de %s a %s ya está ocupado", startAt.In(loc).Format("15:04"), endAt.In(loc).Format("15:04"))
+		}
+	}
+	return nil
+}
+
 func (s *AppointmentService) GetByID(ctx context.Context, id string) (domain.Appointment, error) {
-	return s.repo.GetByID(ctx, id)
+	return s.getAppointment(ctx, id)
 }
 
+// getAppointment loads an appointment the caller may see; every operation
+// on an appointment by ID goes through it.
+func (s *AppointmentService) getAppointment(ctx context.Context, id string) (domain.Appointment, error) {
+	appt, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if err := s.access.checkAppointment(ctx, appt); err != nil {
+		return domain.Appointment{}, err
+	}
+	return appt, nil
+}
+
+// ListByPatient lists the patient's appointments. A restricted doctor sees
+// every appointment of their own patients and only their own appointments
+// of anyone else's.
 func (s *AppointmentService) ListByPatient(ctx context.Context, patientID string) ([]domain.Appointment, error) {
-	return s.repo.ListByPatient(ctx, patientID)
+	items, err := s.repo.ListByPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if visible := s.access.filter(ctx); visible != nil && !visible(patientID) {
+		self, _ := restrictedDoctor(ctx)
+		items = slices.DeleteFunc(items, func(a domain.Appointment) bool { return a.DoctorID != self })
+	}
+	return items, nil
 }
 
+// ListByDoctorAndDate lists the doctor's appointments of a calendar day in the
+// org timezone (today if date is empty).
 func (s *AppointmentService) ListByDoctorAndDate(ctx context.Context, doctorID, date string) ([]domain.Appointment, error) {
-	day := time.Now().UTC()
+	doctorID, err := ownDoctorID(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	loc := s.doctorLocation(ctx, doctorID)
+	day := s.now().In(loc)
 	if date != "" {
-		parsed, err := time.Parse("2006-01-02", date)
+		parsed, err := time.ParseInLocation("2006-01-02", date, loc)
 		if err != nil {
 			return nil, fmt.Errorf("invalid date format use YYYY-MM-DD")
 		}
 		day = parsed
 	}
-	return s.repo.ListByDoctorAndDay(ctx, doctorID, day)
+	from, to := dayBounds(day, loc)
+	return ListByDoctorRange(ctx, s.repo, doctorID, from, to)
 }
 
 func (s *AppointmentService) Confirm(ctx context.Context, appointmentID string) (domain.Appointment, error) {
-	item, err := s.repo.GetByID(ctx, appointmentID)
+	item, err := s.getAppointment(ctx, appointmentID)
 	if err != nil {
 		return domain.Appointment{}, err
 	}
+	if err := ValidateStatusTransition(item.Status, StatusConfirmed); err != nil {
+		return domain.Appointment{}, err
+	}
 	now := time.Now().UTC()
-	item.Status = "confirmed"
+	item.Status = StatusConfirmed
 	item.PatientConfirmedAt = &now
 	updated, err := s.repo.Update(ctx, item)
 	if err != nil {
 		return domain.Appointment{}, err
 	}
 	if s.notifier != nil {
-		loc := time.Local
-		if tz := os.Getenv("CLINIC_TZ"); tz != "" {
-			if l, lerr := time.LoadLocation(tz); lerr == nil {
-				loc = l
-			}
-		}
+		loc := s.doctorLocation(ctx, updated.DoctorID)
 		if email, name := s.patientEmail(ctx, updated.PatientID); email != "" {
-			_ = s.notifier.SendAppointmentEvent(ctx, email, name, "confirmed", updated.StartAt.In(loc), updated.EndAt.In(loc))
+			_ = s.notifier.SendAppointmentEvent(ctx, email, name, "confirmed", localized(updated, loc))
 		}
 	}
 	return updated, nil
 }
 
 func (s *AppointmentService) CloseDayForAppointment(ctx context.Context, appointmentID, evolutionNotes string, paymentAmount float64, paymentMethod, treatmentPlan string) (domain.Appointment, error) {
-	item, err := s.repo.GetByID(ctx, appointmentID)
+	item, err := s.getAppointment(ctx, appointmentID)
 	if err != nil {
 		return domain.Appointment{}, err
 	}
+	if err := ValidateStatusTransition(item.Status, StatusCompleted); err != nil {
+		return domain.Appointment{}, err
+	}
 	now := time.Now().UTC()
-	item.Status = "completed"
+	item.Status = StatusCompleted
 	item.DoctorDailyClosedAt = &now
 	item.EvolutionNotes = evolutionNotes
 	if paymentAmount > 0 {
@@ -261,14 +427,9 @@ func (s *AppointmentService) CloseDayForAppointment(ctx context.Context, appoint
 		return domain.Appointment{}, err
 	}
 	if s.notifier != nil {
-		loc := time.Local
-		if tz := os.Getenv("CLINIC_TZ"); tz != "" {
-			if l, lerr := time.LoadLocation(tz); lerr == nil {
-				loc = l
-			}
-		}
+		loc := s.doctorLocation(ctx, updated.DoctorID)
 		if email, name := s.patientEmail(ctx, updated.PatientID); email != "" {
-			_ = s.notifier.SendAppointmentEvent(ctx, email, name, "completed", updated.StartAt.In(loc), updated.EndAt.In(loc))
+			_ = s.notifier.SendAppointmentEvent(ctx, email, name, "completed", localized(updated, loc))
 			if strings.TrimSpace(updated.TreatmentPlan) != "" {
 				_ = s.notifier.SendTreatmentPlanSummary(ctx, email, name, updated.TreatmentPlan, updated.StartAt.In(loc))
 			}
@@ -277,14 +438,33 @@ func (s *AppointmentService) CloseDayForAppointment(ctx context.Context, appoint
 	return updated, nil
 }
 
+// ErrReminderSent is returned by Send24hReminder when the reminder was already
+// sent, or claimed by a concurrent run.
+var ErrReminderSent = errors.New("reminder already sent")
+
+// Send24hReminder sends the 24h reminder once. The reminder is claimed first
+// with a versioned update of ReminderSentAt, so of two overlapping runs only
+// the one that wins the write notifies the patient.
 func (s *AppointmentService) Send24hReminder(ctx context.Context, appointmentID, channel string) error {
-	item, err := s.repo.GetByID(ctx, appointmentID)
+	item, err := s.getAppointment(ctx, appointmentID)
 	if err != nil {
 		return err
 	}
-	if time.Until(item.StartAt).Hours() > 24.1 || time.Until(item.StartAt).Hours() < 23.9 {
+	if item.ReminderSentAt != nil {
+		return ErrReminderSent
+	}
+	if !In24hReminderWindow(item.StartAt, s.now()) {
 		return fmt.Errorf("appointment is not in 24h window")
 	}
+	now := s.now().UTC()
+	item.ReminderSentAt = &now
+	item, err = s.repo.Update(ctx, item)
+	if errors.Is(err, store.ErrConflict) {
+		return ErrReminderSent
+	}
+	if err != nil {
+		return err
+	}
 	// Mismo email que creación/reenvío: confirmación + todos los consentimientos (asistencia y tratamiento)
 	if s.notifier != nil {
 		email, name := s.patientEmail(ctx, item.PatientID)
@@ -292,7 +472,7 @@ func (s *AppointmentService) Send24hReminder(ctx context.Context, appointmentID,
 			ctx, orgID := s.ensureOrgContext(ctx, item.DoctorID)
 			var consentLinks []notifications.ConsentLink
 			if s.consents != nil && orgID != "" {
-				if list, cerr := s.consents.CreateConsentsForAppointment(ctx, item.ID, orgID, item.PatientID, item.DoctorID, email, name, item.StartAt); cerr == nil {
+				if list, cerr := s.consents.CreateConsentsForAppointment(ctx, item.ID, orgID, item.PatientID, item.DoctorID, email, name, item.StartAt.In(s.doctorLocation(ctx, item.DoctorID)), s.consentTemplateIDs(ctx, item)); cerr == nil {
 					for _, c := range list {
 						if c.AcceptToken != "" {
 							consentLinks = append(consentLinks, notifications.ConsentLink{Title: c.Title, Token: c.AcceptToken})
@@ -300,19 +480,17 @@ func (s *AppointmentService) Send24hReminder(ctx context.Context, appointmentID,
 					}
 				}
 			}
-			if len(consentLinks) == 0 && orgID != "" {
+			if len(consentLinks) == 0 && orgID != "" && item.AppointmentTypeID == "" {
 				log.Printf("[appointment] 24h reminder: no consent templates active for org %s", orgID)
 			}
-			_ = s.notifier.SendAppointmentCreated(ctx, email, name, item, consentLinks)
+			local := localized(item, s.doctorLocation(ctx, item.DoctorID))
+			_ = s.notifier.SendAppointmentCreated(ctx, email, name, local, consentLinks)
 			if phone := s.patientPhone(ctx, item.PatientID); phone != "" {
-				_ = s.notifier.SendAppointmentCreatedSMS(ctx, phone, name, item)
+				_ = s.notifier.SendAppointmentCreatedSMS(ctx, phone, name, local)
 			}
 		}
 	}
-	now := time.Now().UTC()
-	item.ReminderSentAt = &now
-	_, err = s.repo.Update(ctx, item)
-	return err
+	return nil
 }
 
 func (s *AppointmentService) SendDoctorCloseDayReminder(ctx context.Context, doctorID, channel string) error {
@@ -331,21 +509,29 @@ type UpdateAppointmentInput struct {
 	PaymentMethod string   `json:"paymentMethod"`
 	ImageKeys     []string `json:"imageKeys"`
 	Reason        string   `json:"reason"`
+	// ResourceIDs reemplaza los recursos de la cita; nil los deja igual y [] los libera.
+	ResourceIDs []string `json:"resourceIds"`
+	// AppointmentTypeID cambia el tipo (no recalcula duración ni precio).
+	AppointmentTypeID string `json:"appointmentTypeId"`
+	// Version es la que leyó el cliente (o If-Match); 0 no comprueba.
+	Version int `json:"version,omitempty"`
 }
 
 func (s *AppointmentService) UpdateAppointment(ctx context.Context, id string, in UpdateAppointmentInput) (domain.Appointment, error) {
-	appt, err := s.repo.GetByID(ctx, id)
+	return s.updateAppointment(ctx, id, in, true)
+}
+
+func (s *AppointmentService) updateAppointment(ctx context.Context, id string, in UpdateAppointmentInput, notify bool) (domain.Appointment, error) {
+	appt, err := s.getAppointment(ctx, id)
 	if err != nil {
 		return domain.Appointment{}, err
 	}
-	loc := time.Local
-	if tz := os.Getenv("CLINIC_TZ"); tz != "" {
-		if l, lerr := time.LoadLocation(tz); lerr == nil {
-			loc = l
-		}
+	if err := checkClientVersion(appt.Version, in.Version); err != nil {
+		return domain.Appointment{}, err
 	}
+	loc := s.doctorLocation(ctx, appt.DoctorID)
 	prevStatus := appt.Status
-	prevStart := appt.StartAt
+	prevStart, prevEnd := appt.StartAt, appt.EndAt
 	if in.StartAt != "" {
 		t, err := time.Parse(time.RFC3339, in.StartAt)
 		if err != nil {
@@ -360,9 +546,35 @@ func (s *AppointmentService) UpdateAppointment(ctx context.Context, id string, i
 		}
 		appt.EndAt = t.UTC()
 	}
+	moved := (in.StartAt != "" || in.EndAt != "") && (!appt.StartAt.Equal(prevStart) || !appt.EndAt.Equal(prevEnd))
+	if moved {
+		if err := s.checkAvailability(ctx, appt.DoctorID, appt.StartAt, appt.EndAt); err != nil {
+			return domain.Appointment{}, err
+		}
+	}
+	if in.ResourceIDs != nil {
+		if appt.ResourceIDs, err = s.resolveResources(ctx, appt.DoctorID, in.ResourceIDs); err != nil {
+			return domain.Appointment{}, err
+		}
+	}
+	if (moved || in.ResourceIDs != nil) && in.Status != StatusCancelled {
+		if err := s.checkResourceOverlap(ctx, appt, loc); err != nil {
+			return domain.Appointment{}, err
+		}
+	}
 	if in.Status != "" {
+		if err := ValidateStatusTransition(appt.Status, in.Status); err != nil {
+			return domain.Appointment{}, err
+		}
 		appt.Status = in.Status
 	}
+	now := s.now().UTC()
+	if appt.Status == StatusCancelled && prevStatus != StatusCancelled && appt.CancelledAt == nil {
+		appt.CancelledAt = &now
+	}
+	if appt.Status == StatusNoShow && prevStatus != StatusNoShow {
+		appt.NoShowAt = &now
+	}
 	if in.TreatmentPlan != "" {
 		appt.TreatmentPlan = in.TreatmentPlan
 	}
@@ -378,11 +590,27 @@ func (s *AppointmentService) UpdateAppointment(ctx context.Context, id string, i
 	if in.Reason != "" {
 		appt.Reason = in.Reason
 	}
+	if in.AppointmentTypeID != "" && in.AppointmentTypeID != appt.AppointmentTypeID {
+		if _, err := s.appointmentType(ctx, appt.DoctorID, in.AppointmentTypeID); err != nil {
+			return domain.Appointment{}, err
+		}
+		appt.AppointmentTypeID = in.AppointmentTypeID
+	}
 	updated, err := s.repo.Update(ctx, appt)
 	if err != nil {
 		return domain.Appointment{}, err
 	}
-	if s.notifier != nil {
+	if updated.Status == StatusCancelled && prevStatus != StatusCancelled {
+		s.offerFreedSlot(ctx, updated)
+	}
+	if updated.Status == StatusNoShow && prevStatus != StatusNoShow {
+		s.recordNoShow(ctx, updated.PatientID, 1)
+		return updated, nil // sin aviso al paciente
+	}
+	if prevStatus == StatusNoShow && updated.Status != StatusNoShow {
+		s.recordNoShow(ctx, updated.PatientID, -1)
+	}
+	if notify && s.notifier != nil {
 		if email, name := s.patientEmail(ctx, updated.PatientID); email != "" {
 			eventType := "updated"
 			if in.Status == "cancelled" && prevStatus != "cancelled" {
@@ -390,17 +618,120 @@ func (s *AppointmentService) UpdateAppointment(ctx context.Context, id string, i
 			} else if in.StartAt != "" && !updated.StartAt.Equal(prevStart) {
 				eventType = "moved"
 			}
-			_ = s.notifier.SendAppointmentEvent(ctx, email, name, eventType, updated.StartAt.In(loc), updated.EndAt.In(loc))
+			_ = s.notifier.SendAppointmentEvent(ctx, email, name, eventType, localized(updated, loc))
+		}
+	}
+	return updated, nil
+}
+
+type RescheduleAppointmentInput struct {
+	StartAt         string `json:"startAt"`
+	EndAt           string `json:"endAt"`
+	DurationMinutes int    `json:"durationMinutes"`
+	Reason          string `json:"reason"`
+	RescheduledBy   string `json:"-"` // usuario autenticado, lo fija el handler
+	Version         int    `json:"version,omitempty"`
+}
+
+// Reschedule moves an appointment to a new slot. Unlike UpdateAppointment it
+// validates overlap, records the previous slot in RescheduleHistory, issues a
+// new ConfirmToken (the patient must confirm again) and notifies the patient
+// with both the old and the new time.
+func (s *AppointmentService) Reschedule(ctx context.Context, id string, in RescheduleAppointmentInput) (domain.Appointment, error) {
+	if in.StartAt == "" {
+		return domain.Appointment{}, fmt.Errorf("startAt is required")
+	}
+	appt, err := s.getAppointment(ctx, id)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if err := checkClientVersion(appt.Version, in.Version); err != nil {
+		return domain.Appointment{}, err
+	}
+	if !IsOpenStatus(appt.Status) {
+		return domain.Appointment{}, fmt.Errorf("no se puede reprogramar una cita en estado %s", appt.Status)
+	}
+	startAt, err := time.Parse(time.RFC3339, in.StartAt)
+	if err != nil {
+		return domain.Appointment{}, fmt.Errorf("invalid startAt")
+	}
+	// Duración: DurationMinutes, luego EndAt, y si no la de la cita actual.
+	duration := appt.EndAt.Sub(appt.StartAt)
+	if appt.DurationMinutes > 0 {
+		duration = time.Duration(appt.DurationMinutes) * time.Minute
+	}
+	endAt := startAt.Add(duration)
+	if in.DurationMinutes > 0 {
+		endAt = startAt.Add(time.Duration(in.DurationMinutes) * time.Minute)
+	} else if in.EndAt != "" {
+		parsed, err := time.Parse(time.RFC3339, in.EndAt)
+		if err != nil {
+			return domain.Appointment{}, fmt.Errorf("invalid endAt")
+		}
+		endAt = parsed
+	}
+	if !endAt.After(startAt) {
+		return domain.Appointment{}, fmt.Errorf("endAt must be after startAt")
+	}
+	if startAt.Equal(appt.StartAt) && endAt.Equal(appt.EndAt) {
+		return domain.Appointment{}, fmt.Errorf("la cita ya está en ese horario")
+	}
+	loc := s.doctorLocation(ctx, appt.DoctorID)
+	if err := s.checkOverlap(ctx, appt.DoctorID, appt.ID, startAt, endAt, loc); err != nil {
+		return domain.Appointment{}, err
+	}
+	moved := appt
+	moved.StartAt, moved.EndAt = startAt.UTC(), endAt.UTC()
+	if err := s.checkResourceOverlap(ctx, moved, loc); err != nil {
+		return domain.Appointment{}, err
+	}
+	if err := s.checkAvailability(ctx, appt.DoctorID, startAt, endAt); err != nil {
+		return domain.Appointment{}, err
+	}
+
+	prevStart, prevEnd := appt.StartAt, appt.EndAt
+	appt.RescheduleHistory = append(appt.RescheduleHistory, domain.RescheduleEntry{
+		PreviousStartAt: prevStart,
+		PreviousEndAt:   prevEnd,
+		NewStartAt:      startAt.UTC(),
+		NewEndAt:        endAt.UTC(),
+		RescheduledBy:   in.RescheduledBy,
+		Reason:          strings.TrimSpace(in.Reason),
+		RescheduledAt:   s.now().UTC(),
+	})
+	appt.StartAt = startAt.UTC()
+	appt.EndAt = endAt.UTC()
+	appt.DurationMinutes = int(endAt.Sub(startAt).Minutes())
+	appt.Status = StatusScheduled
+	appt.PatientConfirmedAt = nil
+	appt.ReminderSentAt = nil // el recordatorio de 24h vuelve a aplicar al nuevo horario
+	appt.ConfirmToken, _ = generateAppointmentToken()
+
+	updated, err := s.repo.Update(ctx, appt)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if s.notifier != nil {
+		if email, name := s.patientEmail(ctx, updated.PatientID); email != "" {
+			_ = s.notifier.SendAppointmentRescheduled(ctx, email, name, updated.ConfirmToken,
+				prevStart.In(loc), prevEnd.In(loc), updated.StartAt.In(loc), updated.EndAt.In(loc))
 		}
 	}
 	return updated, nil
 }
 
 func (s *AppointmentService) Delete(ctx context.Context, id string) error {
-	if _, err := s.repo.GetByID(ctx, id); err != nil {
+	appt, err := s.getAppointment(ctx, id)
+	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, id)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	if IsOpenStatus(appt.Status) {
+		s.offerFreedSlot(ctx, appt)
+	}
+	return nil
 }
 
 type RegisterPaymentInput struct {
@@ -410,7 +741,10 @@ type RegisterPaymentInput struct {
 }
 
 func (s *AppointmentService) RegisterPayment(ctx context.Context, id string, in RegisterPaymentInput) (domain.Appointment, error) {
-	appt, err := s.repo.GetByID(ctx, id)
+	appt, err := s.getAppointment(ctx, id)
+	if errors.Is(err, ErrAccessDenied) {
+		return domain.Appointment{}, err
+	}
 	if err != nil {
 		return domain.Appointment{}, fmt.Errorf("appointment not found")
 	}
@@ -421,10 +755,19 @@ func (s *AppointmentService) RegisterPayment(ctx context.Context, id string, in
 	if in.PaymentAmount > 0 {
 		appt.PaymentAmount = in.PaymentAmount
 	}
-	if in.Paid {
-		appt.Status = "completed"
+	prevStatus := appt.Status
+	if in.Paid && ValidateStatusTransition(appt.Status, StatusCompleted) == nil {
+		appt.Status = StatusCompleted
 	}
-	return s.repo.Update(ctx, appt)
+	updated, err := s.repo.Update(ctx, appt)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	// Pagar una cita marcada como inasistencia la corrige, como en updateAppointment.
+	if prevStatus == StatusNoShow && updated.Status != StatusNoShow {
+		s.recordNoShow(ctx, updated.PatientID, -1)
+	}
+	return updated, nil
 }
 
 func (s *AppointmentService) ConfirmByToken(ctx context.Context, token string) (domain.Appointment, error) {
@@ -432,11 +775,14 @@ func (s *AppointmentService) ConfirmByToken(ctx context.Context, token string) (
 	if err != nil {
 		return domain.Appointment{}, fmt.Errorf("enlace inválido o expirado")
 	}
-	if appt.Status == "confirmed" || appt.Status == "completed" {
+	if appt.Status == StatusConfirmed || appt.Status == StatusCompleted {
 		return appt, nil // idempotent
 	}
+	if ValidateStatusTransition(appt.Status, StatusConfirmed) != nil {
+		return domain.Appointment{}, fmt.Errorf("la cita ya no puede confirmarse (estado %s)", appt.Status)
+	}
 	now := time.Now().UTC()
-	appt.Status = "confirmed"
+	appt.Status = StatusConfirmed
 	appt.PatientConfirmedAt = &now
 	return s.repo.Update(ctx, appt)
 }
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// Appointment statuses.
+const (
+	StatusScheduled = "scheduled"
+	StatusConfirmed = "confirmed"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+	StatusNoShow    = "no_show"
+)
+
+// appointmentTransitions lists the allowed status changes. completed and
+// cancelled are final; a no_show can still be closed as completed when the
+// doctor closes the agenda late. confirmed → scheduled happens on reschedule.
+var appointmentTransitions = map[string][]string{
+	StatusScheduled: {StatusConfirmed, StatusCompleted, StatusCancelled, StatusNoShow},
+	StatusConfirmed: {StatusScheduled, StatusCompleted, StatusCancelled, StatusNoShow},
+	StatusCompleted: {},
+	StatusCancelled: {},
+	StatusNoShow:    {StatusCompleted},
+}
+
+// ValidateStatusTransition rejects unknown statuses and illegal moves. Setting
+// the current status again is a no-op and always allowed. Legacy appointments
+// without status are treated as scheduled.
+func ValidateStatusTransition(from, to string) error {
+	if from == "" {
+		from = StatusScheduled
+	}
+	if _, ok := appointmentTransitions[to]; !ok {
+		return fmt.Errorf("invalid status %q (scheduled, confirmed, completed, cancelled, no_show)", to)
+	}
+	if from == to {
+		return nil
+	}
+	for _, next := range appointmentTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("no se puede pasar una cita de %s a %s", from, to)
+}
+
+// IsOpenStatus reports whether the appointment is still pending (not closed,
+// cancelled or marked as no-show).
+func IsOpenStatus(status string) bool {
+	return status == "" || status == StatusScheduled || status == StatusConfirmed
+}
+
+// MarkNoShow flags a past appointment the patient did not attend. Used by the
+// end-of-day job; the patient is not notified.
+func (s *AppointmentService) MarkNoShow(ctx context.Context, id string) (domain.Appointment, error) {
+	appt, err := s.getAppointment(ctx, id)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if appt.EndAt.After(s.now()) {
+		return domain.Appointment{}, fmt.Errorf("la cita aún no ha terminado")
+	}
+	return s.updateAppointment(ctx, id, UpdateAppointmentInput{Status: StatusNoShow}, false)
+}
+
+// recordNoShow keeps the patient's no-show counter in sync with status
+// changes: +1 when an appointment becomes no_show, -1 when it is corrected.
+// The patient may be edited concurrently, so a version conflict is retried.
+func (s *AppointmentService) recordNoShow(ctx context.Context, patientID string, delta int) {
+	if s.patientRepo == nil || delta == 0 {
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		p, err := s.patientRepo.GetByID(ctx, patientID)
+		if err != nil {
+			log.Printf("[appointment] no-show counter: patient %s: %v", patientID, err)
+			return
+		}
+		p.NoShowCount += delta
+		if p.NoShowCount < 0 {
+			p.NoShowCount = 0
+		}
+		if delta > 0 {
+			now := s.now().UTC()
+			p.LastNoShowAt = &now
+		}
+		_, err = s.patientRepo.Update(ctx, p)
+		if errors.Is(err, store.ErrConflict) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			log.Printf("[appointment] no-show counter: update patient %s: %v", patientID, err)
+		}
+		return
+	}
+}
+
+// orgNoShowPolicy returns the policy of the doctor's org (nil if none). An
+// org that does not exist has no policy; any other read error is returned so
+// a store outage does not skip the deposit.
+func (s *AppointmentService) orgNoShowPolicy(ctx context.Context, doctorID string) (*domain.NoShowPolicy, error) {
+	if s.authRepo == nil {
+		return nil, nil
+	}
+	ctx, orgID := s.ensureOrgContext(ctx, doctorID)
+	if orgID == "" {
+		return nil, nil
+	}
+	org, err := s.authRepo.GetOrganization(ctx, orgID)
+	if err != nil {
+		if err.Error() == "organization not found" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("no-show policy of org %s: %w", orgID, err)
+	}
+	return org.NoShowPolicy, nil
+}
+
+// BookingPolicy tells how the org no-show policy applies to the patient, so
+// the front desk can warn or ask for the deposit before booking.
+func (s *AppointmentService) BookingPolicy(ctx context.Context, patientID, doctorID string) (domain.BookingNotice, error) {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return domain.BookingNotice{}, err
+	}
+	if s.patientRepo == nil {
+		return domain.BookingNotice{Action: "none"}, nil
+	}
+	p, err := s.patientRepo.GetByID(ctx, patientID)
+	if err != nil {
+		return domain.BookingNotice{}, fmt.Errorf("patient not found")
+	}
+	if doctorID == "" {
+		doctorID = p.DoctorID
+	}
+	notice := domain.BookingNotice{NoShowCount: p.NoShowCount, Action: "none"}
+	policy, err := s.orgNoShowPolicy(ctx, doctorID)
+	if err != nil {
+		return domain.BookingNotice{}, err
+	}
+	if policy == nil {
+		return notice, nil
+	}
+	switch {
+	case policy.DepositAfter > 0 && p.NoShowCount >= policy.DepositAfter:
+		notice.Action = "deposit"
+		notice.DepositAmount = policy.DepositAmount
+		notice.Message = fmt.Sprintf("El paciente tiene %d inasistencias: se requiere un depósito de %.2f para agendar.", p.NoShowCount, policy.DepositAmount)
+	case policy.WarnAfter > 0 && p.NoShowCount >= policy.WarnAfter:
+		notice.Action = "warn"
+		notice.Message = fmt.Sprintf("El paciente tiene %d inasistencias.", p.NoShowCount)
+	}
+	return notice, nil
+}
+
+// checkBookingPolicy applies BookingPolicy to a new booking: a required
+// deposit must be covered by depositAmount. Returns the notice to attach to
+// the response (nil when the policy does not apply). A policy that cannot be
+// read fails the booking rather than skip a required deposit.
+func (s *AppointmentService) checkBookingPolicy(ctx context.Context, patientID, doctorID string, depositAmount float64) (*domain.BookingNotice, error) {
+	notice, err := s.BookingPolicy(ctx, patientID, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if notice.Action == "none" {
+		return nil, nil
+	}
+	if notice.Action == "deposit" && depositAmount < notice.DepositAmount {
+		return nil, fmt.Errorf("%s", notice.Message)
+	}
+	return &notice, nil
+}
+
+// GetNoShowPolicy returns the org's no-show policy (zero value if unset).
+func (s *AppointmentService) GetNoShowPolicy(ctx context.Context, orgID string) (domain.NoShowPolicy, error) {
+	if s.authRepo == nil {
+		return domain.NoShowPolicy{}, fmt.Errorf("organization not found")
+	}
+	org, err := s.authRepo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return domain.NoShowPolicy{}, fmt.Errorf("organization not found")
+	}
+	if org.NoShowPolicy == nil {
+		return domain.NoShowPolicy{}, nil
+	}
+	return *org.NoShowPolicy, nil
+}
+
+// SetNoShowPolicy replaces the org's no-show policy.
+func (s *AppointmentService) SetNoShowPolicy(ctx context.Context, orgID string, p domain.NoShowPolicy) (domain.NoShowPolicy, error) {
+	if s.authRepo == nil || strings.TrimSpace(orgID) == "" {
+		return domain.NoShowPolicy{}, fmt.Errorf("organization not found")
+	}
+	if p.WarnAfter < 0 || p.DepositAfter < 0 || p.DepositAmount < 0 {
+		return domain.NoShowPolicy{}, fmt.Errorf("policy values must not be negative")
+	}
+	if p.DepositAfter > 0 && p.DepositAmount <= 0 {
+		return domain.NoShowPolicy{}, fmt.Errorf("depositAmount is required when depositAfter is set")
+	}
+	org, err := s.authRepo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return domain.NoShowPolicy{}, fmt.Errorf("organization not found")
+	}
+	org.NoShowPolicy = &p
+	now := s.now().UTC()
+	org.UpdatedAt = &now
+	if _, err := s.authRepo.UpdateOrganization(ctx, org); err != nil {
+		return domain.NoShowPolicy{}, err
+	}
+	return p, nil
+}
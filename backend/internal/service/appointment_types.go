@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+var appointmentTypeColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// WithAppointmentTypeRepo enables the org's catalog of appointment types.
+func WithAppointmentTypeRepo(r store.AppointmentTypeRepository) func(*AppointmentService) {
+	return func(s *AppointmentService) { s.typeRepo = r }
+}
+
+// AppointmentTypeInput creates or updates a catalog entry. On update, zero
+// values keep the current value; ConsentTemplateIDs (if not nil) replaces the
+// required consents and [] clears them.
+type AppointmentTypeInput struct {
+	Name               string   `json:"name"`
+	DurationMinutes    int      `json:"durationMinutes"`
+	Color              string   `json:"color"`
+	DefaultPrice       *float64 `json:"defaultPrice,omitempty"`
+	ConsentTemplateIDs []string `json:"consentTemplateIds"`
+	Active             *bool    `json:"active,omitempty"`
+}
+
+// ListAppointmentTypes returns the org's catalog sorted by name.
+func (s *AppointmentService) ListAppointmentTypes(ctx context.Context, orgID string) ([]domain.AppointmentType, error) {
+	if s.typeRepo == nil {
+		return nil, fmt.Errorf("appointment types not configured")
+	}
+	items, err := s.typeRepo.ListByOrg(store.ContextWithOrgID(ctx, orgID), orgID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+// CreateAppointmentType adds an entry to the org's catalog.
+func (s *AppointmentService) CreateAppointmentType(ctx context.Context, orgID string, in AppointmentTypeInput) (domain.AppointmentType, error) {
+	if s.typeRepo == nil {
+		return domain.AppointmentType{}, fmt.Errorf("appointment types not configured")
+	}
+	if strings.TrimSpace(orgID) == "" {
+		return domain.AppointmentType{}, fmt.Errorf("organization not found")
+	}
+	now := s.now().UTC()
+	t := domain.AppointmentType{
+		ID:                 buildID("atype"),
+		OrgID:              orgID,
+		ConsentTemplateIDs: []string{},
+		Active:             true,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if in.ConsentTemplateIDs == nil {
+		in.ConsentTemplateIDs = []string{}
+	}
+	if err := s.applyAppointmentTypeInput(ctx, &t, in); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	return s.typeRepo.Create(store.ContextWithOrgID(ctx, orgID), t)
+}
+
+// UpdateAppointmentType edits a catalog entry. Existing appointments keep
+// their duration and price; new consents only apply to future notifications.
+func (s *AppointmentService) UpdateAppointmentType(ctx context.Context, orgID, id string, in AppointmentTypeInput) (domain.AppointmentType, error) {
+	t, err := s.orgAppointmentType(ctx, orgID, id)
+	if err != nil {
+		return domain.AppointmentType{}, err
+	}
+	if err := s.applyAppointmentTypeInput(ctx, &t, in); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	t.UpdatedAt = s.now().UTC()
+	return s.typeRepo.Update(store.ContextWithOrgID(ctx, orgID), t)
+}
+
+// DeleteAppointmentType removes a catalog entry. Appointments of that type
+// fall back to every active consent template.
+func (s *AppointmentService) DeleteAppointmentType(ctx context.Context, orgID, id string) error {
+	if _, err := s.orgAppointmentType(ctx, orgID, id); err != nil {
+		return err
+	}
+	return s.typeRepo.Delete(store.ContextWithOrgID(ctx, orgID), id)
+}
+
+func (s *AppointmentService) applyAppointmentTypeInput(ctx context.Context, t *domain.AppointmentType, in AppointmentTypeInput) error {
+	if name := strings.TrimSpace(in.Name); name != "" {
+		t.Name = name
+	}
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if in.DurationMinutes < 0 {
+		return fmt.Errorf("durationMinutes must not be negative")
+	}
+	if in.DurationMinutes > 0 {
+		t.DurationMinutes = in.DurationMinutes
+	}
+	if t.DurationMinutes == 0 {
+		t.DurationMinutes = 30
+	}
+	if color := strings.TrimSpace(in.Color); color != "" {
+		if !appointmentTypeColor.MatchString(color) {
+			return fmt.Errorf("invalid color %q (use #RRGGBB)", in.Color)
+		}
+		t.Color = strings.ToLower(color)
+	}
+	if in.DefaultPrice != nil {
+		if *in.DefaultPrice < 0 {
+			return fmt.Errorf("defaultPrice must not be negative")
+		}
+		t.DefaultPrice = *in.DefaultPrice
+	}
+	if in.ConsentTemplateIDs != nil {
+		ids, err := s.validConsentTemplates(ctx, t.OrgID, in.ConsentTemplateIDs)
+		if err != nil {
+			return err
+		}
+		t.ConsentTemplateIDs = ids
+	}
+	if in.Active != nil {
+		t.Active = *in.Active
+	}
+	return nil
+}
+
+// validConsentTemplates checks that every template exists in the org.
+// Duplicates are dropped; the result is never nil.
+func (s *AppointmentService) validConsentTemplates(ctx context.Context, orgID string, ids []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if s.consents == nil {
+			return nil, fmt.Errorf("consent templates not configured")
+		}
+		tmpl, err := s.consents.GetTemplate(store.ContextWithOrgID(ctx, orgID), id)
+		if err != nil || tmpl.OrgID != orgID {
+			return nil, fmt.Errorf("consent template %s not found", id)
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+func (s *AppointmentService) orgAppointmentType(ctx context.Context, orgID, id string) (domain.AppointmentType, error) {
+	if s.typeRepo == nil {
+		return domain.AppointmentType{}, fmt.Errorf("appointment types not configured")
+	}
+	t, err := s.typeRepo.GetByID(store.ContextWithOrgID(ctx, orgID), id)
+	if err != nil || t.OrgID != orgID {
+		return domain.AppointmentType{}, fmt.Errorf("appointment type not found")
+	}
+	return t, nil
+}
+
+// appointmentType resolves the active catalog entry used to book an
+// appointment with doctorID.
+func (s *AppointmentService) appointmentType(ctx context.Context, doctorID, id string) (domain.AppointmentType, error) {
+	if s.typeRepo == nil {
+		return domain.AppointmentType{}, fmt.Errorf("appointment types not configured")
+	}
+	ctx, orgID := s.ensureOrgContext(ctx, doctorID)
+	t, err := s.typeRepo.GetByID(ctx, id)
+	if err != nil || (orgID != "" && t.OrgID != orgID) {
+		return domain.AppointmentType{}, fmt.Errorf("appointment type %s not found", id)
+	}
+	if !t.Active {
+		return domain.AppointmentType{}, fmt.Errorf("el tipo de cita %s no está activo", t.Name)
+	}
+	return t, nil
+}
+
+// consentTemplateIDs returns the consents to send for appt: those of its type,
+// or nil (every active template) for untyped appointments or if the type no
+// longer exists.
+func (s *AppointmentService) consentTemplateIDs(ctx context.Context, appt domain.Appointment) []string {
+	if appt.AppointmentTypeID == "" || s.typeRepo == nil {
+		return nil
+	}
+	t, err := s.typeRepo.GetByID(ctx, appt.AppointmentTypeID)
+	if err != nil {
+		log.Printf("[appointment] type %s of %s not found, sending every active consent", appt.AppointmentTypeID, appt.ID)
+		return nil
+	}
+	if t.ConsentTemplateIDs == nil {
+		return []string{}
+	}
+	return t.ConsentTemplateIDs
+}
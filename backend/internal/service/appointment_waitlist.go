@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// Waitlist entry statuses.
+const (
+	WaitlistWaiting = "waiting"
+	WaitlistOffered = "offered"
+	WaitlistBooked  = "booked"
+)
+
+func WithWaitlistRepo(r store.WaitlistRepository) func(*AppointmentService) {
+	return func(s *AppointmentService) { s.waitlistRepo = r }
+}
+
+// WithWaitlistPolicy sets how long an offer stays open and how many waitlisted
+// patients receive each freed slot (the first to accept books it).
+func WithWaitlistPolicy(offerTTL time.Duration, maxOffers int) func(*AppointmentService) {
+	return func(s *AppointmentService) {
+		s.waitlistOfferTTL = offerTTL
+		s.waitlistMaxOffers = maxOffers
+	}
+}
+
+type WaitlistInput struct {
+	PatientID     string         `json:"patientId"`
+	DoctorID      string         `json:"doctorId"`
+	PreferredDays []time.Weekday `json:"preferredDays"`
+	PreferredFrom string         `json:"preferredFrom"`
+	PreferredTo   string         `json:"preferredTo"`
+	Urgency       string         `json:"urgency"`
+	Notes         string         `json:"notes"`
+}
+
+var waitlistUrgencyRank = map[string]int{"high": 0, "normal": 1, "low": 2}
+
+func (s *AppointmentService) AddToWaitlist(ctx context.Context, in WaitlistInput) (domain.WaitlistEntry, error) {
+	if s.waitlistRepo == nil {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist not configured")
+	}
+	if in.PatientID == "" || in.DoctorID == "" {
+		return domain.WaitlistEntry{}, fmt.Errorf("patientId and doctorId are required")
+	}
+	if err := checkDoctor(ctx, in.DoctorID); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if err := s.access.checkPatient(ctx, in.PatientID); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if s.patientRepo != nil {
+		if _, err := s.patientRepo.GetByID(ctx, in.PatientID); err != nil {
+			return domain.WaitlistEntry{}, fmt.Errorf("patient not found")
+		}
+	}
+	for _, d := range in.PreferredDays {
+		if d < time.Sunday || d > time.Saturday {
+			return domain.WaitlistEntry{}, fmt.Errorf("invalid weekday %d", d)
+		}
+	}
+	from, to := -1, -1
+	var err error
+	if in.PreferredFrom != "" {
+		if from, err = parseClock(in.PreferredFrom); err != nil {
+			return domain.WaitlistEntry{}, err
+		}
+	}
+	if in.PreferredTo != "" {
+		if to, err = parseClock(in.PreferredTo); err != nil {
+			return domain.WaitlistEntry{}, err
+		}
+	}
+	if from >= 0 && to >= 0 && to <= from {
+		return domain.WaitlistEntry{}, fmt.Errorf("preferredTo must be after preferredFrom")
+	}
+	urgency := strings.ToLower(strings.TrimSpace(in.Urgency))
+	if urgency == "" {
+		urgency = "normal"
+	}
+	if _, ok := waitlistUrgencyRank[urgency]; !ok {
+		return domain.WaitlistEntry{}, fmt.Errorf("invalid urgency %q (low, normal, high)", in.Urgency)
+	}
+
+	ctx, orgID := s.ensureOrgContext(ctx, in.DoctorID)
+	now := s.now().UTC()
+	return s.waitlistRepo.Create(ctx, domain.WaitlistEntry{
+		ID:            buildID("wl"),
+		OrgID:         orgID,
+		PatientID:     in.PatientID,
+		DoctorID:      in.DoctorID,
+		PreferredDays: in.PreferredDays,
+		PreferredFrom: strings.TrimSpace(in.PreferredFrom),
+		PreferredTo:   strings.TrimSpace(in.PreferredTo),
+		Urgency:       urgency,
+		Notes:         strings.TrimSpace(in.Notes),
+		Status:        WaitlistWaiting,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+}
+
+// ListWaitlist returns the doctor's waitlist by priority: urgency, then
+// oldest first.
+func (s *AppointmentService) ListWaitlist(ctx context.Context, doctorID string) ([]domain.WaitlistEntry, error) {
+	if s.waitlistRepo == nil {
+		return nil, fmt.Errorf("waitlist not configured")
+	}
+	if doctorID == "" {
+		return nil, fmt.Errorf("doctorId is required")
+	}
+	if err := checkDoctor(ctx, doctorID); err != nil {
+		return nil, err
+	}
+	items, err := s.waitlistRepo.ListByDoctor(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	sortWaitlist(items)
+	return items, nil
+}
+
+func (s *AppointmentService) RemoveFromWaitlist(ctx context.Context, id string) error {
+	if s.waitlistRepo == nil {
+		return fmt.Errorf("waitlist not configured")
+	}
+	e, err := s.waitlistRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkDoctor(ctx, e.DoctorID); err != nil {
+		return err
+	}
+	return s.waitlistRepo.Delete(ctx, id)
+}
+
+func sortWaitlist(items []domain.WaitlistEntry) {
+	sort.SliceStable(items, func(i, j int) bool {
+		ri, rj := waitlistUrgencyRank[items[i].Urgency], waitlistUrgencyRank[items[j].Urgency]
+		if ri != rj {
+			return ri < rj
+		}
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+}
+
+// waitlistOpen reports whether the entry can receive a new offer: waiting, or
+// holding an offer that already expired.
+func waitlistOpen(e domain.WaitlistEntry, now time.Time) bool {
+	switch e.Status {
+	case WaitlistWaiting:
+		return true
+	case WaitlistOffered:
+		return e.Offer == nil || !now.Before(e.Offer.ExpiresAt)
+	}
+	return false
+}
+
+// waitlistMatches checks the patient's preferred days and time window.
+func waitlistMatches(e domain.WaitlistEntry, start, end time.Time, loc *time.Location) bool {
+	ls, le := start.In(loc), end.In(loc)
+	if len(e.PreferredDays) > 0 {
+		ok := false
+		for _, d := range e.PreferredDays {
+			if d == ls.Weekday() {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if e.PreferredFrom != "" {
+		if from, err := parseClock(e.PreferredFrom); err == nil && ls.Hour()*60+ls.Minute() < from {
+			return false
+		}
+	}
+	if e.PreferredTo != "" {
+		if to, err := parseClock(e.PreferredTo); err == nil && (le.Day() != ls.Day() || le.Hour()*60+le.Minute() > to) {
+			return false
+		}
+	}
+	return true
+}
+
+// offerFreedSlot offers the slot of a cancelled or deleted appointment to the
+// matching waitlisted patients. Errors are only logged: the cancellation
+// itself already succeeded.
+func (s *AppointmentService) offerFreedSlot(ctx context.Context, freed domain.Appointment) {
+	now := s.now()
+	if s.waitlistRepo == nil || s.waitlistMaxOffers <= 0 || !freed.StartAt.After(now) {
+		return
+	}
+	entries, err := s.waitlistRepo.ListByDoctor(ctx, freed.DoctorID)
+	if err != nil {
+		log.Printf("[waitlist] list for doctor %s: %v", freed.DoctorID, err)
+		return
+	}
+	loc := s.doctorLocation(ctx, freed.DoctorID)
+	var candidates []domain.WaitlistEntry
+	for _, e := range entries {
+		if e.PatientID != freed.PatientID && waitlistOpen(e, now) && waitlistMatches(e, freed.StartAt, freed.EndAt, loc) {
+			candidates = append(candidates, e)
+		}
+	}
+	sortWaitlist(candidates)
+	if len(candidates) > s.waitlistMaxOffers {
+		candidates = candidates[:s.waitlistMaxOffers]
+	}
+	for _, e := range candidates {
+		token, err := generateAppointmentToken()
+		if err != nil {
+			log.Printf("[waitlist] token: %v", err)
+			return
+		}
+		e.Status = WaitlistOffered
+		e.Offer = &domain.WaitlistOffer{
+			Token:              token,
+			StartAt:            freed.StartAt,
+			EndAt:              freed.EndAt,
+			FreedAppointmentID: freed.ID,
+			OfferedAt:          now.UTC(),
+			ExpiresAt:          now.Add(s.waitlistOfferTTL).UTC(),
+		}
+		e.UpdatedAt = now.UTC()
+		if _, err := s.waitlistRepo.Update(ctx, e); err != nil {
+			log.Printf("[waitlist] offer to entry %s: %v", e.ID, err)
+			continue
+		}
+		if s.notifier != nil {
+			email, name := s.patientEmail(ctx, e.PatientID)
+			if email != "" {
+				_ = s.notifier.SendWaitlistOffer(ctx, email, name, token, freed.StartAt.In(loc), freed.EndAt.In(loc), e.Offer.ExpiresAt.In(loc))
+			}
+			if phone := s.patientPhone(ctx, e.PatientID); phone != "" {
+				_ = s.notifier.SendWaitlistOfferSMS(ctx, phone, name, token, freed.StartAt.In(loc), e.Offer.ExpiresAt.In(loc))
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		log.Printf("[waitlist] slot %s of doctor %s (appointment %s) offered to %d patient(s)", freed.StartAt.Format(time.RFC3339), freed.DoctorID, freed.ID, len(candidates))
+	}
+}
+
+// WaitlistOfferView is what the patient sees through the offer link.
+type WaitlistOfferView struct {
+	StartAt    time.Time `json:"startAt"`
+	EndAt      time.Time `json:"endAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	DoctorName string    `json:"doctorName,omitempty"`
+	Timezone   string    `json:"timezone"`
+	Available  bool      `json:"available"`
+}
+
+func (s *AppointmentService) waitlistOfferByToken(ctx context.Context, token string) (context.Context, domain.WaitlistEntry, error) {
+	if s.waitlistRepo == nil {
+		return ctx, domain.WaitlistEntry{}, fmt.Errorf("waitlist not configured")
+	}
+	e, err := s.waitlistRepo.GetByOfferToken(ctx, strings.TrimSpace(token))
+	if err != nil || e.Offer == nil {
+		return ctx, domain.WaitlistEntry{}, fmt.Errorf("enlace inválido o expirado")
+	}
+	if e.OrgID != "" {
+		ctx = store.ContextWithOrgID(ctx, e.OrgID)
+	}
+	return ctx, e, nil
+}
+
+func (s *AppointmentService) GetWaitlistOffer(ctx context.Context, token string) (WaitlistOfferView, error) {
+	ctx, e, err := s.waitlistOfferByToken(ctx, token)
+	if err != nil {
+		return WaitlistOfferView{}, err
+	}
+	loc := s.doctorLocation(ctx, e.DoctorID)
+	view := WaitlistOfferView{
+		StartAt:   e.Offer.StartAt.In(loc),
+		EndAt:     e.Offer.EndAt.In(loc),
+		ExpiresAt: e.Offer.ExpiresAt.In(loc),
+		Timezone:  loc.String(),
+		Available: e.Status == WaitlistOffered && s.now().Before(e.Offer.ExpiresAt),
+	}
+	if s.authRepo != nil {
+		if u, err := s.authRepo.GetUserByID(ctx, e.DoctorID); err == nil {
+			view.DoctorName = u.Name
+		}
+	}
+	return view, nil
+}
+
+// AcceptWaitlistOffer books the offered slot for the patient. Offers are
+// first-come: once one patient books the slot, the other offers for it are
+// withdrawn and those patients go back to waiting.
+func (s *AppointmentService) AcceptWaitlistOffer(ctx context.Context, token string) (domain.Appointment, error) {
+	ctx, e, err := s.waitlistOfferByToken(ctx, token)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if e.Status != WaitlistOffered {
+		return domain.Appointment{}, fmt.Errorf("la oferta ya no está disponible")
+	}
+	if !s.now().Before(e.Offer.ExpiresAt) {
+		s.withdrawOffer(ctx, e)
+		return domain.Appointment{}, fmt.Errorf("la oferta expiró")
+	}
+	offer := *e.Offer
+	appt, err := s.Create(ctx, CreateAppointmentInput{
+		DoctorID:  e.DoctorID,
+		PatientID: e.PatientID,
+		StartAt:   offer.StartAt.Format(time.RFC3339),
+		EndAt:     offer.EndAt.Format(time.RFC3339),
+		Reason:    "Lista de espera",
+	})
+	if err != nil {
+		s.withdrawOffer(ctx, e)
+		return domain.Appointment{}, fmt.Errorf("el horario ya fue tomado por otro paciente")
+	}
+	e.Status = WaitlistBooked
+	e.AppointmentID = appt.ID
+	e.UpdatedAt = s.now().UTC()
+	if _, err := s.waitlistRepo.Update(ctx, e); err != nil {
+		log.Printf("[waitlist] mark entry %s booked: %v", e.ID, err)
+	}
+	log.Printf("[audit] waitlist entry %s accepted offer: appointment %s for patient %s", e.ID, appt.ID, e.PatientID)
+
+	if others, err := s.waitlistRepo.ListByDoctor(ctx, e.DoctorID); err == nil {
+		for _, o := range others {
+			if o.ID != e.ID && o.Status == WaitlistOffered && o.Offer != nil && o.Offer.FreedAppointmentID == offer.FreedAppointmentID {
+				s.withdrawOffer(ctx, o)
+			}
+		}
+	}
+	return appt, nil
+}
+
+// withdrawOffer puts the entry back on the waitlist.
+func (s *AppointmentService) withdrawOffer(ctx context.Context, e domain.WaitlistEntry) {
+	e.Status = WaitlistWaiting
+	e.Offer = nil
+	e.UpdatedAt = s.now().UTC()
+	if _, err := s.waitlistRepo.Update(ctx, e); err != nil {
+		log.Printf("[waitlist] withdraw offer of entry %s: %v", e.ID, err)
+	}
+}
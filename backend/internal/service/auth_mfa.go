@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/store"
+)
+
+// MFA (TOTP) del personal. Con MFA activo, o si la org lo exige, el login con
+// password correcto no abre sesión: devuelve un mfaToken de un solo uso que
+// se canjea en /auth/mfa/verify junto con un código de la app autenticadora
+// o un código de recuperación.
+
+const (
+	mfaIssuer            = "Clinical"
+	mfaChallengeTTL      = 5 * time.Minute
+	mfaChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+// isStaffRole: todos los roles de la org salvo patient, incluidos los propios
+// (recepción, facturación...).
+func isStaffRole(role string) bool {
+	role = normalizeRole(role)
+	return role != "" && role != "patient" && role != "platform_admin"
+}
+
+// mfaRequired reports whether the user's org makes MFA mandatory for them.
+func (s *AuthService) mfaRequired(ctx context.Context, user store.AuthUser) bool {
+	if !isStaffRole(user.Role) || user.OrgID == "" {
+		return false
+	}
+	org, err := s.repo.GetOrganization(ctx, user.OrgID)
+	return err == nil && org.RequireMFA
+}
+
+// completeLogin runs after the password (or invitation) checked out: it
+// opens the session, or asks for the second factor first.
+func (s *AuthService) completeLogin(ctx context.Context, user store.AuthUser, userAgent, ip string) (LoginOutput, error) {
+	enroll := false
+	if user.MFA.Secret == "" {
+		if !s.mfaRequired(ctx, user) {
+			return s.openSession(ctx, user, userAgent, ip)
+		}
+		enroll = true
+	}
+	secret, err := randomToken(24)
+	if err != nil {
+		return LoginOutput{}, err
+	}
+	mfa := user.MFA
+	mfa.ChallengeHash = hashSecret(secret)
+	mfa.ChallengeExpiresAt = time.Now().UTC().Add(mfaChallengeTTL)
+	if err := s.repo.SetUserMFA(ctx, user.ID, mfa); err != nil {
+		return LoginOutput{}, err
+	}
+	return LoginOutput{
+		MFARequired:           true,
+		MFAEnrollmentRequired: enroll,
+		MFAToken:              user.ID + "." + secret,
+		UserID:                user.ID,
+		OrgID:                 user.OrgID,
+		Email:                 user.Email,
+	}, nil
+}
+
+// challengeUser resolves an mfaToken to its user. A wrong token counts as a
+// failed login of the user, and a challenge that already used up its
+// mfaChallengeAttempts wrong codes is rejected even if it has not been
+// dropped yet (concurrent attempts).
+func (s *AuthService) challengeUser(ctx context.Context, mfaToken, ip string) (store.AuthUser, error) {
+	userID, secret, ok := strings.Cut(strings.TrimSpace(mfaToken), ".")
+	if !ok {
+		return store.AuthUser{}, fmt.Errorf("invalid mfa token")
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil || user.MFA.ChallengeHash == "" {
+		return store.AuthUser{}, fmt.Errorf("invalid mfa token")
+	}
+	if time.Now().UTC().After(user.MFA.ChallengeExpiresAt) {
+		return store.AuthUser{}, fmt.Errorf("mfa token expired")
+	}
+	if subtle.ConstantTimeCompare([]byte(user.MFA.ChallengeHash), []byte(hashSecret(secret))) != 1 {
+		s.loginFailed(ctx, SecurityMFAFailed, user.Email, ip, &user)
+		return store.AuthUser{}, fmt.Errorf("invalid mfa token")
+	}
+	if t, ok := s.getThrottle(ctx, mfaChallengeKey(user.MFA.ChallengeHash)); ok && t.Failures >= mfaChallengeAttempts {
+		return store.AuthUser{}, fmt.Errorf("too many invalid codes, log in again")
+	}
+	if strings.ToLower(strings.TrimSpace(user.Status)) == "disabled" {
+		return store.AuthUser{}, fmt.Errorf("user disabled")
+	}
+	return user, nil
+}
+
+// mfaChallengeKey is the throttle key that counts the wrong codes of one
+// login challenge. RecordAuthFailure is atomic, so parallel guesses with the
+// same mfaToken cannot get past mfaChallengeAttempts.
+func mfaChallengeKey(challengeHash string) string { return "mfa:challenge:" + challengeHash }
+
+// failChallenge records a wrong code against the login challenge. It also
+// counts as a failed login, so the lockout covers the second factor too.
+func (s *AuthService) failChallenge(ctx context.Context, user store.AuthUser, ip string) error {
+	s.loginFailed(ctx, SecurityMFAFailed, user.Email, ip, &user)
+	t, err := s.repo.RecordAuthFailure(ctx, mfaChallengeKey(user.MFA.ChallengeHash), time.Now().UTC(), mfaChallengeTTL)
+	if err != nil {
+		return err
+	}
+	if t.Failures < mfaChallengeAttempts {
+		return fmt.Errorf("invalid code")
+	}
+	log.Printf("[auth] mfa: too many invalid codes for user %s, challenge dropped", user.ID)
+	mfa := user.MFA
+	mfa.ChallengeHash, mfa.ChallengeExpiresAt = "", time.Time{}
+	if err := s.repo.SetUserMFA(ctx, user.ID, mfa); err != nil {
+		return err
+	}
+	return fmt.Errorf("too many invalid codes, log in again")
+}
+
+type MFAEnrollmentOutput struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"` // para el código QR
+}
+
+// StartMFAEnrollment generates a new TOTP secret for the user. MFA stays off
+// until ConfirmMFAEnrollment receives a code from it.
+func (s *AuthService) StartMFAEnrollment(ctx context.Context, userID string) (MFAEnrollmentOutput, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return MFAEnrollmentOutput{}, fmt.Errorf("user not found")
+	}
+	return s.startEnrollment(ctx, user)
+}
+
+// StartMFAEnrollmentForLogin is StartMFAEnrollment for a user whose org
+// requires MFA and who has no session yet (mfaEnrollmentRequired).
+func (s *AuthService) StartMFAEnrollmentForLogin(ctx context.Context, mfaToken, ip string) (MFAEnrollmentOutput, error) {
+	user, err := s.challengeUser(ctx, mfaToken, ip)
+	if err != nil {
+		return MFAEnrollmentOutput{}, err
+	}
+	return s.startEnrollment(ctx, user)
+}
+
+func (s *AuthService) startEnrollment(ctx context.Context, user store.AuthUser) (MFAEnrollmentOutput, error) {
+	if user.MFA.Secret != "" {
+		return MFAEnrollmentOutput{}, fmt.Errorf("mfa already enabled")
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return MFAEnrollmentOutput{}, err
+	}
+	mfa := user.MFA
+	mfa.PendingSecret = secret
+	if err := s.repo.SetUserMFA(ctx, user.ID, mfa); err != nil {
+		return MFAEnrollmentOutput{}, err
+	}
+	return MFAEnrollmentOutput{Secret: secret, OTPAuthURI: totpURI(mfaIssuer, user.Email, secret)}, nil
+}
+
+// enableMFA turns MFA on with the pending secret if code comes from it, and
+// returns the new recovery codes.
+func enableMFA(mfa *store.UserMFA, code string, now time.Time) ([]string, error) {
+	if mfa.PendingSecret == "" {
+		return nil, fmt.Errorf("mfa enrollment not started")
+	}
+	step, ok := verifyTOTP(mfa.PendingSecret, code, now, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid code")
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa.Secret, mfa.PendingSecret = mfa.PendingSecret, ""
+	mfa.EnabledAt = now
+	mfa.LastStep = step
+	mfa.RecoveryCodes = hashes
+	return codes, nil
+}
+
+type MFACodeInput struct {
+	Code         string `json:"code"`         // de la app autenticadora
+	RecoveryCode string `json:"recoveryCode"` // alternativa a code; cada uno vale una vez
+}
+
+type MFARecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recoveryCodes"` // se muestran una sola vez
+}
+
+// ConfirmMFAEnrollment turns MFA on once the user proves the authenticator
+// app has the secret from StartMFAEnrollment.
+func (s *AuthService) ConfirmMFAEnrollment(ctx context.Context, userID string, in MFACodeInput) (MFARecoveryCodesOutput, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return MFARecoveryCodesOutput{}, fmt.Errorf("user not found")
+	}
+	if user.MFA.Secret != "" {
+		return MFARecoveryCodesOutput{}, fmt.Errorf("mfa already enabled")
+	}
+	mfa := user.MFA
+	codes, err := enableMFA(&mfa, in.Code, time.Now().UTC())
+	if err != nil {
+		return MFARecoveryCodesOutput{}, err
+	}
+	if err := s.repo.SetUserMFA(ctx, userID, mfa); err != nil {
+		return MFARecoveryCodesOutput{}, err
+	}
+	return MFARecoveryCodesOutput{RecoveryCodes: codes}, nil
+}
+
+type VerifyMFAInput struct {
+	MFAToken     string `json:"mfaToken"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+	UserAgent    string `json:"-"` // los fija el handler
+	IP           string `json:"-"`
+}
+
+// VerifyMFA completes a login that returned mfaRequired. For a user who had
+// to enroll (mfaEnrollmentRequired) the code must come from the secret of
+// StartMFAEnrollmentForLogin; MFA is then turned on and the recovery codes
+// come back with the session.
+func (s *AuthService) VerifyMFA(ctx context.Context, in VerifyMFAInput) (LoginOutput, error) {
+	user, err := s.challengeUser(ctx, in.MFAToken, in.IP)
+	if err != nil {
+		return LoginOutput{}, err
+	}
+	if err := s.checkLogin(ctx, user.Email, in.IP, false); err != nil {
+		return LoginOutput{}, err
+	}
+	now := time.Now().UTC()
+	mfa := user.MFA
+	var recoveryCodes []string
+	if mfa.Secret == "" {
+		if recoveryCodes, err = enableMFA(&mfa, in.Code, now); err != nil {
+			if mfa.PendingSecret == "" {
+				return LoginOutput{}, err
+			}
+			return LoginOutput{}, s.failChallenge(ctx, user, in.IP)
+		}
+	} else if err := checkMFACode(&mfa, in.Code, in.RecoveryCode, now); err != nil {
+		return LoginOutput{}, s.failChallenge(ctx, user, in.IP)
+	}
+	mfa.ChallengeHash, mfa.ChallengeExpiresAt = "", time.Time{}
+	if err := s.repo.SetUserMFA(ctx, user.ID, mfa); err != nil {
+		return LoginOutput{}, err
+	}
+	s.clearLoginFailures(ctx, user.Email)
+	if err := s.repo.DeleteAuthThrottle(ctx, mfaChallengeKey(user.MFA.ChallengeHash)); err != nil {
+		log.Printf("[auth] throttle: could not clear mfa challenge of user %s: %v", user.ID, err)
+	}
+	out, err := s.openSession(ctx, user, in.UserAgent, in.IP)
+	if err != nil {
+		return LoginOutput{}, err
+	}
+	out.RecoveryCodes = recoveryCodes
+	return out, nil
+}
+
+// checkMFACode accepts a TOTP code or an unused recovery code and updates
+// mfa so neither can be used again.
+func checkMFACode(mfa *store.UserMFA, code, recoveryCode string, now time.Time) error {
+	if mfa.Secret == "" {
+		return fmt.Errorf("mfa not enabled")
+	}
+	if strings.TrimSpace(recoveryCode) != "" {
+		hash := hashSecret(normalizeRecoveryCode(recoveryCode))
+		for i, h := range mfa.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+				mfa.RecoveryCodes = append(append([]string(nil), mfa.RecoveryCodes[:i]...), mfa.RecoveryCodes[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid code")
+	}
+	step, ok := verifyTOTP(mfa.Secret, code, now, mfa.LastStep)
+	if !ok {
+		return fmt.Errorf("invalid code")
+	}
+	mfa.LastStep = step
+	return nil
+}
+
+// verifyUserMFA checks a code of a logged-in user and saves what it used up.
+func (s *AuthService) verifyUserMFA(ctx context.Context, user *store.AuthUser, in MFACodeInput) error {
+	if err := checkMFACode(&user.MFA, in.Code, in.RecoveryCode, time.Now().UTC()); err != nil {
+		return err
+	}
+	return s.repo.SetUserMFA(ctx, user.ID, user.MFA)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes; the old ones
+// stop working.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID string, in MFACodeInput) (MFARecoveryCodesOutput, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return MFARecoveryCodesOutput{}, fmt.Errorf("user not found")
+	}
+	if err := s.verifyUserMFA(ctx, &user, in); err != nil {
+		return MFARecoveryCodesOutput{}, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return MFARecoveryCodesOutput{}, err
+	}
+	user.MFA.RecoveryCodes = hashes
+	if err := s.repo.SetUserMFA(ctx, userID, user.MFA); err != nil {
+		return MFARecoveryCodesOutput{}, err
+	}
+	return MFARecoveryCodesOutput{RecoveryCodes: codes}, nil
+}
+
+// DisableMFA turns MFA off for the user, who must confirm with a code. Not
+// allowed while the org requires MFA.
+func (s *AuthService) DisableMFA(ctx context.Context, userID string, in MFACodeInput) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if s.mfaRequired(ctx, user) {
+		return fmt.Errorf("mfa is required by the organization")
+	}
+	if err := s.verifyUserMFA(ctx, &user, in); err != nil {
+		return err
+	}
+	return s.repo.SetUserMFA(ctx, userID, store.UserMFA{})
+}
+
+// ResetOrgUserMFA is the admin reset for a user who lost their phone and
+// recovery codes: MFA is turned off, their sessions are closed and their
+// failed logins forgotten (they usually arrive locked out by wrong codes). If
+// the org requires MFA the user enrolls again at the next login.
+func (s *AuthService) ResetOrgUserMFA(ctx context.Context, orgID, userID string) error {
+	if err := checkCallerOrg(ctx, orgID); err != nil {
+		return err
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if user.OrgID != orgID {
+		return fmt.Errorf("user does not belong to org")
+	}
+	if err := s.repo.SetUserMFA(ctx, userID, store.UserMFA{}); err != nil {
+		return err
+	}
+	log.Printf("[auth] mfa reset for user %s (org %s)", userID, orgID)
+	s.clearLoginFailures(ctx, user.Email)
+	if _, err := s.RevokeUserSessions(ctx, userID); err != nil {
+		return fmt.Errorf("mfa reset but sessions not revoked: %w", err)
+	}
+	return nil
+}
+
+type MFAPolicyInput struct {
+	RequireMFA bool `json:"requireMfa"`
+}
+
+// SetOrgMFAPolicy turns the MFA requirement for the org's staff on or off.
+// Staff without MFA enroll at their next login; open sessions are kept.
+func (s *AuthService) SetOrgMFAPolicy(ctx context.Context, orgID string, in MFAPolicyInput) (MFAPolicyInput, error) {
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return MFAPolicyInput{}, fmt.Errorf("organization not found")
+	}
+	org.RequireMFA = in.RequireMFA
+	if _, err := s.repo.UpdateOrganization(ctx, org); err != nil {
+		return MFAPolicyInput{}, err
+	}
+	return MFAPolicyInput{RequireMFA: org.RequireMFA}, nil
+}
+
+// GetOrgMFAPolicy returns whether the org requires MFA for its staff.
+func (s *AuthService) GetOrgMFAPolicy(ctx context.Context, orgID string) (MFAPolicyInput, error) {
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return MFAPolicyInput{}, fmt.Errorf("organization not found")
+	}
+	return MFAPolicyInput{RequireMFA: org.RequireMFA}, nil
+}
+
+// newRecoveryCodes returns the codes to show (xxxxx-xxxxx) and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw, err := randomToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashSecret(raw)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"clinical-backend/internal/store"
+)
+
+// Roles y permisos por organización. Cada org parte de la matriz
+// predeterminada (admin, doctor, assistant, patient) y puede ajustar los
+// permisos de doctor, assistant y patient o crear roles propios
+// (recepción, higienista, facturación...). En Organization.Roles solo se
+// guardan los roles creados o modificados; el resto sigue la matriz
+// predeterminada. admin tiene siempre todos los permisos de la org, así un
+// cambio de matriz no puede dejar a la clínica sin nadie que la administre.
+
+// Permisos que se pueden asignar a un rol.
+const (
+	PermUsersManage            = "users.manage"
+	PermPatientsView           = "patients.view"
+	PermPatientsWrite          = "patients.write"
+	PermPatientsDelete         = "patients.delete"
+	PermAppointmentsWrite      = "appointments.write"
+	PermAppointmentsDelete     = "appointments.delete"
+	PermTreatmentsManage       = "treatments.manage"
+	PermFinancesView           = "finances.view"
+	PermFinancesManage         = "finances.manage"
+	PermConsentTemplatesManage = "consent_templates.manage"
+	PermChatUse                = "chat.use"
+)
+
+// PermPlatformManage is only granted to platform_admin; orgs cannot assign it.
+const PermPlatformManage = "platform.manage"
+
+// orgCacheTTL bounds how long another instance keeps using a matrix or an
+// access policy after an admin changed it (this instance drops its copy
+// right away).
+const orgCacheTTL = 30 * time.Second
+
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// permissionCatalog lists the assignable permissions in display order.
+var permissionCatalog = []PermissionInfo{
+	{PermUsersManage, "Administrar usuarios, invitaciones, roles y políticas de la organización"},
+	{PermPatientsView, "Ver pacientes"},
+	{PermPatientsWrite, "Registrar y editar pacientes, enviar consentimientos"},
+	{PermPatientsDelete, "Eliminar pacientes"},
+	{PermAppointmentsWrite, "Agendar, editar, confirmar y cancelar citas; lista de espera"},
+	{PermAppointmentsDelete, "Eliminar citas"},
+	{PermTreatmentsManage, "Odontogramas, planes de tratamiento, cierre de consulta y agenda del doctor"},
+	{PermFinancesView, "Ver pagos y presupuestos de un paciente"},
+	{PermFinancesManage, "Listar y registrar pagos; crear, editar y eliminar presupuestos"},
+	{PermConsentTemplatesManage, "Administrar plantillas de consentimiento"},
+	{PermChatUse, "Usar el asistente Docco"},
+}
+
+// builtInRoles is the default matrix.
+var builtInRoles = []store.OrgRole{
+	{Name: "admin", Label: "Administrador", Permissions: allPermissions()},
+	{Name: "doctor", Label: "Doctor", Permissions: []string{
+		PermPatientsView, PermPatientsWrite, PermAppointmentsWrite, PermTreatmentsManage,
+		PermFinancesView, PermFinancesManage, PermChatUse,
+	}},
+	{Name: "assistant", Label: "Asistente", Permissions: []string{
+		PermPatientsView, PermAppointmentsWrite, PermFinancesView, PermChatUse,
+	}},
+	{Name: "patient", Label: "Paciente", Permissions: []string{}},
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,39}$`)
+
+func allPermissions() []string {
+	out := make([]string, len(permissionCatalog))
+	for i, p := range permissionCatalog {
+		out[i] = p.Name
+	}
+	return out
+}
+
+func builtInRole(name string) (store.OrgRole, bool) {
+	for _, r := range builtInRoles {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return store.OrgRole{}, false
+}
+
+func normalizeRole(role string) string {
+	return strings.ToLower(strings.TrimSpace(role))
+}
+
+// effectiveRoles merges the org's roles over the default matrix.
+func effectiveRoles(org store.Organization) []store.OrgRole {
+	out := make([]store.OrgRole, 0, len(builtInRoles)+len(org.Roles))
+	for _, r := range builtInRoles {
+		if r.Name != "admin" {
+			if i := slices.IndexFunc(org.Roles, func(o store.OrgRole) bool { return o.Name == r.Name }); i >= 0 {
+				r = org.Roles[i]
+			}
+		}
+		out = append(out, r)
+	}
+	for _, r := range org.Roles {
+		if _, ok := builtInRole(r.Name); !ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// IsDoctorRole reports whether users with role keep an agenda of their own:
+// the doctor role and any other role with treatments.manage. admin is not a
+// doctor even though it has every permission; it manages the whole org.
+func IsDoctorRole(org store.Organization, role string) bool {
+	return doctorRole(effectiveRoles(org), role)
+}
+
+// IsDoctor is IsDoctorRole for user, with the org's cached matrix.
+func (s *AuthService) IsDoctor(ctx context.Context, user store.AuthUser) bool {
+	return doctorRole(s.orgRoles(ctx, user.OrgID), user.Role)
+}
+
+func doctorRole(roles []store.OrgRole, role string) bool {
+	role = normalizeRole(role)
+	switch role {
+	case "doctor":
+		return true
+	case "admin", "platform_admin", "patient":
+		return false
+	}
+	for _, r := range roles {
+		if r.Name == role {
+			return slices.Contains(r.Permissions, PermTreatmentsManage)
+		}
+	}
+	return false
+}
+
+// orgCache keeps, per org, what every authenticated request needs: the
+// effective role matrix and the access policy.
+type orgCache struct {
+	mu    sync.Mutex
+	byOrg map[string]cachedOrg
+}
+
+type cachedOrg struct {
+	roles           []store.OrgRole
+	ownPatientsOnly bool
+	expires         time.Time
+}
+
+// orgSettings returns the cached settings of orgID. An org that cannot be
+// loaded (users outside any org, store errors) gets the default matrix and
+// no access restriction.
+func (s *AuthService) orgSettings(ctx context.Context, orgID string) cachedOrg {
+	now := time.Now()
+	s.orgs.mu.Lock()
+	c, ok := s.orgs.byOrg[orgID]
+	s.orgs.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c
+	}
+	// ctx puede venir de antes de autenticar (sin org): con aislamiento
+	// estricto la lectura necesita la org que se consulta.
+	org, err := s.repo.GetOrganization(store.ContextWithOrgID(ctx, orgID), orgID)
+	if err != nil {
+		return cachedOrg{roles: builtInRoles}
+	}
+	c = cachedOrg{roles: effectiveRoles(org), ownPatientsOnly: org.DoctorsOwnPatientsOnly, expires: now.Add(orgCacheTTL)}
+	s.orgs.mu.Lock()
+	s.orgs.byOrg[orgID] = c
+	s.orgs.mu.Unlock()
+	return c
+}
+
+// orgRoles returns the effective matrix of orgID.
+func (s *AuthService) orgRoles(ctx context.Context, orgID string) []store.OrgRole {
+	return s.orgSettings(ctx, orgID).roles
+}
+
+func (s *AuthService) forgetOrg(orgID string) {
+	s.orgs.mu.Lock()
+	delete(s.orgs.byOrg, orgID)
+	s.orgs.mu.Unlock()
+}
+
+// HasPermission evaluates perm for user against the matrix of their org.
+// platform_admin has every permission; a role the org does not define has
+// none.
+func (s *AuthService) HasPermission(ctx context.Context, user store.AuthUser, perm string) bool {
+	role := normalizeRole(user.Role)
+	switch {
+	case role == "platform_admin":
+		return true
+	case perm == PermPlatformManage:
+		return false
+	case role == "admin":
+		return true
+	}
+	for _, r := range s.orgRoles(ctx, user.OrgID) {
+		if r.Name == role {
+			return slices.Contains(r.Permissions, perm)
+		}
+	}
+	return false
+}
+
+// UserPermissions lists the permissions of user, for the frontend to show
+// only what they can use.
+func (s *AuthService) UserPermissions(ctx context.Context, user store.AuthUser) []string {
+	out := []string{}
+	for _, p := range permissionCatalog {
+		if s.HasPermission(ctx, user, p.Name) {
+			out = append(out, p.Name)
+		}
+	}
+	return out
+}
+
+// roleExists reports whether role can be assigned to users of orgID.
+func (s *AuthService) roleExists(ctx context.Context, orgID, role string) bool {
+	return slices.ContainsFunc(s.orgRoles(ctx, orgID), func(r store.OrgRole) bool { return r.Name == role })
+}
+
+// Permissions returns the catalog of assignable permissions.
+func (s *AuthService) Permissions() []PermissionInfo {
+	return slices.Clone(permissionCatalog)
+}
+
+type RoleDTO struct {
+	Name        string   `json:"name"`
+	Label       string   `json:"label,omitempty"`
+	Permissions []string `json:"permissions"`
+	BuiltIn     bool     `json:"builtIn"`
+	Customized  bool     `json:"customized"` // rol predeterminado con permisos propios de la org
+	Editable    bool     `json:"editable"`   // admin no se edita
+}
+
+type RoleInput struct {
+	Label       string   `json:"label"`
+	Permissions []string `json:"permissions"`
+}
+
+// ListOrgRoles returns the effective roles of the org: the default ones
+// first, then the org's own.
+func (s *AuthService) ListOrgRoles(ctx context.Context, orgID string) ([]RoleDTO, error) {
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("organization not found")
+	}
+	out := []RoleDTO{}
+	for _, r := range effectiveRoles(org) {
+		_, builtIn := builtInRole(r.Name)
+		out = append(out, RoleDTO{
+			Name:        r.Name,
+			Label:       r.Label,
+			Permissions: r.Permissions,
+			BuiltIn:     builtIn,
+			Customized:  builtIn && slices.ContainsFunc(org.Roles, func(o store.OrgRole) bool { return o.Name == r.Name }),
+			Editable:    r.Name != "admin",
+		})
+	}
+	return out, nil
+}
+
+// SaveOrgRole creates a role of the org or replaces its permissions. For a
+// default role it overrides the default matrix until DeleteOrgRole.
+func (s *AuthService) SaveOrgRole(ctx context.Context, orgID, name string, in RoleInput) (RoleDTO, error) {
+	name = normalizeRole(name)
+	if name == "admin" || name == "platform_admin" {
+		return RoleDTO{}, fmt.Errorf("role %s cannot be changed", name)
+	}
+	if !roleNamePattern.MatchString(name) {
+		return RoleDTO{}, fmt.Errorf("invalid role name: use 2-40 lowercase letters, digits, - or _")
+	}
+	perms := []string{}
+	for _, p := range in.Permissions {
+		p = strings.TrimSpace(p)
+		if !slices.ContainsFunc(permissionCatalog, func(c PermissionInfo) bool { return c.Name == p }) {
+			return RoleDTO{}, fmt.Errorf("unknown permission %q", p)
+		}
+		if !slices.Contains(perms, p) {
+			perms = append(perms, p)
+		}
+	}
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return RoleDTO{}, fmt.Errorf("organization not found")
+	}
+	role := store.OrgRole{Name: name, Label: strings.TrimSpace(in.Label), Permissions: perms}
+	builtIn, isBuiltIn := builtInRole(name)
+	if role.Label == "" && isBuiltIn {
+		role.Label = builtIn.Label
+	}
+	roles := slices.Clone(org.Roles)
+	if i := slices.IndexFunc(roles, func(r store.OrgRole) bool { return r.Name == name }); i >= 0 {
+		roles[i] = role
+	} else {
+		roles = append(roles, role)
+	}
+	if err := s.saveRoles(ctx, org, roles); err != nil {
+		return RoleDTO{}, err
+	}
+	log.Printf("[auth] org %s: role %s saved with permissions %v", orgID, name, perms)
+	return RoleDTO{Name: role.Name, Label: role.Label, Permissions: role.Permissions, BuiltIn: isBuiltIn, Customized: isBuiltIn, Editable: true}, nil
+}
+
+// DeleteOrgRole removes a role of the org, which must have no users. For a
+// default role it drops the org's changes and restores the default matrix.
+func (s *AuthService) DeleteOrgRole(ctx context.Context, orgID, name string) error {
+	name = normalizeRole(name)
+	if name == "admin" || name == "platform_admin" {
+		return fmt.Errorf("role %s cannot be changed", name)
+	}
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("organization not found")
+	}
+	i := slices.IndexFunc(org.Roles, func(r store.OrgRole) bool { return r.Name == name })
+	if i < 0 {
+		if _, ok := builtInRole(name); ok {
+			return nil // ya usa la matriz predeterminada
+		}
+		return fmt.Errorf("role not found")
+	}
+	if _, ok := builtInRole(name); !ok {
+		users, err := s.repo.ListUsersByOrg(ctx, orgID)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			if u.Role == name {
+				return fmt.Errorf("role in use: reassign its users first")
+			}
+		}
+	}
+	return s.saveRoles(ctx, org, slices.Delete(slices.Clone(org.Roles), i, i+1))
+}
+
+func (s *AuthService) saveRoles(ctx context.Context, org store.Organization, roles []store.OrgRole) error {
+	if len(roles) == 0 {
+		roles = nil
+	}
+	org.Roles = roles
+	now := time.Now().UTC()
+	org.UpdatedAt = &now
+	if _, err := s.repo.UpdateOrganization(ctx, org); err != nil {
+		return err
+	}
+	s.forgetOrg(org.ID)
+	return nil
+}
@@ -3,10 +3,12 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"strings"
 	"time"
 
+	"clinical-backend/internal/domain"
 	"clinical-backend/internal/notifications"
 	"clinical-backend/internal/store"
 )
@@ -17,10 +19,24 @@ type AuthService struct {
 	appointmentRepo store.AppointmentRepository
 	notifier        notifications.Notifier
 	frontendBaseURL string
+	timezones       *TimezoneResolver
+	accessTTL       time.Duration
+	refreshTTL      time.Duration
+	throttle        ThrottlePolicy
+	securityEvents  func(SecurityEvent)
+	orgs            *orgCache
+	strictTenancy   bool
 }
 
 func NewAuthService(repo store.AuthRepository, opts ...func(*AuthService)) *AuthService {
-	svc := &AuthService{repo: repo}
+	svc := &AuthService{
+		repo:           repo,
+		accessTTL:      defaultAccessTTL,
+		refreshTTL:     defaultRefreshTTL,
+		throttle:       defaultThrottlePolicy(),
+		securityEvents: logSecurityEvent,
+		orgs:           &orgCache{byOrg: map[string]cachedOrg{}},
+	}
 	for _, o := range opts {
 		o(svc)
 	}
@@ -39,25 +55,41 @@ func WithAuthPatientRepo(r store.PatientRepository) func(*AuthService) {
 	return func(s *AuthService) { s.patientRepo = r }
 }
 
+// WithAuthTimezones lets org updates invalidate the cached timezone.
+func WithAuthTimezones(r *TimezoneResolver) func(*AuthService) {
+	return func(s *AuthService) { s.timezones = r }
+}
+
 func WithAuthAppointmentRepo(r store.AppointmentRepository) func(*AuthService) {
 	return func(s *AuthService) { s.appointmentRepo = r }
 }
 
+// WithStrictTenancy must match the tenancy the repositories were built with
+// (store.WithStrictTenancy): Register then gives every admin its own org.
+func WithStrictTenancy(on bool) func(*AuthService) {
+	return func(s *AuthService) { s.strictTenancy = on }
+}
+
 type Authenticated struct {
 	User    store.AuthUser
 	Session store.AuthSession
+	// OwnPatientsOnly: doctor (IsDoctorRole) de una org con DoctorsOwnPatientsOnly (ver access.go).
+	OwnPatientsOnly bool
 }
 
 func (s *AuthService) Authenticate(ctx context.Context, token string) (Authenticated, error) {
 	if strings.TrimSpace(token) == "" {
 		return Authenticated{}, fmt.Errorf("missing token")
 	}
-	session, err := s.repo.GetSession(ctx, token)
+	session, err := s.repo.GetSession(ctx, hashSecret(token))
 	if err != nil {
 		return Authenticated{}, fmt.Errorf("invalid token")
 	}
-	if time.Now().UTC().After(session.ExpiresAt) {
-		_ = s.repo.DeleteSession(ctx, token)
+	if now := time.Now().UTC(); now.After(session.ExpiresAt) {
+		// Con refresh token vigente el registro se conserva para poder renovarlo.
+		if session.RefreshToken == "" || now.After(session.RefreshExpiresAt) {
+			_ = s.repo.DeleteSession(ctx, session.Token)
+		}
 		return Authenticated{}, fmt.Errorf("token expired")
 	}
 	user, err := s.repo.GetUserByID(ctx, session.UserID)
@@ -67,17 +99,21 @@ func (s *AuthService) Authenticate(ctx context.Context, token string) (Authentic
 	if strings.ToLower(strings.TrimSpace(user.Status)) == "disabled" {
 		return Authenticated{}, fmt.Errorf("user disabled")
 	}
-	return Authenticated{User: user, Session: session}, nil
+	org := s.orgSettings(ctx, user.OrgID)
+	ownOnly := org.ownPatientsOnly && doctorRole(org.roles, user.Role)
+	return Authenticated{User: user, Session: session, OwnPatientsOnly: ownOnly}, nil
 }
 
 type RegisterInput struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	OrgName  string `json:"orgName,omitempty"`
 }
 
 type RegisterOutput struct {
 	UserID    string    `json:"userId"`
+	OrgID     string    `json:"orgId"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -91,22 +127,54 @@ func (s *AuthService) Register(ctx context.Context, in RegisterInput) (RegisterO
 		return RegisterOutput{}, fmt.Errorf("password must have at least 8 characters")
 	}
 
+	// Con aislamiento estricto el admin no cae en la org "default", que
+	// compartirían todos los registros: se le crea su propia organización.
+	orgID := store.DefaultOrgID
+	if s.strictTenancy {
+		orgName := strings.TrimSpace(in.OrgName)
+		if orgName == "" {
+			orgName = strings.TrimSpace(in.Name)
+		}
+		org, err := s.repo.CreateOrganization(ctx, store.Organization{
+			ID:            buildID("org"),
+			Name:          orgName,
+			Email:         strings.ToLower(strings.TrimSpace(in.Email)),
+			Status:        "active",
+			PaymentStatus: "current",
+			Limits:        store.OrgLimits{MaxDoctors: 5, MaxAssistants: 2, MaxPatients: 20},
+			Timezone:      "America/Caracas",
+			CreatedAt:     time.Now().UTC(),
+		})
+		if err != nil {
+			return RegisterOutput{}, err
+		}
+		orgID = org.ID
+	}
+
+	passwordHash, err := hashPassword(in.Password)
+	if err != nil {
+		return RegisterOutput{}, err
+	}
 	user := store.AuthUser{
 		ID:           buildID("usr"),
-		OrgID:        "default",
+		OrgID:        orgID,
 		Role:         "admin",
 		Status:       "active",
 		Name:         strings.TrimSpace(in.Name),
 		Email:        strings.ToLower(strings.TrimSpace(in.Email)),
-		PasswordHash: hashPassword(in.Password),
+		PasswordHash: passwordHash,
 		CreatedAt:    time.Now().UTC(),
 	}
 	created, err := s.repo.CreateUser(ctx, user)
 	if err != nil {
+		if orgID != store.DefaultOrgID {
+			_ = s.repo.DeleteOrganization(ctx, orgID)
+		}
 		return RegisterOutput{}, err
 	}
 	return RegisterOutput{
 		UserID:    created.ID,
+		OrgID:     created.OrgID,
 		Name:      created.Name,
 		Email:     created.Email,
 		CreatedAt: created.CreatedAt,
@@ -114,63 +182,73 @@ func (s *AuthService) Register(ctx context.Context, in RegisterInput) (RegisterO
 }
 
 type LoginInput struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	UserAgent string `json:"-"` // los fija el handler
+	IP        string `json:"-"`
 }
 
 type LoginOutput struct {
-	AccessToken        string `json:"accessToken"`
-	UserID             string `json:"userId"`
-	OrgID              string `json:"orgId"`
-	Name               string `json:"name"`
-	Email              string `json:"email"`
-	Role               string `json:"role"`
-	MustChangePassword bool   `json:"mustChangePassword"`
+	AccessToken        string    `json:"accessToken"`
+	RefreshToken       string    `json:"refreshToken"`
+	ExpiresAt          time.Time `json:"expiresAt"` // vencimiento del access token
+	SessionID          string    `json:"sessionId"`
+	UserID             string    `json:"userId"`
+	OrgID              string    `json:"orgId"`
+	Name               string    `json:"name"`
+	Email              string    `json:"email"`
+	Role               string    `json:"role"`
+	MustChangePassword bool      `json:"mustChangePassword"`
+	// Segundo factor: sin accessToken; el login sigue en /auth/mfa/verify con mfaToken.
+	MFARequired           bool     `json:"mfaRequired,omitempty"`
+	MFAEnrollmentRequired bool     `json:"mfaEnrollmentRequired,omitempty"` // la org exige MFA y el usuario no lo tiene
+	MFAToken              string   `json:"mfaToken,omitempty"`
+	RecoveryCodes         []string `json:"recoveryCodes,omitempty"` // al completar la inscripción durante el login
 }
 
 func (s *AuthService) Login(ctx context.Context, in LoginInput) (LoginOutput, error) {
 	if strings.TrimSpace(in.Email) == "" || strings.TrimSpace(in.Password) == "" {
 		return LoginOutput{}, fmt.Errorf("email and password are required")
 	}
+	if err := s.checkLogin(ctx, in.Email, in.IP, true); err != nil {
+		return LoginOutput{}, err
+	}
 
 	user, err := s.repo.GetUserByEmail(ctx, in.Email)
 	if err != nil {
+		// Mismo costo que un password erróneo: el tiempo de respuesta no
+		// revela qué emails tienen cuenta.
+		verifyPassword(dummyPasswordHash(), in.Password)
+		s.loginFailed(ctx, SecurityLoginFailed, in.Email, in.IP, nil)
 		return LoginOutput{}, fmt.Errorf("invalid credentials")
 	}
-	if user.PasswordHash != hashPassword(in.Password) {
+	ok, needsRehash := verifyPassword(user.PasswordHash, in.Password)
+	if !ok {
+		s.loginFailed(ctx, SecurityLoginFailed, in.Email, in.IP, &user)
 		return LoginOutput{}, fmt.Errorf("invalid credentials")
 	}
 	if strings.ToLower(strings.TrimSpace(user.Status)) == "disabled" {
 		return LoginOutput{}, fmt.Errorf("user disabled")
 	}
-	token, err := randomToken(24)
-	if err != nil {
-		return LoginOutput{}, err
+	if needsRehash {
+		// Migra hashes legacy (SHA-256) o con parámetros viejos al formato actual.
+		if hash, err := hashPassword(in.Password); err != nil {
+			log.Printf("[auth] login: could not upgrade password hash for user %s: %v", user.ID, err)
+		} else if err := s.repo.UpdateUserPassword(ctx, user.ID, hash); err != nil {
+			log.Printf("[auth] login: could not upgrade password hash for user %s: %v", user.ID, err)
+		}
 	}
-	expiresAt := time.Now().UTC().Add(24 * time.Hour)
-	_, err = s.repo.CreateSession(ctx, store.AuthSession{
-		Token:     token,
-		UserID:    user.ID,
-		OrgID:     user.OrgID,
-		Role:      user.Role,
-		ExpiresAt: expiresAt,
-	})
-	if err != nil {
-		return LoginOutput{}, err
+	out, err := s.completeLogin(ctx, user, in.UserAgent, in.IP)
+	if err == nil && !out.MFARequired {
+		// Con segundo factor los fallos se olvidan recién en VerifyMFA.
+		s.clearLoginFailures(ctx, in.Email)
 	}
-	return LoginOutput{
-		AccessToken:        token,
-		UserID:             user.ID,
-		OrgID:              user.OrgID,
-		Name:               user.Name,
-		Email:              user.Email,
-		Role:               user.Role,
-		MustChangePassword: user.MustChangePassword,
-	}, nil
+	return out, err
 }
 
 type ForgotPasswordInput struct {
 	Email string `json:"email"`
+	IP    string `json:"-"` // lo fija el handler
 }
 
 type ForgotPasswordOutput struct {
@@ -182,6 +260,9 @@ func (s *AuthService) ForgotPassword(ctx context.Context, in ForgotPasswordInput
 	if strings.TrimSpace(in.Email) == "" {
 		return ForgotPasswordOutput{}, fmt.Errorf("email is required")
 	}
+	if err := s.checkResetRequest(ctx, in.Email, in.IP); err != nil {
+		return ForgotPasswordOutput{}, err
+	}
 
 	user, err := s.repo.GetUserByEmail(ctx, in.Email)
 	if err != nil {
@@ -208,6 +289,7 @@ func (s *AuthService) ForgotPassword(ctx context.Context, in ForgotPasswordInput
 type ResetPasswordInput struct {
 	Token       string `json:"token"`
 	NewPassword string `json:"newPassword"`
+	IP          string `json:"-"` // lo fija el handler
 }
 
 type BootstrapPlatformAdminInput struct {
@@ -255,30 +337,40 @@ type OrgLimitsDTO struct {
 }
 
 type OrganizationDTO struct {
-	ID            string       `json:"id"`
-	Name          string       `json:"name"`
-	BusinessName  string       `json:"businessName"`
-	TaxID         string       `json:"taxId"`
-	Address       string       `json:"address"`
-	Email         string       `json:"email"`
-	Phone         string       `json:"phone"`
-	Status        string       `json:"status"`
-	PaymentStatus string       `json:"paymentStatus"`
-	Limits        OrgLimitsDTO `json:"limits"`
-	Timezone      string       `json:"timezone,omitempty"`
-	CreatedAt     time.Time    `json:"createdAt"`
-	UpdatedAt     *time.Time   `json:"updatedAt,omitempty"`
+	ID            string               `json:"id"`
+	Name          string               `json:"name"`
+	BusinessName  string               `json:"businessName"`
+	TaxID         string               `json:"taxId"`
+	Address       string               `json:"address"`
+	Email         string               `json:"email"`
+	Phone         string               `json:"phone"`
+	Status        string               `json:"status"`
+	PaymentStatus string               `json:"paymentStatus"`
+	Limits        OrgLimitsDTO         `json:"limits"`
+	Timezone      string               `json:"timezone,omitempty"`
+	NoShowPolicy  *domain.NoShowPolicy `json:"noShowPolicy,omitempty"`
+	RequireMFA    bool                 `json:"requireMfa"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	UpdatedAt     *time.Time           `json:"updatedAt,omitempty"`
+
+	// DoctorsOwnPatientsOnly: cada doctor solo ve sus pacientes.
+	DoctorsOwnPatientsOnly bool `json:"doctorsOwnPatientsOnly"`
 }
 
 type UserProfileDTO struct {
-	ID        string       `json:"id"`
-	OrgID     string       `json:"orgId"`
-	Name      string       `json:"name"`
-	Email     string       `json:"email"`
-	Role      string       `json:"role"`
-	Status    string       `json:"status"`
-	OrgName   string       `json:"orgName"`
-	OrgLimits OrgLimitsDTO `json:"orgLimits"`
+	ID         string       `json:"id"`
+	OrgID      string       `json:"orgId"`
+	Name       string       `json:"name"`
+	Email      string       `json:"email"`
+	Role       string       `json:"role"`
+	Status     string       `json:"status"`
+	OrgName    string       `json:"orgName"`
+	OrgLimits  OrgLimitsDTO `json:"orgLimits"`
+	MFAEnabled bool         `json:"mfaEnabled"`
+	// MFARequired: la org exige MFA a este usuario (no puede desactivarlo).
+	MFARequired bool `json:"mfaRequired"`
+	// Permisos del rol según la matriz de la org.
+	Permissions []string `json:"permissions"`
 }
 
 type CreateOrgAdminInput struct {
@@ -332,6 +424,10 @@ func (s *AuthService) BootstrapPlatformAdmin(ctx context.Context, in BootstrapPl
 		return BootstrapPlatformAdminOutput{UserID: existing.ID, Email: existing.Email, Role: existing.Role}, nil
 	}
 
+	passwordHash, err := hashPassword(in.Password)
+	if err != nil {
+		return BootstrapPlatformAdminOutput{}, err
+	}
 	user := store.AuthUser{
 		ID:           buildID("usr"),
 		OrgID:        "platform",
@@ -339,7 +435,7 @@ func (s *AuthService) BootstrapPlatformAdmin(ctx context.Context, in BootstrapPl
 		Email:        strings.ToLower(strings.TrimSpace(in.Email)),
 		Role:         "platform_admin",
 		Status:       "active",
-		PasswordHash: hashPassword(in.Password),
+		PasswordHash: passwordHash,
 		CreatedAt:    time.Now().UTC(),
 	}
 	created, err := s.repo.CreateUser(ctx, user)
@@ -362,8 +458,12 @@ func orgToDTO(org store.Organization) OrganizationDTO {
 		PaymentStatus: org.PaymentStatus,
 		Limits:        OrgLimitsDTO{MaxDoctors: org.Limits.MaxDoctors, MaxAssistants: org.Limits.MaxAssistants, MaxPatients: org.Limits.MaxPatients},
 		Timezone:      org.Timezone,
+		NoShowPolicy:  org.NoShowPolicy,
+		RequireMFA:    org.RequireMFA,
 		CreatedAt:     org.CreatedAt,
 		UpdatedAt:     org.UpdatedAt,
+
+		DoctorsOwnPatientsOnly: org.DoctorsOwnPatientsOnly,
 	}
 }
 
@@ -376,6 +476,9 @@ func (s *AuthService) CreateOrganization(ctx context.Context, in CreateOrganizat
 	if tz == "" {
 		tz = "America/Caracas"
 	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return OrganizationDTO{}, fmt.Errorf("invalid timezone %q", tz)
+	}
 	org := store.Organization{
 		ID:            buildID("org"),
 		Name:          name,
@@ -444,6 +547,9 @@ func (s *AuthService) UpdateOrganization(ctx context.Context, orgID string, in U
 		org.PaymentStatus = in.PaymentStatus
 	}
 	if v := strings.TrimSpace(in.Timezone); v != "" {
+		if _, err := time.LoadLocation(v); err != nil {
+			return OrganizationDTO{}, fmt.Errorf("invalid timezone %q", v)
+		}
 		org.Timezone = v
 	}
 	if in.MaxDoctors > 0 {
@@ -459,6 +565,9 @@ func (s *AuthService) UpdateOrganization(ctx context.Context, orgID string, in U
 	if err != nil {
 		return OrganizationDTO{}, err
 	}
+	if s.timezones != nil {
+		s.timezones.Invalidate(updated.ID)
+	}
 	return orgToDTO(updated), nil
 }
 
@@ -566,6 +675,7 @@ func (s *AuthService) GetOrgStats(ctx context.Context, orgID string) (OrgStatsDT
 }
 
 func (s *AuthService) GetPlatformStats(ctx context.Context) (PlatformStatsDTO, error) {
+	ctx = store.SystemContext(ctx)
 	orgs, err := s.repo.ListOrganizations(ctx)
 	if err != nil {
 		return PlatformStatsDTO{}, err
@@ -623,9 +733,13 @@ func (s *AuthService) GetUserProfile(ctx context.Context, userID string) (UserPr
 		Email:  user.Email,
 		Role:   user.Role,
 		Status: user.Status,
+
+		MFAEnabled:  user.MFA.Secret != "",
+		Permissions: s.UserPermissions(ctx, user),
 	}
 	if org, err := s.repo.GetOrganization(ctx, user.OrgID); err == nil {
 		profile.OrgName = org.Name
+		profile.MFARequired = org.RequireMFA && isStaffRole(user.Role)
 		profile.OrgLimits = OrgLimitsDTO{
 			MaxDoctors:    org.Limits.MaxDoctors,
 			MaxAssistants: org.Limits.MaxAssistants,
@@ -653,6 +767,10 @@ func (s *AuthService) CreateOrgAdmin(ctx context.Context, in CreateOrgAdminInput
 	if name == "" {
 		name = strings.TrimSpace(in.Email)
 	}
+	passwordHash, err := hashPassword(tempPassword)
+	if err != nil {
+		return store.AuthUser{}, err
+	}
 	user := store.AuthUser{
 		ID:                 buildID("usr"),
 		OrgID:              in.OrgID,
@@ -660,7 +778,7 @@ func (s *AuthService) CreateOrgAdmin(ctx context.Context, in CreateOrgAdminInput
 		Email:              strings.ToLower(strings.TrimSpace(in.Email)),
 		Role:               "admin",
 		Status:             "active",
-		PasswordHash:       hashPassword(tempPassword),
+		PasswordHash:       passwordHash,
 		MustChangePassword: true,
 		CreatedAt:          time.Now().UTC(),
 	}
@@ -689,13 +807,13 @@ func (s *AuthService) CreateOrgUser(ctx context.Context, in CreateOrgUserInput)
 	if strings.TrimSpace(in.Email) == "" {
 		return UserDTO{}, fmt.Errorf("email is required")
 	}
-	validRoles := map[string]bool{"admin": true, "doctor": true, "assistant": true, "patient": true}
-	if !validRoles[in.Role] {
-		return UserDTO{}, fmt.Errorf("invalid role")
-	}
 	if _, err := s.repo.GetOrganization(ctx, in.OrgID); err != nil {
 		return UserDTO{}, fmt.Errorf("organization not found")
 	}
+	in.Role = normalizeRole(in.Role)
+	if !s.roleExists(ctx, in.OrgID, in.Role) {
+		return UserDTO{}, fmt.Errorf("invalid role")
+	}
 	if err := s.checkRoleLimit(ctx, in.OrgID, in.Role); err != nil {
 		return UserDTO{}, err
 	}
@@ -703,6 +821,10 @@ func (s *AuthService) CreateOrgUser(ctx context.Context, in CreateOrgUserInput)
 	if err != nil {
 		return UserDTO{}, err
 	}
+	passwordHash, err := hashPassword(tempPassword)
+	if err != nil {
+		return UserDTO{}, err
+	}
 	user := store.AuthUser{
 		ID:                 buildID("usr"),
 		OrgID:              in.OrgID,
@@ -712,7 +834,7 @@ func (s *AuthService) CreateOrgUser(ctx context.Context, in CreateOrgUserInput)
 		Address:            strings.TrimSpace(in.Address),
 		Role:               in.Role,
 		Status:             "active",
-		PasswordHash:       hashPassword(tempPassword),
+		PasswordHash:       passwordHash,
 		MustChangePassword: true,
 		CreatedAt:          time.Now().UTC(),
 	}
@@ -773,11 +895,13 @@ type InviteUserOutput struct {
 }
 
 type AcceptInvitationInput struct {
-	Token    string `json:"token"`
-	Name     string `json:"name"`
-	Phone    string `json:"phone"`
-	Address  string `json:"address"`
-	Password string `json:"password"`
+	Token     string `json:"token"`
+	Name      string `json:"name"`
+	Phone     string `json:"phone"`
+	Address   string `json:"address"`
+	Password  string `json:"password"`
+	UserAgent string `json:"-"` // los fija el handler
+	IP        string `json:"-"`
 }
 
 func (s *AuthService) ListOrgUsers(ctx context.Context, orgID string) ([]UserDTO, error) {
@@ -826,8 +950,8 @@ func (s *AuthService) UpdateOrgUser(ctx context.Context, in UpdateOrgUserInput)
 		user.Address = strings.TrimSpace(in.Address)
 	}
 	if in.Role != "" {
-		validRoles := map[string]bool{"admin": true, "doctor": true, "assistant": true, "patient": true}
-		if !validRoles[in.Role] {
+		in.Role = normalizeRole(in.Role)
+		if !s.roleExists(ctx, in.OrgID, in.Role) {
 			return UserDTO{}, fmt.Errorf("invalid role")
 		}
 		if in.Role != user.Role {
@@ -847,6 +971,11 @@ func (s *AuthService) UpdateOrgUser(ctx context.Context, in UpdateOrgUserInput)
 	if err != nil {
 		return UserDTO{}, err
 	}
+	if updated.Status == "disabled" {
+		if _, err := s.RevokeUserSessions(ctx, updated.ID); err != nil {
+			return UserDTO{}, fmt.Errorf("user disabled but sessions not revoked: %w", err)
+		}
+	}
 	return UserDTO{
 		ID: updated.ID, OrgID: updated.OrgID, Name: updated.Name,
 		Email: updated.Email, Phone: updated.Phone, Address: updated.Address,
@@ -905,8 +1034,8 @@ func (s *AuthService) InviteUser(ctx context.Context, in InviteUserInput) (Invit
 	if strings.TrimSpace(in.OrgID) == "" || strings.TrimSpace(in.Email) == "" || strings.TrimSpace(in.Role) == "" {
 		return InviteUserOutput{}, fmt.Errorf("orgId, email and role are required")
 	}
-	validRoles := map[string]bool{"admin": true, "doctor": true, "assistant": true, "patient": true}
-	if !validRoles[in.Role] {
+	in.Role = normalizeRole(in.Role)
+	if !s.roleExists(ctx, in.OrgID, in.Role) {
 		return InviteUserOutput{}, fmt.Errorf("invalid role")
 	}
 	if err := s.checkRoleLimit(ctx, in.OrgID, in.Role); err != nil {
@@ -921,13 +1050,17 @@ func (s *AuthService) InviteUser(ctx context.Context, in InviteUserInput) (Invit
 		return InviteUserOutput{}, err
 	}
 	expiresAt := time.Now().UTC().Add(72 * time.Hour)
+	passwordHash, err := hashPassword(tempPassword)
+	if err != nil {
+		return InviteUserOutput{}, err
+	}
 	inv := store.UserInvitation{
 		Token:        token,
 		OrgID:        in.OrgID,
 		Email:        strings.ToLower(strings.TrimSpace(in.Email)),
 		Role:         in.Role,
 		InvitedBy:    in.InvitedBy,
-		TempPassword: hashPassword(tempPassword),
+		TempPassword: passwordHash,
 		ExpiresAt:    expiresAt,
 		Used:         false,
 	}
@@ -960,6 +1093,10 @@ func (s *AuthService) AcceptInvitation(ctx context.Context, in AcceptInvitationI
 	if inv.Used || time.Now().UTC().After(inv.ExpiresAt) {
 		return LoginOutput{}, fmt.Errorf("invitation expired or already used")
 	}
+	passwordHash, err := hashPassword(in.Password)
+	if err != nil {
+		return LoginOutput{}, err
+	}
 	user := store.AuthUser{
 		ID:           buildID("usr"),
 		OrgID:        inv.OrgID,
@@ -969,7 +1106,7 @@ func (s *AuthService) AcceptInvitation(ctx context.Context, in AcceptInvitationI
 		Address:      strings.TrimSpace(in.Address),
 		Role:         inv.Role,
 		Status:       "active",
-		PasswordHash: hashPassword(in.Password),
+		PasswordHash: passwordHash,
 		CreatedAt:    time.Now().UTC(),
 	}
 	created, err := s.repo.CreateUser(ctx, user)
@@ -979,28 +1116,7 @@ func (s *AuthService) AcceptInvitation(ctx context.Context, in AcceptInvitationI
 	if err := s.repo.MarkInvitationUsed(ctx, in.Token); err != nil {
 		return LoginOutput{}, err
 	}
-	token, err := randomToken(24)
-	if err != nil {
-		return LoginOutput{}, err
-	}
-	_, err = s.repo.CreateSession(ctx, store.AuthSession{
-		Token:     token,
-		UserID:    created.ID,
-		OrgID:     created.OrgID,
-		Role:      created.Role,
-		ExpiresAt: time.Now().UTC().Add(24 * time.Hour),
-	})
-	if err != nil {
-		return LoginOutput{}, err
-	}
-	return LoginOutput{
-		AccessToken: token,
-		UserID:      created.ID,
-		OrgID:       created.OrgID,
-		Name:        created.Name,
-		Email:       created.Email,
-		Role:        created.Role,
-	}, nil
+	return s.completeLogin(ctx, created, in.UserAgent, in.IP)
 }
 
 type ChangePasswordInput struct {
@@ -1017,13 +1133,18 @@ func (s *AuthService) ChangePassword(ctx context.Context, in ChangePasswordInput
 	if err != nil {
 		return fmt.Errorf("user not found")
 	}
-	if user.PasswordHash != hashPassword(in.OldPassword) {
+	if ok, _ := verifyPassword(user.PasswordHash, in.OldPassword); !ok {
 		return fmt.Errorf("invalid current password")
 	}
-	if err := s.repo.UpdateUserPassword(ctx, in.UserID, hashPassword(in.NewPassword)); err != nil {
+	newHash, err := hashPassword(in.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdateUserPassword(ctx, in.UserID, newHash); err != nil {
 		return err
 	}
 	// Clear the must-change flag
+	user.PasswordHash = newHash
 	user.MustChangePassword = false
 	_, err = s.repo.UpdateUser(ctx, user)
 	return err
@@ -1036,17 +1157,32 @@ func (s *AuthService) ResetPassword(ctx context.Context, in ResetPasswordInput)
 	if len(in.NewPassword) < 8 {
 		return fmt.Errorf("newPassword must have at least 8 characters")
 	}
+	if err := s.checkResetToken(ctx, in.IP); err != nil {
+		return err
+	}
 
 	resetToken, err := s.repo.GetResetToken(ctx, in.Token)
 	if err != nil {
+		s.resetTokenFailed(ctx, in.IP)
 		return fmt.Errorf("invalid token")
 	}
 	if resetToken.Used || time.Now().UTC().After(resetToken.ExpiresAt) {
 		return fmt.Errorf("token expired or already used")
 	}
 
-	if err := s.repo.UpdateUserPassword(ctx, resetToken.UserID, hashPassword(in.NewPassword)); err != nil {
+	newHash, err := hashPassword(in.NewPassword)
+	if err != nil {
 		return err
 	}
-	return s.repo.MarkResetTokenUsed(ctx, in.Token)
+	if err := s.repo.UpdateUserPassword(ctx, resetToken.UserID, newHash); err != nil {
+		return err
+	}
+	if err := s.repo.MarkResetTokenUsed(ctx, in.Token); err != nil {
+		return err
+	}
+	// Quien pidió el reset puede no controlar las sesiones abiertas: se cierran todas.
+	if _, err := s.RevokeUserSessions(ctx, resetToken.UserID); err != nil {
+		return fmt.Errorf("password reset but sessions not revoked: %w", err)
+	}
+	return nil
 }
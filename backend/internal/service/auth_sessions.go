@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/store"
+)
+
+// Sesiones: cada login abre una sesión con un access token corto y un refresh
+// token que rota en cada renovación. El refresh token lleva el ID de la
+// sesión ("<id>.<secreto>"): si llega uno ya rotado, alguien más tiene una
+// copia y la sesión se revoca entera. El repositorio solo guarda hashes
+// (hashSecret) de ambos tokens.
+
+const (
+	defaultAccessTTL  = 15 * time.Minute
+	defaultRefreshTTL = 30 * 24 * time.Hour
+	// Un refresh con el token recién rotado dentro de esta ventana es un
+	// reintento concurrente (dos pestañas), no un robo: se rechaza sin revocar.
+	refreshRetryWindow = 30 * time.Second
+)
+
+// WithSessionTTLs sets how long access and refresh tokens live; a zero
+// duration keeps the default (15 minutes and 30 days). The refresh token
+// expiry slides: each renewal starts it again.
+func WithSessionTTLs(access, refresh time.Duration) func(*AuthService) {
+	return func(s *AuthService) {
+		if access > 0 {
+			s.accessTTL = access
+		}
+		if refresh > 0 {
+			s.refreshTTL = refresh
+		}
+	}
+}
+
+// openSession starts a session for user and returns its first tokens.
+func (s *AuthService) openSession(ctx context.Context, user store.AuthUser, userAgent, ip string) (LoginOutput, error) {
+	id, err := randomToken(12)
+	if err != nil {
+		return LoginOutput{}, err
+	}
+	now := time.Now().UTC()
+	session, access, refresh, err := s.withNewTokens(store.AuthSession{
+		ID:        "ses_" + id,
+		UserID:    user.ID,
+		OrgID:     user.OrgID,
+		Role:      user.Role,
+		CreatedAt: now,
+		UserAgent: userAgent,
+		IP:        ip,
+	}, now)
+	if err != nil {
+		return LoginOutput{}, err
+	}
+	if _, err := s.repo.CreateSession(ctx, session); err != nil {
+		return LoginOutput{}, err
+	}
+	return sessionOutput(session, user, access, refresh), nil
+}
+
+// withNewTokens returns session with a new access and refresh token, hashed,
+// and the plaintext tokens for the client.
+func (s *AuthService) withNewTokens(session store.AuthSession, now time.Time) (store.AuthSession, string, string, error) {
+	access, err := randomToken(24)
+	if err != nil {
+		return store.AuthSession{}, "", "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return store.AuthSession{}, "", "", err
+	}
+	refresh := session.ID + "." + secret
+	session.Token = hashSecret(access)
+	session.ExpiresAt = now.Add(s.accessTTL)
+	session.RefreshToken = hashSecret(refresh)
+	session.RefreshExpiresAt = now.Add(s.refreshTTL)
+	session.LastRefreshAt = now
+	return session, access, refresh, nil
+}
+
+func sessionOutput(session store.AuthSession, user store.AuthUser, access, refresh string) LoginOutput {
+	return LoginOutput{
+		AccessToken:        access,
+		RefreshToken:       refresh,
+		ExpiresAt:          session.ExpiresAt,
+		SessionID:          session.ID,
+		UserID:             user.ID,
+		OrgID:              user.OrgID,
+		Name:               user.Name,
+		Email:              user.Email,
+		Role:               user.Role,
+		MustChangePassword: user.MustChangePassword,
+	}
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refreshToken"`
+	UserAgent    string `json:"-"` // los fija el handler
+	IP           string `json:"-"`
+}
+
+// Refresh renews the tokens of a session. The refresh token used stops
+// working; presenting it again revokes the session. The swap is a
+// compare-and-swap on the stored refresh token (store RotateSession): of two
+// concurrent renewals with the same token only one gets new tokens.
+func (s *AuthService) Refresh(ctx context.Context, in RefreshInput) (LoginOutput, error) {
+	sessionID, _, ok := strings.Cut(strings.TrimSpace(in.RefreshToken), ".")
+	if !ok || sessionID == "" {
+		return LoginOutput{}, fmt.Errorf("invalid refresh token")
+	}
+	session, err := s.repo.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return LoginOutput{}, fmt.Errorf("invalid refresh token")
+	}
+	now := time.Now().UTC()
+	given := hashSecret(strings.TrimSpace(in.RefreshToken))
+	switch {
+	case tokensEqual(session.RefreshToken, given):
+	case tokensEqual(session.PreviousRefreshToken, given) && now.Sub(session.LastRefreshAt) < refreshRetryWindow:
+		return LoginOutput{}, fmt.Errorf("refresh token already rotated")
+	default:
+		log.Printf("[auth] refresh token reuse on session %s (user %s): revoking session", session.ID, session.UserID)
+		if err := s.repo.DeleteSession(ctx, session.Token); err != nil {
+			return LoginOutput{}, err
+		}
+		return LoginOutput{}, fmt.Errorf("refresh token reused, session revoked")
+	}
+	if now.After(session.RefreshExpiresAt) {
+		_ = s.repo.DeleteSession(ctx, session.Token)
+		return LoginOutput{}, fmt.Errorf("refresh token expired")
+	}
+	user, err := s.repo.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		_ = s.repo.DeleteSession(ctx, session.Token)
+		return LoginOutput{}, fmt.Errorf("invalid refresh token")
+	}
+	if strings.ToLower(strings.TrimSpace(user.Status)) == "disabled" {
+		_ = s.repo.DeleteSession(ctx, session.Token)
+		return LoginOutput{}, fmt.Errorf("user disabled")
+	}
+
+	next := session
+	next.PreviousRefreshToken = session.RefreshToken
+	next.OrgID, next.Role = user.OrgID, user.Role
+	if in.UserAgent != "" {
+		next.UserAgent = in.UserAgent
+	}
+	if in.IP != "" {
+		next.IP = in.IP
+	}
+	next, access, refresh, err := s.withNewTokens(next, now)
+	if err != nil {
+		return LoginOutput{}, err
+	}
+	// El registro anterior (y su access token) se reemplaza en un solo paso:
+	// en cada momento hay un solo registro por sesión. Si otro refresh ganó
+	// la carrera es un reintento concurrente, no un robo.
+	if err := s.repo.RotateSession(ctx, session.Token, session.RefreshToken, next); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return LoginOutput{}, fmt.Errorf("refresh token already rotated")
+		}
+		return LoginOutput{}, err
+	}
+	return sessionOutput(next, user, access, refresh), nil
+}
+
+func tokensEqual(stored, given string) bool {
+	return stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(given)) == 1
+}
+
+// SessionDTO is an active session as the user sees it.
+type SessionDTO struct {
+	ID            string    `json:"id"`
+	CreatedAt     time.Time `json:"createdAt"`
+	LastRefreshAt time.Time `json:"lastRefreshAt"`
+	ExpiresAt     time.Time `json:"expiresAt"` // vence si no se renueva antes
+	UserAgent     string    `json:"userAgent,omitempty"`
+	IP            string    `json:"ip,omitempty"`
+	Current       bool      `json:"current"`
+}
+
+// ListSessions returns the active sessions of the user, newest first;
+// currentSessionID marks the one making the request. Sessions opened before
+// refresh tokens have no ID and are not listed (they expire within a day).
+func (s *AuthService) ListSessions(ctx context.Context, userID, currentSessionID string) ([]SessionDTO, error) {
+	sessions, err := s.repo.ListSessionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	out := []SessionDTO{}
+	for _, session := range sessions {
+		if session.ID == "" || now.After(session.RefreshExpiresAt) {
+			continue
+		}
+		out = append(out, SessionDTO{
+			ID:            session.ID,
+			CreatedAt:     session.CreatedAt,
+			LastRefreshAt: session.LastRefreshAt,
+			ExpiresAt:     session.RefreshExpiresAt,
+			UserAgent:     session.UserAgent,
+			IP:            session.IP,
+			Current:       session.ID == currentSessionID,
+		})
+	}
+	return out, nil
+}
+
+// RevokeSession closes one of the user's sessions.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.repo.GetSessionByID(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		return fmt.Errorf("session not found")
+	}
+	return s.repo.DeleteSession(ctx, session.Token)
+}
+
+// RevokeUserSessions closes every session of the user, including the ones
+// from before refresh tokens, and returns how many were closed.
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID string) (int, error) {
+	sessions, err := s.repo.ListSessionsByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	for i, session := range sessions {
+		if err := s.repo.DeleteSession(ctx, session.Token); err != nil {
+			return i, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// ErrForeignOrg is returned when an org admin acts on another org.
+var ErrForeignOrg = errors.New("access denied: organization is not yours")
+
+// checkCallerOrg binds the orgID of the path to the caller's org. Only
+// platform_admin manages other orgs; without a caller in ctx (jobs) there is
+// nothing to bind.
+func checkCallerOrg(ctx context.Context, orgID string) error {
+	auth, ok := AuthFromContext(ctx)
+	if !ok || normalizeRole(auth.User.Role) == "platform_admin" || auth.User.OrgID == orgID {
+		return nil
+	}
+	log.Printf("[auth] denied: user %s (org %s) -> org %s", auth.User.ID, auth.User.OrgID, orgID)
+	return ErrForeignOrg
+}
+
+// RevokeOrgUserSessions is RevokeUserSessions for an admin of the user's org.
+func (s *AuthService) RevokeOrgUserSessions(ctx context.Context, orgID, userID string) (int, error) {
+	if err := checkCallerOrg(ctx, orgID); err != nil {
+		return 0, err
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("user not found")
+	}
+	if user.OrgID != orgID {
+		return 0, fmt.Errorf("user does not belong to org")
+	}
+	return s.RevokeUserSessions(ctx, userID)
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/store"
+)
+
+// Protección contra fuerza bruta en los endpoints públicos de auth. Los
+// fallos se cuentan por email y por IP en el AuthRepository (sobreviven a
+// reinicios y se comparten entre instancias). Por email, a partir de
+// throttleDelayAfter fallos cada intento tiene que esperar un poco más, y al
+// llegar a MaxEmailFailures la cuenta se bloquea un rato y el usuario recibe
+// un email para desbloquearla. Los emails desconocidos cuentan igual, para
+// que el bloqueo no revele qué cuentas existen.
+
+const (
+	defaultMaxEmailFailures = 10
+	defaultMaxIPFailures    = 50
+	defaultThrottleWindow   = 15 * time.Minute
+	defaultLockout          = 15 * time.Minute
+	defaultMaxResetRequests = 3
+	// Fallos por email desde los que cada intento espera 1s, 2s, 4s... hasta throttleMaxDelay.
+	throttleDelayAfter = 3
+	throttleMaxDelay   = time.Minute
+	// Ventana de los pedidos de forgot-password.
+	resetRequestWindow = time.Hour
+	// Un IP puede pedir resets para varias cuentas (recepción de una clínica).
+	resetRequestsPerIPFactor = 10
+)
+
+// ThrottlePolicy holds the brute-force limits. Failures are counted in a
+// fixed Window starting at the first one.
+type ThrottlePolicy struct {
+	MaxEmailFailures int           // fallos de login por email antes del bloqueo
+	MaxIPFailures    int           // fallos de login (o tokens de reset inválidos) por IP
+	Window           time.Duration // ventana en que se cuentan los fallos
+	Lockout          time.Duration // duración del bloqueo
+	MaxResetRequests int           // pedidos de forgot-password por email y hora
+}
+
+func defaultThrottlePolicy() ThrottlePolicy {
+	return ThrottlePolicy{
+		MaxEmailFailures: defaultMaxEmailFailures,
+		MaxIPFailures:    defaultMaxIPFailures,
+		Window:           defaultThrottleWindow,
+		Lockout:          defaultLockout,
+		MaxResetRequests: defaultMaxResetRequests,
+	}
+}
+
+// WithThrottlePolicy overrides the brute-force limits; zero fields keep the
+// defaults (10 failures per email and 50 per IP in 15 minutes, 15 minute
+// lockout, 3 password reset requests per email and hour).
+func WithThrottlePolicy(p ThrottlePolicy) func(*AuthService) {
+	return func(s *AuthService) {
+		if p.MaxEmailFailures > 0 {
+			s.throttle.MaxEmailFailures = p.MaxEmailFailures
+		}
+		if p.MaxIPFailures > 0 {
+			s.throttle.MaxIPFailures = p.MaxIPFailures
+		}
+		if p.Window > 0 {
+			s.throttle.Window = p.Window
+		}
+		if p.Lockout > 0 {
+			s.throttle.Lockout = p.Lockout
+		}
+		if p.MaxResetRequests > 0 {
+			s.throttle.MaxResetRequests = p.MaxResetRequests
+		}
+	}
+}
+
+// Tipos de SecurityEvent.
+const (
+	SecurityLoginFailed       = "login_failed"
+	SecurityLoginThrottled    = "login_throttled"
+	SecurityAccountLocked     = "account_locked"
+	SecurityIPLocked          = "ip_locked"
+	SecurityAccountUnlocked   = "account_unlocked"
+	SecurityMFAFailed         = "mfa_failed"
+	SecurityResetThrottled    = "password_reset_throttled"
+	SecurityResetInvalidToken = "password_reset_invalid_token"
+)
+
+// SecurityEvent is a failed or blocked authentication attempt.
+type SecurityEvent struct {
+	Type        string     `json:"type"`
+	Email       string     `json:"email,omitempty"`
+	UserID      string     `json:"userId,omitempty"`
+	IP          string     `json:"ip,omitempty"`
+	Failures    int        `json:"failures,omitempty"` // fallos en la ventana actual
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+	At          time.Time  `json:"at"`
+}
+
+// WithSecurityEvents sends the security events to fn instead of the log.
+func WithSecurityEvents(fn func(SecurityEvent)) func(*AuthService) {
+	return func(s *AuthService) {
+		if fn != nil {
+			s.securityEvents = fn
+		}
+	}
+}
+
+// logSecurityEvent writes the event as one JSON line, easy to filter and
+// alert on in CloudWatch.
+func logSecurityEvent(ev SecurityEvent) {
+	b, _ := json.Marshal(ev)
+	log.Printf("[security] %s", b)
+}
+
+// ThrottledError rejects an attempt without checking it. The API answers
+// 429 with a Retry-After header.
+type ThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // bloqueo por demasiados fallos, no solo una espera
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		return "temporarily locked after too many failed attempts"
+	}
+	return "too many attempts, try again later"
+}
+
+func loginEmailKey(email string) string {
+	return "login:email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginIPKey(ip string) string { return "login:ip:" + ip }
+
+// loginDelay is how long after the last failure the next attempt must wait.
+func loginDelay(failures int) time.Duration {
+	if failures < throttleDelayAfter {
+		return 0
+	}
+	if n := failures - throttleDelayAfter; n < 6 { // 2^6s ya supera throttleMaxDelay
+		return min(time.Duration(1<<n)*time.Second, throttleMaxDelay)
+	}
+	return throttleMaxDelay
+}
+
+// getThrottle returns the record for key. Without a record (or if the store
+// fails) there is nothing to enforce: the login keeps working.
+func (s *AuthService) getThrottle(ctx context.Context, key string) (store.AuthThrottle, bool) {
+	t, err := s.repo.GetAuthThrottle(ctx, key)
+	if err != nil {
+		return store.AuthThrottle{}, false
+	}
+	return t, true
+}
+
+// checkLogin rejects a login attempt for email from ip while either is
+// locked or, with delay, while the email is inside its progressive delay.
+// The second factor only checks the lock: each challenge already allows few
+// codes, and getting a new one goes through the delay of the password step.
+func (s *AuthService) checkLogin(ctx context.Context, email, ip string, delay bool) error {
+	now := time.Now().UTC()
+	var throttled *ThrottledError
+	if t, ok := s.getThrottle(ctx, loginEmailKey(email)); ok {
+		if now.Before(t.LockedUntil) {
+			throttled = &ThrottledError{RetryAfter: t.LockedUntil.Sub(now), Locked: true}
+		} else if delay && now.Before(t.WindowStart.Add(s.throttle.Window)) {
+			if next := t.LastFailureAt.Add(loginDelay(t.Failures)); now.Before(next) {
+				throttled = &ThrottledError{RetryAfter: next.Sub(now)}
+			}
+		}
+	}
+	if throttled == nil && ip != "" {
+		if t, ok := s.getThrottle(ctx, loginIPKey(ip)); ok && now.Before(t.LockedUntil) {
+			throttled = &ThrottledError{RetryAfter: t.LockedUntil.Sub(now), Locked: true}
+		}
+	}
+	if throttled == nil {
+		return nil
+	}
+	s.securityEvents(SecurityEvent{Type: SecurityLoginThrottled, Email: email, IP: ip, At: now})
+	return throttled
+}
+
+// loginFailed counts a failed login (wrong password, unknown email or wrong
+// second factor) and locks the email or the IP when they reach their limit.
+// user is nil when the email does not exist.
+func (s *AuthService) loginFailed(ctx context.Context, eventType, email, ip string, user *store.AuthUser) {
+	now := time.Now().UTC()
+	ev := SecurityEvent{Type: eventType, Email: email, IP: ip, At: now}
+	if user != nil {
+		ev.UserID = user.ID
+	}
+	emailKey := loginEmailKey(email)
+	t, err := s.repo.RecordAuthFailure(ctx, emailKey, now, s.throttle.Window)
+	if err != nil {
+		log.Printf("[auth] throttle: could not record failure for %s: %v", emailKey, err)
+	} else {
+		ev.Failures = t.Failures
+	}
+	s.securityEvents(ev)
+	if err == nil && t.Failures >= s.throttle.MaxEmailFailures && !now.Before(t.LockedUntil) {
+		s.lockAccount(ctx, email, ip, user, now)
+	}
+	if ip == "" {
+		return
+	}
+	s.countIPFailure(ctx, loginIPKey(ip), email, ip, now)
+}
+
+// countIPFailure counts a failure for an IP key and locks it at MaxIPFailures.
+func (s *AuthService) countIPFailure(ctx context.Context, key, email, ip string, now time.Time) {
+	t, err := s.repo.RecordAuthFailure(ctx, key, now, s.throttle.Window)
+	if err != nil {
+		log.Printf("[auth] throttle: could not record failure for %s: %v", key, err)
+		return
+	}
+	if t.Failures < s.throttle.MaxIPFailures || now.Before(t.LockedUntil) {
+		return
+	}
+	until := now.Add(s.throttle.Lockout)
+	if err := s.repo.LockAuthThrottle(ctx, key, until, ""); err != nil {
+		log.Printf("[auth] throttle: could not lock %s: %v", key, err)
+		return
+	}
+	s.securityEvents(SecurityEvent{Type: SecurityIPLocked, Email: email, IP: ip, Failures: t.Failures, LockedUntil: &until, At: now})
+}
+
+// lockAccount locks the email and, when it belongs to a user, mails them a
+// link that unlocks it right away.
+func (s *AuthService) lockAccount(ctx context.Context, email, ip string, user *store.AuthUser, now time.Time) {
+	until := now.Add(s.throttle.Lockout)
+	var unlockToken, unlockHash string
+	if user != nil && s.notifier != nil {
+		secret, err := randomToken(24)
+		if err != nil {
+			log.Printf("[auth] throttle: could not create unlock token: %v", err)
+		} else {
+			unlockToken, unlockHash = user.ID+"."+secret, hashSecret(secret)
+		}
+	}
+	if err := s.repo.LockAuthThrottle(ctx, loginEmailKey(email), until, unlockHash); err != nil {
+		log.Printf("[auth] throttle: could not lock %s: %v", email, err)
+		return
+	}
+	ev := SecurityEvent{Type: SecurityAccountLocked, Email: email, IP: ip, LockedUntil: &until, At: now}
+	if user != nil {
+		ev.UserID = user.ID
+	}
+	s.securityEvents(ev)
+	if unlockToken == "" {
+		return
+	}
+	base := s.frontendBaseURL
+	if base == "" {
+		base = os.Getenv("FRONTEND_BASE_URL")
+	}
+	unlockURL := fmt.Sprintf("%s/unlock-account?token=%s", strings.TrimRight(base, "/"), unlockToken)
+	if err := s.notifier.SendAccountLocked(ctx, user.Email, unlockURL, until); err != nil {
+		log.Printf("[auth] throttle: could not send unlock email to user %s: %v", user.ID, err)
+	}
+}
+
+// clearLoginFailures forgets the failures of email after a complete login.
+func (s *AuthService) clearLoginFailures(ctx context.Context, email string) {
+	if err := s.repo.DeleteAuthThrottle(ctx, loginEmailKey(email)); err != nil {
+		log.Printf("[auth] throttle: could not clear failures for %s: %v", email, err)
+	}
+}
+
+type UnlockAccountInput struct {
+	Token string `json:"token"`
+	IP    string `json:"-"` // lo fija el handler
+}
+
+// UnlockAccount lifts a lockout with the token of the unlock email. The
+// token works once: the failure count goes with it.
+func (s *AuthService) UnlockAccount(ctx context.Context, in UnlockAccountInput) error {
+	userID, secret, ok := strings.Cut(strings.TrimSpace(in.Token), ".")
+	if !ok {
+		return fmt.Errorf("invalid token")
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("invalid token")
+	}
+	t, ok := s.getThrottle(ctx, loginEmailKey(user.Email))
+	if !ok || t.UnlockTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(t.UnlockTokenHash), []byte(hashSecret(secret))) != 1 {
+		return fmt.Errorf("invalid token")
+	}
+	if err := s.repo.DeleteAuthThrottle(ctx, t.Key); err != nil {
+		return err
+	}
+	s.securityEvents(SecurityEvent{Type: SecurityAccountUnlocked, Email: user.Email, UserID: user.ID, IP: in.IP, At: time.Now().UTC()})
+	return nil
+}
+
+// checkResetRequest counts a forgot-password request and rejects it past
+// MaxResetRequests per email (or ten times that per IP) in an hour.
+func (s *AuthService) checkResetRequest(ctx context.Context, email, ip string) error {
+	now := time.Now().UTC()
+	check := func(key string, max int) error {
+		t, err := s.repo.RecordAuthFailure(ctx, key, now, resetRequestWindow)
+		if err != nil {
+			log.Printf("[auth] throttle: could not record reset request for %s: %v", key, err)
+			return nil
+		}
+		if t.Failures <= max {
+			return nil
+		}
+		s.securityEvents(SecurityEvent{Type: SecurityResetThrottled, Email: email, IP: ip, Failures: t.Failures, At: now})
+		return &ThrottledError{RetryAfter: t.WindowStart.Add(resetRequestWindow).Sub(now)}
+	}
+	if ip != "" {
+		if err := check("forgot:ip:"+ip, s.throttle.MaxResetRequests*resetRequestsPerIPFactor); err != nil {
+			return err
+		}
+	}
+	return check("forgot:email:"+strings.ToLower(strings.TrimSpace(email)), s.throttle.MaxResetRequests)
+}
+
+// checkResetToken rejects reset attempts from an IP locked for guessing
+// tokens.
+func (s *AuthService) checkResetToken(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	if t, ok := s.getThrottle(ctx, "reset:ip:"+ip); ok && now.Before(t.LockedUntil) {
+		return &ThrottledError{RetryAfter: t.LockedUntil.Sub(now), Locked: true}
+	}
+	return nil
+}
+
+// resetTokenFailed counts an invalid reset token against the IP.
+func (s *AuthService) resetTokenFailed(ctx context.Context, ip string) {
+	now := time.Now().UTC()
+	s.securityEvents(SecurityEvent{Type: SecurityResetInvalidToken, IP: ip, At: now})
+	if ip != "" {
+		s.countIPFailure(ctx, "reset:ip:"+ip, "", ip, now)
+	}
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// maxAvailabilityDays limits GET /availability to one month per request.
+const maxAvailabilityDays = 31
+
+// orgLocation resolves the org timezone (Organization.Timezone) through the
+// cached resolver; orgs without one use the configured fallback.
+func (s *AppointmentService) orgLocation(ctx context.Context, orgID string) *time.Location {
+	return s.timezones.ForOrg(ctx, orgID)
+}
+
+// doctorLocation resolves the timezone of the doctor's org.
+func (s *AppointmentService) doctorLocation(ctx context.Context, doctorID string) *time.Location {
+	orgID := store.OrgIDFromContext(ctx)
+	if s.authRepo != nil {
+		if u, err := s.authRepo.GetUserByID(ctx, doctorID); err == nil && u.OrgID != "" {
+			orgID = u.OrgID
+		}
+	}
+	return s.orgLocation(ctx, orgID)
+}
+
+// doctorUser loads the doctor and checks it belongs to the org in ctx (if any).
+func (s *AppointmentService) doctorUser(ctx context.Context, doctorID string) (store.AuthUser, error) {
+	if s.authRepo == nil {
+		return store.AuthUser{}, fmt.Errorf("doctor not found")
+	}
+	u, err := s.authRepo.GetUserByID(ctx, doctorID)
+	if err != nil {
+		return store.AuthUser{}, fmt.Errorf("doctor not found")
+	}
+	if orgID := store.OrgIDFromContext(ctx); orgID != "" && u.OrgID != orgID {
+		return store.AuthUser{}, fmt.Errorf("doctor not found")
+	}
+	return u, nil
+}
+
+// parseClock converts "HH:MM" to minutes since midnight.
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func validateAvailability(av domain.DoctorAvailability) error {
+	for _, w := range av.Weekly {
+		if w.Weekday < time.Sunday || w.Weekday > time.Saturday {
+			return fmt.Errorf("invalid weekday %d", w.Weekday)
+		}
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return err
+		}
+		if end <= start {
+			return fmt.Errorf("weekly window %s-%s: end must be after start", w.Start, w.End)
+		}
+	}
+	for _, b := range av.TimeOff {
+		if !b.EndAt.After(b.StartAt) {
+			return fmt.Errorf("time-off block: endAt must be after startAt")
+		}
+	}
+	return nil
+}
+
+type timeRange struct{ start, end time.Time }
+
+// weeklyWindows returns the working windows of the local calendar day that
+// contains day, as absolute times.
+func weeklyWindows(av domain.DoctorAvailability, day time.Time, loc *time.Location) []timeRange {
+	local := day.In(loc)
+	y, m, d := local.Date()
+	var out []timeRange
+	for _, w := range av.Weekly {
+		if w.Weekday != local.Weekday() {
+			continue
+		}
+		start, err1 := parseClock(w.Start)
+		end, err2 := parseClock(w.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		out = append(out, timeRange{
+			start: time.Date(y, m, d, start/60, start%60, 0, 0, loc),
+			end:   time.Date(y, m, d, end/60, end%60, 0, 0, loc),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].start.Before(out[j].start) })
+	return out
+}
+
+// availabilityConflict explains why [start, end) is not bookable, or returns
+// "" if it is.
+func availabilityConflict(av domain.DoctorAvailability, start, end time.Time, loc *time.Location) string {
+	if len(av.Weekly) > 0 {
+		inside := false
+		for _, w := range weeklyWindows(av, start, loc) {
+			if !start.Before(w.start) && !end.After(w.end) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return "fuera del horario de atención"
+		}
+	}
+	for _, b := range av.TimeOff {
+		if start.Before(b.EndAt) && end.After(b.StartAt) {
+			if b.Reason != "" {
+				return "bloqueado: " + b.Reason
+			}
+			return "bloqueado"
+		}
+	}
+	return ""
+}
+
+// checkAvailability enforces the doctor's schedule. Doctors without a
+// configured availability (or not registered as users) are not restricted.
+func (s *AppointmentService) checkAvailability(ctx context.Context, doctorID string, startAt, endAt time.Time) error {
+	if s.authRepo == nil {
+		return nil
+	}
+	u, err := s.authRepo.GetUserByID(ctx, doctorID)
+	if err != nil || u.Availability == nil {
+		return nil
+	}
+	loc := s.orgLocation(ctx, u.OrgID)
+	if reason := availabilityConflict(*u.Availability, startAt, endAt, loc); reason != "" {
+		return fmt.Errorf("el doctor no está disponible el %s de %s a %s (%s)",
+			startAt.In(loc).Format("02/01/2006"), startAt.In(loc).Format("15:04"), endAt.In(loc).Format("15:04"), reason)
+	}
+	return nil
+}
+
+// GetDoctorAvailability returns the doctor's schedule (empty if not configured).
+func (s *AppointmentService) GetDoctorAvailability(ctx context.Context, doctorID string) (domain.DoctorAvailability, error) {
+	u, err := s.doctorUser(ctx, doctorID)
+	if err != nil {
+		return domain.DoctorAvailability{}, err
+	}
+	if u.Availability == nil {
+		return domain.DoctorAvailability{Weekly: []domain.WeeklyHours{}}, nil
+	}
+	return *u.Availability, nil
+}
+
+// SetDoctorAvailability replaces the doctor's weekly hours and time-off blocks.
+func (s *AppointmentService) SetDoctorAvailability(ctx context.Context, doctorID string, av domain.DoctorAvailability) (domain.DoctorAvailability, error) {
+	u, err := s.doctorUser(ctx, doctorID)
+	if err != nil {
+		return domain.DoctorAvailability{}, err
+	}
+	if err := validateAvailability(av); err != nil {
+		return domain.DoctorAvailability{}, err
+	}
+	for i := range av.TimeOff {
+		if av.TimeOff[i].ID == "" {
+			av.TimeOff[i].ID = buildID("off")
+		}
+		av.TimeOff[i].StartAt = av.TimeOff[i].StartAt.UTC()
+		av.TimeOff[i].EndAt = av.TimeOff[i].EndAt.UTC()
+	}
+	now := s.now().UTC()
+	av.UpdatedAt = &now
+	u.Availability = &av
+	if _, err := s.authRepo.UpdateUser(ctx, u); err != nil {
+		return domain.DoctorAvailability{}, err
+	}
+	return av, nil
+}
+
+type AvailabilityQuery struct {
+	DoctorID        string
+	From            string // YYYY-MM-DD (zona de la org); por defecto hoy
+	To              string // YYYY-MM-DD inclusive; por defecto From
+	DurationMinutes int    // por defecto 30
+}
+
+type AvailableSlot struct {
+	StartAt time.Time `json:"startAt"`
+	EndAt   time.Time `json:"endAt"`
+}
+
+type AvailabilityResult struct {
+	DoctorID        string          `json:"doctorId"`
+	Timezone        string          `json:"timezone"`
+	DurationMinutes int             `json:"durationMinutes"`
+	Slots           []AvailableSlot `json:"slots"`
+}
+
+// FindFreeSlots lists bookable slots of the given duration between From and To
+// (inclusive local days): weekly windows minus time-off, existing appointments
+// and the past. Slots are returned in the org timezone.
+func (s *AppointmentService) FindFreeSlots(ctx context.Context, q AvailabilityQuery) (AvailabilityResult, error) {
+	if q.DoctorID == "" {
+		return AvailabilityResult{}, fmt.Errorf("doctorId is required")
+	}
+	u, err := s.doctorUser(ctx, q.DoctorID)
+	if err != nil {
+		return AvailabilityResult{}, err
+	}
+	if u.Availability == nil || len(u.Availability.Weekly) == 0 {
+		return AvailabilityResult{}, fmt.Errorf("el doctor no tiene horario de atención configurado")
+	}
+	return s.freeSlots(ctx, q, *u.Availability, s.orgLocation(ctx, u.OrgID), s.now(), "")
+}
+
+// freeSlots computes the slots of av not taken by the doctor's appointments
+// (excludeID aside) and not starting before notBefore.
+func (s *AppointmentService) freeSlots(ctx context.Context, q AvailabilityQuery, av domain.DoctorAvailability, loc *time.Location, notBefore time.Time, excludeID string) (AvailabilityResult, error) {
+	duration := q.DurationMinutes
+	if duration <= 0 {
+		duration = 30
+	}
+	var err error
+	from := s.now().In(loc)
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	if q.From != "" {
+		if from, err = time.ParseInLocation("2006-01-02", q.From, loc); err != nil {
+			return AvailabilityResult{}, fmt.Errorf("invalid from, use YYYY-MM-DD")
+		}
+	}
+	to := from
+	if q.To != "" {
+		if to, err = time.ParseInLocation("2006-01-02", q.To, loc); err != nil {
+			return AvailabilityResult{}, fmt.Errorf("invalid to, use YYYY-MM-DD")
+		}
+	}
+	if to.Before(from) {
+		return AvailabilityResult{}, fmt.Errorf("to must not be before from")
+	}
+	if to.Sub(from) > maxAvailabilityDays*24*time.Hour {
+		return AvailabilityResult{}, fmt.Errorf("range too large (max %d days)", maxAvailabilityDays)
+	}
+	end := to.AddDate(0, 0, 1)
+
+	busy, err := s.listDoctorRange(ctx, q.DoctorID, from, end)
+	if err != nil {
+		return AvailabilityResult{}, err
+	}
+
+	step := time.Duration(duration) * time.Minute
+	slots := []AvailableSlot{}
+	for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+		for _, w := range weeklyWindows(av, day, loc) {
+			for start := w.start; !start.Add(step).After(w.end); start = start.Add(step) {
+				slotEnd := start.Add(step)
+				if start.Before(notBefore) || availabilityConflict(av, start, slotEnd, loc) != "" {
+					continue
+				}
+				taken := false
+				for _, a := range busy {
+					if a.ID != excludeID && start.Before(a.EndAt) && slotEnd.After(a.StartAt) {
+						taken = true
+						break
+					}
+				}
+				if !taken {
+					slots = append(slots, AvailableSlot{StartAt: start.In(loc), EndAt: slotEnd.In(loc)})
+				}
+			}
+		}
+	}
+	return AvailabilityResult{DoctorID: q.DoctorID, Timezone: loc.String(), DurationMinutes: duration, Slots: slots}, nil
+}
+
+// listDoctorRange returns the doctor's non-cancelled appointments starting in
+// [from, to). The repository is indexed by UTC day.
+func (s *AppointmentService) listDoctorRange(ctx context.Context, doctorID string, from, to time.Time) ([]domain.Appointment, error) {
+	items, err := s.listDoctorDays(ctx, doctorID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify existing appointments: %w", err)
+	}
+	out := items[:0]
+	for _, a := range items {
+		if a.Status != "cancelled" {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// listDoctorDays returns every appointment (cancelled included) starting in
+// [from, to).
+func (s *AppointmentService) listDoctorDays(ctx context.Context, doctorID string, from, to time.Time) ([]domain.Appointment, error) {
+	return ListByDoctorRange(ctx, s.repo, doctorID, from, to)
+}
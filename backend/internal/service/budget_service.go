@@ -10,11 +10,21 @@ import (
 )
 
 type BudgetService struct {
-	repo store.BudgetRepository
+	repo   store.BudgetRepository
+	access *PatientAccess
 }
 
-func NewBudgetService(repo store.BudgetRepository) *BudgetService {
-	return &BudgetService{repo: repo}
+func NewBudgetService(repo store.BudgetRepository, opts ...func(*BudgetService)) *BudgetService {
+	s := &BudgetService{repo: repo}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// WithBudgetAccess enables the org policy "doctors only see their own patients".
+func WithBudgetAccess(a *PatientAccess) func(*BudgetService) {
+	return func(s *BudgetService) { s.access = a }
 }
 
 type CreateBudgetInput struct {
@@ -32,6 +42,9 @@ func (s *BudgetService) CreateBudget(ctx context.Context, in CreateBudgetInput)
 	if in.PatientID == "" || in.Title == "" {
 		return domain.Budget{}, fmt.Errorf("patientId and title are required")
 	}
+	if err := s.access.checkPatient(ctx, in.PatientID); err != nil {
+		return domain.Budget{}, err
+	}
 	currency := in.Currency
 	if currency == "" {
 		currency = "USD"
@@ -75,10 +88,20 @@ func (s *BudgetService) CreateBudget(ctx context.Context, in CreateBudgetInput)
 }
 
 func (s *BudgetService) GetBudget(ctx context.Context, id string) (domain.Budget, error) {
-	return s.repo.GetByID(ctx, id)
+	b, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	if err := s.access.checkPatient(ctx, b.PatientID); err != nil {
+		return domain.Budget{}, err
+	}
+	return b, nil
 }
 
 func (s *BudgetService) ListBudgetsByPatient(ctx context.Context, patientID string) ([]domain.Budget, error) {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
 	return s.repo.ListByPatient(ctx, patientID)
 }
 
@@ -89,6 +112,7 @@ type UpdateBudgetInput struct {
 	Status     string             `json:"status"`
 	Notes      string             `json:"notes"`
 	ValidUntil *time.Time         `json:"validUntil,omitempty"`
+	Version    int                `json:"version,omitempty"`
 }
 
 func (s *BudgetService) UpdateBudget(ctx context.Context, id string, in UpdateBudgetInput) (domain.Budget, error) {
@@ -96,6 +120,12 @@ func (s *BudgetService) UpdateBudget(ctx context.Context, id string, in UpdateBu
 	if err != nil {
 		return domain.Budget{}, fmt.Errorf("budget not found: %w", err)
 	}
+	if err := s.access.checkPatient(ctx, b.PatientID); err != nil {
+		return domain.Budget{}, err
+	}
+	if err := checkClientVersion(b.Version, in.Version); err != nil {
+		return domain.Budget{}, err
+	}
 	if in.Title != "" {
 		b.Title = in.Title
 	}
@@ -133,5 +163,8 @@ func (s *BudgetService) UpdateBudget(ctx context.Context, id string, in UpdateBu
 }
 
 func (s *BudgetService) DeleteBudget(ctx context.Context, id string) error {
+	if _, err := s.GetBudget(ctx, id); err != nil {
+		return err
+	}
 	return s.repo.Delete(ctx, id)
 }
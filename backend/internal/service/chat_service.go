@@ -41,6 +41,7 @@ type clinicalContext struct {
 	Payments             []domain.PaymentRecord
 	Today                time.Time
 	Tomorrow             time.Time
+	Location             *time.Location
 }
 
 // rateLimitEntry tracks per-user request timestamps for a simple sliding window.
@@ -55,7 +56,8 @@ type ChatService struct {
 	patientRepo     store.PatientRepository
 	paymentRepo     store.PaymentRepository
 	bedrockClient   *bedrock.Client
-	tz              *time.Location
+	timezones       *TimezoneResolver
+	access          *PatientAccess
 
 	rateMu   sync.Mutex
 	rateMap  map[string]*rateLimitEntry
@@ -68,7 +70,8 @@ func NewChatService(
 	patients store.PatientRepository,
 	payments store.PaymentRepository,
 	bedrockClient *bedrock.Client,
-	tz *time.Location,
+	timezones *TimezoneResolver,
+	opts ...func(*ChatService),
 ) *ChatService {
 	maxPerMin := 20
 	if v := os.Getenv("DOCCO_RATE_LIMIT"); v != "" {
@@ -76,15 +79,25 @@ func NewChatService(
 			maxPerMin = n
 		}
 	}
-	return &ChatService{
+	svc := &ChatService{
 		appointmentRepo: appointments,
 		patientRepo:     patients,
 		paymentRepo:     payments,
 		bedrockClient:   bedrockClient,
-		tz:              tz,
+		timezones:       timezones,
 		rateMap:         make(map[string]*rateLimitEntry),
 		maxPerMin:       maxPerMin,
 	}
+	for _, o := range opts {
+		o(svc)
+	}
+	return svc
+}
+
+// WithChatAccess enables the org policy "doctors only see their own patients"
+// for the data Docco puts in the prompt.
+func WithChatAccess(a *PatientAccess) func(*ChatService) {
+	return func(s *ChatService) { s.access = a }
 }
 
 // ProcessMessage is the main entry point for a chat request.
@@ -159,9 +172,9 @@ func (s *ChatService) checkRateLimit(userID string) bool {
 
 // gatherContext queries DynamoDB concurrently.
 func (s *ChatService) gatherContext(ctx context.Context, orgID, userID, role string) clinicalContext {
-	now := time.Now().In(s.tz)
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.tz)
-	tomorrow := today.Add(24 * time.Hour)
+	loc := s.timezones.ForOrg(ctx, orgID)
+	today, tomorrow := dayBounds(time.Now(), loc)
+	dayAfter := tomorrow.AddDate(0, 0, 1)
 
 	var (
 		wg   sync.WaitGroup
@@ -170,12 +183,13 @@ func (s *ChatService) gatherContext(ctx context.Context, orgID, userID, role str
 	)
 	cctx.Today = today
 	cctx.Tomorrow = tomorrow
+	cctx.Location = loc
 
 	// Citas de hoy
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		appts, err := s.appointmentRepo.ListByDoctorAndDay(ctx, userID, today)
+		appts, err := ListByDoctorRange(ctx, s.appointmentRepo, userID, today, tomorrow)
 		if err == nil {
 			mu.Lock()
 			cctx.TodayAppointments = appts
@@ -187,7 +201,7 @@ func (s *ChatService) gatherContext(ctx context.Context, orgID, userID, role str
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		appts, err := s.appointmentRepo.ListByDoctorAndDay(ctx, userID, tomorrow)
+		appts, err := ListByDoctorRange(ctx, s.appointmentRepo, userID, tomorrow, dayAfter)
 		if err == nil {
 			mu.Lock()
 			cctx.TomorrowAppointments = appts
@@ -201,8 +215,11 @@ func (s *ChatService) gatherContext(ctx context.Context, orgID, userID, role str
 		defer wg.Done()
 		var patients []domain.Patient
 		var err error
-		if role == "admin" || role == "platform_admin" {
-			patients, err = s.patientRepo.ListAll(ctx)
+		if role == "platform_admin" {
+			patients, err = s.patientRepo.ListAll(store.SystemContext(ctx))
+		} else if role == "admin" {
+			// ListAll cruza orgs: el admin solo ve los pacientes de su org
+			patients, err = s.patientRepo.ListByDoctor(ctx, "")
 		} else {
 			patients, err = s.patientRepo.ListByDoctor(ctx, userID)
 		}
@@ -221,8 +238,20 @@ func (s *ChatService) gatherContext(ctx context.Context, orgID, userID, role str
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		payments, err := s.paymentRepo.ListByOrg(ctx, 20)
+		// Un doctor restringido solo ve los pagos de sus pacientes: se leen
+		// más en una sola consulta y se filtran en memoria contra sus
+		// pacientes, que access carga una vez por request.
+		limit := 20
+		visible := s.access.filter(ctx)
+		if visible != nil {
+			limit = 200
+		}
+		payments, err := s.paymentRepo.ListByOrg(ctx, limit)
 		if err == nil {
+			payments = filterByPatient(payments, visible, func(p domain.PaymentRecord) string { return p.PatientID })
+			if len(payments) > 20 {
+				payments = payments[:20]
+			}
 			mu.Lock()
 			cctx.Payments = payments
 			mu.Unlock()
@@ -247,14 +276,14 @@ REGLAS:
 - Cuando listes citas, incluye: hora, nombre del paciente, motivo si existe.
 - Cuando menciones pagos, incluye montos y método de pago.
 - NUNCA reveles información de otros doctores u organizaciones.
-- Formato de hora: 12h (ej: 2:00 PM). Zona horaria: America/Caracas.
+- Formato de hora: 12h (ej: 2:00 PM). Zona horaria: %s.
 
 DATOS DEL USUARIO ACTUAL:
 - Nombre: %s
 - Rol: %s
 - Organización: %s
 
-`, userName, role, orgID)
+`, cctx.Location, userName, role, orgID)
 
 	todayStr := cctx.Today.Format("02/01/2006")
 	tomorrowStr := cctx.Tomorrow.Format("02/01/2006")
@@ -264,7 +293,7 @@ DATOS DEL USUARIO ACTUAL:
 		b.WriteString("No hay citas programadas para hoy.\n")
 	} else {
 		for _, a := range cctx.TodayAppointments {
-			hora := a.StartAt.In(s.tz).Format("3:04 PM")
+			hora := a.StartAt.In(cctx.Location).Format("3:04 PM")
 			reason := a.Reason
 			if reason == "" {
 				reason = a.EvolutionNotes
@@ -283,7 +312,7 @@ DATOS DEL USUARIO ACTUAL:
 		b.WriteString("No hay citas programadas para mañana.\n")
 	} else {
 		for _, a := range cctx.TomorrowAppointments {
-			hora := a.StartAt.In(s.tz).Format("3:04 PM")
+			hora := a.StartAt.In(cctx.Location).Format("3:04 PM")
 			reason := a.Reason
 			if reason == "" {
 				reason = a.EvolutionNotes
@@ -321,7 +350,7 @@ DATOS DEL USUARIO ACTUAL:
 				name = "Paciente ID: " + pay.PatientID
 			}
 			fmt.Fprintf(&b, "- %s | %.2f %s | %s | %s | %s\n",
-				name, pay.Amount, pay.Currency, pay.PaymentMethod, pay.PaymentType, pay.CreatedAt.In(s.tz).Format("02/01/2006"))
+				name, pay.Amount, pay.Currency, pay.PaymentMethod, pay.PaymentType, pay.CreatedAt.In(cctx.Location).Format("02/01/2006"))
 		}
 	}
 	b.WriteString("\n")
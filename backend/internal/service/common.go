@@ -2,12 +2,13 @@ package service
 
 import (
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
 	"strings"
 	"time"
+
+	"clinical-backend/internal/store"
 )
 
 // generateTempPassword generates a secure temporary password
@@ -24,12 +25,6 @@ func generateTempPassword() (string, error) {
 	return string(b), nil
 }
 
-// hashPassword creates a SHA-256 hash of the password
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:])
-}
-
 // randomToken generates a random token of specified size
 func randomToken(size int) (string, error) {
 	b := make([]byte, size)
@@ -162,3 +157,13 @@ func unique(slice []string) []string {
 
 	return result
 }
+
+// checkClientVersion fails with store.ErrConflict when the client sent the
+// version it edited (body "version" or If-Match) and the stored entity has
+// moved on. 0 means the client did not send one and skips the check.
+func checkClientVersion(stored, sent int) error {
+	if sent > 0 && sent != stored {
+		return store.ErrConflict
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"time"
 
 	"clinical-backend/internal/domain"
@@ -135,7 +136,7 @@ func (s *ConsentService) CreateAndSend(ctx context.Context, in CreateConsentInpu
 // CreateForAppointment creates a single consent from the first active template (legacy).
 // Prefer CreateConsentsForAppointment to include all active templates (asistencia + tratamiento).
 func (s *ConsentService) CreateForAppointment(ctx context.Context, appointmentID, orgID, patientID, doctorID, patientEmail, patientName string, startAt time.Time) (domain.Consent, error) {
-	list, err := s.CreateConsentsForAppointment(ctx, appointmentID, orgID, patientID, doctorID, patientEmail, patientName, startAt)
+	list, err := s.CreateConsentsForAppointment(ctx, appointmentID, orgID, patientID, doctorID, patientEmail, patientName, startAt, nil)
 	if err != nil || len(list) == 0 {
 		return domain.Consent{}, nil
 	}
@@ -144,11 +145,16 @@ func (s *ConsentService) CreateForAppointment(ctx context.Context, appointmentID
 
 // CreateConsentsForAppointment creates one consent per active template (asistencia, tratamiento, etc.)
 // and returns all consents for this appointment (existing + newly created). Does not send email.
-func (s *ConsentService) CreateConsentsForAppointment(ctx context.Context, appointmentID, orgID, patientID, doctorID, patientEmail, patientName string, startAt time.Time) ([]domain.Consent, error) {
+// templateIDs are the templates required by the appointment type: when not nil only those are
+// created (an empty list means the type needs no consent); nil keeps every active template.
+func (s *ConsentService) CreateConsentsForAppointment(ctx context.Context, appointmentID, orgID, patientID, doctorID, patientEmail, patientName string, startAt time.Time, templateIDs []string) ([]domain.Consent, error) {
 	templates, err := s.templateRepo.ListActiveByOrg(ctx, orgID)
 	if err != nil {
 		return nil, nil
 	}
+	if templateIDs != nil {
+		templates = filterTemplates(templates, templateIDs)
+	}
 	if len(templates) == 0 {
 		// Sin plantillas activas no se generan consentimientos. El correo llegará solo con confirmación de cita.
 		// Para enviar los 2 enlaces (asistencia + tratamiento) hay que crear y activar 2 plantillas en Plantillas de consentimiento.
@@ -192,6 +198,25 @@ func (s *ConsentService) CreateConsentsForAppointment(ctx context.Context, appoi
 	return result, nil
 }
 
+// filterTemplates keeps the templates listed in ids, in the order of ids.
+// Required templates that are no longer active are skipped.
+func filterTemplates(templates []domain.ConsentTemplate, ids []string) []domain.ConsentTemplate {
+	byID := make(map[string]domain.ConsentTemplate, len(templates))
+	for _, t := range templates {
+		byID[t.ID] = t
+	}
+	var out []domain.ConsentTemplate
+	for _, id := range ids {
+		t, ok := byID[id]
+		if !ok {
+			log.Printf("[consent] template %s required by the appointment type is not active, skipping", id)
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
 func (s *ConsentService) Accept(ctx context.Context, consentID string) (domain.Consent, error) {
 	consent, err := s.repo.GetByID(ctx, consentID)
 	if err != nil {
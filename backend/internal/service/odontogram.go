@@ -16,6 +16,7 @@ type OdontogramService struct {
 	repo        store.OdontogramRepository
 	patientRepo store.PatientRepository
 	planRepo    store.TreatmentPlanRepository
+	access      *PatientAccess
 }
 
 // NewOdontogramService creates a new odontogram service
@@ -23,16 +24,41 @@ func NewOdontogramService(
 	repo store.OdontogramRepository,
 	patientRepo store.PatientRepository,
 	planRepo store.TreatmentPlanRepository,
+	opts ...func(*OdontogramService),
 ) *OdontogramService {
-	return &OdontogramService{
+	svc := &OdontogramService{
 		repo:        repo,
 		patientRepo: patientRepo,
 		planRepo:    planRepo,
 	}
+	for _, o := range opts {
+		o(svc)
+	}
+	return svc
+}
+
+// WithOdontogramAccess enables the org policy "doctors only see their own patients".
+func WithOdontogramAccess(a *PatientAccess) func(*OdontogramService) {
+	return func(s *OdontogramService) { s.access = a }
+}
+
+// odontogram loads an odontogram the caller may see.
+func (s *OdontogramService) odontogram(ctx context.Context, id string) (domain.Odontogram, error) {
+	odn, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Odontogram{}, err
+	}
+	if err := s.access.checkPatient(ctx, odn.PatientID); err != nil {
+		return domain.Odontogram{}, err
+	}
+	return odn, nil
 }
 
 // CreateOdontogram creates a new odontogram for a patient
 func (s *OdontogramService) CreateOdontogram(ctx context.Context, patientID, doctorID string) (domain.Odontogram, error) {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return domain.Odontogram{}, err
+	}
 	// Verify patient exists
 	_, err := s.patientRepo.GetByID(ctx, patientID)
 	if err != nil {
@@ -61,16 +87,22 @@ func (s *OdontogramService) CreateOdontogram(ctx context.Context, patientID, doc
 
 // GetOdontogramByPatient retrieves the odontogram for a specific patient
 func (s *OdontogramService) GetOdontogramByPatient(ctx context.Context, patientID string) (domain.Odontogram, error) {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return domain.Odontogram{}, err
+	}
 	return s.repo.GetByPatientID(ctx, patientID)
 }
 
 // GetOdontogramByID retrieves an odontogram by its ID
 func (s *OdontogramService) GetOdontogramByID(ctx context.Context, id string) (domain.Odontogram, error) {
-	return s.repo.GetByID(ctx, id)
+	return s.odontogram(ctx, id)
 }
 
 // UpdateOdontogram updates an existing odontogram
 func (s *OdontogramService) UpdateOdontogram(ctx context.Context, odn domain.Odontogram) (domain.Odontogram, error) {
+	if _, err := s.odontogram(ctx, odn.ID); err != nil {
+		return domain.Odontogram{}, err
+	}
 	odn.UpdatedAt = time.Now()
 	odn.LastExamDate = time.Now()
 	return s.repo.Update(ctx, odn)
@@ -78,6 +110,9 @@ func (s *OdontogramService) UpdateOdontogram(ctx context.Context, odn domain.Odo
 
 // UpdateToothCondition updates the condition of specific tooth surfaces
 func (s *OdontogramService) UpdateToothCondition(ctx context.Context, odontogramID string, toothNumber domain.ToothNumber, surfaces []domain.ToothSurfaceCondition, doctorID string) error {
+	if _, err := s.odontogram(ctx, odontogramID); err != nil {
+		return err
+	}
 	// Add doctor info to surface conditions
 	now := time.Now()
 	for i := range surfaces {
@@ -99,17 +134,26 @@ func (s *OdontogramService) RecordTreatment(ctx context.Context, treatment domai
 	if err != nil {
 		return fmt.Errorf("odontogram not found: %w", err)
 	}
+	if err := s.access.checkPatient(ctx, odontogram.PatientID); err != nil {
+		return err
+	}
 
 	return s.repo.AddTreatment(ctx, odontogram.ID, treatment)
 }
 
 // GetTreatmentHistory retrieves treatment history for a patient
 func (s *OdontogramService) GetTreatmentHistory(ctx context.Context, patientID string, limit int) ([]domain.ToothTreatment, error) {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
 	return s.repo.GetTreatmentHistory(ctx, patientID, limit)
 }
 
 // GenerateInitialAssessment creates an initial dental assessment
 func (s *OdontogramService) GenerateInitialAssessment(ctx context.Context, odontogramID string, findings map[domain.ToothNumber][]domain.ToothSurfaceCondition, doctorID string) error {
+	if _, err := s.odontogram(ctx, odontogramID); err != nil {
+		return err
+	}
 	now := time.Now()
 
 	for toothNumber, surfaces := range findings {
@@ -130,7 +174,7 @@ func (s *OdontogramService) GenerateInitialAssessment(ctx context.Context, odont
 
 // GetToothHistory gets the complete history of a specific tooth
 func (s *OdontogramService) GetToothHistory(ctx context.Context, patientID string, toothNumber domain.ToothNumber) ([]domain.ToothTreatment, error) {
-	allTreatments, err := s.repo.GetTreatmentHistory(ctx, patientID, 0)
+	allTreatments, err := s.GetTreatmentHistory(ctx, patientID, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -218,6 +262,7 @@ type TreatmentPlanService struct {
 	planRepo       store.TreatmentPlanRepository
 	odontogramRepo store.OdontogramRepository
 	patientRepo    store.PatientRepository
+	access         *PatientAccess
 }
 
 // NewTreatmentPlanService creates a new treatment plan service
@@ -225,16 +270,41 @@ func NewTreatmentPlanService(
 	planRepo store.TreatmentPlanRepository,
 	odontogramRepo store.OdontogramRepository,
 	patientRepo store.PatientRepository,
+	opts ...func(*TreatmentPlanService),
 ) *TreatmentPlanService {
-	return &TreatmentPlanService{
+	svc := &TreatmentPlanService{
 		planRepo:       planRepo,
 		odontogramRepo: odontogramRepo,
 		patientRepo:    patientRepo,
 	}
+	for _, o := range opts {
+		o(svc)
+	}
+	return svc
+}
+
+// WithTreatmentPlanAccess enables the org policy "doctors only see their own patients".
+func WithTreatmentPlanAccess(a *PatientAccess) func(*TreatmentPlanService) {
+	return func(s *TreatmentPlanService) { s.access = a }
+}
+
+// plan loads a treatment plan the caller may see.
+func (s *TreatmentPlanService) plan(ctx context.Context, id string) (domain.TreatmentPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, id)
+	if err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	if err := s.access.checkPatient(ctx, plan.PatientID); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	return plan, nil
 }
 
 // CreateTreatmentPlan creates a new treatment plan
 func (s *TreatmentPlanService) CreateTreatmentPlan(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	if err := s.access.checkPatient(ctx, plan.PatientID); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
 	// Verify patient exists
 	_, err := s.patientRepo.GetByID(ctx, plan.PatientID)
 	if err != nil {
@@ -277,16 +347,29 @@ func (s *TreatmentPlanService) CreateTreatmentPlan(ctx context.Context, plan dom
 
 // GetTreatmentPlan retrieves a treatment plan by ID
 func (s *TreatmentPlanService) GetTreatmentPlan(ctx context.Context, planID string) (domain.TreatmentPlan, error) {
-	return s.planRepo.GetByID(ctx, planID)
+	return s.plan(ctx, planID)
 }
 
 // GetPatientTreatmentPlans retrieves all treatment plans for a patient
 func (s *TreatmentPlanService) GetPatientTreatmentPlans(ctx context.Context, patientID string) ([]domain.TreatmentPlan, error) {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
 	return s.planRepo.GetByPatientID(ctx, patientID)
 }
 
 // UpdateTreatmentPlan updates an existing treatment plan
 func (s *TreatmentPlanService) UpdateTreatmentPlan(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	current, err := s.plan(ctx, plan.ID)
+	if err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	// Sin versión del cliente se sobrescribe la actual (clientes anteriores).
+	if plan.Version == 0 {
+		plan.Version = current.Version
+	}
+	plan.CreatedAt = current.CreatedAt
+
 	// Recalculate totals
 	var totalCost float64
 	var totalTime int
@@ -305,7 +388,7 @@ func (s *TreatmentPlanService) UpdateTreatmentPlan(ctx context.Context, plan dom
 
 // MarkTreatmentCompleted marks a planned treatment as completed
 func (s *TreatmentPlanService) MarkTreatmentCompleted(ctx context.Context, planID string, treatmentIndex int, completedTreatmentID string) error {
-	plan, err := s.planRepo.GetByID(ctx, planID)
+	plan, err := s.plan(ctx, planID)
 	if err != nil {
 		return err
 	}
@@ -325,7 +408,7 @@ func (s *TreatmentPlanService) MarkTreatmentCompleted(ctx context.Context, planI
 
 // ApproveTreatmentPlan approves a treatment plan for execution
 func (s *TreatmentPlanService) ApproveTreatmentPlan(ctx context.Context, planID string) error {
-	plan, err := s.planRepo.GetByID(ctx, planID)
+	plan, err := s.plan(ctx, planID)
 	if err != nil {
 		return err
 	}
@@ -344,7 +427,7 @@ func (s *TreatmentPlanService) ApproveTreatmentPlan(ctx context.Context, planID
 
 // StartTreatmentPlan marks a treatment plan as in progress
 func (s *TreatmentPlanService) StartTreatmentPlan(ctx context.Context, planID string) error {
-	plan, err := s.planRepo.GetByID(ctx, planID)
+	plan, err := s.plan(ctx, planID)
 	if err != nil {
 		return err
 	}
@@ -368,6 +451,9 @@ func (s *TreatmentPlanService) GenerateTreatmentPlanFromFindings(ctx context.Con
 	if err != nil {
 		return domain.TreatmentPlan{}, err
 	}
+	if err := s.access.checkPatient(ctx, odontogram.PatientID); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
 
 	var plannedTreatments []domain.PlannedTreatment
 	priority := 1
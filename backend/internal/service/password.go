@@ -0,0 +1,104 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Parámetros argon2id actuales (OWASP: m=19 MiB, t=2, p=1). Si se suben, los
+// hashes existentes se regeneran en el siguiente login exitoso.
+const (
+	argon2Memory  uint32 = 19 * 1024
+	argon2Time    uint32 = 2
+	argon2Threads uint8  = 1
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+)
+
+// Límites aceptados al verificar un hash guardado. Un hash corrupto o
+// manipulado con t=0 o p=0 hace entrar en pánico a argon2, y un m enorme
+// agota la memoria de la Lambda.
+const (
+	argon2MaxMemory  uint32 = 256 * 1024
+	argon2MaxTime    uint32 = 16
+	argon2MaxThreads uint8  = 16
+	argon2MinKeyLen         = 16
+	argon2MaxKeyLen         = 64
+)
+
+// dummyPasswordHash is verified when the login email does not exist, so an
+// unknown email costs the same argon2id work as a wrong password. It uses the
+// current parameters; it is only empty if crypto/rand fails.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashPassword("dummy-password-for-unknown-emails")
+	return hash
+})
+
+// hashPassword returns a PHC-style argon2id hash:
+// $argon2id$v=19$m=<mem>,t=<time>,p=<threads>$<salt>$<key>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate password salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyPassword checks password against encoded in constant time. needsRehash
+// is true when the hash is valid but uses a legacy format (unsalted SHA-256) or
+// outdated argon2 parameters.
+func verifyPassword(encoded, password string) (ok, needsRehash bool) {
+	if !strings.HasPrefix(encoded, "$") {
+		// Legacy: hex(sha256(password)) sin sal.
+		sum := sha256.Sum256([]byte(password))
+		legacy := hex.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(encoded)), []byte(legacy)) == 1 {
+			return true, true
+		}
+		return false, false
+	}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, false
+	}
+	if iterations < 1 || iterations > argon2MaxTime || threads < 1 || threads > argon2MaxThreads ||
+		memory < 8*uint32(threads) || memory > argon2MaxMemory {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) < 8 {
+		return false, false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) < argon2MinKeyLen || len(key) > argon2MaxKeyLen {
+		return false, false
+	}
+	candidate := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return false, false
+	}
+	outdated := memory != argon2Memory || iterations != argon2Time || threads != argon2Threads || uint32(len(key)) != argon2KeyLen
+	return true, outdated
+}
@@ -10,11 +10,21 @@ import (
 )
 
 type PatientService struct {
-	repo store.PatientRepository
+	repo   store.PatientRepository
+	access *PatientAccess
 }
 
-func NewPatientService(repo store.PatientRepository) *PatientService {
-	return &PatientService{repo: repo}
+func NewPatientService(repo store.PatientRepository, opts ...func(*PatientService)) *PatientService {
+	svc := &PatientService{repo: repo}
+	for _, o := range opts {
+		o(svc)
+	}
+	return svc
+}
+
+// WithPatientAccess enables the org policy "doctors only see their own patients".
+func WithPatientAccess(a *PatientAccess) func(*PatientService) {
+	return func(s *PatientService) { s.access = a }
 }
 
 type CreatePatientInput struct {
@@ -58,6 +68,9 @@ func (s *PatientService) Onboard(ctx context.Context, in CreatePatientInput) (do
 	if in.DoctorID == "" || in.FirstName == "" || in.LastName == "" {
 		return domain.Patient{}, fmt.Errorf("doctorId, firstName and lastName are required")
 	}
+	if err := checkDoctor(ctx, in.DoctorID); err != nil {
+		return domain.Patient{}, err
+	}
 
 	specialty := domain.SpecialtyOdontology
 	if in.Specialty != "" {
@@ -108,10 +121,19 @@ func (s *PatientService) GetByID(ctx context.Context, id string) (domain.Patient
 	if id == "" {
 		return domain.Patient{}, fmt.Errorf("patient id required")
 	}
+	if err := s.access.checkPatient(ctx, id); err != nil {
+		return domain.Patient{}, err
+	}
 	return s.repo.GetByID(ctx, id)
 }
 
+// ListByDoctor lists the patients assigned to doctorID ("" = whole org). A
+// restricted doctor always gets their own.
 func (s *PatientService) ListByDoctor(ctx context.Context, doctorID string) ([]domain.Patient, error) {
+	doctorID, err := ownDoctorID(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
 	return s.repo.ListByDoctor(ctx, doctorID)
 }
 
@@ -119,6 +141,10 @@ func (s *PatientService) Search(ctx context.Context, doctorID, query string) ([]
 	if query == "" {
 		return nil, fmt.Errorf("query is required")
 	}
+	doctorID, err := ownDoctorID(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
 	return s.repo.SearchByQuery(ctx, doctorID, query)
 }
 
@@ -154,13 +180,21 @@ type UpdatePatientInput struct {
 	RepresentativeDocType   string  `json:"representativeDocType,omitempty"`
 	RepresentativeDocId     string  `json:"representativeDocId,omitempty"`
 	RepresentativePhone     string  `json:"representativePhone,omitempty"`
+	// Version es la que leyó el cliente (o If-Match); 0 no comprueba.
+	Version int `json:"version,omitempty"`
 }
 
 func (s *PatientService) Update(ctx context.Context, patientID string, in UpdatePatientInput) (domain.Patient, error) {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return domain.Patient{}, err
+	}
 	patient, err := s.repo.GetByID(ctx, patientID)
 	if err != nil {
 		return domain.Patient{}, fmt.Errorf("patient not found: %w", err)
 	}
+	if err := checkClientVersion(patient.Version, in.Version); err != nil {
+		return domain.Patient{}, err
+	}
 
 	if in.FirstName != "" {
 		patient.FirstName = in.FirstName
@@ -258,5 +292,8 @@ func (s *PatientService) Update(ctx context.Context, patientID string, in Update
 }
 
 func (s *PatientService) Delete(ctx context.Context, patientID string) error {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return err
+	}
 	return s.repo.Delete(ctx, patientID)
 }
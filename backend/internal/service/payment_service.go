@@ -14,6 +14,7 @@ type PaymentService struct {
 	repo     store.PaymentRepository
 	patients store.PatientRepository
 	users    store.AuthRepository
+	access   *PatientAccess
 }
 
 func NewPaymentService(repo store.PaymentRepository, opts ...func(*PaymentService)) *PaymentService {
@@ -32,6 +33,11 @@ func WithPaymentUserRepo(r store.AuthRepository) func(*PaymentService) {
 	return func(s *PaymentService) { s.users = r }
 }
 
+// WithPaymentAccess enables the org policy "doctors only see their own patients".
+func WithPaymentAccess(a *PatientAccess) func(*PaymentService) {
+	return func(s *PaymentService) { s.access = a }
+}
+
 type CreatePaymentInput struct {
 	AppointmentID string  `json:"appointmentId"`
 	PatientID     string  `json:"patientId"`
@@ -50,6 +56,9 @@ func (s *PaymentService) CreatePayment(ctx context.Context, in CreatePaymentInpu
 	if in.Amount <= 0 {
 		return domain.PaymentRecord{}, fmt.Errorf("amount must be positive")
 	}
+	if err := s.access.checkPatient(ctx, in.PatientID); err != nil {
+		return domain.PaymentRecord{}, err
+	}
 	currency := in.Currency
 	if currency == "" {
 		currency = "USD"
@@ -87,16 +96,22 @@ func (s *PaymentService) CreatePayment(ctx context.Context, in CreatePaymentInpu
 	return s.repo.Create(ctx, p)
 }
 
+// ListPaymentsByOrg lists the org's latest payments; a restricted doctor gets
+// those of their patients among them.
 func (s *PaymentService) ListPaymentsByOrg(ctx context.Context, limit int) ([]domain.PaymentRecord, error) {
 	payments, err := s.repo.ListByOrg(ctx, limit)
 	if err != nil {
 		return nil, err
 	}
+	payments = filterByPatient(payments, s.access.filter(ctx), func(p domain.PaymentRecord) string { return p.PatientID })
 	s.enrichPayments(ctx, payments)
 	return payments, nil
 }
 
 func (s *PaymentService) ListPaymentsByPatient(ctx context.Context, patientID string) ([]domain.PaymentRecord, error) {
+	if err := s.access.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
 	payments, err := s.repo.ListByPatient(ctx, patientID)
 	if err != nil {
 		return nil, err
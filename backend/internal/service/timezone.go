@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// defaultTimezoneTTL bounds how long a cached org timezone is trusted; a
+// change in the org record is picked up at most this late (or immediately in
+// this process via Invalidate).
+const defaultTimezoneTTL = 5 * time.Minute
+
+// TimezoneResolver resolves the timezone of a tenant from its organization
+// record. Lookups are cached per org; orgs without a (valid) timezone use the
+// fallback, which defaults to UTC.
+type TimezoneResolver struct {
+	orgs     store.AuthRepository
+	fallback *time.Location
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	byOrg  map[string]cachedTimezone
+	byName map[string]*time.Location
+}
+
+type cachedTimezone struct {
+	loc     *time.Location
+	expires time.Time
+}
+
+// NewTimezoneResolver creates a resolver over orgs (may be nil: everything
+// resolves to the fallback).
+func NewTimezoneResolver(orgs store.AuthRepository, opts ...func(*TimezoneResolver)) *TimezoneResolver {
+	r := &TimezoneResolver{
+		orgs:     orgs,
+		fallback: time.UTC,
+		ttl:      defaultTimezoneTTL,
+		now:      time.Now,
+		byOrg:    map[string]cachedTimezone{},
+		byName:   map[string]*time.Location{},
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// WithFallbackTimezone sets the zone used for orgs without a timezone (the
+// former global CLINIC_TZ). Invalid names keep UTC.
+func WithFallbackTimezone(name string) func(*TimezoneResolver) {
+	return func(r *TimezoneResolver) {
+		if name = strings.TrimSpace(name); name == "" {
+			return
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			log.Printf("[timezone] invalid fallback %q, using UTC: %v", name, err)
+			return
+		}
+		r.fallback = loc
+	}
+}
+
+// WithTimezoneTTL overrides the cache TTL (0 disables caching).
+func WithTimezoneTTL(ttl time.Duration) func(*TimezoneResolver) {
+	return func(r *TimezoneResolver) { r.ttl = ttl }
+}
+
+// Fallback returns the zone used when the org has none.
+func (r *TimezoneResolver) Fallback() *time.Location {
+	return r.fallback
+}
+
+// ForOrg returns the org's timezone, loading the org record on a cache miss.
+func (r *TimezoneResolver) ForOrg(ctx context.Context, orgID string) *time.Location {
+	orgID = strings.TrimSpace(orgID)
+	if orgID == "" || r.orgs == nil {
+		return r.fallback
+	}
+	now := r.now()
+	r.mu.Lock()
+	if c, ok := r.byOrg[orgID]; ok && now.Before(c.expires) {
+		r.mu.Unlock()
+		return c.loc
+	}
+	r.mu.Unlock()
+
+	org, err := r.orgs.GetOrganization(store.ContextWithOrgID(ctx, orgID), orgID)
+	if err != nil {
+		// No se cachea: un fallo transitorio no debe fijar la zona por defecto.
+		return r.fallback
+	}
+	loc := r.FromOrg(org)
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.byOrg[orgID] = cachedTimezone{loc: loc, expires: now.Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return loc
+}
+
+// ForContext resolves the timezone of the org carried by ctx.
+func (r *TimezoneResolver) ForContext(ctx context.Context) *time.Location {
+	return r.ForOrg(ctx, store.OrgIDFromContext(ctx))
+}
+
+// FromOrg returns the timezone of an already loaded org record.
+func (r *TimezoneResolver) FromOrg(org store.Organization) *time.Location {
+	name := strings.TrimSpace(org.Timezone)
+	if name == "" {
+		return r.fallback
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if loc, ok := r.byName[name]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("[timezone] org %s has invalid timezone %q, using %s", org.ID, name, r.fallback)
+		loc = r.fallback
+	}
+	r.byName[name] = loc
+	return loc
+}
+
+// Invalidate drops the cached zone of an org (call after changing it).
+func (r *TimezoneResolver) Invalidate(orgID string) {
+	r.mu.Lock()
+	delete(r.byOrg, orgID)
+	r.mu.Unlock()
+}
+
+// dayBounds returns [start, end) of the local calendar day containing t.
+func dayBounds(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := t.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// ListByDoctorRange returns every appointment (cancelled included) starting in
+// [from, to), sorted by start; doctorID "" means every doctor of the org.
+// Repositories index by UTC day, so a local day may span two of them; every
+// UTC day touched by the range is read. The scheduler jobs use it too.
+func ListByDoctorRange(ctx context.Context, repo store.AppointmentRepository, doctorID string, from, to time.Time) ([]domain.Appointment, error) {
+	var out []domain.Appointment
+	seen := map[string]bool{}
+	first := from.UTC()
+	first = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	for day := first; day.Before(to); day = day.AddDate(0, 0, 1) {
+		items, err := repo.ListByDoctorAndDay(ctx, doctorID, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range items {
+			if seen[a.ID] || a.StartAt.Before(from) || !a.StartAt.Before(to) {
+				continue
+			}
+			seen[a.ID] = true
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartAt.Before(out[j].StartAt) })
+	return out, nil
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -27,8 +28,13 @@ type DynamoDBConfig struct {
 	UserTableName            string
 	PaymentTableName         string
 	BudgetTableName          string
+	WaitlistTableName        string
+	ResourceTableName        string
+	AppointmentTypeTableName string
 	UseLocalProfile          bool
 	ProfileName              string
+	// Endpoint apunta a DynamoDB Local (p. ej. http://localhost:8000); vacío usa AWS.
+	Endpoint string
 }
 
 // DynamoDBRepositories provides all DynamoDB repositories
@@ -44,10 +50,13 @@ type DynamoDBRepositories struct {
 	TreatmentPlans   TreatmentPlanRepository
 	Payments         PaymentRepository
 	Budgets          BudgetRepository
+	Waitlist         WaitlistRepository
+	Resources        ResourceRepository
+	AppointmentTypes AppointmentTypeRepository
 }
 
 // NewDynamoDBRepositories creates new DynamoDB repositories with table auto-creation
-func NewDynamoDBRepositories(ctx context.Context, cfg DynamoDBConfig) (*DynamoDBRepositories, error) {
+func NewDynamoDBRepositories(ctx context.Context, cfg DynamoDBConfig, opts ...func(*Tenancy)) (*DynamoDBRepositories, error) {
 	// Configure AWS SDK
 	var awsConfig aws.Config
 	var err error
@@ -70,7 +79,13 @@ func NewDynamoDBRepositories(ctx context.Context, cfg DynamoDBConfig) (*DynamoDB
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := dynamodb.NewFromConfig(awsConfig)
+	var clientOpts []func(*dynamodb.Options)
+	if cfg.Endpoint != "" {
+		clientOpts = append(clientOpts, func(o *dynamodb.Options) {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		})
+	}
+	client := dynamodb.NewFromConfig(awsConfig, clientOpts...)
 
 	repos := &DynamoDBRepositories{
 		client: client,
@@ -94,6 +109,11 @@ func NewDynamoDBRepositories(ctx context.Context, cfg DynamoDBConfig) (*DynamoDB
 		{cfg.UserTableName, &dynamoAuthRepo{client: client, tableName: cfg.UserTableName}},
 		{cfg.OdontogramTableName, &dynamoOdontogramRepo{client: client, tableName: cfg.OdontogramTableName}},
 		{cfg.TreatmentPlanTableName, &dynamoTreatmentPlanRepo{client: client, tableName: cfg.TreatmentPlanTableName}},
+		{cfg.PaymentTableName, &dynamoPaymentRepo{client: client, tableName: cfg.PaymentTableName}},
+		{cfg.BudgetTableName, &dynamoBudgetRepo{client: client, tableName: cfg.BudgetTableName}},
+		{cfg.WaitlistTableName, &dynamoWaitlistRepo{client: client, tableName: cfg.WaitlistTableName}},
+		{cfg.ResourceTableName, &dynamoResourceRepo{client: client, tableName: cfg.ResourceTableName}},
+		{cfg.AppointmentTypeTableName, &dynamoAppointmentTypeRepo{client: client, tableName: cfg.AppointmentTypeTableName}},
 	}
 
 	for _, table := range tables {
@@ -102,18 +122,22 @@ func NewDynamoDBRepositories(ctx context.Context, cfg DynamoDBConfig) (*DynamoDB
 		}
 	}
 
+	t := newTenancy(opts)
 	return &DynamoDBRepositories{
 		client:           client,
 		config:           cfg,
-		Patients:         &dynamoPatientRepo{client: client, tableName: cfg.PatientTableName},
-		Appointments:     &dynamoAppointmentRepo{client: client, tableName: cfg.AppointmentTableName},
-		Consents:         &dynamoConsentRepo{client: client, tableName: cfg.ConsentTableName},
-		ConsentTemplates: &dynamoConsentTemplateRepo{client: client, tableName: cfg.ConsentTemplateTableName},
-		Users:            &dynamoAuthRepo{client: client, tableName: cfg.UserTableName},
-		Odontograms:      &dynamoOdontogramRepo{client: client, tableName: cfg.OdontogramTableName},
-		TreatmentPlans:   &dynamoTreatmentPlanRepo{client: client, tableName: cfg.TreatmentPlanTableName},
-		Payments:         &dynamoPaymentRepo{client: client, tableName: cfg.PaymentTableName},
-		Budgets:          &dynamoBudgetRepo{client: client, tableName: cfg.BudgetTableName},
+		Patients:         &dynamoPatientRepo{Tenancy: t, client: client, tableName: cfg.PatientTableName},
+		Appointments:     &dynamoAppointmentRepo{Tenancy: t, client: client, tableName: cfg.AppointmentTableName},
+		Consents:         &dynamoConsentRepo{Tenancy: t, client: client, tableName: cfg.ConsentTableName},
+		ConsentTemplates: &dynamoConsentTemplateRepo{Tenancy: t, client: client, tableName: cfg.ConsentTemplateTableName},
+		Users:            &dynamoAuthRepo{Tenancy: t, client: client, tableName: cfg.UserTableName},
+		Odontograms:      &dynamoOdontogramRepo{Tenancy: t, client: client, tableName: cfg.OdontogramTableName},
+		TreatmentPlans:   &dynamoTreatmentPlanRepo{Tenancy: t, client: client, tableName: cfg.TreatmentPlanTableName},
+		Payments:         &dynamoPaymentRepo{Tenancy: t, client: client, tableName: cfg.PaymentTableName},
+		Budgets:          &dynamoBudgetRepo{Tenancy: t, client: client, tableName: cfg.BudgetTableName},
+		Waitlist:         &dynamoWaitlistRepo{Tenancy: t, client: client, tableName: cfg.WaitlistTableName},
+		Resources:        &dynamoResourceRepo{Tenancy: t, client: client, tableName: cfg.ResourceTableName},
+		AppointmentTypes: &dynamoAppointmentTypeRepo{Tenancy: t, client: client, tableName: cfg.AppointmentTypeTableName},
 	}, nil
 }
 
@@ -310,17 +334,9 @@ func (r *DynamoDBRepositories) createAppointmentTable(ctx context.Context, table
 				KeyType:       types.KeyTypeRange,
 			},
 		},
-		AttributeDefinitions: []types.AttributeDefinition{
-			{
-				AttributeName: aws.String("PK"),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-			{
-				AttributeName: aws.String("SK"),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-		},
-		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions:   appointmentAttributeDefinitions(),
+		GlobalSecondaryIndexes: appointmentGSIs(),
+		BillingMode:            types.BillingModePayPerRequest,
 		Tags: []types.Tag{
 			{
 				Key:   aws.String("Environment"),
@@ -369,6 +385,7 @@ func (r *DynamoDBRepositories) createConsentTable(ctx context.Context, tableName
 
 // Patient repository implementation
 type dynamoPatientRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
@@ -376,13 +393,25 @@ type dynamoPatientRepo struct {
 func orgIDOrDefault(ctx context.Context) string {
 	orgID := OrgIDFromContext(ctx)
 	if strings.TrimSpace(orgID) == "" {
-		return "default"
+		return DefaultOrgID
 	}
 	return orgID
 }
 
 func (r *dynamoPatientRepo) Create(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
-	orgID := orgIDOrDefault(ctx)
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
+	patient.Version = initialVersion(patient.Version)
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      patientItem(orgIDOrDefault(ctx), patient),
+	})
+
+	return patient, err
+}
+
+func patientItem(orgID string, patient domain.Patient) map[string]types.AttributeValue {
 	item := map[string]types.AttributeValue{
 		"PK":         &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", orgID)},
 		"SK":         &types.AttributeValueMemberS{Value: fmt.Sprintf("PATIENT#%s", patient.ID)},
@@ -396,6 +425,7 @@ func (r *dynamoPatientRepo) Create(ctx context.Context, patient domain.Patient)
 		"Email":      &types.AttributeValueMemberS{Value: patient.Email},
 		"BirthDate":  &types.AttributeValueMemberS{Value: patient.BirthDate},
 		"CreatedAt":  &types.AttributeValueMemberS{Value: patient.CreatedAt.Format(time.RFC3339)},
+		"Version":    versionAttr(patient.Version),
 	}
 
 	// Handle optional fields
@@ -409,15 +439,19 @@ func (r *dynamoPatientRepo) Create(ctx context.Context, patient domain.Patient)
 		item["ImageKeys"] = imageKeys
 	}
 
-	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
-	})
-
-	return patient, err
+	if patient.NoShowCount > 0 {
+		item["NoShowCount"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", patient.NoShowCount)}
+	}
+	if patient.LastNoShowAt != nil {
+		item["LastNoShowAt"] = &types.AttributeValueMemberS{Value: patient.LastNoShowAt.Format(time.RFC3339)}
+	}
+	return item
 }
 
 func (r *dynamoPatientRepo) GetByID(ctx context.Context, id string) (domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
@@ -445,6 +479,9 @@ func (r *dynamoPatientRepo) GetByID(ctx context.Context, id string) (domain.Pati
 }
 
 func (r *dynamoPatientRepo) ListByDoctor(ctx context.Context, doctorID string) ([]domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	input := &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
@@ -475,6 +512,9 @@ func (r *dynamoPatientRepo) ListByDoctor(ctx context.Context, doctorID string) (
 }
 
 func (r *dynamoPatientRepo) ListAll(ctx context.Context) ([]domain.Patient, error) {
+	if err := r.RequireSystem(ctx); err != nil {
+		return nil, err
+	}
 	input := &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
 		FilterExpression: aws.String("begins_with(SK, :sk)"),
@@ -498,6 +538,9 @@ func (r *dynamoPatientRepo) ListAll(ctx context.Context) ([]domain.Patient, erro
 }
 
 func (r *dynamoPatientRepo) SearchByQuery(ctx context.Context, doctorID, query string) ([]domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	q := strings.ToLower(strings.TrimSpace(query))
 
@@ -538,11 +581,21 @@ func (r *dynamoPatientRepo) SearchByQuery(ctx context.Context, doctorID, query s
 }
 
 func (r *dynamoPatientRepo) Update(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
-	// PutItem will overwrite the existing item, which is what we want for an update.
-	return r.Create(ctx, patient)
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
+	expected := patient.Version
+	patient.Version++
+	if err := putVersioned(ctx, r.client, r.tableName, patientItem(orgIDOrDefault(ctx), patient), expected); err != nil {
+		return domain.Patient{}, err
+	}
+	return patient, nil
 }
 
 func (r *dynamoPatientRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	orgID := orgIDOrDefault(ctx)
 	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String(r.tableName),
@@ -556,11 +609,29 @@ func (r *dynamoPatientRepo) Delete(ctx context.Context, id string) error {
 
 // Appointment repository implementation
 type dynamoAppointmentRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
 
 func (r *dynamoAppointmentRepo) Create(ctx context.Context, appointment domain.Appointment) (domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, appointment.OrgID); err != nil {
+		return domain.Appointment{}, err
+	}
+	appointment.Version = initialVersion(appointment.Version)
+	item, err := appointmentItem(ctx, appointment)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+
+	return appointment, err
+}
+
+func appointmentItem(ctx context.Context, appointment domain.Appointment) (map[string]types.AttributeValue, error) {
 	orgID := strings.TrimSpace(appointment.OrgID)
 	if orgID == "" {
 		orgID = orgIDOrDefault(ctx)
@@ -571,8 +642,8 @@ func (r *dynamoAppointmentRepo) Create(ctx context.Context, appointment domain.A
 		"ID":              &types.AttributeValueMemberS{Value: appointment.ID},
 		"DoctorID":        &types.AttributeValueMemberS{Value: appointment.DoctorID},
 		"PatientID":       &types.AttributeValueMemberS{Value: appointment.PatientID},
-		"StartAt":         &types.AttributeValueMemberS{Value: appointment.StartAt.Format(time.RFC3339)},
-		"EndAt":           &types.AttributeValueMemberS{Value: appointment.EndAt.Format(time.RFC3339)},
+		"StartAt":         &types.AttributeValueMemberS{Value: appointment.StartAt.UTC().Format(time.RFC3339)},
+		"EndAt":           &types.AttributeValueMemberS{Value: appointment.EndAt.UTC().Format(time.RFC3339)},
 		"DurationMinutes": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", appointment.DurationMinutes)},
 		"Status":          &types.AttributeValueMemberS{Value: appointment.Status},
 		"EvolutionNotes":  &types.AttributeValueMemberS{Value: appointment.EvolutionNotes},
@@ -580,8 +651,15 @@ func (r *dynamoAppointmentRepo) Create(ctx context.Context, appointment domain.A
 		"PaymentAmount":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%.2f", appointment.PaymentAmount)},
 		"PaymentMethod":   &types.AttributeValueMemberS{Value: appointment.PaymentMethod},
 		"PaymentPaid":     &types.AttributeValueMemberBOOL{Value: appointment.PaymentPaid},
-		"ConfirmToken":    &types.AttributeValueMemberS{Value: appointment.ConfirmToken},
 		"Reason":          &types.AttributeValueMemberS{Value: appointment.Reason},
+		"Version":         versionAttr(appointment.Version),
+	}
+	for k, v := range appointmentIndexKeys(orgID, appointment) {
+		item[k] = v
+	}
+	// ConfirmToken es clave del ConfirmTokenIndex: no se admite string vacío.
+	if appointment.ConfirmToken != "" {
+		item["ConfirmToken"] = &types.AttributeValueMemberS{Value: appointment.ConfirmToken}
 	}
 
 	// Handle optional time fields
@@ -601,16 +679,45 @@ func (r *dynamoAppointmentRepo) Create(ctx context.Context, appointment domain.A
 		}
 		item["ImageKeys"] = &types.AttributeValueMemberL{Value: vals}
 	}
-
-	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
-	})
-
-	return appointment, err
+	if appointment.CancelledAt != nil {
+		item["CancelledAt"] = &types.AttributeValueMemberS{Value: appointment.CancelledAt.Format(time.RFC3339)}
+		item["CancelledBy"] = &types.AttributeValueMemberS{Value: appointment.CancelledBy}
+		item["CancelReason"] = &types.AttributeValueMemberS{Value: appointment.CancelReason}
+	}
+	if appointment.NoShowAt != nil {
+		item["NoShowAt"] = &types.AttributeValueMemberS{Value: appointment.NoShowAt.Format(time.RFC3339)}
+	}
+	if appointment.DepositAmount > 0 {
+		item["DepositAmount"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%.2f", appointment.DepositAmount)}
+	}
+	if appointment.AppointmentTypeID != "" {
+		item["AppointmentTypeID"] = &types.AttributeValueMemberS{Value: appointment.AppointmentTypeID}
+	}
+	if len(appointment.ResourceIDs) > 0 {
+		vals := make([]types.AttributeValue, len(appointment.ResourceIDs))
+		for i, id := range appointment.ResourceIDs {
+			vals[i] = &types.AttributeValueMemberS{Value: id}
+		}
+		item["ResourceIDs"] = &types.AttributeValueMemberL{Value: vals}
+	}
+	if appointment.SeriesID != "" {
+		item["SeriesID"] = &types.AttributeValueMemberS{Value: appointment.SeriesID}
+		item["SeriesIndex"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", appointment.SeriesIndex)}
+	}
+	if len(appointment.RescheduleHistory) > 0 {
+		history, err := attributevalue.Marshal(appointment.RescheduleHistory)
+		if err != nil {
+			return nil, err
+		}
+		item["RescheduleHistory"] = history
+	}
+	return item, nil
 }
 
 func (r *dynamoAppointmentRepo) GetByID(ctx context.Context, id string) (domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Appointment{}, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
@@ -638,7 +745,17 @@ func (r *dynamoAppointmentRepo) GetByID(ctx context.Context, id string) (domain.
 }
 
 func (r *dynamoAppointmentRepo) GetByConfirmToken(ctx context.Context, token string) (domain.Appointment, error) {
-	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+	if token == "" {
+		return domain.Appointment{}, fmt.Errorf("appointment not found")
+	}
+	items, err := r.queryOrScan(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(apptConfirmTokenIndex),
+		KeyConditionExpression: aws.String("ConfirmToken = :token"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":token": &types.AttributeValueMemberS{Value: token},
+		},
+	}, &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
 		FilterExpression: aws.String("ConfirmToken = :token"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
@@ -648,10 +765,10 @@ func (r *dynamoAppointmentRepo) GetByConfirmToken(ctx context.Context, token str
 	if err != nil {
 		return domain.Appointment{}, err
 	}
-	if len(result.Items) == 0 {
+	if len(items) == 0 {
 		return domain.Appointment{}, fmt.Errorf("appointment not found")
 	}
-	item := result.Items[0]
+	item := items[0]
 	var appt domain.Appointment
 	if err := attributevalue.UnmarshalMap(item, &appt); err != nil {
 		return domain.Appointment{}, err
@@ -663,12 +780,24 @@ func (r *dynamoAppointmentRepo) GetByConfirmToken(ctx context.Context, token str
 }
 
 func (r *dynamoAppointmentRepo) ListByDoctorAndDay(ctx context.Context, doctorID string, day time.Time) ([]domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	dayStr := day.Format("2006-01-02")
 
+	var query *dynamodb.QueryInput
 	var scanInput *dynamodb.ScanInput
 	if doctorID == "" {
 		// Admin/assistant: return all appointments of the org for that day
+		query = &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			IndexName:              aws.String(apptOrgDayIndex),
+			KeyConditionExpression: aws.String("OrgDayKey = :key"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":key": &types.AttributeValueMemberS{Value: orgDayKey(orgID, dayStr)},
+			},
+		}
 		scanInput = &dynamodb.ScanInput{
 			TableName:        aws.String(r.tableName),
 			FilterExpression: aws.String("PK = :pk AND begins_with(SK, :skPrefix) AND begins_with(StartAt, :dayStr)"),
@@ -680,6 +809,14 @@ func (r *dynamoAppointmentRepo) ListByDoctorAndDay(ctx context.Context, doctorID
 		}
 	} else {
 		// Doctor: filter by their own doctorID
+		query = &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			IndexName:              aws.String(apptDoctorDayIndex),
+			KeyConditionExpression: aws.String("DoctorDayKey = :key"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":key": &types.AttributeValueMemberS{Value: doctorDayKey(orgID, doctorID, dayStr)},
+			},
+		}
 		scanInput = &dynamodb.ScanInput{
 			TableName:        aws.String(r.tableName),
 			FilterExpression: aws.String("PK = :pk AND DoctorID = :doctorID AND begins_with(StartAt, :dayStr)"),
@@ -691,27 +828,28 @@ func (r *dynamoAppointmentRepo) ListByDoctorAndDay(ctx context.Context, doctorID
 		}
 	}
 
-	result, err := r.client.Scan(ctx, scanInput)
-
+	items, err := r.queryOrScan(ctx, query, scanInput)
 	if err != nil {
 		return nil, err
 	}
-
-	var appointments []domain.Appointment
-	for _, item := range result.Items {
-		var appointment domain.Appointment
-		if err := attributevalue.UnmarshalMap(item, &appointment); err != nil {
-			continue // Skip malformed items
-		}
-		appointments = append(appointments, appointment)
-	}
-
+	appointments := unmarshalAppointments(items)
+	sortByStartAt(appointments)
 	return appointments, nil
 }
 
 func (r *dynamoAppointmentRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	orgID := orgIDOrDefault(ctx)
-	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+	items, err := r.queryOrScan(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(apptPatientIndex),
+		KeyConditionExpression: aws.String("PatientKey = :key"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key": &types.AttributeValueMemberS{Value: patientKey(orgID, patientID)},
+		},
+	}, &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
 		FilterExpression: aws.String("PK = :pk AND PatientID = :patientID AND begins_with(SK, :skPrefix)"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
@@ -723,23 +861,64 @@ func (r *dynamoAppointmentRepo) ListByPatient(ctx context.Context, patientID str
 	if err != nil {
 		return nil, err
 	}
-	var appointments []domain.Appointment
-	for _, item := range result.Items {
-		var a domain.Appointment
-		if err := attributevalue.UnmarshalMap(item, &a); err != nil {
-			continue
+	appointments := unmarshalAppointments(items)
+	sortByStartAt(appointments)
+	return appointments, nil
+}
+
+// ListPatientIDsByDoctor reads only the PatientID attribute of the org's
+// appointments with the doctor.
+func (r *dynamoAppointmentRepo) ListPatientIDsByDoctor(ctx context.Context, doctorID string) ([]string, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	orgID := orgIDOrDefault(ctx)
+	items, err := queryAll(ctx, r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :skPrefix)"),
+		FilterExpression:       aws.String("DoctorID = :doctorID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":       &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", orgID)},
+			":skPrefix": &types.AttributeValueMemberS{Value: "APPOINTMENT#"},
+			":doctorID": &types.AttributeValueMemberS{Value: doctorID},
+		},
+		ProjectionExpression: aws.String("PatientID"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	out := []string{}
+	for _, item := range items {
+		if v, ok := item["PatientID"].(*types.AttributeValueMemberS); ok && v.Value != "" && !seen[v.Value] {
+			seen[v.Value] = true
+			out = append(out, v.Value)
 		}
-		appointments = append(appointments, a)
 	}
-	return appointments, nil
+	return out, nil
 }
 
 func (r *dynamoAppointmentRepo) Update(ctx context.Context, appointment domain.Appointment) (domain.Appointment, error) {
-	return r.Create(ctx, appointment)
+	if err := r.RequireOrg(ctx, appointment.OrgID); err != nil {
+		return domain.Appointment{}, err
+	}
+	expected := appointment.Version
+	appointment.Version++
+	item, err := appointmentItem(ctx, appointment)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	if err := putVersioned(ctx, r.client, r.tableName, item, expected); err != nil {
+		return domain.Appointment{}, err
+	}
+	return appointment, nil
 }
 
 func (r *dynamoAppointmentRepo) ScanAllPayments(ctx context.Context) ([]PaymentSummary, error) {
-	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+	if err := r.RequireSystem(ctx); err != nil {
+		return nil, err
+	}
+	items, err := scanAll(ctx, r.client, &dynamodb.ScanInput{
 		TableName:                aws.String(r.tableName),
 		FilterExpression:         aws.String("begins_with(SK, :skPrefix) AND #st = :completed"),
 		ExpressionAttributeNames: map[string]string{"#st": "Status"},
@@ -752,8 +931,8 @@ func (r *dynamoAppointmentRepo) ScanAllPayments(ctx context.Context) ([]PaymentS
 	if err != nil {
 		return nil, err
 	}
-	summaries := make([]PaymentSummary, 0, len(result.Items))
-	for _, item := range result.Items {
+	summaries := make([]PaymentSummary, 0, len(items))
+	for _, item := range items {
 		var s PaymentSummary
 		if pk, ok := item["PK"].(*types.AttributeValueMemberS); ok {
 			s.OrgID = strings.TrimPrefix(pk.Value, "ORG#")
@@ -770,9 +949,12 @@ func (r *dynamoAppointmentRepo) ScanAllPayments(ctx context.Context) ([]PaymentS
 }
 
 func (r *dynamoAppointmentRepo) ScanOrgPayments(ctx context.Context, orgID string) ([]PaymentSummary, error) {
-	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	items, err := queryAll(ctx, r.client, &dynamodb.QueryInput{
 		TableName:                aws.String(r.tableName),
-		FilterExpression:         aws.String("PK = :pk AND begins_with(SK, :skPrefix)"),
+		KeyConditionExpression:   aws.String("PK = :pk AND begins_with(SK, :skPrefix)"),
 		ExpressionAttributeNames: map[string]string{"#st": "Status"},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":pk":       &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", orgID)},
@@ -783,8 +965,8 @@ func (r *dynamoAppointmentRepo) ScanOrgPayments(ctx context.Context, orgID strin
 	if err != nil {
 		return nil, err
 	}
-	summaries := make([]PaymentSummary, 0, len(result.Items))
-	for _, item := range result.Items {
+	summaries := make([]PaymentSummary, 0, len(items))
+	for _, item := range items {
 		var s PaymentSummary
 		s.OrgID = orgID
 		if st, ok := item["Status"].(*types.AttributeValueMemberS); ok {
@@ -799,6 +981,9 @@ func (r *dynamoAppointmentRepo) ScanOrgPayments(ctx context.Context, orgID strin
 }
 
 func (r *dynamoAppointmentRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	orgID := orgIDOrDefault(ctx)
 	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String(r.tableName),
@@ -812,11 +997,15 @@ func (r *dynamoAppointmentRepo) Delete(ctx context.Context, id string) error {
 
 // Consent repository implementation
 type dynamoConsentRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
 
 func (r *dynamoConsentRepo) Create(ctx context.Context, consent domain.Consent) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, consent.OrgID); err != nil {
+		return domain.Consent{}, err
+	}
 	orgID := strings.TrimSpace(consent.OrgID)
 	if orgID == "" {
 		orgID = orgIDOrDefault(ctx)
@@ -850,6 +1039,9 @@ func (r *dynamoConsentRepo) Create(ctx context.Context, consent domain.Consent)
 }
 
 func (r *dynamoConsentRepo) Update(ctx context.Context, consent domain.Consent) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, consent.OrgID); err != nil {
+		return domain.Consent{}, err
+	}
 	if strings.TrimSpace(consent.OrgID) == "" {
 		if _, err := r.GetByID(ctx, consent.ID); err != nil {
 			return domain.Consent{}, err
@@ -859,6 +1051,9 @@ func (r *dynamoConsentRepo) Update(ctx context.Context, consent domain.Consent)
 }
 
 func (r *dynamoConsentRepo) GetByID(ctx context.Context, id string) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Consent{}, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
@@ -882,7 +1077,7 @@ func (r *dynamoConsentRepo) GetByToken(ctx context.Context, token string) (domai
 	orgID := OrgIDFromContext(ctx)
 	// Enlace público (aceptar consentimiento desde el correo): no hay org en contexto.
 	// Buscar solo por token en toda la tabla para que el link funcione.
-	if strings.TrimSpace(orgID) == "" || orgID == "default" {
+	if strings.TrimSpace(orgID) == "" || orgID == DefaultOrgID {
 		var lastKey map[string]types.AttributeValue
 		for {
 			scanInput := &dynamodb.ScanInput{
@@ -940,6 +1135,9 @@ func (r *dynamoConsentRepo) GetByToken(ctx context.Context, token string) (domai
 }
 
 func (r *dynamoConsentRepo) GetByAppointmentID(ctx context.Context, appointmentID string) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Consent{}, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
@@ -962,6 +1160,9 @@ func (r *dynamoConsentRepo) GetByAppointmentID(ctx context.Context, appointmentI
 }
 
 func (r *dynamoConsentRepo) ListByAppointmentID(ctx context.Context, appointmentID string) ([]domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
@@ -987,11 +1188,15 @@ func (r *dynamoConsentRepo) ListByAppointmentID(ctx context.Context, appointment
 
 // ConsentTemplate DynamoDB repository
 type dynamoConsentTemplateRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
 
 func (r *dynamoConsentTemplateRepo) Create(ctx context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
 	item := map[string]types.AttributeValue{
 		"PK":        &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", t.OrgID)},
 		"SK":        &types.AttributeValueMemberS{Value: fmt.Sprintf("CONSENT_TEMPLATE#%s", t.ID)},
@@ -1012,11 +1217,25 @@ func (r *dynamoConsentTemplateRepo) Create(ctx context.Context, t domain.Consent
 }
 
 func (r *dynamoConsentTemplateRepo) Update(ctx context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	current, err := r.GetByID(ctx, t.ID)
+	if err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	if t.OrgID != "" && t.OrgID != current.OrgID {
+		return domain.ConsentTemplate{}, fmt.Errorf("consent template not found")
+	}
+	t.OrgID = current.OrgID
 	t.UpdatedAt = time.Now().UTC()
 	return r.Create(ctx, t)
 }
 
 func (r *dynamoConsentTemplateRepo) GetByID(ctx context.Context, id string) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
 	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
 		FilterExpression: aws.String("ID = :id AND begins_with(SK, :skPrefix)"),
@@ -1032,11 +1251,19 @@ func (r *dynamoConsentTemplateRepo) GetByID(ctx context.Context, id string) (dom
 		return domain.ConsentTemplate{}, fmt.Errorf("consent template not found")
 	}
 	var t domain.ConsentTemplate
-	err = attributevalue.UnmarshalMap(result.Items[0], &t)
-	return t, err
+	if err := attributevalue.UnmarshalMap(result.Items[0], &t); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	if !r.VisibleTo(ctx, t.OrgID) {
+		return domain.ConsentTemplate{}, fmt.Errorf("consent template not found")
+	}
+	return t, nil
 }
 
 func (r *dynamoConsentTemplateRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
 	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
 		FilterExpression: aws.String("PK = :pk AND begins_with(SK, :skPrefix)"),
@@ -1059,6 +1286,9 @@ func (r *dynamoConsentTemplateRepo) ListByOrg(ctx context.Context, orgID string)
 }
 
 func (r *dynamoConsentTemplateRepo) GetActiveByOrg(ctx context.Context, orgID string) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
 	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
 		FilterExpression: aws.String("PK = :pk AND begins_with(SK, :skPrefix) AND IsActive = :active"),
@@ -1080,6 +1310,9 @@ func (r *dynamoConsentTemplateRepo) GetActiveByOrg(ctx context.Context, orgID st
 }
 
 func (r *dynamoConsentTemplateRepo) ListActiveByOrg(ctx context.Context, orgID string) ([]domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
 	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
 		FilterExpression: aws.String("PK = :pk AND begins_with(SK, :skPrefix) AND IsActive = :active"),
@@ -1104,6 +1337,7 @@ func (r *dynamoConsentTemplateRepo) ListActiveByOrg(ctx context.Context, orgID s
 
 // Auth repository implementation
 type dynamoAuthRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
@@ -1135,6 +1369,13 @@ func (r *dynamoAuthRepo) CreateUser(ctx context.Context, user AuthUser) (AuthUse
 		"MustChangePassword": &types.AttributeValueMemberBOOL{Value: user.MustChangePassword},
 		"CreatedAt":          &types.AttributeValueMemberS{Value: user.CreatedAt.Format(time.RFC3339)},
 	}
+	if user.Availability != nil {
+		av, err := attributevalue.Marshal(user.Availability)
+		if err != nil {
+			return AuthUser{}, err
+		}
+		item["Availability"] = av
+	}
 
 	// Also create email index entry
 	emailItem := map[string]types.AttributeValue{
@@ -1270,28 +1511,41 @@ func (r *dynamoAuthRepo) UpdateUser(ctx context.Context, user AuthUser) (AuthUse
 		user.Status = "active"
 	}
 
+	updateExpr := "SET #Name = :name, OrgID = :orgId, #Role = :role, #Status = :status, Phone = :phone, Address = :address, MustChangePassword = :mcp"
+	values := map[string]types.AttributeValue{
+		":name":    &types.AttributeValueMemberS{Value: user.Name},
+		":orgId":   &types.AttributeValueMemberS{Value: user.OrgID},
+		":role":    &types.AttributeValueMemberS{Value: user.Role},
+		":status":  &types.AttributeValueMemberS{Value: user.Status},
+		":phone":   &types.AttributeValueMemberS{Value: user.Phone},
+		":address": &types.AttributeValueMemberS{Value: user.Address},
+		":mcp":     &types.AttributeValueMemberBOOL{Value: user.MustChangePassword},
+	}
+	if user.Availability != nil {
+		av, err := attributevalue.Marshal(user.Availability)
+		if err != nil {
+			return AuthUser{}, err
+		}
+		updateExpr += ", Availability = :av"
+		values[":av"] = av
+	} else {
+		updateExpr += " REMOVE Availability"
+	}
+
 	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", user.ID)},
 			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", user.ID)},
 		},
-		UpdateExpression: aws.String("SET #Name = :name, OrgID = :orgId, #Role = :role, #Status = :status, Phone = :phone, Address = :address, MustChangePassword = :mcp"),
+		UpdateExpression: aws.String(updateExpr),
 		ExpressionAttributeNames: map[string]string{
 			"#Name":   "Name",
 			"#Role":   "Role",
 			"#Status": "Status",
 		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":name":    &types.AttributeValueMemberS{Value: user.Name},
-			":orgId":   &types.AttributeValueMemberS{Value: user.OrgID},
-			":role":    &types.AttributeValueMemberS{Value: user.Role},
-			":status":  &types.AttributeValueMemberS{Value: user.Status},
-			":phone":   &types.AttributeValueMemberS{Value: user.Phone},
-			":address": &types.AttributeValueMemberS{Value: user.Address},
-			":mcp":     &types.AttributeValueMemberBOOL{Value: user.MustChangePassword},
-		},
-		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
 	})
 	if err != nil {
 		return AuthUser{}, err
@@ -1322,6 +1576,9 @@ func (r *dynamoAuthRepo) UpdateUser(ctx context.Context, user AuthUser) (AuthUse
 }
 
 func (r *dynamoAuthRepo) DeleteUser(ctx context.Context, orgID, userID string) error {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return err
+	}
 	user, err := r.GetUserByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("user not found")
@@ -1361,6 +1618,9 @@ func (r *dynamoAuthRepo) DeleteUser(ctx context.Context, orgID, userID string) e
 }
 
 func (r *dynamoAuthRepo) ListUsersByOrg(ctx context.Context, orgID string) ([]AuthUser, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
 	out, err := r.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(r.tableName),
 		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
@@ -1418,6 +1678,22 @@ func orgToItem(org Organization) map[string]types.AttributeValue {
 	if org.Timezone != "" {
 		item["Timezone"] = &types.AttributeValueMemberS{Value: org.Timezone}
 	}
+	if org.NoShowPolicy != nil {
+		if v, err := attributevalue.Marshal(org.NoShowPolicy); err == nil {
+			item["NoShowPolicy"] = v
+		}
+	}
+	if org.Roles != nil {
+		if v, err := attributevalue.Marshal(org.Roles); err == nil {
+			item["Roles"] = v
+		}
+	}
+	if org.RequireMFA {
+		item["RequireMFA"] = &types.AttributeValueMemberBOOL{Value: true}
+	}
+	if org.DoctorsOwnPatientsOnly {
+		item["DoctorsOwnPatientsOnly"] = &types.AttributeValueMemberBOOL{Value: true}
+	}
 	if org.UpdatedAt != nil {
 		item["UpdatedAt"] = &types.AttributeValueMemberS{Value: org.UpdatedAt.Format(time.RFC3339)}
 	}
@@ -1468,6 +1744,24 @@ func itemToOrg(item map[string]types.AttributeValue) Organization {
 	if v, ok := item["Timezone"]; ok {
 		org.Timezone = v.(*types.AttributeValueMemberS).Value
 	}
+	if v, ok := item["NoShowPolicy"]; ok {
+		var p domain.NoShowPolicy
+		if err := attributevalue.Unmarshal(v, &p); err == nil {
+			org.NoShowPolicy = &p
+		}
+	}
+	if v, ok := item["Roles"]; ok {
+		var roles []OrgRole
+		if err := attributevalue.Unmarshal(v, &roles); err == nil {
+			org.Roles = roles
+		}
+	}
+	if v, ok := item["RequireMFA"].(*types.AttributeValueMemberBOOL); ok {
+		org.RequireMFA = v.Value
+	}
+	if v, ok := item["DoctorsOwnPatientsOnly"].(*types.AttributeValueMemberBOOL); ok {
+		org.DoctorsOwnPatientsOnly = v.Value
+	}
 	if v, ok := item["UpdatedAt"]; ok {
 		t, _ := time.Parse(time.RFC3339, v.(*types.AttributeValueMemberS).Value)
 		org.UpdatedAt = &t
@@ -1550,6 +1844,43 @@ func (r *dynamoAuthRepo) ListOrganizations(ctx context.Context) ([]Organization,
 }
 
 func (r *dynamoAuthRepo) CreateSession(ctx context.Context, session AuthSession) (AuthSession, error) {
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(r.tableName), Item: sessionItem(session)})
+	if err != nil || session.ID == "" {
+		return session, err
+	}
+	// Puntero ID → token del registro vigente, para renovar sin Scan.
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(r.tableName), Item: sessionPointerItem(session)})
+	return session, err
+}
+
+// RotateSession borra el registro anterior (con la condición sobre su
+// RefreshToken), crea el nuevo y mueve el puntero en una sola transacción.
+func (r *dynamoAuthRepo) RotateSession(ctx context.Context, oldToken, refreshToken string, next AuthSession) error {
+	_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+		{Delete: &types.Delete{
+			TableName: aws.String(r.tableName),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%s", oldToken)},
+				"SK": &types.AttributeValueMemberS{Value: "SESSION"},
+			},
+			ConditionExpression:       aws.String("RefreshToken = :refresh"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":refresh": &types.AttributeValueMemberS{Value: refreshToken}},
+		}},
+		{Put: &types.Put{
+			TableName:           aws.String(r.tableName),
+			Item:                sessionItem(next),
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		}},
+		{Put: &types.Put{TableName: aws.String(r.tableName), Item: sessionPointerItem(next)}},
+	}})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		return ErrConflict
+	}
+	return err
+}
+
+func sessionItem(session AuthSession) map[string]types.AttributeValue {
 	item := map[string]types.AttributeValue{
 		"PK":        &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%s", session.Token)},
 		"SK":        &types.AttributeValueMemberS{Value: "SESSION"},
@@ -1559,8 +1890,70 @@ func (r *dynamoAuthRepo) CreateSession(ctx context.Context, session AuthSession)
 		"Role":      &types.AttributeValueMemberS{Value: session.Role},
 		"ExpiresAt": &types.AttributeValueMemberS{Value: session.ExpiresAt.Format(time.RFC3339)},
 	}
-	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(r.tableName), Item: item})
-	return session, err
+	if session.ID != "" {
+		item["ID"] = &types.AttributeValueMemberS{Value: session.ID}
+		item["RefreshToken"] = &types.AttributeValueMemberS{Value: session.RefreshToken}
+		item["RefreshExpiresAt"] = &types.AttributeValueMemberS{Value: session.RefreshExpiresAt.Format(time.RFC3339)}
+		item["PreviousRefreshToken"] = &types.AttributeValueMemberS{Value: session.PreviousRefreshToken}
+		item["CreatedAt"] = &types.AttributeValueMemberS{Value: session.CreatedAt.Format(time.RFC3339)}
+		item["LastRefreshAt"] = &types.AttributeValueMemberS{Value: session.LastRefreshAt.Format(time.RFC3339)}
+		item["UserAgent"] = &types.AttributeValueMemberS{Value: session.UserAgent}
+		item["IP"] = &types.AttributeValueMemberS{Value: session.IP}
+	}
+	return item
+}
+
+func sessionPointerItem(session AuthSession) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK":    &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSIONID#%s", session.ID)},
+		"SK":    &types.AttributeValueMemberS{Value: "SESSIONID"},
+		"Token": &types.AttributeValueMemberS{Value: session.Token},
+	}
+}
+
+func (r *dynamoAuthRepo) GetSessionByID(ctx context.Context, sessionID string) (AuthSession, error) {
+	if sessionID == "" {
+		return AuthSession{}, fmt.Errorf("session not found")
+	}
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSIONID#%s", sessionID)},
+			"SK": &types.AttributeValueMemberS{Value: "SESSIONID"},
+		},
+	})
+	if err != nil {
+		return AuthSession{}, err
+	}
+	token, ok := out.Item["Token"].(*types.AttributeValueMemberS)
+	if !ok {
+		return AuthSession{}, fmt.Errorf("session not found")
+	}
+	return r.GetSession(ctx, token.Value)
+}
+
+func (r *dynamoAuthRepo) ListSessionsByUser(ctx context.Context, userID string) ([]AuthSession, error) {
+	items, err := scanAll(ctx, r.client, &dynamodb.ScanInput{
+		TableName:        aws.String(r.tableName),
+		FilterExpression: aws.String("SK = :sk AND UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sk":     &types.AttributeValueMemberS{Value: "SESSION"},
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AuthSession, 0, len(items))
+	for _, item := range items {
+		var s AuthSession
+		if err := attributevalue.UnmarshalMap(item, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	sortSessionsNewestFirst(out)
+	return out, nil
 }
 
 func (r *dynamoAuthRepo) GetSession(ctx context.Context, token string) (AuthSession, error) {
@@ -1583,6 +1976,7 @@ func (r *dynamoAuthRepo) GetSession(ctx context.Context, token string) (AuthSess
 }
 
 func (r *dynamoAuthRepo) DeleteSession(ctx context.Context, token string) error {
+	session, getErr := r.GetSession(ctx, token)
 	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
@@ -1590,13 +1984,31 @@ func (r *dynamoAuthRepo) DeleteSession(ctx context.Context, token string) error
 			"SK": &types.AttributeValueMemberS{Value: "SESSION"},
 		},
 	})
-	return err
-}
-
-func (r *dynamoAuthRepo) CreateInvitation(ctx context.Context, inv UserInvitation) (UserInvitation, error) {
-	item := map[string]types.AttributeValue{
-		"PK":           &types.AttributeValueMemberS{Value: fmt.Sprintf("INVITE#%s", inv.Token)},
-		"SK":           &types.AttributeValueMemberS{Value: "INVITE"},
+	if err != nil || getErr != nil || session.ID == "" {
+		return err
+	}
+	// El puntero solo se borra si todavía apunta a este token.
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSIONID#%s", session.ID)},
+			"SK": &types.AttributeValueMemberS{Value: "SESSIONID"},
+		},
+		ConditionExpression:       aws.String("#token = :token"),
+		ExpressionAttributeNames:  map[string]string{"#token": "Token"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":token": &types.AttributeValueMemberS{Value: token}},
+	})
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return nil
+	}
+	return err
+}
+
+func (r *dynamoAuthRepo) CreateInvitation(ctx context.Context, inv UserInvitation) (UserInvitation, error) {
+	item := map[string]types.AttributeValue{
+		"PK":           &types.AttributeValueMemberS{Value: fmt.Sprintf("INVITE#%s", inv.Token)},
+		"SK":           &types.AttributeValueMemberS{Value: "INVITE"},
 		"Token":        &types.AttributeValueMemberS{Value: inv.Token},
 		"OrgID":        &types.AttributeValueMemberS{Value: inv.OrgID},
 		"Email":        &types.AttributeValueMemberS{Value: strings.ToLower(strings.TrimSpace(inv.Email))},
@@ -1662,6 +2074,195 @@ func (r *dynamoAuthRepo) UpdateUserPassword(ctx context.Context, userID, passwor
 	return err
 }
 
+// SetCalendarToken stores the token on the user item plus a CALFEED#<token>
+// index item (same pattern as EMAIL#) so the feed is resolved with a GetItem.
+func (r *dynamoAuthRepo) SetCalendarToken(ctx context.Context, userID, token string) error {
+	user, err := r.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	userKey := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userID)},
+		"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userID)},
+	}
+	update := &types.Update{
+		TableName:           aws.String(r.tableName),
+		Key:                 userKey,
+		UpdateExpression:    aws.String("REMOVE CalendarToken"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+	}
+	if token != "" {
+		update.UpdateExpression = aws.String("SET CalendarToken = :token")
+		update.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":token": &types.AttributeValueMemberS{Value: token},
+		}
+	}
+	transactItems := []types.TransactWriteItem{{Update: update}}
+	if user.CalendarToken != "" {
+		transactItems = append(transactItems, types.TransactWriteItem{Delete: &types.Delete{
+			TableName: aws.String(r.tableName),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("CALFEED#%s", user.CalendarToken)},
+				"SK": &types.AttributeValueMemberS{Value: "USER"},
+			},
+		}})
+	}
+	if token != "" {
+		transactItems = append(transactItems, types.TransactWriteItem{Put: &types.Put{
+			TableName: aws.String(r.tableName),
+			Item: map[string]types.AttributeValue{
+				"PK":     &types.AttributeValueMemberS{Value: fmt.Sprintf("CALFEED#%s", token)},
+				"SK":     &types.AttributeValueMemberS{Value: "USER"},
+				"UserID": &types.AttributeValueMemberS{Value: userID},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		}})
+	}
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+	return err
+}
+
+func (r *dynamoAuthRepo) SetUserMFA(ctx context.Context, userID string, mfa UserMFA) error {
+	av, err := attributevalue.Marshal(mfa)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userID)},
+			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userID)},
+		},
+		UpdateExpression:          aws.String("SET MFA = :mfa"),
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":mfa": av},
+	})
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return fmt.Errorf("user not found")
+	}
+	return err
+}
+
+// Intentos de autenticación: un item THROTTLE#<key> por clave.
+func throttleKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "THROTTLE#" + key},
+		"SK": &types.AttributeValueMemberS{Value: "THROTTLE"},
+	}
+}
+
+func (r *dynamoAuthRepo) GetAuthThrottle(ctx context.Context, key string) (AuthThrottle, error) {
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            throttleKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return AuthThrottle{}, err
+	}
+	if out.Item == nil {
+		return AuthThrottle{}, fmt.Errorf("throttle not found")
+	}
+	var t AuthThrottle
+	err = attributevalue.UnmarshalMap(out.Item, &t)
+	return t, err
+}
+
+// RecordAuthFailure reads the count and writes the next one only if no other
+// failure was recorded in between (LastFailureAt unchanged), retrying
+// otherwise. The lock attributes are not touched.
+func (r *dynamoAuthRepo) RecordAuthFailure(ctx context.Context, key string, at time.Time, window time.Duration) (AuthThrottle, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		current, err := r.GetAuthThrottle(ctx, key)
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return AuthThrottle{}, err
+		}
+		next := NextAuthFailure(current, key, at, window)
+		values := map[string]types.AttributeValue{}
+		for name, v := range map[string]any{":key": next.Key, ":failures": next.Failures, ":start": next.WindowStart, ":last": next.LastFailureAt} {
+			if values[name], err = attributevalue.Marshal(v); err != nil {
+				return AuthThrottle{}, err
+			}
+		}
+		cond := "attribute_not_exists(PK)"
+		if !current.LastFailureAt.IsZero() {
+			cond = "LastFailureAt = :prev"
+			if values[":prev"], err = attributevalue.Marshal(current.LastFailureAt); err != nil {
+				return AuthThrottle{}, err
+			}
+		}
+		_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(r.tableName),
+			Key:                       throttleKey(key),
+			UpdateExpression:          aws.String("SET #key = :key, Failures = :failures, WindowStart = :start, LastFailureAt = :last"),
+			ConditionExpression:       aws.String(cond),
+			ExpressionAttributeNames:  map[string]string{"#key": "Key"},
+			ExpressionAttributeValues: values,
+		})
+		var failed *types.ConditionalCheckFailedException
+		if errors.As(err, &failed) {
+			continue
+		}
+		if err != nil {
+			return AuthThrottle{}, err
+		}
+		return next, nil
+	}
+	return AuthThrottle{}, fmt.Errorf("record auth failure %s: too much contention", key)
+}
+
+func (r *dynamoAuthRepo) LockAuthThrottle(ctx context.Context, key string, until time.Time, unlockTokenHash string) error {
+	lockedUntil, err := attributevalue.Marshal(until)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(r.tableName),
+		Key:                      throttleKey(key),
+		UpdateExpression:         aws.String("SET #key = :key, LockedUntil = :until, UnlockTokenHash = :hash"),
+		ExpressionAttributeNames: map[string]string{"#key": "Key"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key":   &types.AttributeValueMemberS{Value: key},
+			":until": lockedUntil,
+			":hash":  &types.AttributeValueMemberS{Value: unlockTokenHash},
+		},
+	})
+	return err
+}
+
+func (r *dynamoAuthRepo) DeleteAuthThrottle(ctx context.Context, key string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       throttleKey(key),
+	})
+	return err
+}
+
+func (r *dynamoAuthRepo) GetUserByCalendarToken(ctx context.Context, token string) (AuthUser, error) {
+	if token == "" {
+		return AuthUser{}, fmt.Errorf("user not found")
+	}
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("CALFEED#%s", token)},
+			"SK": &types.AttributeValueMemberS{Value: "USER"},
+		},
+	})
+	if err != nil {
+		return AuthUser{}, err
+	}
+	if result.Item == nil {
+		return AuthUser{}, fmt.Errorf("user not found")
+	}
+	userID, ok := result.Item["UserID"].(*types.AttributeValueMemberS)
+	if !ok {
+		return AuthUser{}, fmt.Errorf("invalid calendar feed index entry")
+	}
+	return r.GetUserByID(ctx, userID.Value)
+}
+
 func (r *dynamoAuthRepo) SaveResetToken(ctx context.Context, token PasswordResetToken) (PasswordResetToken, error) {
 	item := map[string]types.AttributeValue{
 		"PK":        &types.AttributeValueMemberS{Value: fmt.Sprintf("RESET_TOKEN#%s", token.Token)},
@@ -1724,11 +2325,15 @@ func (r *dynamoAuthRepo) MarkResetTokenUsed(ctx context.Context, token string) e
 // ─────────────────────────────────────────────────────────────────────────────
 
 type dynamoPaymentRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
 
 func (r *dynamoPaymentRepo) Create(ctx context.Context, p domain.PaymentRecord) (domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.PaymentRecord{}, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	sk := fmt.Sprintf("PAYMENT#%s#%s", p.CreatedAt.UTC().Format("20060102T150405"), p.ID)
 	item := map[string]types.AttributeValue{
@@ -1760,6 +2365,9 @@ func (r *dynamoPaymentRepo) Create(ctx context.Context, p domain.PaymentRecord)
 }
 
 func (r *dynamoPaymentRepo) ListByOrg(ctx context.Context, limit int) ([]domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(r.tableName),
@@ -1789,6 +2397,9 @@ func (r *dynamoPaymentRepo) ListByOrg(ctx context.Context, limit int) ([]domain.
 }
 
 func (r *dynamoPaymentRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	orgID := orgIDOrDefault(ctx)
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(r.tableName),
@@ -1812,6 +2423,7 @@ func (r *dynamoPaymentRepo) ListByPatient(ctx context.Context, patientID string)
 		}
 		payments = append(payments, p)
 	}
+	sortPaymentsNewestFirst(payments)
 	return payments, nil
 }
 
@@ -1820,19 +2432,21 @@ func (r *dynamoPaymentRepo) ListByPatient(ctx context.Context, patientID string)
 // ─────────────────────────────────────────────────────────────────────────────
 
 type dynamoBudgetRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
 
 func (r *dynamoBudgetRepo) Create(ctx context.Context, b domain.Budget) (domain.Budget, error) {
-	orgID := orgIDOrDefault(ctx)
-	b.OrgID = orgID
-	data, err := attributevalue.MarshalMap(b)
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
+	b.OrgID = orgIDOrDefault(ctx)
+	b.Version = initialVersion(b.Version)
+	data, err := budgetItem(b)
 	if err != nil {
 		return domain.Budget{}, err
 	}
-	data["PK"] = &types.AttributeValueMemberS{Value: fmt.Sprintf("PATIENT#%s", b.PatientID)}
-	data["SK"] = &types.AttributeValueMemberS{Value: fmt.Sprintf("BUDGET#%s", b.ID)}
 	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName: aws.String(r.tableName),
 		Item:      data,
@@ -1840,7 +2454,20 @@ func (r *dynamoBudgetRepo) Create(ctx context.Context, b domain.Budget) (domain.
 	return b, err
 }
 
+func budgetItem(b domain.Budget) (map[string]types.AttributeValue, error) {
+	data, err := attributevalue.MarshalMap(b)
+	if err != nil {
+		return nil, err
+	}
+	data["PK"] = &types.AttributeValueMemberS{Value: fmt.Sprintf("PATIENT#%s", b.PatientID)}
+	data["SK"] = &types.AttributeValueMemberS{Value: fmt.Sprintf("BUDGET#%s", b.ID)}
+	return data, nil
+}
+
 func (r *dynamoBudgetRepo) GetByID(ctx context.Context, id string) (domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
 	input := &dynamodb.ScanInput{
 		TableName:        aws.String(r.tableName),
 		FilterExpression: aws.String("ID = :id"),
@@ -1859,10 +2486,16 @@ func (r *dynamoBudgetRepo) GetByID(ctx context.Context, id string) (domain.Budge
 	if err := attributevalue.UnmarshalMap(result.Items[0], &b); err != nil {
 		return domain.Budget{}, err
 	}
+	if !r.VisibleTo(ctx, b.OrgID) {
+		return domain.Budget{}, fmt.Errorf("budget not found")
+	}
 	return b, nil
 }
 
 func (r *dynamoBudgetRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(r.tableName),
 		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
@@ -1879,19 +2512,43 @@ func (r *dynamoBudgetRepo) ListByPatient(ctx context.Context, patientID string)
 	var budgets []domain.Budget
 	for _, item := range result.Items {
 		var b domain.Budget
-		if err := attributevalue.UnmarshalMap(item, &b); err != nil {
+		if err := attributevalue.UnmarshalMap(item, &b); err != nil || !r.VisibleTo(ctx, b.OrgID) {
 			continue
 		}
 		budgets = append(budgets, b)
 	}
+	// SK es BUDGET#<id>: el orden de la query no es cronológico.
+	sortBudgetsNewestFirst(budgets)
 	return budgets, nil
 }
 
 func (r *dynamoBudgetRepo) Update(ctx context.Context, b domain.Budget) (domain.Budget, error) {
-	return r.Create(ctx, b)
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
+	// La tabla está particionada por paciente: se comprueba el dueño antes de
+	// escribir y se conserva su OrgID.
+	current, err := r.GetByID(ctx, b.ID)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	b.OrgID = current.OrgID
+	expected := b.Version
+	b.Version++
+	data, err := budgetItem(b)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	if err := putVersioned(ctx, r.client, r.tableName, data, expected); err != nil {
+		return domain.Budget{}, err
+	}
+	return b, nil
 }
 
 func (r *dynamoBudgetRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	b, err := r.GetByID(ctx, id)
 	if err != nil {
 		return err
@@ -1905,3 +2562,347 @@ func (r *dynamoBudgetRepo) Delete(ctx context.Context, id string) error {
 	})
 	return err
 }
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Waitlist DynamoDB Repository
+// ─────────────────────────────────────────────────────────────────────────────
+
+type dynamoWaitlistRepo struct {
+	Tenancy
+	client    *dynamodb.Client
+	tableName string
+}
+
+func waitlistKey(e domain.WaitlistEntry) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("DOCTOR#%s", e.DoctorID)},
+		"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("WAITLIST#%s", e.ID)},
+	}
+}
+
+func (r *dynamoWaitlistRepo) Create(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, e.OrgID); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if e.OrgID == "" {
+		e.OrgID = orgIDOrDefault(ctx)
+	}
+	data, err := attributevalue.MarshalMap(e)
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	for k, v := range waitlistKey(e) {
+		data[k] = v
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      data,
+	})
+	return e, err
+}
+
+func (r *dynamoWaitlistRepo) scanOne(ctx context.Context, filter string, values map[string]types.AttributeValue) (domain.WaitlistEntry, error) {
+	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+		TableName:                 aws.String(r.tableName),
+		FilterExpression:          aws.String(filter),
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if len(result.Items) == 0 {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist entry not found")
+	}
+	var e domain.WaitlistEntry
+	if err := attributevalue.UnmarshalMap(result.Items[0], &e); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	return e, nil
+}
+
+func (r *dynamoWaitlistRepo) GetByID(ctx context.Context, id string) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	e, err := r.scanOne(ctx, "ID = :id", map[string]types.AttributeValue{
+		":id": &types.AttributeValueMemberS{Value: id},
+	})
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if !r.VisibleTo(ctx, e.OrgID) {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist entry not found")
+	}
+	return e, nil
+}
+
+func (r *dynamoWaitlistRepo) GetByOfferToken(ctx context.Context, token string) (domain.WaitlistEntry, error) {
+	if token == "" {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist offer not found")
+	}
+	return r.scanOne(ctx, "Offer.Token = :t", map[string]types.AttributeValue{
+		":t": &types.AttributeValueMemberS{Value: token},
+	})
+}
+
+func (r *dynamoWaitlistRepo) ListByDoctor(ctx context.Context, doctorID string) ([]domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("DOCTOR#%s", doctorID)},
+			":sk": &types.AttributeValueMemberS{Value: "WAITLIST#"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query waitlist: %w", err)
+	}
+	var entries []domain.WaitlistEntry
+	for _, item := range result.Items {
+		var e domain.WaitlistEntry
+		if err := attributevalue.UnmarshalMap(item, &e); err != nil || !r.VisibleTo(ctx, e.OrgID) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (r *dynamoWaitlistRepo) Update(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, e.OrgID); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	current, err := r.GetByID(ctx, e.ID)
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if e.OrgID != "" && e.OrgID != current.OrgID {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist entry not found")
+	}
+	e.OrgID = current.OrgID
+	return r.Create(ctx, e)
+}
+
+func (r *dynamoWaitlistRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	e, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       waitlistKey(e),
+	})
+	return err
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Resource DynamoDB Repository
+// ─────────────────────────────────────────────────────────────────────────────
+
+type dynamoResourceRepo struct {
+	Tenancy
+	client    *dynamodb.Client
+	tableName string
+}
+
+func resourceKey(orgID, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", orgID)},
+		"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("RESOURCE#%s", id)},
+	}
+}
+
+func (r *dynamoResourceRepo) Create(ctx context.Context, res domain.Resource) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, res.OrgID); err != nil {
+		return domain.Resource{}, err
+	}
+	if res.OrgID == "" {
+		res.OrgID = orgIDOrDefault(ctx)
+	}
+	data, err := attributevalue.MarshalMap(res)
+	if err != nil {
+		return domain.Resource{}, err
+	}
+	for k, v := range resourceKey(res.OrgID, res.ID) {
+		data[k] = v
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      data,
+	})
+	return res, err
+}
+
+func (r *dynamoResourceRepo) GetByID(ctx context.Context, id string) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Resource{}, err
+	}
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       resourceKey(orgIDOrDefault(ctx), id),
+	})
+	if err != nil {
+		return domain.Resource{}, err
+	}
+	if result.Item == nil {
+		return domain.Resource{}, fmt.Errorf("resource not found")
+	}
+	var res domain.Resource
+	if err := attributevalue.UnmarshalMap(result.Item, &res); err != nil {
+		return domain.Resource{}, err
+	}
+	return res, nil
+}
+
+func (r *dynamoResourceRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.Resource, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", orgID)},
+			":sk": &types.AttributeValueMemberS{Value: "RESOURCE#"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query resources: %w", err)
+	}
+	var out []domain.Resource
+	for _, item := range result.Items {
+		var res domain.Resource
+		if err := attributevalue.UnmarshalMap(item, &res); err != nil {
+			continue
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+func (r *dynamoResourceRepo) Update(ctx context.Context, res domain.Resource) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, res.OrgID); err != nil {
+		return domain.Resource{}, err
+	}
+	return r.Create(ctx, res)
+}
+
+func (r *dynamoResourceRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       resourceKey(orgIDOrDefault(ctx), id),
+	})
+	return err
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// AppointmentType DynamoDB Repository
+// ─────────────────────────────────────────────────────────────────────────────
+
+type dynamoAppointmentTypeRepo struct {
+	Tenancy
+	client    *dynamodb.Client
+	tableName string
+}
+
+func appointmentTypeKey(orgID, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", orgID)},
+		"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("APPTTYPE#%s", id)},
+	}
+}
+
+func (r *dynamoAppointmentTypeRepo) Create(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	if t.OrgID == "" {
+		t.OrgID = orgIDOrDefault(ctx)
+	}
+	data, err := attributevalue.MarshalMap(t)
+	if err != nil {
+		return domain.AppointmentType{}, err
+	}
+	for k, v := range appointmentTypeKey(t.OrgID, t.ID) {
+		data[k] = v
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      data,
+	})
+	return t, err
+}
+
+func (r *dynamoAppointmentTypeRepo) GetByID(ctx context.Context, id string) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       appointmentTypeKey(orgIDOrDefault(ctx), id),
+	})
+	if err != nil {
+		return domain.AppointmentType{}, err
+	}
+	if result.Item == nil {
+		return domain.AppointmentType{}, fmt.Errorf("appointment type not found")
+	}
+	var t domain.AppointmentType
+	if err := attributevalue.UnmarshalMap(result.Item, &t); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	return t, nil
+}
+
+func (r *dynamoAppointmentTypeRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", orgID)},
+			":sk": &types.AttributeValueMemberS{Value: "APPTTYPE#"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query appointment types: %w", err)
+	}
+	var out []domain.AppointmentType
+	for _, item := range result.Items {
+		var t domain.AppointmentType
+		if err := attributevalue.UnmarshalMap(item, &t); err != nil {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (r *dynamoAppointmentTypeRepo) Update(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	return r.Create(ctx, t)
+}
+
+func (r *dynamoAppointmentTypeRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       appointmentTypeKey(orgIDOrDefault(ctx), id),
+	})
+	return err
+}
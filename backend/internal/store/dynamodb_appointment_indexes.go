@@ -0,0 +1,306 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/domain"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// Appointment table GSIs. Every index projects ALL attributes so reads never
+// need a follow-up GetItem. MigrateAppointmentIndexes owns them; they are not
+// declared in template.yaml.
+//
+//	DoctorDayIndex    DoctorDayKey = ORG#<org>#DOCTOR#<doctor>#DAY#<yyyy-mm-dd>, StartAt
+//	OrgDayIndex       OrgDayKey    = ORG#<org>#DAY#<yyyy-mm-dd>,                  StartAt
+//	PatientIndex      PatientKey   = ORG#<org>#PATIENT#<patient>,                 StartAt
+//	ConfirmTokenIndex ConfirmToken
+const (
+	apptDoctorDayIndex    = "DoctorDayIndex"
+	apptOrgDayIndex       = "OrgDayIndex"
+	apptPatientIndex      = "PatientIndex"
+	apptConfirmTokenIndex = "ConfirmTokenIndex"
+)
+
+type gsiDef struct {
+	name     string
+	hashKey  string
+	rangeKey string
+}
+
+var appointmentIndexes = []gsiDef{
+	{apptDoctorDayIndex, "DoctorDayKey", "StartAt"},
+	{apptOrgDayIndex, "OrgDayKey", "StartAt"},
+	{apptPatientIndex, "PatientKey", "StartAt"},
+	{apptConfirmTokenIndex, "ConfirmToken", ""},
+}
+
+func (g gsiDef) toIndex() types.GlobalSecondaryIndex {
+	schema := []types.KeySchemaElement{{AttributeName: aws.String(g.hashKey), KeyType: types.KeyTypeHash}}
+	if g.rangeKey != "" {
+		schema = append(schema, types.KeySchemaElement{AttributeName: aws.String(g.rangeKey), KeyType: types.KeyTypeRange})
+	}
+	return types.GlobalSecondaryIndex{
+		IndexName:  aws.String(g.name),
+		KeySchema:  schema,
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+	}
+}
+
+// appointmentAttributeDefinitions lists the table keys plus every GSI key.
+func appointmentAttributeDefinitions() []types.AttributeDefinition {
+	names := []string{"PK", "SK"}
+	seen := map[string]bool{"PK": true, "SK": true}
+	for _, g := range appointmentIndexes {
+		for _, n := range []string{g.hashKey, g.rangeKey} {
+			if n != "" && !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	defs := make([]types.AttributeDefinition, len(names))
+	for i, n := range names {
+		defs[i] = types.AttributeDefinition{AttributeName: aws.String(n), AttributeType: types.ScalarAttributeTypeS}
+	}
+	return defs
+}
+
+func appointmentGSIs() []types.GlobalSecondaryIndex {
+	out := make([]types.GlobalSecondaryIndex, len(appointmentIndexes))
+	for i, g := range appointmentIndexes {
+		out[i] = g.toIndex()
+	}
+	return out
+}
+
+func doctorDayKey(orgID, doctorID, day string) string {
+	return fmt.Sprintf("ORG#%s#DOCTOR#%s#DAY#%s", orgID, doctorID, day)
+}
+
+func orgDayKey(orgID, day string) string {
+	return fmt.Sprintf("ORG#%s#DAY#%s", orgID, day)
+}
+
+func patientKey(orgID, patientID string) string {
+	return fmt.Sprintf("ORG#%s#PATIENT#%s", orgID, patientID)
+}
+
+// appointmentIndexKeys returns the GSI key attributes for an appointment. The
+// day is the UTC date of StartAt; appointmentItem stores StartAt in UTC too, so
+// the Scan fallback (begins_with(StartAt, day)) picks the same day.
+func appointmentIndexKeys(orgID string, a domain.Appointment) map[string]types.AttributeValue {
+	day := a.StartAt.UTC().Format("2006-01-02")
+	return map[string]types.AttributeValue{
+		"DoctorDayKey": &types.AttributeValueMemberS{Value: doctorDayKey(orgID, a.DoctorID, day)},
+		"OrgDayKey":    &types.AttributeValueMemberS{Value: orgDayKey(orgID, day)},
+		"PatientKey":   &types.AttributeValueMemberS{Value: patientKey(orgID, a.PatientID)},
+	}
+}
+
+// queryAll follows LastEvaluatedKey until the query is exhausted.
+func queryAll(ctx context.Context, client dynamodb.QueryAPIClient, in *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	p := dynamodb.NewQueryPaginator(client, in)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+	}
+	return items, nil
+}
+
+// scanAll follows LastEvaluatedKey until the scan is exhausted.
+func scanAll(ctx context.Context, client dynamodb.ScanAPIClient, in *dynamodb.ScanInput) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	p := dynamodb.NewScanPaginator(client, in)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+	}
+	return items, nil
+}
+
+// isMissingIndex reports whether a Query failed because the index does not
+// exist (yet): DynamoDB answers with a ValidationException for an unknown index
+// and a ResourceNotFoundException while the table itself is being created.
+func isMissingIndex(err error) bool {
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException" &&
+		strings.Contains(apiErr.ErrorMessage(), "specified index")
+}
+
+// queryOrScan queries a GSI and, on tables that have not been migrated yet,
+// falls back to the equivalent (paginated) Scan.
+func (r *dynamoAppointmentRepo) queryOrScan(ctx context.Context, q *dynamodb.QueryInput, fallback *dynamodb.ScanInput) ([]map[string]types.AttributeValue, error) {
+	items, err := queryAll(ctx, r.client, q)
+	if isMissingIndex(err) {
+		log.Printf("[store] appointments: index %s missing on %s, falling back to Scan — run cmd/migrate", aws.ToString(q.IndexName), r.tableName)
+		return scanAll(ctx, r.client, fallback)
+	}
+	return items, err
+}
+
+func unmarshalAppointments(items []map[string]types.AttributeValue) []domain.Appointment {
+	out := make([]domain.Appointment, 0, len(items))
+	for _, item := range items {
+		var a domain.Appointment
+		if err := attributevalue.UnmarshalMap(item, &a); err != nil {
+			continue // Skip malformed items
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// MigrateAppointmentIndexes backfills the GSI key attributes on appointments
+// written before the indexes existed (normalising StartAt/EndAt to UTC), then creates any missing GSI on the
+// appointment table. DynamoDB only allows one GSI creation per UpdateTable, so
+// each index is created and awaited in turn. Safe to re-run; returns the
+// number of items updated.
+func (r *DynamoDBRepositories) MigrateAppointmentIndexes(ctx context.Context) (int, error) {
+	tableName := r.config.AppointmentTableName
+	items, err := scanAll(ctx, r.client, &dynamodb.ScanInput{
+		TableName:        aws.String(tableName),
+		FilterExpression: aws.String("begins_with(SK, :skPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":skPrefix": &types.AttributeValueMemberS{Value: "APPOINTMENT#"},
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scan appointments: %w", err)
+	}
+
+	updated := 0
+	for _, item := range items {
+		var a domain.Appointment
+		if err := attributevalue.UnmarshalMap(item, &a); err != nil {
+			log.Printf("[migrate] skip malformed appointment item: %v", err)
+			continue
+		}
+		pk, _ := item["PK"].(*types.AttributeValueMemberS)
+		if pk == nil {
+			continue
+		}
+		orgID := strings.TrimPrefix(pk.Value, "ORG#")
+
+		keys := appointmentIndexKeys(orgID, a)
+		upToDate := true
+		for name, want := range keys {
+			if got, ok := item[name].(*types.AttributeValueMemberS); !ok || got.Value != want.(*types.AttributeValueMemberS).Value {
+				upToDate = false
+			}
+		}
+		// Citas viejas guardaban StartAt/EndAt con el offset original.
+		startAt := a.StartAt.UTC().Format(time.RFC3339)
+		endAt := a.EndAt.UTC().Format(time.RFC3339)
+		for name, want := range map[string]string{"StartAt": startAt, "EndAt": endAt} {
+			if got, ok := item[name].(*types.AttributeValueMemberS); !ok || got.Value != want {
+				upToDate = false
+			}
+		}
+		// Un ConfirmToken vacío no puede ser clave de índice.
+		emptyToken := false
+		if tok, ok := item["ConfirmToken"].(*types.AttributeValueMemberS); ok && tok.Value == "" {
+			emptyToken = true
+		}
+		if upToDate && !emptyToken {
+			continue
+		}
+
+		expr := "SET DoctorDayKey = :dd, OrgDayKey = :od, PatientKey = :pk, StartAt = :start, EndAt = :end"
+		if emptyToken {
+			expr += " REMOVE ConfirmToken"
+		}
+		_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:        aws.String(tableName),
+			Key:              map[string]types.AttributeValue{"PK": item["PK"], "SK": item["SK"]},
+			UpdateExpression: aws.String(expr),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":dd":    keys["DoctorDayKey"],
+				":od":    keys["OrgDayKey"],
+				":pk":    keys["PatientKey"],
+				":start": &types.AttributeValueMemberS{Value: startAt},
+				":end":   &types.AttributeValueMemberS{Value: endAt},
+			},
+		})
+		if err != nil {
+			return updated, fmt.Errorf("backfill appointment %s: %w", a.ID, err)
+		}
+		updated++
+	}
+	log.Printf("[migrate] appointments: backfilled %d of %d items", updated, len(items))
+
+	desc, err := r.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return updated, fmt.Errorf("describe %s: %w", tableName, err)
+	}
+	existing := map[string]bool{}
+	for _, gsi := range desc.Table.GlobalSecondaryIndexes {
+		existing[aws.ToString(gsi.IndexName)] = true
+	}
+	for _, g := range appointmentIndexes {
+		if existing[g.name] {
+			continue
+		}
+		log.Printf("[migrate] creating index %s on %s", g.name, tableName)
+		idx := g.toIndex()
+		_, err := r.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(tableName),
+			AttributeDefinitions: appointmentAttributeDefinitions(),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+				Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName:  idx.IndexName,
+					KeySchema:  idx.KeySchema,
+					Projection: idx.Projection,
+				},
+			}},
+		})
+		if err != nil {
+			return updated, fmt.Errorf("create index %s: %w", g.name, err)
+		}
+		if err := r.waitForIndex(ctx, tableName, g.name); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+func (r *DynamoDBRepositories) waitForIndex(ctx context.Context, tableName, indexName string) error {
+	for {
+		desc, err := r.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+		if err != nil {
+			return fmt.Errorf("describe %s: %w", tableName, err)
+		}
+		for _, gsi := range desc.Table.GlobalSecondaryIndexes {
+			if aws.ToString(gsi.IndexName) == indexName && gsi.IndexStatus == types.IndexStatusActive {
+				log.Printf("[migrate] index %s is ACTIVE", indexName)
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/domain"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestAppointmentIndexKeys(t *testing.T) {
+	// 22:30 en Caracas (UTC-4) es el día siguiente en UTC.
+	caracas := time.FixedZone("VET", -4*3600)
+	a := domain.Appointment{ID: "apt-1", DoctorID: "doc-1", PatientID: "pat-1",
+		StartAt: time.Date(2026, 3, 10, 22, 30, 0, 0, caracas)}
+	a.EndAt = a.StartAt.Add(30 * time.Minute)
+
+	keys := appointmentIndexKeys("org-1", a)
+	want := map[string]string{
+		"DoctorDayKey": "ORG#org-1#DOCTOR#doc-1#DAY#2026-03-11",
+		"OrgDayKey":    "ORG#org-1#DAY#2026-03-11",
+		"PatientKey":   "ORG#org-1#PATIENT#pat-1",
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("keys = %v", keys)
+	}
+	for name, v := range want {
+		if got := keys[name].(*types.AttributeValueMemberS).Value; got != v {
+			t.Errorf("%s = %q, want %q", name, got, v)
+		}
+	}
+
+	// El Scan de respaldo filtra con begins_with(StartAt, día UTC).
+	item, err := appointmentItem(ContextWithOrgID(context.Background(), "org-1"), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := item["StartAt"].(*types.AttributeValueMemberS).Value; got != "2026-03-11T02:30:00Z" {
+		t.Errorf("StartAt = %q, want UTC", got)
+	}
+	if got := item["EndAt"].(*types.AttributeValueMemberS).Value; got != "2026-03-11T03:00:00Z" {
+		t.Errorf("EndAt = %q, want UTC", got)
+	}
+}
+
+// pagedQuery serves items in pages of size, keyed by the index of the next item.
+type pagedQuery struct {
+	items []string
+	size  int
+	calls int
+}
+
+func (p *pagedQuery) Query(_ context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	p.calls++
+	start := 0
+	if k, ok := in.ExclusiveStartKey["ID"].(*types.AttributeValueMemberS); ok {
+		fmt.Sscanf(k.Value, "%d", &start)
+	}
+	end := min(start+p.size, len(p.items))
+	out := &dynamodb.QueryOutput{}
+	for _, id := range p.items[start:end] {
+		out.Items = append(out.Items, map[string]types.AttributeValue{"ID": &types.AttributeValueMemberS{Value: id}})
+	}
+	if end < len(p.items) {
+		out.LastEvaluatedKey = map[string]types.AttributeValue{"ID": &types.AttributeValueMemberS{Value: fmt.Sprint(end)}}
+	}
+	return out, nil
+}
+
+func TestQueryAllFollowsPages(t *testing.T) {
+	client := &pagedQuery{items: []string{"a", "b", "c", "d", "e"}, size: 2}
+	items, err := queryAll(context.Background(), client, &dynamodb.QueryInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, it := range items {
+		got = append(got, it["ID"].(*types.AttributeValueMemberS).Value)
+	}
+	if strings.Join(got, ",") != "a,b,c,d,e" || client.calls != 3 {
+		t.Fatalf("items = %v after %d calls, want a..e after 3", got, client.calls)
+	}
+}
+
+func TestIsMissingIndex(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&smithy.GenericAPIError{Code: "ValidationException", Message: "The table does not have the specified index: DoctorDayIndex"}, true},
+		{fmt.Errorf("operation error DynamoDB: Query: %w", &types.ResourceNotFoundException{}), true},
+		{&smithy.GenericAPIError{Code: "ValidationException", Message: "Invalid KeyConditionExpression"}, false},
+		{errors.New("the table does not have the specified index"), false},
+		{nil, false},
+	} {
+		if got := isMissingIndex(tc.err); got != tc.want {
+			t.Errorf("isMissingIndex(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
@@ -16,14 +16,43 @@ import (
 
 // DynamoDB Odontogram Repository
 type dynamoOdontogramRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
 
 func (r *dynamoOdontogramRepo) Create(ctx context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
+	odontogram.OrgID = orgIDOrDefault(ctx)
 	odontogram.CreatedAt = time.Now()
 	odontogram.UpdatedAt = time.Now()
+	odontogram.Version = initialVersion(odontogram.Version)
+
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      odontogramItem(odontogram),
+	})
+
+	if err != nil {
+		return domain.Odontogram{}, fmt.Errorf("failed to create odontogram: %w", err)
+	}
 
+	// Create patient index
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]types.AttributeValue{
+			"PK":           &types.AttributeValueMemberS{Value: "PATIENT#" + odontogram.PatientID},
+			"SK":           &types.AttributeValueMemberS{Value: "ODONTOGRAM"},
+			"OdontogramID": &types.AttributeValueMemberS{Value: odontogram.ID},
+		},
+	})
+
+	return odontogram, err
+}
+
+func odontogramItem(odontogram domain.Odontogram) map[string]types.AttributeValue {
 	item := map[string]types.AttributeValue{
 		"PK":           &types.AttributeValueMemberS{Value: "ODONTOGRAM#" + odontogram.ID},
 		"SK":           &types.AttributeValueMemberS{Value: "METADATA"},
@@ -33,6 +62,10 @@ func (r *dynamoOdontogramRepo) Create(ctx context.Context, odontogram domain.Odo
 		"LastExamDate": &types.AttributeValueMemberS{Value: odontogram.LastExamDate.Format(time.RFC3339)},
 		"CreatedAt":    &types.AttributeValueMemberS{Value: odontogram.CreatedAt.Format(time.RFC3339)},
 		"UpdatedAt":    &types.AttributeValueMemberS{Value: odontogram.UpdatedAt.Format(time.RFC3339)},
+		"Version":      versionAttr(odontogram.Version),
+	}
+	if odontogram.OrgID != "" {
+		item["OrgID"] = &types.AttributeValueMemberS{Value: odontogram.OrgID}
 	}
 
 	if odontogram.NextExamDate != nil {
@@ -54,30 +87,13 @@ func (r *dynamoOdontogramRepo) Create(ctx context.Context, odontogram domain.Odo
 			item["TreatmentHistory"] = treatmentData
 		}
 	}
-
-	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
-	})
-
-	if err != nil {
-		return domain.Odontogram{}, fmt.Errorf("failed to create odontogram: %w", err)
-	}
-
-	// Create patient index
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item: map[string]types.AttributeValue{
-			"PK":           &types.AttributeValueMemberS{Value: "PATIENT#" + odontogram.PatientID},
-			"SK":           &types.AttributeValueMemberS{Value: "ODONTOGRAM"},
-			"OdontogramID": &types.AttributeValueMemberS{Value: odontogram.ID},
-		},
-	})
-
-	return odontogram, err
+	return item
 }
 
 func (r *dynamoOdontogramRepo) GetByPatientID(ctx context.Context, patientID string) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
 	// First get the odontogram ID from patient index
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
@@ -104,6 +120,9 @@ func (r *dynamoOdontogramRepo) GetByPatientID(ctx context.Context, patientID str
 }
 
 func (r *dynamoOdontogramRepo) GetByID(ctx context.Context, id string) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
@@ -122,6 +141,12 @@ func (r *dynamoOdontogramRepo) GetByID(ctx context.Context, id string) (domain.O
 
 	var odontogram domain.Odontogram
 	odontogram.ID = id
+	if orgID, ok := result.Item["OrgID"].(*types.AttributeValueMemberS); ok {
+		odontogram.OrgID = orgID.Value
+	}
+	if !r.VisibleTo(ctx, odontogram.OrgID) {
+		return domain.Odontogram{}, fmt.Errorf("odontogram not found")
+	}
 
 	if patientID, ok := result.Item["PatientID"].(*types.AttributeValueMemberS); ok {
 		odontogram.PatientID = patientID.Value
@@ -152,6 +177,9 @@ func (r *dynamoOdontogramRepo) GetByID(ctx context.Context, id string) (domain.O
 			odontogram.UpdatedAt = t
 		}
 	}
+	if version, ok := result.Item["Version"].(*types.AttributeValueMemberN); ok {
+		odontogram.Version, _ = strconv.Atoi(version.Value)
+	}
 
 	// Unmarshal teeth data
 	if teethData, ok := result.Item["Teeth"]; ok {
@@ -173,11 +201,29 @@ func (r *dynamoOdontogramRepo) GetByID(ctx context.Context, id string) (domain.O
 }
 
 func (r *dynamoOdontogramRepo) Update(ctx context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
+	// La tabla no está particionada por org: se comprueba el dueño antes de
+	// escribir y se conserva su OrgID.
+	current, err := r.GetByID(ctx, odontogram.ID)
+	if err != nil {
+		return domain.Odontogram{}, err
+	}
+	odontogram.OrgID = current.OrgID
 	odontogram.UpdatedAt = time.Now()
-	return r.Create(ctx, odontogram) // For simplicity, use create (overwrite)
+	expected := odontogram.Version
+	odontogram.Version++
+	if err := putVersioned(ctx, r.client, r.tableName, odontogramItem(odontogram), expected); err != nil {
+		return domain.Odontogram{}, fmt.Errorf("failed to update odontogram: %w", err)
+	}
+	return odontogram, nil
 }
 
 func (r *dynamoOdontogramRepo) AddTreatment(ctx context.Context, odontogramID string, treatment domain.ToothTreatment) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	// Get current odontogram
 	current, err := r.GetByID(ctx, odontogramID)
 	if err != nil {
@@ -195,6 +241,9 @@ func (r *dynamoOdontogramRepo) AddTreatment(ctx context.Context, odontogramID st
 }
 
 func (r *dynamoOdontogramRepo) UpdateToothCondition(ctx context.Context, odontogramID string, toothNumber domain.ToothNumber, surfaces []domain.ToothSurfaceCondition) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	// Get current odontogram
 	current, err := r.GetByID(ctx, odontogramID)
 	if err != nil {
@@ -217,6 +266,9 @@ func (r *dynamoOdontogramRepo) UpdateToothCondition(ctx context.Context, odontog
 }
 
 func (r *dynamoOdontogramRepo) GetTreatmentHistory(ctx context.Context, patientID string, limit int) ([]domain.ToothTreatment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	odontogram, err := r.GetByPatientID(ctx, patientID)
 	if err != nil {
 		return []domain.ToothTreatment{}, nil
@@ -232,17 +284,57 @@ func (r *dynamoOdontogramRepo) GetTreatmentHistory(ctx context.Context, patientI
 
 // DynamoDB Treatment Plan Repository
 type dynamoTreatmentPlanRepo struct {
+	Tenancy
 	client    *dynamodb.Client
 	tableName string
 }
 
 func (r *dynamoTreatmentPlanRepo) Create(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	plan.OrgID = orgIDOrDefault(ctx)
 	plan.CreatedAt = time.Now()
 	plan.UpdatedAt = time.Now()
+	plan.Version = initialVersion(plan.Version)
 
+	item, err := treatmentPlanItem(plan)
+	if err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+
+	if err != nil {
+		return domain.TreatmentPlan{}, fmt.Errorf("failed to create treatment plan: %w", err)
+	}
+
+	return plan, r.putPatientIndex(ctx, plan)
+}
+
+// putPatientIndex writes the PATIENT#/PLAN# item used to list a patient's plans.
+func (r *dynamoTreatmentPlanRepo) putPatientIndex(ctx context.Context, plan domain.TreatmentPlan) error {
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]types.AttributeValue{
+			"PK":              &types.AttributeValueMemberS{Value: "PATIENT#" + plan.PatientID},
+			"SK":              &types.AttributeValueMemberS{Value: "PLAN#" + plan.ID},
+			"TreatmentPlanID": &types.AttributeValueMemberS{Value: plan.ID},
+			"Title":           &types.AttributeValueMemberS{Value: plan.Title},
+			"Status":          &types.AttributeValueMemberS{Value: string(plan.Status)},
+			"CreatedAt":       &types.AttributeValueMemberS{Value: plan.CreatedAt.Format(time.RFC3339)},
+		},
+	})
+	return err
+}
+
+func treatmentPlanItem(plan domain.TreatmentPlan) (map[string]types.AttributeValue, error) {
 	treatmentData, err := attributevalue.Marshal(plan.Treatments)
 	if err != nil {
-		return domain.TreatmentPlan{}, fmt.Errorf("failed to marshal treatments: %w", err)
+		return nil, fmt.Errorf("failed to marshal treatments: %w", err)
 	}
 
 	item := map[string]types.AttributeValue{
@@ -261,6 +353,10 @@ func (r *dynamoTreatmentPlanRepo) Create(ctx context.Context, plan domain.Treatm
 		"Notes":         &types.AttributeValueMemberS{Value: plan.Notes},
 		"CreatedAt":     &types.AttributeValueMemberS{Value: plan.CreatedAt.Format(time.RFC3339)},
 		"UpdatedAt":     &types.AttributeValueMemberS{Value: plan.UpdatedAt.Format(time.RFC3339)},
+		"Version":       versionAttr(plan.Version),
+	}
+	if plan.OrgID != "" {
+		item["OrgID"] = &types.AttributeValueMemberS{Value: plan.OrgID}
 	}
 
 	if plan.StartDate != nil {
@@ -269,33 +365,13 @@ func (r *dynamoTreatmentPlanRepo) Create(ctx context.Context, plan domain.Treatm
 	if plan.EndDate != nil {
 		item["EndDate"] = &types.AttributeValueMemberS{Value: plan.EndDate.Format(time.RFC3339)}
 	}
-
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
-	})
-
-	if err != nil {
-		return domain.TreatmentPlan{}, fmt.Errorf("failed to create treatment plan: %w", err)
-	}
-
-	// Create patient index
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item: map[string]types.AttributeValue{
-			"PK":              &types.AttributeValueMemberS{Value: "PATIENT#" + plan.PatientID},
-			"SK":              &types.AttributeValueMemberS{Value: "PLAN#" + plan.ID},
-			"TreatmentPlanID": &types.AttributeValueMemberS{Value: plan.ID},
-			"Title":           &types.AttributeValueMemberS{Value: plan.Title},
-			"Status":          &types.AttributeValueMemberS{Value: string(plan.Status)},
-			"CreatedAt":       &types.AttributeValueMemberS{Value: plan.CreatedAt.Format(time.RFC3339)},
-		},
-	})
-
-	return plan, err
+	return item, nil
 }
 
 func (r *dynamoTreatmentPlanRepo) GetByID(ctx context.Context, id string) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
@@ -314,6 +390,12 @@ func (r *dynamoTreatmentPlanRepo) GetByID(ctx context.Context, id string) (domai
 
 	var plan domain.TreatmentPlan
 	plan.ID = id
+	if orgID, ok := result.Item["OrgID"].(*types.AttributeValueMemberS); ok {
+		plan.OrgID = orgID.Value
+	}
+	if !r.VisibleTo(ctx, plan.OrgID) {
+		return domain.TreatmentPlan{}, fmt.Errorf("treatment plan not found")
+	}
 
 	// Parse basic fields
 	if patientID, ok := result.Item["PatientID"].(*types.AttributeValueMemberS); ok {
@@ -364,6 +446,9 @@ func (r *dynamoTreatmentPlanRepo) GetByID(ctx context.Context, id string) (domai
 			plan.UpdatedAt = t
 		}
 	}
+	if version, ok := result.Item["Version"].(*types.AttributeValueMemberN); ok {
+		plan.Version, _ = strconv.Atoi(version.Value)
+	}
 	if startDate, ok := result.Item["StartDate"].(*types.AttributeValueMemberS); ok {
 		if t, err := time.Parse(time.RFC3339, startDate.Value); err == nil {
 			plan.StartDate = &t
@@ -387,6 +472,9 @@ func (r *dynamoTreatmentPlanRepo) GetByID(ctx context.Context, id string) (domai
 }
 
 func (r *dynamoTreatmentPlanRepo) GetByPatientID(ctx context.Context, patientID string) ([]domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(r.tableName),
 		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
@@ -413,11 +501,32 @@ func (r *dynamoTreatmentPlanRepo) GetByPatientID(ctx context.Context, patientID
 }
 
 func (r *dynamoTreatmentPlanRepo) Update(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	current, err := r.GetByID(ctx, plan.ID)
+	if err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	plan.OrgID = current.OrgID
 	plan.UpdatedAt = time.Now()
-	return r.Create(ctx, plan) // For simplicity, use create (overwrite)
+	expected := plan.Version
+	plan.Version++
+	item, err := treatmentPlanItem(plan)
+	if err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	if err := putVersioned(ctx, r.client, r.tableName, item, expected); err != nil {
+		return domain.TreatmentPlan{}, fmt.Errorf("failed to update treatment plan: %w", err)
+	}
+	// El índice por paciente guarda título y estado.
+	return plan, r.putPatientIndex(ctx, plan)
 }
 
 func (r *dynamoTreatmentPlanRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	// Get plan to get patient ID for index cleanup
 	plan, err := r.GetByID(ctx, id)
 	if err != nil {
@@ -449,6 +558,9 @@ func (r *dynamoTreatmentPlanRepo) Delete(ctx context.Context, id string) error {
 }
 
 func (r *dynamoTreatmentPlanRepo) UpdateTreatmentStatus(ctx context.Context, planID, treatmentIndex string, status domain.PlannedTreatmentStatus, completedTreatmentID *string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	// Get current plan
 	plan, err := r.GetByID(ctx, planID)
 	if err != nil {
@@ -0,0 +1,503 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"clinical-backend/internal/domain"
+)
+
+// FileRepositories keep every repository in memory and persist them to a
+// single JSON file (DATA_FILE) after each write, for on-prem installs of one
+// clinic that run with LOCAL_HTTP=true.
+//
+// Las lecturas se sirven desde memoria. Cada escritura reescribe el archivo
+// completo en un temporal del mismo directorio y lo renombra encima, así que
+// el archivo siempre contiene un estado completo: un corte de luz pierde como
+// mucho la última escritura, nunca deja el archivo a medias. Si no se puede
+// guardar, la escritura se deshace en memoria y devuelve el error.
+//
+// Un solo proceso debe abrir cada archivo; no hay bloqueo entre procesos.
+type FileRepositories struct {
+	Patients         PatientRepository
+	Appointments     AppointmentRepository
+	Consents         ConsentRepository
+	ConsentTemplates ConsentTemplateRepository
+	Users            AuthRepository
+	Odontograms      OdontogramRepository
+	TreatmentPlans   TreatmentPlanRepository
+	Payments         PaymentRepository
+	Budgets          BudgetRepository
+	Waitlist         WaitlistRepository
+	Resources        ResourceRepository
+	AppointmentTypes AppointmentTypeRepository
+
+	fs *fileStore
+}
+
+// NewFileRepositories opens (or creates on the first write) the data file at
+// path and loads its contents.
+func NewFileRepositories(path string, opts ...func(*Tenancy)) (*FileRepositories, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file store: path is required")
+	}
+	mem := NewInMemoryRepositories(opts...)
+	fs := &fileStore{path: path, mem: mem}
+	snap, data, err := readSnapshotFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if data, err = encodeSnapshot(fs.capture()); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		fs.restore(snap)
+	}
+	fs.last = data
+
+	return &FileRepositories{
+		Patients:         filePatientRepo{mem.Patients, fs},
+		Appointments:     fileAppointmentRepo{mem.Appointments, fs},
+		Consents:         fileConsentRepo{mem.Consents, fs},
+		ConsentTemplates: fileConsentTemplateRepo{mem.ConsentTemplates, fs},
+		Users:            fileAuthRepo{mem.Users, fs},
+		Odontograms:      fileOdontogramRepo{mem.Odontograms, fs},
+		TreatmentPlans:   fileTreatmentPlanRepo{mem.TreatmentPlans, fs},
+		Payments:         filePaymentRepo{mem.Payments, fs},
+		Budgets:          fileBudgetRepo{mem.Budgets, fs},
+		Waitlist:         fileWaitlistRepo{mem.Waitlist, fs},
+		Resources:        fileResourceRepo{mem.Resources, fs},
+		AppointmentTypes: fileAppointmentTypeRepo{mem.AppointmentTypes, fs},
+		fs:               fs,
+	}, nil
+}
+
+// Backup writes a consistent snapshot of every repository to dst
+// atomically; writes wait until it is taken. A backup is itself a valid
+// data file: to restore it, stop the server and put it in place of DATA_FILE.
+func (r *FileRepositories) Backup(dst string) error {
+	r.fs.mu.Lock()
+	data, err := encodeSnapshot(r.fs.capture())
+	r.fs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data)
+}
+
+// BackupFile copies the data file at src to dst after checking that it can
+// be loaded. It is safe while the server runs: writes replace the file with
+// a rename, so src is always complete.
+func BackupFile(src, dst string) error {
+	_, data, err := readSnapshotFile(src)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data)
+}
+
+// fileStoreFormat es la versión del formato del archivo.
+const fileStoreFormat = 1
+
+type fileStore struct {
+	mu   sync.Mutex // serializa escrituras y guardado
+	path string
+	mem  *InMemoryRepositories
+	last []byte // último estado guardado, para deshacer
+}
+
+// write runs a repository write and saves the result. If saving fails the
+// in-memory state goes back to the last saved snapshot.
+func (fs *fileStore) write(fn func() error) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fn(); err != nil {
+		return err
+	}
+	data, err := encodeSnapshot(fs.capture())
+	if err == nil {
+		err = writeFileAtomic(fs.path, data)
+	}
+	if err != nil {
+		// last se guarda serializado: las entidades en memoria comparten
+		// slices con las capturas y una escritura puede modificarlos.
+		var snap fileSnapshot
+		if jerr := json.Unmarshal(fs.last, &snap); jerr == nil {
+			fs.restore(snap)
+		}
+		return err
+	}
+	fs.last = data
+	return nil
+}
+
+// persist is write for the methods that return the stored entity.
+func persist[T any](fs *fileStore, fn func() (T, error)) (T, error) {
+	var out T
+	err := fs.write(func() error {
+		var err error
+		out, err = fn()
+		return err
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return out, nil
+}
+
+type fileSnapshot struct {
+	Format  int       `json:"format"`
+	SavedAt time.Time `json:"savedAt"`
+
+	Patients             []orgItem[domain.Patient]         `json:"patients"`
+	Appointments         []orgItem[domain.Appointment]     `json:"appointments"`
+	Consents             []orgItem[domain.Consent]         `json:"consents"`
+	ConsentTemplates     []domain.ConsentTemplate          `json:"consentTemplates"`
+	Auth                 fileAuth                          `json:"auth"`
+	Odontograms          []domain.Odontogram               `json:"odontograms"`
+	OdontogramsByPatient map[string]string                 `json:"odontogramsByPatient"`
+	TreatmentPlans       []domain.TreatmentPlan            `json:"treatmentPlans"`
+	PlansByPatient       map[string][]string               `json:"plansByPatient"`
+	Payments             []domain.PaymentRecord            `json:"payments"`
+	Budgets              []domain.Budget                   `json:"budgets"`
+	Waitlist             []fileWaitlistEntry               `json:"waitlist"`
+	Resources            []orgItem[domain.Resource]        `json:"resources"`
+	AppointmentTypes     []orgItem[domain.AppointmentType] `json:"appointmentTypes"`
+}
+
+// orgItem guarda la org de la clave junto con la entidad: en los repos
+// particionados por org la entidad no siempre trae OrgID.
+type orgItem[T any] struct {
+	Org  string `json:"org"`
+	Item T      `json:"item"`
+}
+
+// fileWaitlistEntry conserva el token de la oferta, que no se serializa en
+// la API (json:"-").
+type fileWaitlistEntry struct {
+	domain.WaitlistEntry
+	OfferToken string `json:"offerToken,omitempty"`
+}
+
+type fileAuth struct {
+	Users         []AuthUser           `json:"users"`
+	EmailIndex    map[string]string    `json:"emailIndex"`
+	UsersByOrg    map[string][]string  `json:"usersByOrg"`
+	Sessions      []AuthSession        `json:"sessions"`
+	Invitations   []UserInvitation     `json:"invitations"`
+	ResetTokens   []PasswordResetToken `json:"resetTokens"`
+	Organizations []Organization       `json:"organizations"`
+	Throttles     []AuthThrottle       `json:"throttles,omitempty"`
+}
+
+// capture copies the in-memory repositories. Callers hold fs.mu, so no
+// write runs in between.
+func (fs *fileStore) capture() fileSnapshot {
+	m := fs.mem
+	snap := fileSnapshot{Format: fileStoreFormat, SavedAt: time.Now().UTC()}
+
+	patients := m.Patients.(*memoryPatientRepo)
+	patients.mu.RLock()
+	snap.Patients = orgItems(patients.items)
+	patients.mu.RUnlock()
+
+	appointments := m.Appointments.(*memoryAppointmentRepo)
+	appointments.mu.RLock()
+	snap.Appointments = orgItems(appointments.items)
+	appointments.mu.RUnlock()
+
+	consents := m.Consents.(*memoryConsentRepo)
+	consents.mu.RLock()
+	snap.Consents = orgItems(consents.items)
+	consents.mu.RUnlock()
+
+	templates := m.ConsentTemplates.(*memoryConsentTemplateRepo)
+	templates.mu.RLock()
+	snap.ConsentTemplates = values(templates.items)
+	templates.mu.RUnlock()
+
+	auth := m.Users.(*memoryAuthRepo)
+	auth.mu.RLock()
+	snap.Auth = fileAuth{
+		Users:         values(auth.usersByID),
+		EmailIndex:    copyMap(auth.emailIndex),
+		UsersByOrg:    map[string][]string{},
+		Sessions:      values(auth.sessions),
+		Invitations:   values(auth.invitations),
+		ResetTokens:   values(auth.resetTokens),
+		Organizations: values(auth.orgs),
+		Throttles:     values(auth.throttles),
+	}
+	for org, ids := range auth.usersByOrg {
+		snap.Auth.UsersByOrg[org] = sortedKeys(ids)
+	}
+	auth.mu.RUnlock()
+
+	odontograms := m.Odontograms.(*memoryOdontogramRepo)
+	odontograms.mu.RLock()
+	snap.Odontograms = values(odontograms.items)
+	snap.OdontogramsByPatient = copyMap(odontograms.byPatient)
+	odontograms.mu.RUnlock()
+
+	plans := m.TreatmentPlans.(*memoryTreatmentPlanRepo)
+	plans.mu.RLock()
+	snap.TreatmentPlans = values(plans.items)
+	snap.PlansByPatient = map[string][]string{}
+	for patientID, ids := range plans.byPatient {
+		snap.PlansByPatient[patientID] = append([]string(nil), ids...)
+	}
+	plans.mu.RUnlock()
+
+	payments := m.Payments.(*memoryPaymentRepo)
+	payments.mu.RLock()
+	snap.Payments = append([]domain.PaymentRecord(nil), payments.items...)
+	payments.mu.RUnlock()
+
+	budgets := m.Budgets.(*memoryBudgetRepo)
+	budgets.mu.RLock()
+	snap.Budgets = values(budgets.items)
+	budgets.mu.RUnlock()
+
+	waitlist := m.Waitlist.(*memoryWaitlistRepo)
+	waitlist.mu.RLock()
+	for _, e := range values(waitlist.items) {
+		fe := fileWaitlistEntry{WaitlistEntry: e}
+		if e.Offer != nil {
+			fe.OfferToken = e.Offer.Token
+		}
+		snap.Waitlist = append(snap.Waitlist, fe)
+	}
+	waitlist.mu.RUnlock()
+
+	resources := m.Resources.(*memoryResourceRepo)
+	resources.mu.RLock()
+	snap.Resources = orgItems(resources.items)
+	resources.mu.RUnlock()
+
+	types := m.AppointmentTypes.(*memoryAppointmentTypeRepo)
+	types.mu.RLock()
+	snap.AppointmentTypes = orgItems(types.items)
+	types.mu.RUnlock()
+
+	return snap
+}
+
+// restore replaces the contents of the in-memory repositories with snap.
+func (fs *fileStore) restore(snap fileSnapshot) {
+	m := fs.mem
+
+	patients := m.Patients.(*memoryPatientRepo)
+	patients.mu.Lock()
+	patients.items = fromOrgItems(snap.Patients, func(p domain.Patient) string { return p.ID })
+	patients.mu.Unlock()
+
+	appointments := m.Appointments.(*memoryAppointmentRepo)
+	appointments.mu.Lock()
+	appointments.items = fromOrgItems(snap.Appointments, func(a domain.Appointment) string { return a.ID })
+	appointments.mu.Unlock()
+
+	consents := m.Consents.(*memoryConsentRepo)
+	consents.mu.Lock()
+	consents.items = fromOrgItems(snap.Consents, func(c domain.Consent) string { return c.ID })
+	consents.mu.Unlock()
+
+	templates := m.ConsentTemplates.(*memoryConsentTemplateRepo)
+	templates.mu.Lock()
+	templates.items = byID(snap.ConsentTemplates, func(t domain.ConsentTemplate) string { return t.ID })
+	templates.mu.Unlock()
+
+	auth := m.Users.(*memoryAuthRepo)
+	auth.mu.Lock()
+	auth.usersByID = byID(snap.Auth.Users, func(u AuthUser) string { return u.ID })
+	auth.emailIndex = copyMap(snap.Auth.EmailIndex)
+	auth.usersByOrg = map[string]map[string]struct{}{}
+	for org, ids := range snap.Auth.UsersByOrg {
+		set := map[string]struct{}{}
+		for _, id := range ids {
+			set[id] = struct{}{}
+		}
+		auth.usersByOrg[org] = set
+	}
+	auth.sessions = byID(snap.Auth.Sessions, func(s AuthSession) string { return s.Token })
+	auth.invitations = byID(snap.Auth.Invitations, func(inv UserInvitation) string { return inv.Token })
+	auth.resetTokens = byID(snap.Auth.ResetTokens, func(t PasswordResetToken) string { return t.Token })
+	auth.orgs = byID(snap.Auth.Organizations, func(o Organization) string { return o.ID })
+	auth.throttles = byID(snap.Auth.Throttles, func(t AuthThrottle) string { return t.Key })
+	auth.mu.Unlock()
+
+	odontograms := m.Odontograms.(*memoryOdontogramRepo)
+	odontograms.mu.Lock()
+	odontograms.items = byID(snap.Odontograms, func(o domain.Odontogram) string { return o.ID })
+	odontograms.byPatient = copyMap(snap.OdontogramsByPatient)
+	odontograms.mu.Unlock()
+
+	plans := m.TreatmentPlans.(*memoryTreatmentPlanRepo)
+	plans.mu.Lock()
+	plans.items = byID(snap.TreatmentPlans, func(p domain.TreatmentPlan) string { return p.ID })
+	plans.byPatient = map[string][]string{}
+	for patientID, ids := range snap.PlansByPatient {
+		plans.byPatient[patientID] = append([]string(nil), ids...)
+	}
+	plans.mu.Unlock()
+
+	payments := m.Payments.(*memoryPaymentRepo)
+	payments.mu.Lock()
+	payments.items = append([]domain.PaymentRecord{}, snap.Payments...)
+	payments.mu.Unlock()
+
+	budgets := m.Budgets.(*memoryBudgetRepo)
+	budgets.mu.Lock()
+	budgets.items = byID(snap.Budgets, func(b domain.Budget) string { return b.ID })
+	budgets.mu.Unlock()
+
+	waitlist := m.Waitlist.(*memoryWaitlistRepo)
+	waitlist.mu.Lock()
+	waitlist.items = map[string]domain.WaitlistEntry{}
+	for _, fe := range snap.Waitlist {
+		e := fe.WaitlistEntry
+		if e.Offer != nil {
+			offer := *e.Offer
+			offer.Token = fe.OfferToken
+			e.Offer = &offer
+		}
+		waitlist.items[e.ID] = e
+	}
+	waitlist.mu.Unlock()
+
+	resources := m.Resources.(*memoryResourceRepo)
+	resources.mu.Lock()
+	resources.items = fromOrgItems(snap.Resources, func(res domain.Resource) string { return res.ID })
+	resources.mu.Unlock()
+
+	types := m.AppointmentTypes.(*memoryAppointmentTypeRepo)
+	types.mu.Lock()
+	types.items = fromOrgItems(snap.AppointmentTypes, func(t domain.AppointmentType) string { return t.ID })
+	types.mu.Unlock()
+}
+
+// Los listados del archivo van ordenados por clave para que dos copias del
+// mismo estado sean idénticas.
+
+func orgItems[T any](items map[orgKey]T) []orgItem[T] {
+	keys := make([]orgKey, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].org != keys[j].org {
+			return keys[i].org < keys[j].org
+		}
+		return keys[i].id < keys[j].id
+	})
+	out := make([]orgItem[T], 0, len(keys))
+	for _, k := range keys {
+		out = append(out, orgItem[T]{Org: k.org, Item: items[k]})
+	}
+	return out
+}
+
+func fromOrgItems[T any](items []orgItem[T], id func(T) string) map[orgKey]T {
+	out := make(map[orgKey]T, len(items))
+	for _, it := range items {
+		out[orgKey{org: it.Org, id: id(it.Item)}] = it.Item
+	}
+	return out
+}
+
+func values[V any](items map[string]V) []V {
+	out := make([]V, 0, len(items))
+	for _, k := range sortedKeys(items) {
+		out = append(out, items[k])
+	}
+	return out
+}
+
+func byID[V any](items []V, id func(V) string) map[string]V {
+	out := make(map[string]V, len(items))
+	for _, it := range items {
+		out[id(it)] = it
+	}
+	return out
+}
+
+func sortedKeys[V any](items map[string]V) []string {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyMap(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func encodeSnapshot(snap fileSnapshot) ([]byte, error) {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("file store: encode: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func readSnapshotFile(path string) (fileSnapshot, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileSnapshot{}, nil, err
+	}
+	var snap fileSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fileSnapshot{}, nil, fmt.Errorf("file store: read %s: %w", path, err)
+	}
+	if snap.Format != fileStoreFormat {
+		return fileSnapshot{}, nil, fmt.Errorf("file store: %s has format %d, want %d", path, snap.Format, fileStoreFormat)
+	}
+	return snap, data, nil
+}
+
+// writeFileAtomic replaces path with data: temp file in the same directory,
+// fsync, rename and fsync of the directory.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op después del rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("file store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("file store: sync: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	// Sin el fsync del directorio el rename podría perderse tras un corte.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"clinical-backend/internal/domain"
+)
+
+// Los repos de FileRepositories envuelven a los in-memory: las lecturas pasan
+// tal cual y cada escritura se guarda en el archivo antes de responder.
+
+type filePatientRepo struct {
+	PatientRepository
+	fs *fileStore
+}
+
+func (r filePatientRepo) Create(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	return persist(r.fs, func() (domain.Patient, error) { return r.PatientRepository.Create(ctx, patient) })
+}
+
+func (r filePatientRepo) Update(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	return persist(r.fs, func() (domain.Patient, error) { return r.PatientRepository.Update(ctx, patient) })
+}
+
+func (r filePatientRepo) Delete(ctx context.Context, id string) error {
+	return r.fs.write(func() error { return r.PatientRepository.Delete(ctx, id) })
+}
+
+type fileAppointmentRepo struct {
+	AppointmentRepository
+	fs *fileStore
+}
+
+func (r fileAppointmentRepo) Create(ctx context.Context, appointment domain.Appointment) (domain.Appointment, error) {
+	return persist(r.fs, func() (domain.Appointment, error) { return r.AppointmentRepository.Create(ctx, appointment) })
+}
+
+func (r fileAppointmentRepo) Update(ctx context.Context, appointment domain.Appointment) (domain.Appointment, error) {
+	return persist(r.fs, func() (domain.Appointment, error) { return r.AppointmentRepository.Update(ctx, appointment) })
+}
+
+func (r fileAppointmentRepo) Delete(ctx context.Context, id string) error {
+	return r.fs.write(func() error { return r.AppointmentRepository.Delete(ctx, id) })
+}
+
+type fileConsentRepo struct {
+	ConsentRepository
+	fs *fileStore
+}
+
+func (r fileConsentRepo) Create(ctx context.Context, consent domain.Consent) (domain.Consent, error) {
+	return persist(r.fs, func() (domain.Consent, error) { return r.ConsentRepository.Create(ctx, consent) })
+}
+
+func (r fileConsentRepo) Update(ctx context.Context, consent domain.Consent) (domain.Consent, error) {
+	return persist(r.fs, func() (domain.Consent, error) { return r.ConsentRepository.Update(ctx, consent) })
+}
+
+type fileConsentTemplateRepo struct {
+	ConsentTemplateRepository
+	fs *fileStore
+}
+
+func (r fileConsentTemplateRepo) Create(ctx context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+	return persist(r.fs, func() (domain.ConsentTemplate, error) { return r.ConsentTemplateRepository.Create(ctx, t) })
+}
+
+func (r fileConsentTemplateRepo) Update(ctx context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+	return persist(r.fs, func() (domain.ConsentTemplate, error) { return r.ConsentTemplateRepository.Update(ctx, t) })
+}
+
+type fileAuthRepo struct {
+	AuthRepository
+	fs *fileStore
+}
+
+func (r fileAuthRepo) CreateUser(ctx context.Context, user AuthUser) (AuthUser, error) {
+	return persist(r.fs, func() (AuthUser, error) { return r.AuthRepository.CreateUser(ctx, user) })
+}
+
+func (r fileAuthRepo) UpdateUserPassword(ctx context.Context, userID, passwordHash string) error {
+	return r.fs.write(func() error { return r.AuthRepository.UpdateUserPassword(ctx, userID, passwordHash) })
+}
+
+func (r fileAuthRepo) SetCalendarToken(ctx context.Context, userID, token string) error {
+	return r.fs.write(func() error { return r.AuthRepository.SetCalendarToken(ctx, userID, token) })
+}
+
+func (r fileAuthRepo) SetUserMFA(ctx context.Context, userID string, mfa UserMFA) error {
+	return r.fs.write(func() error { return r.AuthRepository.SetUserMFA(ctx, userID, mfa) })
+}
+
+func (r fileAuthRepo) UpdateUser(ctx context.Context, user AuthUser) (AuthUser, error) {
+	return persist(r.fs, func() (AuthUser, error) { return r.AuthRepository.UpdateUser(ctx, user) })
+}
+
+func (r fileAuthRepo) DeleteUser(ctx context.Context, orgID, userID string) error {
+	return r.fs.write(func() error { return r.AuthRepository.DeleteUser(ctx, orgID, userID) })
+}
+
+func (r fileAuthRepo) CreateOrganization(ctx context.Context, org Organization) (Organization, error) {
+	return persist(r.fs, func() (Organization, error) { return r.AuthRepository.CreateOrganization(ctx, org) })
+}
+
+func (r fileAuthRepo) UpdateOrganization(ctx context.Context, org Organization) (Organization, error) {
+	return persist(r.fs, func() (Organization, error) { return r.AuthRepository.UpdateOrganization(ctx, org) })
+}
+
+func (r fileAuthRepo) DeleteOrganization(ctx context.Context, orgID string) error {
+	return r.fs.write(func() error { return r.AuthRepository.DeleteOrganization(ctx, orgID) })
+}
+
+func (r fileAuthRepo) CreateSession(ctx context.Context, session AuthSession) (AuthSession, error) {
+	return persist(r.fs, func() (AuthSession, error) { return r.AuthRepository.CreateSession(ctx, session) })
+}
+
+func (r fileAuthRepo) RotateSession(ctx context.Context, oldToken, refreshToken string, next AuthSession) error {
+	return r.fs.write(func() error { return r.AuthRepository.RotateSession(ctx, oldToken, refreshToken, next) })
+}
+
+func (r fileAuthRepo) DeleteSession(ctx context.Context, token string) error {
+	return r.fs.write(func() error { return r.AuthRepository.DeleteSession(ctx, token) })
+}
+
+func (r fileAuthRepo) CreateInvitation(ctx context.Context, inv UserInvitation) (UserInvitation, error) {
+	return persist(r.fs, func() (UserInvitation, error) { return r.AuthRepository.CreateInvitation(ctx, inv) })
+}
+
+func (r fileAuthRepo) MarkInvitationUsed(ctx context.Context, token string) error {
+	return r.fs.write(func() error { return r.AuthRepository.MarkInvitationUsed(ctx, token) })
+}
+
+func (r fileAuthRepo) SaveResetToken(ctx context.Context, token PasswordResetToken) (PasswordResetToken, error) {
+	return persist(r.fs, func() (PasswordResetToken, error) { return r.AuthRepository.SaveResetToken(ctx, token) })
+}
+
+func (r fileAuthRepo) MarkResetTokenUsed(ctx context.Context, token string) error {
+	return r.fs.write(func() error { return r.AuthRepository.MarkResetTokenUsed(ctx, token) })
+}
+
+func (r fileAuthRepo) RecordAuthFailure(ctx context.Context, key string, at time.Time, window time.Duration) (AuthThrottle, error) {
+	return persist(r.fs, func() (AuthThrottle, error) { return r.AuthRepository.RecordAuthFailure(ctx, key, at, window) })
+}
+
+func (r fileAuthRepo) LockAuthThrottle(ctx context.Context, key string, until time.Time, unlockTokenHash string) error {
+	return r.fs.write(func() error { return r.AuthRepository.LockAuthThrottle(ctx, key, until, unlockTokenHash) })
+}
+
+func (r fileAuthRepo) DeleteAuthThrottle(ctx context.Context, key string) error {
+	return r.fs.write(func() error { return r.AuthRepository.DeleteAuthThrottle(ctx, key) })
+}
+
+type fileOdontogramRepo struct {
+	OdontogramRepository
+	fs *fileStore
+}
+
+func (r fileOdontogramRepo) Create(ctx context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+	return persist(r.fs, func() (domain.Odontogram, error) { return r.OdontogramRepository.Create(ctx, odontogram) })
+}
+
+func (r fileOdontogramRepo) Update(ctx context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+	return persist(r.fs, func() (domain.Odontogram, error) { return r.OdontogramRepository.Update(ctx, odontogram) })
+}
+
+func (r fileOdontogramRepo) AddTreatment(ctx context.Context, odontogramID string, treatment domain.ToothTreatment) error {
+	return r.fs.write(func() error { return r.OdontogramRepository.AddTreatment(ctx, odontogramID, treatment) })
+}
+
+func (r fileOdontogramRepo) UpdateToothCondition(ctx context.Context, odontogramID string, toothNumber domain.ToothNumber, surfaces []domain.ToothSurfaceCondition) error {
+	return r.fs.write(func() error {
+		return r.OdontogramRepository.UpdateToothCondition(ctx, odontogramID, toothNumber, surfaces)
+	})
+}
+
+type fileTreatmentPlanRepo struct {
+	TreatmentPlanRepository
+	fs *fileStore
+}
+
+func (r fileTreatmentPlanRepo) Create(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	return persist(r.fs, func() (domain.TreatmentPlan, error) { return r.TreatmentPlanRepository.Create(ctx, plan) })
+}
+
+func (r fileTreatmentPlanRepo) Update(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	return persist(r.fs, func() (domain.TreatmentPlan, error) { return r.TreatmentPlanRepository.Update(ctx, plan) })
+}
+
+func (r fileTreatmentPlanRepo) Delete(ctx context.Context, id string) error {
+	return r.fs.write(func() error { return r.TreatmentPlanRepository.Delete(ctx, id) })
+}
+
+func (r fileTreatmentPlanRepo) UpdateTreatmentStatus(ctx context.Context, planID, treatmentIndex string, status domain.PlannedTreatmentStatus, completedTreatmentID *string) error {
+	return r.fs.write(func() error {
+		return r.TreatmentPlanRepository.UpdateTreatmentStatus(ctx, planID, treatmentIndex, status, completedTreatmentID)
+	})
+}
+
+type filePaymentRepo struct {
+	PaymentRepository
+	fs *fileStore
+}
+
+func (r filePaymentRepo) Create(ctx context.Context, p domain.PaymentRecord) (domain.PaymentRecord, error) {
+	return persist(r.fs, func() (domain.PaymentRecord, error) { return r.PaymentRepository.Create(ctx, p) })
+}
+
+type fileBudgetRepo struct {
+	BudgetRepository
+	fs *fileStore
+}
+
+func (r fileBudgetRepo) Create(ctx context.Context, b domain.Budget) (domain.Budget, error) {
+	return persist(r.fs, func() (domain.Budget, error) { return r.BudgetRepository.Create(ctx, b) })
+}
+
+func (r fileBudgetRepo) Update(ctx context.Context, b domain.Budget) (domain.Budget, error) {
+	return persist(r.fs, func() (domain.Budget, error) { return r.BudgetRepository.Update(ctx, b) })
+}
+
+func (r fileBudgetRepo) Delete(ctx context.Context, id string) error {
+	return r.fs.write(func() error { return r.BudgetRepository.Delete(ctx, id) })
+}
+
+type fileWaitlistRepo struct {
+	WaitlistRepository
+	fs *fileStore
+}
+
+func (r fileWaitlistRepo) Create(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error) {
+	return persist(r.fs, func() (domain.WaitlistEntry, error) { return r.WaitlistRepository.Create(ctx, e) })
+}
+
+func (r fileWaitlistRepo) Update(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error) {
+	return persist(r.fs, func() (domain.WaitlistEntry, error) { return r.WaitlistRepository.Update(ctx, e) })
+}
+
+func (r fileWaitlistRepo) Delete(ctx context.Context, id string) error {
+	return r.fs.write(func() error { return r.WaitlistRepository.Delete(ctx, id) })
+}
+
+type fileResourceRepo struct {
+	ResourceRepository
+	fs *fileStore
+}
+
+func (r fileResourceRepo) Create(ctx context.Context, res domain.Resource) (domain.Resource, error) {
+	return persist(r.fs, func() (domain.Resource, error) { return r.ResourceRepository.Create(ctx, res) })
+}
+
+func (r fileResourceRepo) Update(ctx context.Context, res domain.Resource) (domain.Resource, error) {
+	return persist(r.fs, func() (domain.Resource, error) { return r.ResourceRepository.Update(ctx, res) })
+}
+
+func (r fileResourceRepo) Delete(ctx context.Context, id string) error {
+	return r.fs.write(func() error { return r.ResourceRepository.Delete(ctx, id) })
+}
+
+type fileAppointmentTypeRepo struct {
+	AppointmentTypeRepository
+	fs *fileStore
+}
+
+func (r fileAppointmentTypeRepo) Create(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error) {
+	return persist(r.fs, func() (domain.AppointmentType, error) { return r.AppointmentTypeRepository.Create(ctx, t) })
+}
+
+func (r fileAppointmentTypeRepo) Update(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error) {
+	return persist(r.fs, func() (domain.AppointmentType, error) { return r.AppointmentTypeRepository.Update(ctx, t) })
+}
+
+func (r fileAppointmentTypeRepo) Delete(ctx context.Context, id string) error {
+	return r.fs.write(func() error { return r.AppointmentTypeRepository.Delete(ctx, id) })
+}
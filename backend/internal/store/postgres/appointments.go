@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+type appointmentRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+// appointmentOrg is the org the appointment is stored in: its OrgID (public
+// booking and confirmation) or the org of ctx.
+func appointmentOrg(ctx context.Context, appt domain.Appointment) string {
+	if org := strings.TrimSpace(appt.OrgID); org != "" {
+		return org
+	}
+	return orgID(ctx)
+}
+
+// appointmentData drops the fields that are only filled for responses.
+func appointmentData(appt domain.Appointment) (string, error) {
+	appt.ConsentSummary = nil
+	appt.BookingNotice = nil
+	return marshal(appt)
+}
+
+func (r *appointmentRepo) Create(ctx context.Context, appt domain.Appointment) (domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, appt.OrgID); err != nil {
+		return domain.Appointment{}, err
+	}
+	appt.Version = initialVersion(appt.Version)
+	data, err := appointmentData(appt)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO appointments (org_id, id, doctor_id, patient_id, day, start_at, status, payment_amount, confirm_token, version, data)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		appointmentOrg(ctx, appt), appt.ID, appt.DoctorID, appt.PatientID, appt.StartAt.UTC().Format("2006-01-02"),
+		appt.StartAt, appt.Status, appt.PaymentAmount, appt.ConfirmToken, appt.Version, data)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	return appt, nil
+}
+
+func (r *appointmentRepo) GetByID(ctx context.Context, id string) (domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Appointment{}, err
+	}
+	return getOne[domain.Appointment](ctx, r.db, "appointment not found",
+		`SELECT data FROM appointments WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+// GetByConfirmToken busca en todas las orgs (enlace público del email) y
+// devuelve la cita con su OrgID.
+func (r *appointmentRepo) GetByConfirmToken(ctx context.Context, token string) (domain.Appointment, error) {
+	if token == "" {
+		return domain.Appointment{}, fmt.Errorf("appointment not found")
+	}
+	appt, org, err := getOneWithOrg[domain.Appointment](ctx, r.db, "appointment not found",
+		`SELECT org_id, data FROM appointments WHERE confirm_token = $1 LIMIT 1`, token)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	appt.OrgID = org
+	return appt, nil
+}
+
+// ListByDoctorAndDay devuelve las citas del día (fecha UTC de StartAt);
+// doctorID vacío: todas las citas del día de la org.
+func (r *appointmentRepo) ListByDoctorAndDay(ctx context.Context, doctorID string, day time.Time) ([]domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	return getAll[domain.Appointment](ctx, r.db,
+		`SELECT data FROM appointments WHERE org_id = $1 AND day = $2 AND ($3 = '' OR doctor_id = $3) ORDER BY start_at`,
+		orgID(ctx), day.Format("2006-01-02"), doctorID)
+}
+
+func (r *appointmentRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	return getAll[domain.Appointment](ctx, r.db,
+		`SELECT data FROM appointments WHERE org_id = $1 AND patient_id = $2 ORDER BY start_at`, orgID(ctx), patientID)
+}
+
+func (r *appointmentRepo) ListPatientIDsByDoctor(ctx context.Context, doctorID string) ([]string, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT patient_id FROM appointments WHERE org_id = $1 AND doctor_id = $2 AND patient_id <> ''`,
+		orgID(ctx), doctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (r *appointmentRepo) Update(ctx context.Context, appt domain.Appointment) (domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, appt.OrgID); err != nil {
+		return domain.Appointment{}, err
+	}
+	org := appointmentOrg(ctx, appt)
+	expected := appt.Version
+	appt.Version++
+	data, err := appointmentData(appt)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	err = updateVersioned(ctx, r.db, "appointments", org, appt.ID, "appointment not found",
+		`UPDATE appointments SET doctor_id = $3, patient_id = $4, day = $5, start_at = $6, status = $7,
+		 payment_amount = $8, confirm_token = $9, version = $10, data = $11
+		 WHERE org_id = $1 AND id = $2 AND version = $12`,
+		org, appt.ID, appt.DoctorID, appt.PatientID, appt.StartAt.UTC().Format("2006-01-02"), appt.StartAt,
+		appt.Status, appt.PaymentAmount, appt.ConfirmToken, appt.Version, data, expected)
+	if err != nil {
+		return domain.Appointment{}, err
+	}
+	return appt, nil
+}
+
+func (r *appointmentRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	return execOne(ctx, r.db, "appointment not found",
+		`DELETE FROM appointments WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+// ScanAllPayments recorre todas las orgs (dashboard de plataforma): solo citas completadas.
+func (r *appointmentRepo) ScanAllPayments(ctx context.Context) ([]store.PaymentSummary, error) {
+	if err := r.RequireSystem(ctx); err != nil {
+		return nil, err
+	}
+	return r.paymentSummaries(ctx,
+		`SELECT org_id, status, payment_amount FROM appointments WHERE status = 'completed'`)
+}
+
+func (r *appointmentRepo) ScanOrgPayments(ctx context.Context, orgID string) ([]store.PaymentSummary, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	return r.paymentSummaries(ctx,
+		`SELECT org_id, status, payment_amount FROM appointments WHERE org_id = $1`, orgID)
+}
+
+func (r *appointmentRepo) paymentSummaries(ctx context.Context, query string, args ...any) ([]store.PaymentSummary, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []store.PaymentSummary{}
+	for rows.Next() {
+		var s store.PaymentSummary
+		if err := rows.Scan(&s.OrgID, &s.Status, &s.PaymentAmount); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,357 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"clinical-backend/internal/store"
+)
+
+// authRepo guarda usuarios, organizaciones, sesiones, invitaciones y tokens
+// de recuperación. Los usuarios son globales (login por email) y se listan
+// por org_id.
+type authRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (r *authRepo) CreateUser(ctx context.Context, user store.AuthUser) (store.AuthUser, error) {
+	user.Email = normalizeEmail(user.Email)
+	if user.Role == "" {
+		user.Role = "admin"
+	}
+	if user.Status == "" {
+		user.Status = "active"
+	}
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`, user.Email).Scan(&exists); err != nil {
+		return store.AuthUser{}, err
+	}
+	if exists {
+		return store.AuthUser{}, fmt.Errorf("email already exists")
+	}
+	data, err := marshal(user)
+	if err != nil {
+		return store.AuthUser{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO users (id, org_id, email, calendar_token, data) VALUES ($1, $2, $3, $4, $5)`,
+		user.ID, user.OrgID, user.Email, user.CalendarToken, data)
+	if err != nil {
+		return store.AuthUser{}, err
+	}
+	return user, nil
+}
+
+func (r *authRepo) GetUserByID(ctx context.Context, userID string) (store.AuthUser, error) {
+	return getOne[store.AuthUser](ctx, r.db, "user not found", `SELECT data FROM users WHERE id = $1`, userID)
+}
+
+func (r *authRepo) GetUserByEmail(ctx context.Context, email string) (store.AuthUser, error) {
+	return getOne[store.AuthUser](ctx, r.db, "user not found",
+		`SELECT data FROM users WHERE email = $1`, normalizeEmail(email))
+}
+
+func (r *authRepo) UpdateUserPassword(ctx context.Context, userID, passwordHash string) error {
+	return execOne(ctx, r.db, "user not found",
+		`UPDATE users SET data = jsonb_set(data, '{PasswordHash}', to_jsonb($2::text)) WHERE id = $1`,
+		userID, passwordHash)
+}
+
+func (r *authRepo) SetCalendarToken(ctx context.Context, userID, token string) error {
+	return execOne(ctx, r.db, "user not found",
+		`UPDATE users SET calendar_token = $2, data = jsonb_set(data, '{CalendarToken}', to_jsonb($2::text)) WHERE id = $1`,
+		userID, token)
+}
+
+func (r *authRepo) SetUserMFA(ctx context.Context, userID string, mfa store.UserMFA) error {
+	data, err := marshal(mfa)
+	if err != nil {
+		return err
+	}
+	return execOne(ctx, r.db, "user not found",
+		`UPDATE users SET data = jsonb_set(data, '{MFA}', $2::jsonb) WHERE id = $1`,
+		userID, data)
+}
+
+func (r *authRepo) GetUserByCalendarToken(ctx context.Context, token string) (store.AuthUser, error) {
+	if token == "" {
+		return store.AuthUser{}, fmt.Errorf("user not found")
+	}
+	return getOne[store.AuthUser](ctx, r.db, "user not found",
+		`SELECT data FROM users WHERE calendar_token = $1`, token)
+}
+
+func (r *authRepo) UpdateUser(ctx context.Context, user store.AuthUser) (store.AuthUser, error) {
+	user.Email = normalizeEmail(user.Email)
+	data, err := marshal(user)
+	if err != nil {
+		return store.AuthUser{}, err
+	}
+	// El MFA guardado se conserva (solo lo cambia SetUserMFA).
+	var mfa []byte
+	err = r.db.QueryRowContext(ctx,
+		`UPDATE users SET org_id = $2, email = $3, calendar_token = $4, data = $5::jsonb || jsonb_build_object('MFA', data->'MFA')
+		 WHERE id = $1 RETURNING COALESCE(data->'MFA', 'null'::jsonb)`,
+		user.ID, user.OrgID, user.Email, user.CalendarToken, data).Scan(&mfa)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.AuthUser{}, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return store.AuthUser{}, err
+	}
+	user.MFA = store.UserMFA{}
+	if err := json.Unmarshal(mfa, &user.MFA); err != nil {
+		return store.AuthUser{}, err
+	}
+	return user, nil
+}
+
+func (r *authRepo) DeleteUser(ctx context.Context, orgID, userID string) error {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return err
+	}
+	return execOne(ctx, r.db, "user not found",
+		`DELETE FROM users WHERE id = $1 AND org_id = $2`, userID, orgID)
+}
+
+func (r *authRepo) ListUsersByOrg(ctx context.Context, orgID string) ([]store.AuthUser, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	users, err := getAll[store.AuthUser](ctx, r.db,
+		`SELECT data FROM users WHERE org_id = $1 ORDER BY data->>'CreatedAt'`, orgID)
+	if users == nil && err == nil {
+		users = []store.AuthUser{}
+	}
+	return users, err
+}
+
+func (r *authRepo) CreateOrganization(ctx context.Context, org store.Organization) (store.Organization, error) {
+	if org.Status == "" {
+		org.Status = "active"
+	}
+	if org.PaymentStatus == "" {
+		org.PaymentStatus = "current"
+	}
+	if org.Limits.MaxDoctors == 0 {
+		org.Limits.MaxDoctors = 5
+	}
+	if org.Limits.MaxAssistants == 0 {
+		org.Limits.MaxAssistants = 2
+	}
+	if org.Limits.MaxPatients == 0 {
+		org.Limits.MaxPatients = 20
+	}
+	data, err := marshal(org)
+	if err != nil {
+		return store.Organization{}, err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO organizations (id, data) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`, org.ID, data)
+	if err != nil {
+		return store.Organization{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return store.Organization{}, fmt.Errorf("organization already exists")
+	}
+	return org, nil
+}
+
+func (r *authRepo) GetOrganization(ctx context.Context, orgID string) (store.Organization, error) {
+	return getOne[store.Organization](ctx, r.db, "organization not found",
+		`SELECT data FROM organizations WHERE id = $1`, orgID)
+}
+
+func (r *authRepo) UpdateOrganization(ctx context.Context, org store.Organization) (store.Organization, error) {
+	now := time.Now()
+	org.UpdatedAt = &now
+	data, err := marshal(org)
+	if err != nil {
+		return store.Organization{}, err
+	}
+	err = execOne(ctx, r.db, "organization not found", `UPDATE organizations SET data = $2 WHERE id = $1`, org.ID, data)
+	if err != nil {
+		return store.Organization{}, err
+	}
+	return org, nil
+}
+
+func (r *authRepo) DeleteOrganization(ctx context.Context, orgID string) error {
+	return execOne(ctx, r.db, "organization not found", `DELETE FROM organizations WHERE id = $1`, orgID)
+}
+
+func (r *authRepo) ListOrganizations(ctx context.Context) ([]store.Organization, error) {
+	orgs, err := getAll[store.Organization](ctx, r.db, `SELECT data FROM organizations ORDER BY data->>'createdAt'`)
+	if orgs == nil && err == nil {
+		orgs = []store.Organization{}
+	}
+	return orgs, err
+}
+
+func (r *authRepo) CreateSession(ctx context.Context, session store.AuthSession) (store.AuthSession, error) {
+	data, err := marshal(session)
+	if err != nil {
+		return store.AuthSession{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO sessions (token, user_id, session_id, data) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (token) DO UPDATE SET user_id = EXCLUDED.user_id, session_id = EXCLUDED.session_id, data = EXCLUDED.data`,
+		session.Token, session.UserID, session.ID, data)
+	if err != nil {
+		return store.AuthSession{}, err
+	}
+	return session, nil
+}
+
+func (r *authRepo) GetSession(ctx context.Context, token string) (store.AuthSession, error) {
+	return getOne[store.AuthSession](ctx, r.db, "session not found", `SELECT data FROM sessions WHERE token = $1`, token)
+}
+
+func (r *authRepo) GetSessionByID(ctx context.Context, sessionID string) (store.AuthSession, error) {
+	if sessionID == "" {
+		return store.AuthSession{}, fmt.Errorf("session not found")
+	}
+	return getOne[store.AuthSession](ctx, r.db, "session not found", `SELECT data FROM sessions WHERE session_id = $1 LIMIT 1`, sessionID)
+}
+
+func (r *authRepo) ListSessionsByUser(ctx context.Context, userID string) ([]store.AuthSession, error) {
+	return getAll[store.AuthSession](ctx, r.db,
+		`SELECT data FROM sessions WHERE user_id = $1 ORDER BY (data->>'CreatedAt')::timestamptz DESC`, userID)
+}
+
+func (r *authRepo) RotateSession(ctx context.Context, oldToken, refreshToken string, next store.AuthSession) error {
+	data, err := marshal(next)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET token = $1, user_id = $2, session_id = $3, data = $4
+		 WHERE token = $5 AND data->>'RefreshToken' <> '' AND data->>'RefreshToken' = $6`,
+		next.Token, next.UserID, next.ID, data, oldToken, refreshToken)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrConflict
+	}
+	return nil
+}
+
+func (r *authRepo) DeleteSession(ctx context.Context, token string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE token = $1`, token)
+	return err
+}
+
+func (r *authRepo) CreateInvitation(ctx context.Context, inv store.UserInvitation) (store.UserInvitation, error) {
+	data, err := marshal(inv)
+	if err != nil {
+		return store.UserInvitation{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO invitations (token, data) VALUES ($1, $2) ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data`,
+		inv.Token, data)
+	if err != nil {
+		return store.UserInvitation{}, err
+	}
+	return inv, nil
+}
+
+func (r *authRepo) GetInvitation(ctx context.Context, token string) (store.UserInvitation, error) {
+	return getOne[store.UserInvitation](ctx, r.db, "invitation not found",
+		`SELECT data FROM invitations WHERE token = $1`, token)
+}
+
+func (r *authRepo) MarkInvitationUsed(ctx context.Context, token string) error {
+	return execOne(ctx, r.db, "invitation not found",
+		`UPDATE invitations SET data = jsonb_set(data, '{Used}', 'true') WHERE token = $1`, token)
+}
+
+func (r *authRepo) SaveResetToken(ctx context.Context, token store.PasswordResetToken) (store.PasswordResetToken, error) {
+	data, err := marshal(token)
+	if err != nil {
+		return store.PasswordResetToken{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO reset_tokens (token, data) VALUES ($1, $2) ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data`,
+		token.Token, data)
+	if err != nil {
+		return store.PasswordResetToken{}, err
+	}
+	return token, nil
+}
+
+func (r *authRepo) GetResetToken(ctx context.Context, token string) (store.PasswordResetToken, error) {
+	return getOne[store.PasswordResetToken](ctx, r.db, "reset token not found",
+		`SELECT data FROM reset_tokens WHERE token = $1`, token)
+}
+
+func (r *authRepo) MarkResetTokenUsed(ctx context.Context, token string) error {
+	return execOne(ctx, r.db, "reset token not found",
+		`UPDATE reset_tokens SET data = jsonb_set(data, '{Used}', 'true') WHERE token = $1`, token)
+}
+
+func (r *authRepo) GetAuthThrottle(ctx context.Context, key string) (store.AuthThrottle, error) {
+	return getOne[store.AuthThrottle](ctx, r.db, "throttle not found", `SELECT data FROM auth_throttles WHERE key = $1`, key)
+}
+
+// RecordAuthFailure locks the row (creating it if needed) so concurrent
+// failures of the same key are counted one after the other.
+func (r *authRepo) RecordAuthFailure(ctx context.Context, key string, at time.Time, window time.Duration) (store.AuthThrottle, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return store.AuthThrottle{}, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO auth_throttles (key, data) VALUES ($1, '{}') ON CONFLICT (key) DO NOTHING`, key); err != nil {
+		return store.AuthThrottle{}, err
+	}
+	var raw []byte
+	if err := tx.QueryRowContext(ctx, `SELECT data FROM auth_throttles WHERE key = $1 FOR UPDATE`, key).Scan(&raw); err != nil {
+		return store.AuthThrottle{}, err
+	}
+	var current store.AuthThrottle
+	if err := json.Unmarshal(raw, &current); err != nil {
+		return store.AuthThrottle{}, fmt.Errorf("decode row: %w", err)
+	}
+	next := store.NextAuthFailure(current, key, at, window)
+	data, err := marshal(next)
+	if err != nil {
+		return store.AuthThrottle{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE auth_throttles SET data = $2 WHERE key = $1`, key, data); err != nil {
+		return store.AuthThrottle{}, err
+	}
+	return next, tx.Commit()
+}
+
+func (r *authRepo) LockAuthThrottle(ctx context.Context, key string, until time.Time, unlockTokenHash string) error {
+	lock, err := marshal(map[string]any{"Key": key, "LockedUntil": until, "UnlockTokenHash": unlockTokenHash})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO auth_throttles (key, data) VALUES ($1, $2)
+		 ON CONFLICT (key) DO UPDATE SET data = auth_throttles.data || EXCLUDED.data`,
+		key, lock)
+	return err
+}
+
+func (r *authRepo) DeleteAuthThrottle(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM auth_throttles WHERE key = $1`, key)
+	return err
+}
@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+type consentRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+// consentOrg is the org the consent is stored in: its OrgID (public
+// acceptance) or the org of ctx.
+func consentOrg(ctx context.Context, consent domain.Consent) string {
+	if org := strings.TrimSpace(consent.OrgID); org != "" {
+		return org
+	}
+	return orgID(ctx)
+}
+
+func (r *consentRepo) Create(ctx context.Context, consent domain.Consent) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, consent.OrgID); err != nil {
+		return domain.Consent{}, err
+	}
+	data, err := marshal(consent)
+	if err != nil {
+		return domain.Consent{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO consents (org_id, id, appointment_id, accept_token, data) VALUES ($1, $2, $3, $4, $5)`,
+		consentOrg(ctx, consent), consent.ID, consent.AppointmentID, consent.AcceptToken, data)
+	if err != nil {
+		return domain.Consent{}, err
+	}
+	return consent, nil
+}
+
+func (r *consentRepo) Update(ctx context.Context, consent domain.Consent) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, consent.OrgID); err != nil {
+		return domain.Consent{}, err
+	}
+	data, err := marshal(consent)
+	if err != nil {
+		return domain.Consent{}, err
+	}
+	err = execOne(ctx, r.db, "consent not found",
+		`UPDATE consents SET appointment_id = $3, accept_token = $4, data = $5 WHERE org_id = $1 AND id = $2`,
+		consentOrg(ctx, consent), consent.ID, consent.AppointmentID, consent.AcceptToken, data)
+	if err != nil {
+		return domain.Consent{}, err
+	}
+	return consent, nil
+}
+
+func (r *consentRepo) GetByID(ctx context.Context, id string) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Consent{}, err
+	}
+	return getOne[domain.Consent](ctx, r.db, "consent not found",
+		`SELECT data FROM consents WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+// GetByToken busca en todas las orgs cuando no hay org en el contexto (enlace
+// público de aceptación) y devuelve el consentimiento con su OrgID.
+func (r *consentRepo) GetByToken(ctx context.Context, token string) (domain.Consent, error) {
+	if token == "" {
+		return domain.Consent{}, fmt.Errorf("consent not found")
+	}
+	org := store.OrgIDFromContext(ctx)
+	if strings.TrimSpace(org) == "" || org == store.DefaultOrgID {
+		consent, org, err := getOneWithOrg[domain.Consent](ctx, r.db, "consent not found",
+			`SELECT org_id, data FROM consents WHERE accept_token = $1 LIMIT 1`, token)
+		if err != nil {
+			return domain.Consent{}, err
+		}
+		consent.OrgID = org
+		return consent, nil
+	}
+	return getOne[domain.Consent](ctx, r.db, "consent not found",
+		`SELECT data FROM consents WHERE org_id = $1 AND accept_token = $2`, org, token)
+}
+
+func (r *consentRepo) GetByAppointmentID(ctx context.Context, appointmentID string) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Consent{}, err
+	}
+	return getOne[domain.Consent](ctx, r.db, "consent not found",
+		`SELECT data FROM consents WHERE org_id = $1 AND appointment_id = $2 LIMIT 1`, orgID(ctx), appointmentID)
+}
+
+func (r *consentRepo) ListByAppointmentID(ctx context.Context, appointmentID string) ([]domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	return getAll[domain.Consent](ctx, r.db,
+		`SELECT data FROM consents WHERE org_id = $1 AND appointment_id = $2`, orgID(ctx), appointmentID)
+}
+
+type consentTemplateRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func (r *consentTemplateRepo) Create(ctx context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	data, err := marshal(t)
+	if err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO consent_templates (id, org_id, is_active, data) VALUES ($1, $2, $3, $4)`,
+		t.ID, t.OrgID, t.IsActive, data)
+	if err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	return t, nil
+}
+
+func (r *consentTemplateRepo) Update(ctx context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	data, err := marshal(t)
+	if err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	err = execOne(ctx, r.db, "consent template not found",
+		`UPDATE consent_templates SET is_active = $3, data = $4 WHERE id = $1 AND org_id = $2`,
+		t.ID, t.OrgID, t.IsActive, data)
+	if err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	return t, nil
+}
+
+// GetByID busca por ID en todas las orgs y descarta las plantillas que no
+// son de la org del contexto (store.VisibleTo).
+func (r *consentTemplateRepo) GetByID(ctx context.Context, id string) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	t, err := getOne[domain.ConsentTemplate](ctx, r.db, "consent template not found",
+		`SELECT data FROM consent_templates WHERE id = $1`, id)
+	if err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	if !r.VisibleTo(ctx, t.OrgID) {
+		return domain.ConsentTemplate{}, fmt.Errorf("consent template not found")
+	}
+	return t, nil
+}
+
+func (r *consentTemplateRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	return getAll[domain.ConsentTemplate](ctx, r.db,
+		`SELECT data FROM consent_templates WHERE org_id = $1`, orgID)
+}
+
+func (r *consentTemplateRepo) GetActiveByOrg(ctx context.Context, orgID string) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
+	return getOne[domain.ConsentTemplate](ctx, r.db, "no active consent template found",
+		`SELECT data FROM consent_templates WHERE org_id = $1 AND is_active LIMIT 1`, orgID)
+}
+
+func (r *consentTemplateRepo) ListActiveByOrg(ctx context.Context, orgID string) ([]domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	return getAll[domain.ConsentTemplate](ctx, r.db,
+		`SELECT data FROM consent_templates WHERE org_id = $1 AND is_active`, orgID)
+}
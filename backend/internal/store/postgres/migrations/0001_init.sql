@@ -0,0 +1,149 @@
+-- Esquema inicial. Cada tabla guarda la entidad completa en data (JSONB) y
+-- duplica en columnas solo lo que se usa para filtrar, ordenar o versionar.
+
+CREATE TABLE patients (
+    org_id    TEXT    NOT NULL,
+    id        TEXT    NOT NULL,
+    doctor_id TEXT    NOT NULL DEFAULT '',
+    version   INTEGER NOT NULL DEFAULT 0,
+    data      JSONB   NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+CREATE INDEX patients_doctor_idx ON patients (org_id, doctor_id);
+
+CREATE TABLE appointments (
+    org_id         TEXT             NOT NULL,
+    id             TEXT             NOT NULL,
+    doctor_id      TEXT             NOT NULL DEFAULT '',
+    patient_id     TEXT             NOT NULL DEFAULT '',
+    day            TEXT             NOT NULL, -- YYYY-MM-DD (UTC) de start_at, igual que DynamoDB
+    start_at       TIMESTAMPTZ      NOT NULL,
+    status         TEXT             NOT NULL DEFAULT '',
+    payment_amount DOUBLE PRECISION NOT NULL DEFAULT 0,
+    confirm_token  TEXT             NOT NULL DEFAULT '',
+    version        INTEGER          NOT NULL DEFAULT 0,
+    data           JSONB            NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+CREATE INDEX appointments_doctor_day_idx ON appointments (org_id, doctor_id, day);
+CREATE INDEX appointments_day_idx ON appointments (org_id, day);
+CREATE INDEX appointments_patient_idx ON appointments (org_id, patient_id);
+CREATE INDEX appointments_confirm_token_idx ON appointments (confirm_token) WHERE confirm_token <> '';
+
+CREATE TABLE consents (
+    org_id         TEXT  NOT NULL,
+    id             TEXT  NOT NULL,
+    appointment_id TEXT  NOT NULL DEFAULT '',
+    accept_token   TEXT  NOT NULL DEFAULT '',
+    data           JSONB NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+CREATE INDEX consents_appointment_idx ON consents (org_id, appointment_id);
+CREATE INDEX consents_accept_token_idx ON consents (accept_token) WHERE accept_token <> '';
+
+CREATE TABLE consent_templates (
+    id        TEXT    PRIMARY KEY,
+    org_id    TEXT    NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT FALSE,
+    data      JSONB   NOT NULL
+);
+CREATE INDEX consent_templates_org_idx ON consent_templates (org_id, is_active);
+
+CREATE TABLE organizations (
+    id   TEXT  PRIMARY KEY,
+    data JSONB NOT NULL
+);
+
+CREATE TABLE users (
+    id             TEXT  PRIMARY KEY,
+    org_id         TEXT  NOT NULL DEFAULT '',
+    email          TEXT  NOT NULL UNIQUE,
+    calendar_token TEXT  NOT NULL DEFAULT '',
+    data           JSONB NOT NULL
+);
+CREATE INDEX users_org_idx ON users (org_id);
+CREATE INDEX users_calendar_token_idx ON users (calendar_token) WHERE calendar_token <> '';
+
+CREATE TABLE sessions (
+    token   TEXT  PRIMARY KEY,
+    user_id TEXT  NOT NULL,
+    data    JSONB NOT NULL
+);
+
+CREATE TABLE invitations (
+    token TEXT  PRIMARY KEY,
+    data  JSONB NOT NULL
+);
+
+CREATE TABLE reset_tokens (
+    token TEXT  PRIMARY KEY,
+    data  JSONB NOT NULL
+);
+
+CREATE TABLE odontograms (
+    org_id     TEXT        NOT NULL,
+    id         TEXT        NOT NULL,
+    patient_id TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    version    INTEGER     NOT NULL DEFAULT 0,
+    data       JSONB       NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+CREATE INDEX odontograms_patient_idx ON odontograms (org_id, patient_id, created_at);
+
+CREATE TABLE treatment_plans (
+    org_id     TEXT        NOT NULL,
+    id         TEXT        NOT NULL,
+    patient_id TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    version    INTEGER     NOT NULL DEFAULT 0,
+    data       JSONB       NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+CREATE INDEX treatment_plans_patient_idx ON treatment_plans (org_id, patient_id, created_at);
+
+CREATE TABLE payments (
+    org_id     TEXT        NOT NULL,
+    id         TEXT        NOT NULL,
+    patient_id TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    data       JSONB       NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+CREATE INDEX payments_created_idx ON payments (org_id, created_at DESC);
+CREATE INDEX payments_patient_idx ON payments (org_id, patient_id);
+
+CREATE TABLE budgets (
+    org_id     TEXT        NOT NULL,
+    id         TEXT        NOT NULL,
+    patient_id TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    version    INTEGER     NOT NULL DEFAULT 0,
+    data       JSONB       NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+CREATE INDEX budgets_patient_idx ON budgets (org_id, patient_id, created_at DESC);
+
+CREATE TABLE waitlist (
+    id          TEXT  PRIMARY KEY,
+    org_id      TEXT  NOT NULL,
+    doctor_id   TEXT  NOT NULL DEFAULT '',
+    offer_token TEXT  NOT NULL DEFAULT '', -- Offer.Token no se serializa en JSON
+    data        JSONB NOT NULL
+);
+CREATE INDEX waitlist_doctor_idx ON waitlist (doctor_id);
+CREATE INDEX waitlist_offer_token_idx ON waitlist (offer_token) WHERE offer_token <> '';
+
+CREATE TABLE resources (
+    org_id TEXT  NOT NULL,
+    id     TEXT  NOT NULL,
+    data   JSONB NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+
+CREATE TABLE appointment_types (
+    org_id TEXT  NOT NULL,
+    id     TEXT  NOT NULL,
+    data   JSONB NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
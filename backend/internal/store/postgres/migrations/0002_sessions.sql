@@ -0,0 +1,6 @@
+-- Sesiones con refresh token: el ID de la sesión se mantiene entre
+-- renovaciones mientras el token (clave) cambia.
+ALTER TABLE sessions ADD COLUMN session_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX sessions_session_id_idx ON sessions (session_id) WHERE session_id <> '';
+CREATE INDEX sessions_user_idx ON sessions (user_id);
@@ -0,0 +1,6 @@
+-- Intentos fallidos de login / recuperación por email e IP (protección
+-- contra fuerza bruta).
+CREATE TABLE auth_throttles (
+    key  TEXT  PRIMARY KEY,
+    data JSONB NOT NULL
+);
@@ -0,0 +1,225 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+type odontogramRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func (r *odontogramRepo) Create(ctx context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
+	odontogram.OrgID = orgID(ctx)
+	odontogram.CreatedAt = time.Now()
+	odontogram.UpdatedAt = time.Now()
+	odontogram.Version = initialVersion(odontogram.Version)
+	data, err := marshal(odontogram)
+	if err != nil {
+		return domain.Odontogram{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO odontograms (org_id, id, patient_id, created_at, version, data) VALUES ($1, $2, $3, $4, $5, $6)`,
+		odontogram.OrgID, odontogram.ID, odontogram.PatientID, odontogram.CreatedAt, odontogram.Version, data)
+	if err != nil {
+		return domain.Odontogram{}, fmt.Errorf("failed to create odontogram: %w", err)
+	}
+	return odontogram, nil
+}
+
+// GetByPatientID devuelve el odontograma más reciente del paciente.
+func (r *odontogramRepo) GetByPatientID(ctx context.Context, patientID string) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
+	return getOne[domain.Odontogram](ctx, r.db, "odontogram not found for patient",
+		`SELECT data FROM odontograms WHERE org_id = $1 AND patient_id = $2 ORDER BY created_at DESC LIMIT 1`,
+		orgID(ctx), patientID)
+}
+
+func (r *odontogramRepo) GetByID(ctx context.Context, id string) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
+	return getOne[domain.Odontogram](ctx, r.db, "odontogram not found",
+		`SELECT data FROM odontograms WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+func (r *odontogramRepo) Update(ctx context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
+	org := orgID(ctx)
+	odontogram.OrgID = org
+	odontogram.UpdatedAt = time.Now()
+	expected := odontogram.Version
+	odontogram.Version++
+	data, err := marshal(odontogram)
+	if err != nil {
+		return domain.Odontogram{}, err
+	}
+	err = updateVersioned(ctx, r.db, "odontograms", org, odontogram.ID, "odontogram not found",
+		`UPDATE odontograms SET version = $3, data = $4 WHERE org_id = $1 AND id = $2 AND version = $5`,
+		org, odontogram.ID, odontogram.Version, data, expected)
+	if err != nil {
+		return domain.Odontogram{}, err
+	}
+	return odontogram, nil
+}
+
+func (r *odontogramRepo) AddTreatment(ctx context.Context, odontogramID string, treatment domain.ToothTreatment) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	current, err := r.GetByID(ctx, odontogramID)
+	if err != nil {
+		return err
+	}
+	treatment.CreatedAt = time.Now()
+	treatment.CompletedAt = time.Now()
+	current.TreatmentHistory = append(current.TreatmentHistory, treatment)
+	_, err = r.Update(ctx, current)
+	return err
+}
+
+func (r *odontogramRepo) UpdateToothCondition(ctx context.Context, odontogramID string, toothNumber domain.ToothNumber, surfaces []domain.ToothSurfaceCondition) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	current, err := r.GetByID(ctx, odontogramID)
+	if err != nil {
+		return err
+	}
+	for i, tooth := range current.Teeth {
+		if tooth.ToothNumber == toothNumber {
+			tooth.Surfaces = surfaces
+			tooth.LastUpdated = time.Now()
+			current.Teeth[i] = tooth
+			break
+		}
+	}
+	_, err = r.Update(ctx, current)
+	return err
+}
+
+func (r *odontogramRepo) GetTreatmentHistory(ctx context.Context, patientID string, limit int) ([]domain.ToothTreatment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	odontogram, err := r.GetByPatientID(ctx, patientID)
+	if err != nil {
+		return []domain.ToothTreatment{}, nil
+	}
+	treatments := odontogram.TreatmentHistory
+	if limit > 0 && len(treatments) > limit {
+		treatments = treatments[len(treatments)-limit:]
+	}
+	return treatments, nil
+}
+
+type treatmentPlanRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func (r *treatmentPlanRepo) Create(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	plan.OrgID = orgID(ctx)
+	plan.CreatedAt = time.Now()
+	plan.UpdatedAt = time.Now()
+	plan.Version = initialVersion(plan.Version)
+	data, err := marshal(plan)
+	if err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO treatment_plans (org_id, id, patient_id, created_at, version, data) VALUES ($1, $2, $3, $4, $5, $6)`,
+		plan.OrgID, plan.ID, plan.PatientID, plan.CreatedAt, plan.Version, data)
+	if err != nil {
+		return domain.TreatmentPlan{}, fmt.Errorf("failed to create treatment plan: %w", err)
+	}
+	return plan, nil
+}
+
+func (r *treatmentPlanRepo) GetByID(ctx context.Context, id string) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	return getOne[domain.TreatmentPlan](ctx, r.db, "treatment plan not found",
+		`SELECT data FROM treatment_plans WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+func (r *treatmentPlanRepo) GetByPatientID(ctx context.Context, patientID string) ([]domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	plans, err := getAll[domain.TreatmentPlan](ctx, r.db,
+		`SELECT data FROM treatment_plans WHERE org_id = $1 AND patient_id = $2 ORDER BY created_at`,
+		orgID(ctx), patientID)
+	if plans == nil && err == nil {
+		plans = []domain.TreatmentPlan{}
+	}
+	return plans, err
+}
+
+func (r *treatmentPlanRepo) Update(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	org := orgID(ctx)
+	plan.OrgID = org
+	plan.UpdatedAt = time.Now()
+	expected := plan.Version
+	plan.Version++
+	data, err := marshal(plan)
+	if err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	err = updateVersioned(ctx, r.db, "treatment_plans", org, plan.ID, "treatment plan not found",
+		`UPDATE treatment_plans SET patient_id = $3, version = $4, data = $5 WHERE org_id = $1 AND id = $2 AND version = $6`,
+		org, plan.ID, plan.PatientID, plan.Version, data, expected)
+	if err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	return plan, nil
+}
+
+func (r *treatmentPlanRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	return execOne(ctx, r.db, "treatment plan not found",
+		`DELETE FROM treatment_plans WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+func (r *treatmentPlanRepo) UpdateTreatmentStatus(ctx context.Context, planID, treatmentIndex string, status domain.PlannedTreatmentStatus, completedTreatmentID *string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	plan, err := r.GetByID(ctx, planID)
+	if err != nil {
+		return err
+	}
+	for i := range plan.Treatments {
+		if fmt.Sprintf("%d", i) == treatmentIndex {
+			plan.Treatments[i].Status = status
+			if completedTreatmentID != nil {
+				plan.Treatments[i].CompletedTreatmentID = completedTreatmentID
+			}
+			break
+		}
+	}
+	_, err = r.Update(ctx, plan)
+	return err
+}
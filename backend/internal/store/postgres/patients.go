@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+type patientRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func (r *patientRepo) Create(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
+	patient.Version = initialVersion(patient.Version)
+	data, err := marshal(patient)
+	if err != nil {
+		return domain.Patient{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO patients (org_id, id, doctor_id, version, data) VALUES ($1, $2, $3, $4, $5)`,
+		orgID(ctx), patient.ID, patient.DoctorID, patient.Version, data)
+	if err != nil {
+		return domain.Patient{}, err
+	}
+	return patient, nil
+}
+
+func (r *patientRepo) GetByID(ctx context.Context, id string) (domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
+	return getOne[domain.Patient](ctx, r.db, "patient not found",
+		`SELECT data FROM patients WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+func (r *patientRepo) ListByDoctor(ctx context.Context, doctorID string) ([]domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	return getAll[domain.Patient](ctx, r.db,
+		`SELECT data FROM patients WHERE org_id = $1 AND ($2 = '' OR doctor_id = $2)`, orgID(ctx), doctorID)
+}
+
+// ListAll devuelve los pacientes de todas las orgs (estadísticas de plataforma).
+func (r *patientRepo) ListAll(ctx context.Context) ([]domain.Patient, error) {
+	if err := r.RequireSystem(ctx); err != nil {
+		return nil, err
+	}
+	items, err := getAll[domain.Patient](ctx, r.db, `SELECT data FROM patients`)
+	if items == nil && err == nil {
+		items = []domain.Patient{}
+	}
+	return items, err
+}
+
+// SearchByQuery filtra en Go con las mismas reglas que el repo en memoria
+// (nombre, documento, email y teléfono sin espacios).
+func (r *patientRepo) SearchByQuery(ctx context.Context, doctorID, query string) ([]domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	items, err := r.ListByDoctor(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return items, nil
+	}
+	phone := strings.ReplaceAll(q, " ", "")
+	var results []domain.Patient
+	for _, p := range items {
+		if strings.Contains(strings.ToLower(p.FirstName+" "+p.LastName), q) ||
+			strings.Contains(strings.ToLower(p.DocumentID), q) ||
+			strings.Contains(strings.ToLower(p.Email), q) ||
+			strings.Contains(strings.ReplaceAll(p.Phone, " ", ""), phone) {
+			results = append(results, p)
+		}
+	}
+	return results, nil
+}
+
+func (r *patientRepo) Update(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
+	org := orgID(ctx)
+	expected := patient.Version
+	patient.Version++
+	data, err := marshal(patient)
+	if err != nil {
+		return domain.Patient{}, err
+	}
+	err = updateVersioned(ctx, r.db, "patients", org, patient.ID, "patient not found",
+		`UPDATE patients SET doctor_id = $3, version = $4, data = $5 WHERE org_id = $1 AND id = $2 AND version = $6`,
+		org, patient.ID, patient.DoctorID, patient.Version, data, expected)
+	if err != nil {
+		return domain.Patient{}, err
+	}
+	return patient, nil
+}
+
+func (r *patientRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	return execOne(ctx, r.db, "patient not found",
+		`DELETE FROM patients WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
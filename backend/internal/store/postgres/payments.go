@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+type paymentRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func (r *paymentRepo) Create(ctx context.Context, p domain.PaymentRecord) (domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.PaymentRecord{}, err
+	}
+	p.OrgID = orgID(ctx)
+	data, err := marshal(p)
+	if err != nil {
+		return domain.PaymentRecord{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO payments (org_id, id, patient_id, created_at, data) VALUES ($1, $2, $3, $4, $5)`,
+		p.OrgID, p.ID, p.PatientID, p.CreatedAt, data)
+	if err != nil {
+		return domain.PaymentRecord{}, err
+	}
+	return p, nil
+}
+
+// ListByOrg devuelve los pagos de la org, los más recientes primero.
+func (r *paymentRepo) ListByOrg(ctx context.Context, limit int) ([]domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	query := `SELECT data FROM payments WHERE org_id = $1 ORDER BY created_at DESC, id DESC`
+	if limit > 0 {
+		return getAll[domain.PaymentRecord](ctx, r.db, query+` LIMIT $2`, orgID(ctx), limit)
+	}
+	return getAll[domain.PaymentRecord](ctx, r.db, query, orgID(ctx))
+}
+
+func (r *paymentRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	return getAll[domain.PaymentRecord](ctx, r.db,
+		`SELECT data FROM payments WHERE org_id = $1 AND patient_id = $2 ORDER BY created_at DESC`, orgID(ctx), patientID)
+}
+
+type budgetRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func (r *budgetRepo) Create(ctx context.Context, b domain.Budget) (domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
+	b.OrgID = orgID(ctx)
+	b.Version = initialVersion(b.Version)
+	data, err := marshal(b)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO budgets (org_id, id, patient_id, created_at, version, data) VALUES ($1, $2, $3, $4, $5, $6)`,
+		b.OrgID, b.ID, b.PatientID, b.CreatedAt, b.Version, data)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	return b, nil
+}
+
+func (r *budgetRepo) GetByID(ctx context.Context, id string) (domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
+	return getOne[domain.Budget](ctx, r.db, "budget not found",
+		`SELECT data FROM budgets WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+func (r *budgetRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	return getAll[domain.Budget](ctx, r.db,
+		`SELECT data FROM budgets WHERE org_id = $1 AND patient_id = $2 ORDER BY created_at DESC`, orgID(ctx), patientID)
+}
+
+func (r *budgetRepo) Update(ctx context.Context, b domain.Budget) (domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
+	b.OrgID = orgID(ctx)
+	expected := b.Version
+	b.Version++
+	data, err := marshal(b)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	err = updateVersioned(ctx, r.db, "budgets", b.OrgID, b.ID, "budget not found",
+		`UPDATE budgets SET patient_id = $3, version = $4, data = $5 WHERE org_id = $1 AND id = $2 AND version = $6`,
+		b.OrgID, b.ID, b.PatientID, b.Version, data, expected)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	return b, nil
+}
+
+func (r *budgetRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	return execOne(ctx, r.db, "budget not found",
+		`DELETE FROM budgets WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
@@ -0,0 +1,195 @@
+// Package postgres implements the store repositories on PostgreSQL.
+//
+// Cada tabla guarda la entidad completa en una columna JSONB (data) y, aparte,
+// las columnas por las que se filtra: org_id, doctor, paciente, tokens y
+// version. Las lecturas de datos de una organización filtran siempre por
+// org_id (la org del contexto, "default" si no hay), igual que las particiones
+// ORG# de DynamoDB; solo los enlaces públicos (tokens) y los reportes de la
+// plataforma buscan en todas las orgs.
+//
+// El paquete usa solo database/sql: el binario registra el driver (pgx,
+// lib/pq…) y abre la conexión.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"clinical-backend/internal/store"
+)
+
+// Repositories are the PostgreSQL implementations of every store repository.
+type Repositories struct {
+	Patients         store.PatientRepository
+	Appointments     store.AppointmentRepository
+	Consents         store.ConsentRepository
+	ConsentTemplates store.ConsentTemplateRepository
+	Users            store.AuthRepository
+	Odontograms      store.OdontogramRepository
+	TreatmentPlans   store.TreatmentPlanRepository
+	Payments         store.PaymentRepository
+	Budgets          store.BudgetRepository
+	Waitlist         store.WaitlistRepository
+	Resources        store.ResourceRepository
+	AppointmentTypes store.AppointmentTypeRepository
+}
+
+// NewRepositories applies the pending migrations on db and returns the
+// repositories. The caller owns db.
+func NewRepositories(ctx context.Context, db *sql.DB, opts ...func(*store.Tenancy)) (*Repositories, error) {
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	if err := Migrate(ctx, db); err != nil {
+		return nil, err
+	}
+	var t store.Tenancy
+	for _, o := range opts {
+		o(&t)
+	}
+	return &Repositories{
+		Patients:         &patientRepo{Tenancy: t, db: db},
+		Appointments:     &appointmentRepo{Tenancy: t, db: db},
+		Consents:         &consentRepo{Tenancy: t, db: db},
+		ConsentTemplates: &consentTemplateRepo{Tenancy: t, db: db},
+		Users:            &authRepo{Tenancy: t, db: db},
+		Odontograms:      &odontogramRepo{Tenancy: t, db: db},
+		TreatmentPlans:   &treatmentPlanRepo{Tenancy: t, db: db},
+		Payments:         &paymentRepo{Tenancy: t, db: db},
+		Budgets:          &budgetRepo{Tenancy: t, db: db},
+		Waitlist:         &waitlistRepo{Tenancy: t, db: db},
+		Resources:        &resourceRepo{Tenancy: t, db: db},
+		AppointmentTypes: &appointmentTypeRepo{Tenancy: t, db: db},
+	}, nil
+}
+
+// orgID is the org the rows of ctx belong to ("default" without org, like
+// the DynamoDB store).
+func orgID(ctx context.Context) string {
+	org := strings.TrimSpace(store.OrgIDFromContext(ctx))
+	if org == "" {
+		return store.DefaultOrgID
+	}
+	return org
+}
+
+func initialVersion(v int) int {
+	if v <= 0 {
+		return 1
+	}
+	return v
+}
+
+// getOne runs a query that selects the data column of one row.
+func getOne[T any](ctx context.Context, db *sql.DB, notFound, query string, args ...any) (T, error) {
+	var out T
+	var data []byte
+	err := db.QueryRowContext(ctx, query, args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return out, errors.New(notFound)
+	}
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("decode row: %w", err)
+	}
+	return out, nil
+}
+
+// getOneWithOrg is getOne for the lookups across orgs (public tokens): the
+// query selects org_id and data, and the org of the row is returned too.
+func getOneWithOrg[T any](ctx context.Context, db *sql.DB, notFound, query string, args ...any) (T, string, error) {
+	var out T
+	var org string
+	var data []byte
+	err := db.QueryRowContext(ctx, query, args...).Scan(&org, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return out, "", errors.New(notFound)
+	}
+	if err != nil {
+		return out, "", err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, "", fmt.Errorf("decode row: %w", err)
+	}
+	return out, org, nil
+}
+
+// getAll runs a query that selects the data column of many rows.
+func getAll[T any](ctx context.Context, db *sql.DB, query string, args ...any) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []T
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("decode row: %w", err)
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// execOne runs a statement that must touch exactly one row.
+func execOne(ctx context.Context, db *sql.DB, notFound, query string, args ...any) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New(notFound)
+	}
+	return nil
+}
+
+// updateVersioned runs an UPDATE that also filters by the expected version.
+// If no row changed, it tells a missing row (notFound) from a row that
+// someone else saved in between (store.ErrConflict).
+func updateVersioned(ctx context.Context, db *sql.DB, table, org, id, notFound, query string, args ...any) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE org_id = $1 AND id = $2)", org, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return store.ErrConflict
+	}
+	return errors.New(notFound)
+}
+
+// marshal encodes the data column. It is passed as text so that any driver
+// can cast it to JSONB.
+func marshal(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("encode row: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// waitlistRepo: GetByOfferToken no filtra por org porque el enlace público
+// de la oferta llega sin org en el contexto; el resto solo ve las entradas
+// de la org del contexto (store.VisibleTo).
+type waitlistRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+// waitlistOfferToken is the token of the entry's offer. Offer.Token is not
+// serialized in JSON, so it lives in its own column.
+func waitlistOfferToken(e domain.WaitlistEntry) string {
+	if e.Offer == nil {
+		return ""
+	}
+	return e.Offer.Token
+}
+
+func (r *waitlistRepo) Create(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, e.OrgID); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if e.OrgID == "" {
+		e.OrgID = orgID(ctx)
+	}
+	data, err := marshal(e)
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO waitlist (id, org_id, doctor_id, offer_token, data) VALUES ($1, $2, $3, $4, $5)`,
+		e.ID, e.OrgID, e.DoctorID, waitlistOfferToken(e), data)
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	return e, nil
+}
+
+func (r *waitlistRepo) get(ctx context.Context, notFound, query string, args ...any) (domain.WaitlistEntry, error) {
+	var token string
+	var data []byte
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&token, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.WaitlistEntry{}, errors.New(notFound)
+	}
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	return decodeWaitlistEntry(token, data)
+}
+
+func decodeWaitlistEntry(token string, data []byte) (domain.WaitlistEntry, error) {
+	var e domain.WaitlistEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return domain.WaitlistEntry{}, fmt.Errorf("decode row: %w", err)
+	}
+	if e.Offer != nil {
+		e.Offer.Token = token
+	}
+	return e, nil
+}
+
+func (r *waitlistRepo) GetByID(ctx context.Context, id string) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	e, err := r.get(ctx, "waitlist entry not found", `SELECT offer_token, data FROM waitlist WHERE id = $1`, id)
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if !r.VisibleTo(ctx, e.OrgID) {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist entry not found")
+	}
+	return e, nil
+}
+
+func (r *waitlistRepo) GetByOfferToken(ctx context.Context, token string) (domain.WaitlistEntry, error) {
+	if token == "" {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist offer not found")
+	}
+	return r.get(ctx, "waitlist offer not found", `SELECT offer_token, data FROM waitlist WHERE offer_token = $1`, token)
+}
+
+func (r *waitlistRepo) ListByDoctor(ctx context.Context, doctorID string) ([]domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT offer_token, data FROM waitlist WHERE doctor_id = $1 ORDER BY data->>'createdAt'`, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []domain.WaitlistEntry
+	for rows.Next() {
+		var token string
+		var data []byte
+		if err := rows.Scan(&token, &data); err != nil {
+			return nil, err
+		}
+		e, err := decodeWaitlistEntry(token, data)
+		if err != nil {
+			return nil, err
+		}
+		if r.VisibleTo(ctx, e.OrgID) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, rows.Err()
+}
+
+func (r *waitlistRepo) Update(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, e.OrgID); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	current, err := r.GetByID(ctx, e.ID)
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	if e.OrgID != "" && e.OrgID != current.OrgID {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist entry not found")
+	}
+	e.OrgID = current.OrgID
+	data, err := marshal(e)
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	err = execOne(ctx, r.db, "waitlist entry not found",
+		`UPDATE waitlist SET doctor_id = $2, offer_token = $3, data = $4 WHERE id = $1`,
+		e.ID, e.DoctorID, waitlistOfferToken(e), data)
+	if err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	return e, nil
+}
+
+func (r *waitlistRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `DELETE FROM waitlist WHERE id = $1`, id)
+	return err
+}
+
+type resourceRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func (r *resourceRepo) Create(ctx context.Context, res domain.Resource) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, res.OrgID); err != nil {
+		return domain.Resource{}, err
+	}
+	if res.OrgID == "" {
+		res.OrgID = orgID(ctx)
+	}
+	data, err := marshal(res)
+	if err != nil {
+		return domain.Resource{}, err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO resources (org_id, id, data) VALUES ($1, $2, $3)`, res.OrgID, res.ID, data)
+	if err != nil {
+		return domain.Resource{}, err
+	}
+	return res, nil
+}
+
+func (r *resourceRepo) GetByID(ctx context.Context, id string) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Resource{}, err
+	}
+	return getOne[domain.Resource](ctx, r.db, "resource not found",
+		`SELECT data FROM resources WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+func (r *resourceRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.Resource, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	return getAll[domain.Resource](ctx, r.db, `SELECT data FROM resources WHERE org_id = $1`, orgID)
+}
+
+func (r *resourceRepo) Update(ctx context.Context, res domain.Resource) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, res.OrgID); err != nil {
+		return domain.Resource{}, err
+	}
+	if res.OrgID == "" {
+		res.OrgID = orgID(ctx)
+	}
+	data, err := marshal(res)
+	if err != nil {
+		return domain.Resource{}, err
+	}
+	err = execOne(ctx, r.db, "resource not found",
+		`UPDATE resources SET data = $3 WHERE org_id = $1 AND id = $2`, res.OrgID, res.ID, data)
+	if err != nil {
+		return domain.Resource{}, err
+	}
+	return res, nil
+}
+
+func (r *resourceRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	return execOne(ctx, r.db, "resource not found",
+		`DELETE FROM resources WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+type appointmentTypeRepo struct {
+	store.Tenancy
+	db *sql.DB
+}
+
+func (r *appointmentTypeRepo) Create(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	if t.OrgID == "" {
+		t.OrgID = orgID(ctx)
+	}
+	data, err := marshal(t)
+	if err != nil {
+		return domain.AppointmentType{}, err
+	}
+	_, err = r.db.ExecContext(ctx, `INSERT INTO appointment_types (org_id, id, data) VALUES ($1, $2, $3)`, t.OrgID, t.ID, data)
+	if err != nil {
+		return domain.AppointmentType{}, err
+	}
+	return t, nil
+}
+
+func (r *appointmentTypeRepo) GetByID(ctx context.Context, id string) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	return getOne[domain.AppointmentType](ctx, r.db, "appointment type not found",
+		`SELECT data FROM appointment_types WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
+
+func (r *appointmentTypeRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	return getAll[domain.AppointmentType](ctx, r.db, `SELECT data FROM appointment_types WHERE org_id = $1`, orgID)
+}
+
+func (r *appointmentTypeRepo) Update(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	if t.OrgID == "" {
+		t.OrgID = orgID(ctx)
+	}
+	data, err := marshal(t)
+	if err != nil {
+		return domain.AppointmentType{}, err
+	}
+	err = execOne(ctx, r.db, "appointment type not found",
+		`UPDATE appointment_types SET data = $3 WHERE org_id = $1 AND id = $2`, t.OrgID, t.ID, data)
+	if err != nil {
+		return domain.AppointmentType{}, err
+	}
+	return t, nil
+}
+
+func (r *appointmentTypeRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	return execOne(ctx, r.db, "appointment type not found",
+		`DELETE FROM appointment_types WHERE org_id = $1 AND id = $2`, orgID(ctx), id)
+}
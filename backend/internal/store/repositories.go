@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -26,6 +27,9 @@ type AppointmentRepository interface {
 	GetByConfirmToken(ctx context.Context, token string) (domain.Appointment, error)
 	ListByDoctorAndDay(ctx context.Context, doctorID string, day time.Time) ([]domain.Appointment, error)
 	ListByPatient(ctx context.Context, patientID string) ([]domain.Appointment, error)
+	// ListPatientIDsByDoctor returns the distinct patients the doctor has
+	// appointments with, in any status.
+	ListPatientIDsByDoctor(ctx context.Context, doctorID string) ([]string, error)
 	Update(ctx context.Context, appointment domain.Appointment) (domain.Appointment, error)
 	Delete(ctx context.Context, id string) error
 	ScanAllPayments(ctx context.Context) ([]PaymentSummary, error)
@@ -68,14 +72,48 @@ type AuthUser struct {
 	PasswordHash       string
 	MustChangePassword bool
 	CreatedAt          time.Time
-}
-
+	// Availability es el horario de atención (solo doctores); nil = sin restricción.
+	Availability *domain.DoctorAvailability
+	// CalendarToken da acceso al feed ICS de la agenda del doctor ("" = sin feed).
+	CalendarToken string
+	// MFA se escribe solo con SetUserMFA; UpdateUser lo conserva.
+	MFA UserMFA
+}
+
+// UserMFA is the TOTP second factor of a user and the login challenge in
+// progress. MFA is on while Secret is set.
+type UserMFA struct {
+	Secret        string // base32 (RFC 4648, sin padding)
+	EnabledAt     time.Time
+	PendingSecret string   // inscripción iniciada y aún sin confirmar con un código
+	RecoveryCodes []string // SHA-256 (hex) de los códigos de recuperación sin usar
+	LastStep      int64    // último paso TOTP aceptado: un código no se acepta dos veces
+	// Desafío del login: el password fue correcto y falta el segundo factor.
+	// Los códigos erróneos se cuentan aparte, en un AuthThrottle por desafío.
+	ChallengeHash      string // SHA-256 (hex) del secreto del mfaToken
+	ChallengeExpiresAt time.Time
+}
+
+// AuthSession is one access token. A login opens a session (ID) whose
+// access token is short-lived and renewed with RefreshToken; each renewal
+// replaces the record with a new Token and RefreshToken under the same ID.
+// Token and the refresh tokens are SHA-256 hashes: the plaintext only goes to
+// the client. Sessions from before refresh tokens have no ID nor RefreshToken.
 type AuthSession struct {
 	Token     string
 	UserID    string
 	OrgID     string
 	Role      string
-	ExpiresAt time.Time
+	ExpiresAt time.Time // vencimiento del access token
+
+	ID                   string
+	RefreshToken         string
+	RefreshExpiresAt     time.Time
+	PreviousRefreshToken string // el rotado en LastRefreshAt (reintentos concurrentes)
+	CreatedAt            time.Time
+	LastRefreshAt        time.Time
+	UserAgent            string
+	IP                   string
 }
 
 type UserInvitation struct {
@@ -96,19 +134,33 @@ type OrgLimits struct {
 }
 
 type Organization struct {
-	ID            string     `json:"id"`
-	Name          string     `json:"name"`
-	BusinessName  string     `json:"businessName"`
-	TaxID         string     `json:"taxId"`
-	Address       string     `json:"address"`
-	Email         string     `json:"email"`
-	Phone         string     `json:"phone"`
-	Status        string     `json:"status"`
-	PaymentStatus string     `json:"paymentStatus"`
-	Limits        OrgLimits  `json:"limits"`
-	Timezone      string     `json:"timezone,omitempty"`
-	CreatedAt     time.Time  `json:"createdAt"`
-	UpdatedAt     *time.Time `json:"updatedAt,omitempty"`
+	ID            string               `json:"id"`
+	Name          string               `json:"name"`
+	BusinessName  string               `json:"businessName"`
+	TaxID         string               `json:"taxId"`
+	Address       string               `json:"address"`
+	Email         string               `json:"email"`
+	Phone         string               `json:"phone"`
+	Status        string               `json:"status"`
+	PaymentStatus string               `json:"paymentStatus"`
+	Limits        OrgLimits            `json:"limits"`
+	Timezone      string               `json:"timezone,omitempty"`
+	NoShowPolicy  *domain.NoShowPolicy `json:"noShowPolicy,omitempty"`
+	// RequireMFA obliga al personal (todo rol salvo patient) a usar TOTP.
+	RequireMFA bool `json:"requireMfa,omitempty"`
+	// DoctorsOwnPatientsOnly limita a cada doctor a sus propios pacientes.
+	DoctorsOwnPatientsOnly bool `json:"doctorsOwnPatientsOnly,omitempty"`
+	// Roles es la matriz de permisos de la org; nil usa la predeterminada.
+	Roles     []OrgRole  `json:"roles,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// OrgRole is a role of an organization and the permissions it grants.
+type OrgRole struct {
+	Name        string   `json:"name"` // el valor de AuthUser.Role
+	Label       string   `json:"label,omitempty"`
+	Permissions []string `json:"permissions"`
 }
 
 type PasswordResetToken struct {
@@ -118,11 +170,41 @@ type PasswordResetToken struct {
 	Used      bool
 }
 
+// AuthThrottle counts the recent attempts of one key (an email or an IP for
+// one operation) for brute-force protection. Failures only counts attempts
+// within the window that started at WindowStart.
+type AuthThrottle struct {
+	Key             string // ej. "login:email:ana@example.com", "login:ip:10.0.0.1"
+	Failures        int
+	WindowStart     time.Time
+	LastFailureAt   time.Time
+	LockedUntil     time.Time // bloqueo temporal; sobrevive al cambio de ventana
+	UnlockTokenHash string    // SHA-256 del token del email de desbloqueo
+}
+
+// NextAuthFailure adds a failure at at to t, starting a new window when the
+// current one is older than window. Shared by the RecordAuthFailure
+// implementations.
+func NextAuthFailure(t AuthThrottle, key string, at time.Time, window time.Duration) AuthThrottle {
+	t.Key = key
+	if t.WindowStart.IsZero() || !at.Before(t.WindowStart.Add(window)) {
+		t.Failures, t.WindowStart = 0, at
+	}
+	t.Failures++
+	t.LastFailureAt = at
+	return t
+}
+
 type AuthRepository interface {
 	CreateUser(ctx context.Context, user AuthUser) (AuthUser, error)
 	GetUserByID(ctx context.Context, userID string) (AuthUser, error)
 	GetUserByEmail(ctx context.Context, email string) (AuthUser, error)
 	UpdateUserPassword(ctx context.Context, userID, passwordHash string) error
+	// SetCalendarToken replaces the user's ICS feed token ("" disables the feed).
+	SetCalendarToken(ctx context.Context, userID, token string) error
+	// SetUserMFA replaces the user's MFA state (the zero value turns MFA off).
+	SetUserMFA(ctx context.Context, userID string, mfa UserMFA) error
+	GetUserByCalendarToken(ctx context.Context, token string) (AuthUser, error)
 	UpdateUser(ctx context.Context, user AuthUser) (AuthUser, error)
 	DeleteUser(ctx context.Context, orgID, userID string) error
 	ListUsersByOrg(ctx context.Context, orgID string) ([]AuthUser, error)
@@ -135,6 +217,14 @@ type AuthRepository interface {
 	CreateSession(ctx context.Context, session AuthSession) (AuthSession, error)
 	GetSession(ctx context.Context, token string) (AuthSession, error)
 	DeleteSession(ctx context.Context, token string) error
+	// GetSessionByID returns the current record of a session (see AuthSession).
+	GetSessionByID(ctx context.Context, sessionID string) (AuthSession, error)
+	ListSessionsByUser(ctx context.Context, userID string) ([]AuthSession, error)
+	// RotateSession replaces the record of oldToken with next in one step,
+	// only if its RefreshToken is still refreshToken; otherwise it returns
+	// ErrConflict and changes nothing, so of two concurrent renewals with
+	// the same refresh token only one succeeds.
+	RotateSession(ctx context.Context, oldToken, refreshToken string, next AuthSession) error
 
 	CreateInvitation(ctx context.Context, inv UserInvitation) (UserInvitation, error)
 	GetInvitation(ctx context.Context, token string) (UserInvitation, error)
@@ -143,6 +233,15 @@ type AuthRepository interface {
 	SaveResetToken(ctx context.Context, token PasswordResetToken) (PasswordResetToken, error)
 	GetResetToken(ctx context.Context, token string) (PasswordResetToken, error)
 	MarkResetTokenUsed(ctx context.Context, token string) error
+
+	// GetAuthThrottle returns a "not found" error for keys without attempts.
+	GetAuthThrottle(ctx context.Context, key string) (AuthThrottle, error)
+	// RecordAuthFailure atomically adds a failure to key (see AuthThrottle)
+	// and returns the updated record.
+	RecordAuthFailure(ctx context.Context, key string, at time.Time, window time.Duration) (AuthThrottle, error)
+	// LockAuthThrottle locks key until the given time, keeping its count.
+	LockAuthThrottle(ctx context.Context, key string, until time.Time, unlockTokenHash string) error
+	DeleteAuthThrottle(ctx context.Context, key string) error
 }
 
 // Odontogram repository interface
@@ -182,6 +281,34 @@ type BudgetRepository interface {
 	Delete(ctx context.Context, id string) error
 }
 
+// WaitlistRepository for patients waiting for a freed slot.
+type WaitlistRepository interface {
+	Create(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error)
+	GetByID(ctx context.Context, id string) (domain.WaitlistEntry, error)
+	GetByOfferToken(ctx context.Context, token string) (domain.WaitlistEntry, error)
+	ListByDoctor(ctx context.Context, doctorID string) ([]domain.WaitlistEntry, error)
+	Update(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// ResourceRepository for the org's chairs, rooms and equipment.
+type ResourceRepository interface {
+	Create(ctx context.Context, res domain.Resource) (domain.Resource, error)
+	GetByID(ctx context.Context, id string) (domain.Resource, error)
+	ListByOrg(ctx context.Context, orgID string) ([]domain.Resource, error)
+	Update(ctx context.Context, res domain.Resource) (domain.Resource, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// AppointmentTypeRepository for the org's catalog of appointment types.
+type AppointmentTypeRepository interface {
+	Create(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error)
+	GetByID(ctx context.Context, id string) (domain.AppointmentType, error)
+	ListByOrg(ctx context.Context, orgID string) ([]domain.AppointmentType, error)
+	Update(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error)
+	Delete(ctx context.Context, id string) error
+}
+
 type InMemoryRepositories struct {
 	Patients         PatientRepository
 	Appointments     AppointmentRepository
@@ -192,57 +319,110 @@ type InMemoryRepositories struct {
 	TreatmentPlans   TreatmentPlanRepository
 	Payments         PaymentRepository
 	Budgets          BudgetRepository
+	Waitlist         WaitlistRepository
+	Resources        ResourceRepository
+	AppointmentTypes AppointmentTypeRepository
 }
 
-func NewInMemoryRepositories() *InMemoryRepositories {
+func NewInMemoryRepositories(opts ...func(*Tenancy)) *InMemoryRepositories {
+	t := newTenancy(opts)
 	return &InMemoryRepositories{
-		Patients:         &memoryPatientRepo{items: map[string]domain.Patient{}},
-		Appointments:     &memoryAppointmentRepo{items: map[string]domain.Appointment{}},
-		Consents:         &memoryConsentRepo{items: map[string]domain.Consent{}},
-		ConsentTemplates: &memoryConsentTemplateRepo{items: map[string]domain.ConsentTemplate{}},
-		Users:            &memoryAuthRepo{usersByID: map[string]AuthUser{}, emailIndex: map[string]string{}, usersByOrg: map[string]map[string]struct{}{}, sessions: map[string]AuthSession{}, invitations: map[string]UserInvitation{}, resetTokens: map[string]PasswordResetToken{}, orgs: map[string]Organization{}},
-		Odontograms:      &memoryOdontogramRepo{items: map[string]domain.Odontogram{}, byPatient: map[string]string{}},
-		TreatmentPlans:   &memoryTreatmentPlanRepo{items: map[string]domain.TreatmentPlan{}, byPatient: map[string][]string{}},
-		Payments:         &memoryPaymentRepo{items: []domain.PaymentRecord{}},
-		Budgets:          &memoryBudgetRepo{items: map[string]domain.Budget{}},
+		Patients:         &memoryPatientRepo{Tenancy: t, items: map[orgKey]domain.Patient{}},
+		Appointments:     &memoryAppointmentRepo{Tenancy: t, items: map[orgKey]domain.Appointment{}},
+		Consents:         &memoryConsentRepo{Tenancy: t, items: map[orgKey]domain.Consent{}},
+		ConsentTemplates: &memoryConsentTemplateRepo{Tenancy: t, items: map[string]domain.ConsentTemplate{}},
+		Users:            &memoryAuthRepo{Tenancy: t, usersByID: map[string]AuthUser{}, emailIndex: map[string]string{}, usersByOrg: map[string]map[string]struct{}{}, sessions: map[string]AuthSession{}, invitations: map[string]UserInvitation{}, resetTokens: map[string]PasswordResetToken{}, orgs: map[string]Organization{}, throttles: map[string]AuthThrottle{}},
+		Odontograms:      &memoryOdontogramRepo{Tenancy: t, items: map[string]domain.Odontogram{}, byPatient: map[string]string{}},
+		TreatmentPlans:   &memoryTreatmentPlanRepo{Tenancy: t, items: map[string]domain.TreatmentPlan{}, byPatient: map[string][]string{}},
+		Payments:         &memoryPaymentRepo{Tenancy: t, items: []domain.PaymentRecord{}},
+		Budgets:          &memoryBudgetRepo{Tenancy: t, items: map[string]domain.Budget{}},
+		Waitlist:         &memoryWaitlistRepo{Tenancy: t, items: map[string]domain.WaitlistEntry{}},
+		Resources:        &memoryResourceRepo{Tenancy: t, items: map[orgKey]domain.Resource{}},
+		AppointmentTypes: &memoryAppointmentTypeRepo{Tenancy: t, items: map[orgKey]domain.AppointmentType{}},
 	}
 }
 
+// orgKey identifies a record within its organization. The in-memory repos
+// partition by org like the ORG# keys of DynamoDB, so that both backends
+// behave the same (see storetest).
+type orgKey struct {
+	org string
+	id  string
+}
+
+func keyFor(ctx context.Context, id string) orgKey {
+	return orgKey{org: orgIDOrDefault(ctx), id: id}
+}
+
+// Orden común de los listados, igual en todos los backends.
+
+func sortByStartAt(items []domain.Appointment) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].StartAt.Before(items[j].StartAt) })
+}
+
+func sortPaymentsNewestFirst(items []domain.PaymentRecord) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+}
+
+func sortSessionsNewestFirst(items []AuthSession) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+}
+
+func sortBudgetsNewestFirst(items []domain.Budget) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+}
+
 type memoryPatientRepo struct {
+	Tenancy
 	mu    sync.RWMutex
-	items map[string]domain.Patient
+	items map[orgKey]domain.Patient
 }
 
-func (r *memoryPatientRepo) Create(_ context.Context, patient domain.Patient) (domain.Patient, error) {
+func (r *memoryPatientRepo) Create(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.items[patient.ID] = patient
+	patient.Version = initialVersion(patient.Version)
+	r.items[keyFor(ctx, patient.ID)] = patient
 	return patient, nil
 }
 
-func (r *memoryPatientRepo) GetByID(_ context.Context, id string) (domain.Patient, error) {
+func (r *memoryPatientRepo) GetByID(ctx context.Context, id string) (domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	item, ok := r.items[id]
+	item, ok := r.items[keyFor(ctx, id)]
 	if !ok {
 		return domain.Patient{}, fmt.Errorf("patient not found")
 	}
 	return item, nil
 }
 
-func (r *memoryPatientRepo) ListByDoctor(_ context.Context, doctorID string) ([]domain.Patient, error) {
+func (r *memoryPatientRepo) ListByDoctor(ctx context.Context, doctorID string) ([]domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	org := orgIDOrDefault(ctx)
 	var results []domain.Patient
-	for _, p := range r.items {
-		if doctorID == "" || p.DoctorID == doctorID {
+	for k, p := range r.items {
+		if k.org == org && (doctorID == "" || p.DoctorID == doctorID) {
 			results = append(results, p)
 		}
 	}
 	return results, nil
 }
 
-func (r *memoryPatientRepo) ListAll(_ context.Context) ([]domain.Patient, error) {
+// ListAll returns the patients of every org (platform stats).
+func (r *memoryPatientRepo) ListAll(ctx context.Context) ([]domain.Patient, error) {
+	if err := r.RequireSystem(ctx); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	results := make([]domain.Patient, 0, len(r.items))
@@ -252,13 +432,17 @@ func (r *memoryPatientRepo) ListAll(_ context.Context) ([]domain.Patient, error)
 	return results, nil
 }
 
-func (r *memoryPatientRepo) SearchByQuery(_ context.Context, doctorID, query string) ([]domain.Patient, error) {
+func (r *memoryPatientRepo) SearchByQuery(ctx context.Context, doctorID, query string) ([]domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	org := orgIDOrDefault(ctx)
 	q := strings.ToLower(strings.TrimSpace(query))
 	var results []domain.Patient
-	for _, p := range r.items {
-		if doctorID != "" && p.DoctorID != doctorID {
+	for k, p := range r.items {
+		if k.org != org || (doctorID != "" && p.DoctorID != doctorID) {
 			continue
 		}
 		if q == "" ||
@@ -274,189 +458,316 @@ func (r *memoryPatientRepo) SearchByQuery(_ context.Context, doctorID, query str
 	return results, nil
 }
 
-func (r *memoryPatientRepo) Update(_ context.Context, patient domain.Patient) (domain.Patient, error) {
+func (r *memoryPatientRepo) Update(ctx context.Context, patient domain.Patient) (domain.Patient, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Patient{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.items[patient.ID]; !ok {
+	key := keyFor(ctx, patient.ID)
+	current, ok := r.items[key]
+	if !ok {
 		return domain.Patient{}, fmt.Errorf("patient not found")
 	}
-	r.items[patient.ID] = patient
+	if err := checkVersion(current.Version, patient.Version); err != nil {
+		return domain.Patient{}, err
+	}
+	patient.Version++
+	r.items[key] = patient
 	return patient, nil
 }
 
-func (r *memoryPatientRepo) Delete(_ context.Context, id string) error {
+func (r *memoryPatientRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.items[id]; !ok {
+	key := keyFor(ctx, id)
+	if _, ok := r.items[key]; !ok {
 		return fmt.Errorf("patient not found")
 	}
-	delete(r.items, id)
+	delete(r.items, key)
 	return nil
 }
 
 type memoryAppointmentRepo struct {
+	Tenancy
 	mu    sync.RWMutex
-	items map[string]domain.Appointment
+	items map[orgKey]domain.Appointment
+}
+
+// appointmentKey stores the appointment in its OrgID (public booking and
+// confirmation) or in the org of ctx, like appointmentItem.
+func appointmentKey(ctx context.Context, appointment domain.Appointment) orgKey {
+	if org := strings.TrimSpace(appointment.OrgID); org != "" {
+		return orgKey{org: org, id: appointment.ID}
+	}
+	return keyFor(ctx, appointment.ID)
 }
 
-func (r *memoryAppointmentRepo) Create(_ context.Context, appointment domain.Appointment) (domain.Appointment, error) {
+func (r *memoryAppointmentRepo) Create(ctx context.Context, appointment domain.Appointment) (domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, appointment.OrgID); err != nil {
+		return domain.Appointment{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.items[appointment.ID] = appointment
+	appointment.Version = initialVersion(appointment.Version)
+	r.items[appointmentKey(ctx, appointment)] = appointment
 	return appointment, nil
 }
 
-func (r *memoryAppointmentRepo) GetByID(_ context.Context, id string) (domain.Appointment, error) {
+func (r *memoryAppointmentRepo) GetByID(ctx context.Context, id string) (domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Appointment{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	item, ok := r.items[id]
+	item, ok := r.items[keyFor(ctx, id)]
 	if !ok {
 		return domain.Appointment{}, fmt.Errorf("appointment not found")
 	}
 	return item, nil
 }
 
+// GetByConfirmToken busca en todas las orgs (enlace público) y devuelve la
+// cita con su OrgID.
 func (r *memoryAppointmentRepo) GetByConfirmToken(_ context.Context, token string) (domain.Appointment, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	for _, item := range r.items {
+	if token == "" {
+		return domain.Appointment{}, fmt.Errorf("appointment not found")
+	}
+	for k, item := range r.items {
 		if item.ConfirmToken == token {
+			item.OrgID = k.org
 			return item, nil
 		}
 	}
 	return domain.Appointment{}, fmt.Errorf("appointment not found")
 }
 
-func (r *memoryAppointmentRepo) ListByDoctorAndDay(_ context.Context, doctorID string, day time.Time) ([]domain.Appointment, error) {
+// ListByDoctorAndDay compara la fecha UTC de StartAt con day (como los GSI de
+// DynamoDB) y ordena por StartAt.
+func (r *memoryAppointmentRepo) ListByDoctorAndDay(ctx context.Context, doctorID string, day time.Time) ([]domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	org := orgIDOrDefault(ctx)
+	dayStr := day.Format("2006-01-02")
 	var out []domain.Appointment
-	y, m, d := day.Date()
-	for _, item := range r.items {
-		if item.DoctorID != doctorID {
+	for k, item := range r.items {
+		// doctorID vacío: todas las citas del día (igual que DynamoDB).
+		if k.org != org || (doctorID != "" && item.DoctorID != doctorID) {
 			continue
 		}
-		iy, im, id := item.StartAt.Date()
-		if iy == y && im == m && id == d {
+		if item.StartAt.UTC().Format("2006-01-02") == dayStr {
 			out = append(out, item)
 		}
 	}
+	sortByStartAt(out)
 	return out, nil
 }
 
-func (r *memoryAppointmentRepo) ListByPatient(_ context.Context, patientID string) ([]domain.Appointment, error) {
+func (r *memoryAppointmentRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	org := orgIDOrDefault(ctx)
 	var out []domain.Appointment
-	for _, item := range r.items {
-		if item.PatientID == patientID {
+	for k, item := range r.items {
+		if k.org == org && item.PatientID == patientID {
 			out = append(out, item)
 		}
 	}
+	sortByStartAt(out)
+	return out, nil
+}
+
+func (r *memoryAppointmentRepo) ListPatientIDsByDoctor(ctx context.Context, doctorID string) ([]string, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	org := orgIDOrDefault(ctx)
+	seen := map[string]bool{}
+	out := []string{}
+	for k, item := range r.items {
+		if k.org == org && item.DoctorID == doctorID && item.PatientID != "" && !seen[item.PatientID] {
+			seen[item.PatientID] = true
+			out = append(out, item.PatientID)
+		}
+	}
 	return out, nil
 }
 
-func (r *memoryAppointmentRepo) Update(_ context.Context, appointment domain.Appointment) (domain.Appointment, error) {
+func (r *memoryAppointmentRepo) Update(ctx context.Context, appointment domain.Appointment) (domain.Appointment, error) {
+	if err := r.RequireOrg(ctx, appointment.OrgID); err != nil {
+		return domain.Appointment{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.items[appointment.ID]; !ok {
+	key := appointmentKey(ctx, appointment)
+	current, ok := r.items[key]
+	if !ok {
 		return domain.Appointment{}, fmt.Errorf("appointment not found")
 	}
-	r.items[appointment.ID] = appointment
+	if err := checkVersion(current.Version, appointment.Version); err != nil {
+		return domain.Appointment{}, err
+	}
+	appointment.Version++
+	r.items[key] = appointment
 	return appointment, nil
 }
 
-func (r *memoryAppointmentRepo) Delete(_ context.Context, id string) error {
+func (r *memoryAppointmentRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.items[id]; !ok {
+	key := keyFor(ctx, id)
+	if _, ok := r.items[key]; !ok {
 		return fmt.Errorf("appointment not found")
 	}
-	delete(r.items, id)
+	delete(r.items, key)
 	return nil
 }
 
-func (r *memoryAppointmentRepo) ScanAllPayments(_ context.Context) ([]PaymentSummary, error) {
+// ScanAllPayments recorre todas las orgs: solo citas completadas.
+func (r *memoryAppointmentRepo) ScanAllPayments(ctx context.Context) ([]PaymentSummary, error) {
+	if err := r.RequireSystem(ctx); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	out := make([]PaymentSummary, 0, len(r.items))
-	for _, a := range r.items {
-		out = append(out, PaymentSummary{OrgID: "default", Status: a.Status, PaymentAmount: a.PaymentAmount})
+	for k, a := range r.items {
+		if a.Status == "completed" {
+			out = append(out, PaymentSummary{OrgID: k.org, Status: a.Status, PaymentAmount: a.PaymentAmount})
+		}
 	}
 	return out, nil
 }
 
-func (r *memoryAppointmentRepo) ScanOrgPayments(_ context.Context, orgID string) ([]PaymentSummary, error) {
+func (r *memoryAppointmentRepo) ScanOrgPayments(ctx context.Context, orgID string) ([]PaymentSummary, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	out := make([]PaymentSummary, 0, len(r.items))
-	for _, a := range r.items {
-		out = append(out, PaymentSummary{OrgID: orgID, Status: a.Status, PaymentAmount: a.PaymentAmount})
+	for k, a := range r.items {
+		if k.org == orgID {
+			out = append(out, PaymentSummary{OrgID: orgID, Status: a.Status, PaymentAmount: a.PaymentAmount})
+		}
 	}
 	return out, nil
 }
 
 type memoryConsentRepo struct {
+	Tenancy
 	mu    sync.RWMutex
-	items map[string]domain.Consent
+	items map[orgKey]domain.Consent
 }
 
-func (r *memoryConsentRepo) Create(_ context.Context, consent domain.Consent) (domain.Consent, error) {
+func consentKey(ctx context.Context, consent domain.Consent) orgKey {
+	if org := strings.TrimSpace(consent.OrgID); org != "" {
+		return orgKey{org: org, id: consent.ID}
+	}
+	return keyFor(ctx, consent.ID)
+}
+
+func (r *memoryConsentRepo) Create(ctx context.Context, consent domain.Consent) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, consent.OrgID); err != nil {
+		return domain.Consent{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.items[consent.ID] = consent
+	r.items[consentKey(ctx, consent)] = consent
 	return consent, nil
 }
 
-func (r *memoryConsentRepo) Update(_ context.Context, consent domain.Consent) (domain.Consent, error) {
+func (r *memoryConsentRepo) Update(ctx context.Context, consent domain.Consent) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, consent.OrgID); err != nil {
+		return domain.Consent{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.items[consent.ID]; !ok {
+	key := consentKey(ctx, consent)
+	if _, ok := r.items[key]; !ok {
 		return domain.Consent{}, fmt.Errorf("consent not found")
 	}
-	r.items[consent.ID] = consent
+	r.items[key] = consent
 	return consent, nil
 }
 
-func (r *memoryConsentRepo) GetByID(_ context.Context, id string) (domain.Consent, error) {
+func (r *memoryConsentRepo) GetByID(ctx context.Context, id string) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Consent{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	item, ok := r.items[id]
+	item, ok := r.items[keyFor(ctx, id)]
 	if !ok {
 		return domain.Consent{}, fmt.Errorf("consent not found")
 	}
 	return item, nil
 }
 
-func (r *memoryConsentRepo) GetByToken(_ context.Context, token string) (domain.Consent, error) {
+// GetByToken busca en todas las orgs si no hay org en el contexto (enlace
+// público de aceptación) y devuelve el consentimiento con su OrgID.
+func (r *memoryConsentRepo) GetByToken(ctx context.Context, token string) (domain.Consent, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	for _, item := range r.items {
-		if item.AcceptToken == token {
+	org := strings.TrimSpace(OrgIDFromContext(ctx))
+	public := org == "" || org == DefaultOrgID
+	for k, item := range r.items {
+		if token == "" || item.AcceptToken != token {
+			continue
+		}
+		if public {
+			item.OrgID = k.org
+			return item, nil
+		}
+		if k.org == org {
 			return item, nil
 		}
 	}
 	return domain.Consent{}, fmt.Errorf("consent not found")
 }
 
-func (r *memoryConsentRepo) GetByAppointmentID(_ context.Context, appointmentID string) (domain.Consent, error) {
+func (r *memoryConsentRepo) GetByAppointmentID(ctx context.Context, appointmentID string) (domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Consent{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	for _, item := range r.items {
-		if item.AppointmentID == appointmentID {
+	org := orgIDOrDefault(ctx)
+	for k, item := range r.items {
+		if k.org == org && item.AppointmentID == appointmentID {
 			return item, nil
 		}
 	}
 	return domain.Consent{}, fmt.Errorf("consent not found")
 }
 
-func (r *memoryConsentRepo) ListByAppointmentID(_ context.Context, appointmentID string) ([]domain.Consent, error) {
+func (r *memoryConsentRepo) ListByAppointmentID(ctx context.Context, appointmentID string) ([]domain.Consent, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	org := orgIDOrDefault(ctx)
 	var out []domain.Consent
-	for _, item := range r.items {
-		if item.AppointmentID == appointmentID {
+	for k, item := range r.items {
+		if k.org == org && item.AppointmentID == appointmentID {
 			out = append(out, item)
 		}
 	}
@@ -465,38 +776,52 @@ func (r *memoryConsentRepo) ListByAppointmentID(_ context.Context, appointmentID
 
 // In-memory consent template repository
 type memoryConsentTemplateRepo struct {
+	Tenancy
 	mu    sync.RWMutex
 	items map[string]domain.ConsentTemplate
 }
 
-func (r *memoryConsentTemplateRepo) Create(_ context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+func (r *memoryConsentTemplateRepo) Create(ctx context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.items[t.ID] = t
 	return t, nil
 }
 
-func (r *memoryConsentTemplateRepo) Update(_ context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+func (r *memoryConsentTemplateRepo) Update(ctx context.Context, t domain.ConsentTemplate) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.items[t.ID]; !ok {
+	current, ok := r.items[t.ID]
+	if !ok || !r.VisibleTo(ctx, current.OrgID) || (t.OrgID != "" && t.OrgID != current.OrgID) {
 		return domain.ConsentTemplate{}, fmt.Errorf("consent template not found")
 	}
 	r.items[t.ID] = t
 	return t, nil
 }
 
-func (r *memoryConsentTemplateRepo) GetByID(_ context.Context, id string) (domain.ConsentTemplate, error) {
+func (r *memoryConsentTemplateRepo) GetByID(ctx context.Context, id string) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	item, ok := r.items[id]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, item.OrgID) {
 		return domain.ConsentTemplate{}, fmt.Errorf("consent template not found")
 	}
 	return item, nil
 }
 
-func (r *memoryConsentTemplateRepo) ListByOrg(_ context.Context, orgID string) ([]domain.ConsentTemplate, error) {
+func (r *memoryConsentTemplateRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	var out []domain.ConsentTemplate
@@ -508,7 +833,10 @@ func (r *memoryConsentTemplateRepo) ListByOrg(_ context.Context, orgID string) (
 	return out, nil
 }
 
-func (r *memoryConsentTemplateRepo) GetActiveByOrg(_ context.Context, orgID string) (domain.ConsentTemplate, error) {
+func (r *memoryConsentTemplateRepo) GetActiveByOrg(ctx context.Context, orgID string) (domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return domain.ConsentTemplate{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	for _, item := range r.items {
@@ -519,7 +847,10 @@ func (r *memoryConsentTemplateRepo) GetActiveByOrg(_ context.Context, orgID stri
 	return domain.ConsentTemplate{}, fmt.Errorf("no active consent template found")
 }
 
-func (r *memoryConsentTemplateRepo) ListActiveByOrg(_ context.Context, orgID string) ([]domain.ConsentTemplate, error) {
+func (r *memoryConsentTemplateRepo) ListActiveByOrg(ctx context.Context, orgID string) ([]domain.ConsentTemplate, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	var out []domain.ConsentTemplate
@@ -532,6 +863,7 @@ func (r *memoryConsentTemplateRepo) ListActiveByOrg(_ context.Context, orgID str
 }
 
 type memoryAuthRepo struct {
+	Tenancy
 	mu          sync.RWMutex
 	usersByID   map[string]AuthUser
 	emailIndex  map[string]string
@@ -540,6 +872,7 @@ type memoryAuthRepo struct {
 	invitations map[string]UserInvitation
 	resetTokens map[string]PasswordResetToken
 	orgs        map[string]Organization
+	throttles   map[string]AuthThrottle
 }
 
 func (r *memoryAuthRepo) CreateUser(_ context.Context, user AuthUser) (AuthUser, error) {
@@ -594,15 +927,20 @@ func (r *memoryAuthRepo) GetUserByEmail(_ context.Context, email string) (AuthUs
 func (r *memoryAuthRepo) UpdateUser(_ context.Context, user AuthUser) (AuthUser, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.usersByID[user.ID]; !ok {
+	existing, ok := r.usersByID[user.ID]
+	if !ok {
 		return AuthUser{}, fmt.Errorf("user not found")
 	}
 	user.Email = normalizeEmail(user.Email)
+	user.MFA = existing.MFA
 	r.usersByID[user.ID] = user
 	return user, nil
 }
 
-func (r *memoryAuthRepo) DeleteUser(_ context.Context, orgID, userID string) error {
+func (r *memoryAuthRepo) DeleteUser(ctx context.Context, orgID, userID string) error {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	u, ok := r.usersByID[userID]
@@ -617,7 +955,10 @@ func (r *memoryAuthRepo) DeleteUser(_ context.Context, orgID, userID string) err
 	return nil
 }
 
-func (r *memoryAuthRepo) ListUsersByOrg(_ context.Context, orgID string) ([]AuthUser, error) {
+func (r *memoryAuthRepo) ListUsersByOrg(ctx context.Context, orgID string) ([]AuthUser, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	ids, ok := r.usersByOrg[orgID]
@@ -724,6 +1065,44 @@ func (r *memoryAuthRepo) GetSession(_ context.Context, token string) (AuthSessio
 	return s, nil
 }
 
+func (r *memoryAuthRepo) GetSessionByID(_ context.Context, sessionID string) (AuthSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if sessionID != "" {
+		for _, s := range r.sessions {
+			if s.ID == sessionID {
+				return s, nil
+			}
+		}
+	}
+	return AuthSession{}, fmt.Errorf("session not found")
+}
+
+func (r *memoryAuthRepo) ListSessionsByUser(_ context.Context, userID string) ([]AuthSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []AuthSession
+	for _, s := range r.sessions {
+		if s.UserID == userID {
+			out = append(out, s)
+		}
+	}
+	sortSessionsNewestFirst(out)
+	return out, nil
+}
+
+func (r *memoryAuthRepo) RotateSession(_ context.Context, oldToken, refreshToken string, next AuthSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.sessions[oldToken]
+	if !ok || current.RefreshToken == "" || current.RefreshToken != refreshToken {
+		return ErrConflict
+	}
+	delete(r.sessions, oldToken)
+	r.sessions[next.Token] = next
+	return nil
+}
+
 func (r *memoryAuthRepo) DeleteSession(_ context.Context, token string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -783,6 +1162,45 @@ func (r *memoryAuthRepo) UpdateUserPassword(_ context.Context, userID, passwordH
 	return nil
 }
 
+func (r *memoryAuthRepo) SetCalendarToken(_ context.Context, userID, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.usersByID[userID]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	user.CalendarToken = token
+	r.usersByID[userID] = user
+	return nil
+}
+
+func (r *memoryAuthRepo) SetUserMFA(_ context.Context, userID string, mfa UserMFA) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.usersByID[userID]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	mfa.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+	user.MFA = mfa
+	r.usersByID[userID] = user
+	return nil
+}
+
+func (r *memoryAuthRepo) GetUserByCalendarToken(_ context.Context, token string) (AuthUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if token == "" {
+		return AuthUser{}, fmt.Errorf("user not found")
+	}
+	for _, u := range r.usersByID {
+		if u.CalendarToken == token {
+			return u, nil
+		}
+	}
+	return AuthUser{}, fmt.Errorf("user not found")
+}
+
 func (r *memoryAuthRepo) SaveResetToken(_ context.Context, token PasswordResetToken) (PasswordResetToken, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -812,23 +1230,69 @@ func (r *memoryAuthRepo) MarkResetTokenUsed(_ context.Context, token string) err
 	return nil
 }
 
+func (r *memoryAuthRepo) GetAuthThrottle(_ context.Context, key string) (AuthThrottle, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.throttles[key]
+	if !ok {
+		return AuthThrottle{}, fmt.Errorf("throttle not found")
+	}
+	return t, nil
+}
+
+func (r *memoryAuthRepo) RecordAuthFailure(_ context.Context, key string, at time.Time, window time.Duration) (AuthThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.throttles == nil {
+		r.throttles = map[string]AuthThrottle{}
+	}
+	t := NextAuthFailure(r.throttles[key], key, at, window)
+	r.throttles[key] = t
+	return t, nil
+}
+
+func (r *memoryAuthRepo) LockAuthThrottle(_ context.Context, key string, until time.Time, unlockTokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.throttles == nil {
+		r.throttles = map[string]AuthThrottle{}
+	}
+	t := r.throttles[key]
+	t.Key, t.LockedUntil, t.UnlockTokenHash = key, until, unlockTokenHash
+	r.throttles[key] = t
+	return nil
+}
+
+func (r *memoryAuthRepo) DeleteAuthThrottle(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.throttles, key)
+	return nil
+}
+
 func normalizeEmail(email string) string {
 	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // In-memory odontogram repository
 type memoryOdontogramRepo struct {
+	Tenancy
 	mu        sync.RWMutex
 	items     map[string]domain.Odontogram
 	byPatient map[string]string // patientID -> odontogramID
 }
 
-func (r *memoryOdontogramRepo) Create(_ context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+func (r *memoryOdontogramRepo) Create(ctx context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	odontogram.OrgID = orgIDOrDefault(ctx)
 	odontogram.CreatedAt = time.Now()
 	odontogram.UpdatedAt = time.Now()
+	odontogram.Version = initialVersion(odontogram.Version)
 
 	r.items[odontogram.ID] = odontogram
 	r.byPatient[odontogram.PatientID] = odontogram.ID
@@ -836,7 +1300,10 @@ func (r *memoryOdontogramRepo) Create(_ context.Context, odontogram domain.Odont
 	return odontogram, nil
 }
 
-func (r *memoryOdontogramRepo) GetByPatientID(_ context.Context, patientID string) (domain.Odontogram, error) {
+func (r *memoryOdontogramRepo) GetByPatientID(ctx context.Context, patientID string) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -846,45 +1313,60 @@ func (r *memoryOdontogramRepo) GetByPatientID(_ context.Context, patientID strin
 	}
 
 	item, ok := r.items[odontogramID]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, item.OrgID) {
 		return domain.Odontogram{}, fmt.Errorf("odontogram not found")
 	}
 
 	return item, nil
 }
 
-func (r *memoryOdontogramRepo) GetByID(_ context.Context, id string) (domain.Odontogram, error) {
+func (r *memoryOdontogramRepo) GetByID(ctx context.Context, id string) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	item, ok := r.items[id]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, item.OrgID) {
 		return domain.Odontogram{}, fmt.Errorf("odontogram not found")
 	}
 
 	return item, nil
 }
 
-func (r *memoryOdontogramRepo) Update(_ context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+func (r *memoryOdontogramRepo) Update(ctx context.Context, odontogram domain.Odontogram) (domain.Odontogram, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Odontogram{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, ok := r.items[odontogram.ID]; !ok {
+	current, ok := r.items[odontogram.ID]
+	if !ok || !r.VisibleTo(ctx, current.OrgID) {
 		return domain.Odontogram{}, fmt.Errorf("odontogram not found")
 	}
+	if err := checkVersion(current.Version, odontogram.Version); err != nil {
+		return domain.Odontogram{}, err
+	}
+	odontogram.OrgID = current.OrgID
 
 	odontogram.UpdatedAt = time.Now()
+	odontogram.Version++
 	r.items[odontogram.ID] = odontogram
 
 	return odontogram, nil
 }
 
-func (r *memoryOdontogramRepo) AddTreatment(_ context.Context, odontogramID string, treatment domain.ToothTreatment) error {
+func (r *memoryOdontogramRepo) AddTreatment(ctx context.Context, odontogramID string, treatment domain.ToothTreatment) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	odontogram, ok := r.items[odontogramID]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, odontogram.OrgID) {
 		return fmt.Errorf("odontogram not found")
 	}
 
@@ -893,18 +1375,22 @@ func (r *memoryOdontogramRepo) AddTreatment(_ context.Context, odontogramID stri
 
 	odontogram.TreatmentHistory = append(odontogram.TreatmentHistory, treatment)
 	odontogram.UpdatedAt = time.Now()
+	odontogram.Version++
 
 	r.items[odontogramID] = odontogram
 
 	return nil
 }
 
-func (r *memoryOdontogramRepo) UpdateToothCondition(_ context.Context, odontogramID string, toothNumber domain.ToothNumber, surfaces []domain.ToothSurfaceCondition) error {
+func (r *memoryOdontogramRepo) UpdateToothCondition(ctx context.Context, odontogramID string, toothNumber domain.ToothNumber, surfaces []domain.ToothSurfaceCondition) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	odontogram, ok := r.items[odontogramID]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, odontogram.OrgID) {
 		return fmt.Errorf("odontogram not found")
 	}
 
@@ -919,12 +1405,16 @@ func (r *memoryOdontogramRepo) UpdateToothCondition(_ context.Context, odontogra
 	}
 
 	odontogram.UpdatedAt = time.Now()
+	odontogram.Version++
 	r.items[odontogramID] = odontogram
 
 	return nil
 }
 
-func (r *memoryOdontogramRepo) GetTreatmentHistory(_ context.Context, patientID string, limit int) ([]domain.ToothTreatment, error) {
+func (r *memoryOdontogramRepo) GetTreatmentHistory(ctx context.Context, patientID string, limit int) ([]domain.ToothTreatment, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -934,7 +1424,7 @@ func (r *memoryOdontogramRepo) GetTreatmentHistory(_ context.Context, patientID
 	}
 
 	odontogram, ok := r.items[odontogramID]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, odontogram.OrgID) {
 		return []domain.ToothTreatment{}, nil
 	}
 
@@ -948,17 +1438,23 @@ func (r *memoryOdontogramRepo) GetTreatmentHistory(_ context.Context, patientID
 
 // In-memory treatment plan repository
 type memoryTreatmentPlanRepo struct {
+	Tenancy
 	mu        sync.RWMutex
 	items     map[string]domain.TreatmentPlan
 	byPatient map[string][]string // patientID -> []planID
 }
 
-func (r *memoryTreatmentPlanRepo) Create(_ context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+func (r *memoryTreatmentPlanRepo) Create(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	plan.OrgID = orgIDOrDefault(ctx)
 	plan.CreatedAt = time.Now()
 	plan.UpdatedAt = time.Now()
+	plan.Version = initialVersion(plan.Version)
 
 	r.items[plan.ID] = plan
 
@@ -970,19 +1466,25 @@ func (r *memoryTreatmentPlanRepo) Create(_ context.Context, plan domain.Treatmen
 	return plan, nil
 }
 
-func (r *memoryTreatmentPlanRepo) GetByID(_ context.Context, id string) (domain.TreatmentPlan, error) {
+func (r *memoryTreatmentPlanRepo) GetByID(ctx context.Context, id string) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	item, ok := r.items[id]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, item.OrgID) {
 		return domain.TreatmentPlan{}, fmt.Errorf("treatment plan not found")
 	}
 
 	return item, nil
 }
 
-func (r *memoryTreatmentPlanRepo) GetByPatientID(_ context.Context, patientID string) ([]domain.TreatmentPlan, error) {
+func (r *memoryTreatmentPlanRepo) GetByPatientID(ctx context.Context, patientID string) ([]domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -993,7 +1495,7 @@ func (r *memoryTreatmentPlanRepo) GetByPatientID(_ context.Context, patientID st
 
 	var plans []domain.TreatmentPlan
 	for _, planID := range planIDs {
-		if plan, exists := r.items[planID]; exists {
+		if plan, exists := r.items[planID]; exists && r.VisibleTo(ctx, plan.OrgID) {
 			plans = append(plans, plan)
 		}
 	}
@@ -1001,26 +1503,38 @@ func (r *memoryTreatmentPlanRepo) GetByPatientID(_ context.Context, patientID st
 	return plans, nil
 }
 
-func (r *memoryTreatmentPlanRepo) Update(_ context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+func (r *memoryTreatmentPlanRepo) Update(ctx context.Context, plan domain.TreatmentPlan) (domain.TreatmentPlan, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, ok := r.items[plan.ID]; !ok {
+	current, ok := r.items[plan.ID]
+	if !ok || !r.VisibleTo(ctx, current.OrgID) {
 		return domain.TreatmentPlan{}, fmt.Errorf("treatment plan not found")
 	}
+	if err := checkVersion(current.Version, plan.Version); err != nil {
+		return domain.TreatmentPlan{}, err
+	}
+	plan.OrgID = current.OrgID
 
 	plan.UpdatedAt = time.Now()
+	plan.Version++
 	r.items[plan.ID] = plan
 
 	return plan, nil
 }
 
-func (r *memoryTreatmentPlanRepo) Delete(_ context.Context, id string) error {
+func (r *memoryTreatmentPlanRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	plan, ok := r.items[id]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, plan.OrgID) {
 		return fmt.Errorf("treatment plan not found")
 	}
 
@@ -1038,12 +1552,15 @@ func (r *memoryTreatmentPlanRepo) Delete(_ context.Context, id string) error {
 	return nil
 }
 
-func (r *memoryTreatmentPlanRepo) UpdateTreatmentStatus(_ context.Context, planID, treatmentIndex string, status domain.PlannedTreatmentStatus, completedTreatmentID *string) error {
+func (r *memoryTreatmentPlanRepo) UpdateTreatmentStatus(ctx context.Context, planID, treatmentIndex string, status domain.PlannedTreatmentStatus, completedTreatmentID *string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	plan, ok := r.items[planID]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, plan.OrgID) {
 		return fmt.Errorf("treatment plan not found")
 	}
 
@@ -1059,6 +1576,7 @@ func (r *memoryTreatmentPlanRepo) UpdateTreatmentStatus(_ context.Context, planI
 	}
 
 	plan.UpdatedAt = time.Now()
+	plan.Version++
 	r.items[planID] = plan
 
 	return nil
@@ -1066,85 +1584,379 @@ func (r *memoryTreatmentPlanRepo) UpdateTreatmentStatus(_ context.Context, planI
 
 // In-memory PaymentRepository
 type memoryPaymentRepo struct {
+	Tenancy
 	mu    sync.RWMutex
 	items []domain.PaymentRecord
 }
 
-func (r *memoryPaymentRepo) Create(_ context.Context, p domain.PaymentRecord) (domain.PaymentRecord, error) {
+func (r *memoryPaymentRepo) Create(ctx context.Context, p domain.PaymentRecord) (domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.PaymentRecord{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	p.OrgID = orgIDOrDefault(ctx)
 	r.items = append(r.items, p)
 	return p, nil
 }
 
-func (r *memoryPaymentRepo) ListByOrg(_ context.Context, limit int) ([]domain.PaymentRecord, error) {
+// ListByOrg devuelve los pagos de la org, los más recientes primero.
+func (r *memoryPaymentRepo) ListByOrg(ctx context.Context, limit int) ([]domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	result := make([]domain.PaymentRecord, len(r.items))
-	copy(result, r.items)
+	org := orgIDOrDefault(ctx)
+	var result []domain.PaymentRecord
+	for _, p := range r.items {
+		if p.OrgID == org {
+			result = append(result, p)
+		}
+	}
+	sortPaymentsNewestFirst(result)
 	if limit > 0 && len(result) > limit {
 		result = result[:limit]
 	}
 	return result, nil
 }
 
-func (r *memoryPaymentRepo) ListByPatient(_ context.Context, patientID string) ([]domain.PaymentRecord, error) {
+func (r *memoryPaymentRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.PaymentRecord, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	org := orgIDOrDefault(ctx)
 	var result []domain.PaymentRecord
 	for _, p := range r.items {
-		if p.PatientID == patientID {
+		if p.OrgID == org && p.PatientID == patientID {
 			result = append(result, p)
 		}
 	}
+	sortPaymentsNewestFirst(result)
 	return result, nil
 }
 
 // In-memory BudgetRepository
 type memoryBudgetRepo struct {
+	Tenancy
 	mu    sync.RWMutex
 	items map[string]domain.Budget
 }
 
-func (r *memoryBudgetRepo) Create(_ context.Context, b domain.Budget) (domain.Budget, error) {
+func (r *memoryBudgetRepo) Create(ctx context.Context, b domain.Budget) (domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	b.OrgID = orgIDOrDefault(ctx)
+	b.Version = initialVersion(b.Version)
 	r.items[b.ID] = b
 	return b, nil
 }
 
-func (r *memoryBudgetRepo) GetByID(_ context.Context, id string) (domain.Budget, error) {
+func (r *memoryBudgetRepo) GetByID(ctx context.Context, id string) (domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	b, ok := r.items[id]
-	if !ok {
+	if !ok || !r.VisibleTo(ctx, b.OrgID) {
 		return domain.Budget{}, fmt.Errorf("budget not found")
 	}
 	return b, nil
 }
 
-func (r *memoryBudgetRepo) ListByPatient(_ context.Context, patientID string) ([]domain.Budget, error) {
+func (r *memoryBudgetRepo) ListByPatient(ctx context.Context, patientID string) ([]domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	var result []domain.Budget
 	for _, b := range r.items {
-		if b.PatientID == patientID {
+		if b.PatientID == patientID && r.VisibleTo(ctx, b.OrgID) {
 			result = append(result, b)
 		}
 	}
+	sortBudgetsNewestFirst(result)
 	return result, nil
 }
 
-func (r *memoryBudgetRepo) Update(_ context.Context, b domain.Budget) (domain.Budget, error) {
+func (r *memoryBudgetRepo) Update(ctx context.Context, b domain.Budget) (domain.Budget, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Budget{}, err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	current, ok := r.items[b.ID]
+	if !ok || !r.VisibleTo(ctx, current.OrgID) {
+		return domain.Budget{}, fmt.Errorf("budget not found")
+	}
+	b.OrgID = current.OrgID
+	if err := checkVersion(current.Version, b.Version); err != nil {
+		return domain.Budget{}, err
+	}
+	b.Version++
 	r.items[b.ID] = b
 	return b, nil
 }
 
-func (r *memoryBudgetRepo) Delete(_ context.Context, id string) error {
+func (r *memoryBudgetRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	delete(r.items, id)
+	if b, ok := r.items[id]; ok && r.VisibleTo(ctx, b.OrgID) {
+		delete(r.items, id)
+	}
+	return nil
+}
+
+// In-memory WaitlistRepository
+type memoryWaitlistRepo struct {
+	Tenancy
+	mu    sync.RWMutex
+	items map[string]domain.WaitlistEntry
+}
+
+func (r *memoryWaitlistRepo) Create(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, e.OrgID); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e.OrgID == "" {
+		e.OrgID = orgIDOrDefault(ctx)
+	}
+	r.items[e.ID] = e
+	return e, nil
+}
+
+func (r *memoryWaitlistRepo) GetByID(ctx context.Context, id string) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.items[id]
+	if !ok || !r.VisibleTo(ctx, e.OrgID) {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist entry not found")
+	}
+	return e, nil
+}
+
+func (r *memoryWaitlistRepo) GetByOfferToken(_ context.Context, token string) (domain.WaitlistEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.items {
+		if token != "" && e.Offer != nil && e.Offer.Token == token {
+			return e, nil
+		}
+	}
+	return domain.WaitlistEntry{}, fmt.Errorf("waitlist offer not found")
+}
+
+func (r *memoryWaitlistRepo) ListByDoctor(ctx context.Context, doctorID string) ([]domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []domain.WaitlistEntry
+	for _, e := range r.items {
+		if e.DoctorID == doctorID && r.VisibleTo(ctx, e.OrgID) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryWaitlistRepo) Update(ctx context.Context, e domain.WaitlistEntry) (domain.WaitlistEntry, error) {
+	if err := r.RequireOrg(ctx, e.OrgID); err != nil {
+		return domain.WaitlistEntry{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.items[e.ID]
+	if !ok || !r.VisibleTo(ctx, current.OrgID) || (e.OrgID != "" && e.OrgID != current.OrgID) {
+		return domain.WaitlistEntry{}, fmt.Errorf("waitlist entry not found")
+	}
+	e.OrgID = current.OrgID
+	r.items[e.ID] = e
+	return e, nil
+}
+
+func (r *memoryWaitlistRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.items[id]; ok && r.VisibleTo(ctx, e.OrgID) {
+		delete(r.items, id)
+	}
+	return nil
+}
+
+// In-memory ResourceRepository
+type memoryResourceRepo struct {
+	Tenancy
+	mu    sync.RWMutex
+	items map[orgKey]domain.Resource
+}
+
+func (r *memoryResourceRepo) Create(ctx context.Context, res domain.Resource) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, res.OrgID); err != nil {
+		return domain.Resource{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if res.OrgID == "" {
+		res.OrgID = orgIDOrDefault(ctx)
+	}
+	r.items[orgKey{org: res.OrgID, id: res.ID}] = res
+	return res, nil
+}
+
+func (r *memoryResourceRepo) GetByID(ctx context.Context, id string) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.Resource{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res, ok := r.items[keyFor(ctx, id)]
+	if !ok {
+		return domain.Resource{}, fmt.Errorf("resource not found")
+	}
+	return res, nil
+}
+
+func (r *memoryResourceRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.Resource, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []domain.Resource
+	for k, res := range r.items {
+		if k.org == orgID {
+			result = append(result, res)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryResourceRepo) Update(ctx context.Context, res domain.Resource) (domain.Resource, error) {
+	if err := r.RequireOrg(ctx, res.OrgID); err != nil {
+		return domain.Resource{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if res.OrgID == "" {
+		res.OrgID = orgIDOrDefault(ctx)
+	}
+	key := orgKey{org: res.OrgID, id: res.ID}
+	if _, ok := r.items[key]; !ok {
+		return domain.Resource{}, fmt.Errorf("resource not found")
+	}
+	r.items[key] = res
+	return res, nil
+}
+
+func (r *memoryResourceRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := keyFor(ctx, id)
+	if _, ok := r.items[key]; !ok {
+		return fmt.Errorf("resource not found")
+	}
+	delete(r.items, key)
+	return nil
+}
+
+// In-memory AppointmentTypeRepository
+type memoryAppointmentTypeRepo struct {
+	Tenancy
+	mu    sync.RWMutex
+	items map[orgKey]domain.AppointmentType
+}
+
+func (r *memoryAppointmentTypeRepo) Create(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.OrgID == "" {
+		t.OrgID = orgIDOrDefault(ctx)
+	}
+	r.items[orgKey{org: t.OrgID, id: t.ID}] = t
+	return t, nil
+}
+
+func (r *memoryAppointmentTypeRepo) GetByID(ctx context.Context, id string) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.items[keyFor(ctx, id)]
+	if !ok {
+		return domain.AppointmentType{}, fmt.Errorf("appointment type not found")
+	}
+	return t, nil
+}
+
+func (r *memoryAppointmentTypeRepo) ListByOrg(ctx context.Context, orgID string) ([]domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []domain.AppointmentType
+	for k, t := range r.items {
+		if k.org == orgID {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryAppointmentTypeRepo) Update(ctx context.Context, t domain.AppointmentType) (domain.AppointmentType, error) {
+	if err := r.RequireOrg(ctx, t.OrgID); err != nil {
+		return domain.AppointmentType{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.OrgID == "" {
+		t.OrgID = orgIDOrDefault(ctx)
+	}
+	key := orgKey{org: t.OrgID, id: t.ID}
+	if _, ok := r.items[key]; !ok {
+		return domain.AppointmentType{}, fmt.Errorf("appointment type not found")
+	}
+	r.items[key] = t
+	return t, nil
+}
+
+func (r *memoryAppointmentTypeRepo) Delete(ctx context.Context, id string) error {
+	if err := r.RequireOrg(ctx, ""); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := keyFor(ctx, id)
+	if _, ok := r.items[key]; !ok {
+		return fmt.Errorf("appointment type not found")
+	}
+	delete(r.items, key)
 	return nil
 }
@@ -0,0 +1,224 @@
+package storetest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/store"
+)
+
+func testUsers(t *testing.T, r Repositories) {
+	repo := r.Users
+	skipIfNil(t, repo)
+	ctx := context.Background()
+	orgA, orgB := newID("org"), newID("org")
+
+	_, err := repo.GetUserByID(ctx, newID("user"))
+	requireNotFound(t, err, "GetUserByID(missing)")
+	_, err = repo.GetOrganization(ctx, orgA)
+	requireNotFound(t, err, "GetOrganization(missing)")
+
+	org, err := repo.CreateOrganization(ctx, store.Organization{ID: orgA, Name: "Clínica", CreatedAt: baseTime})
+	must(t, err, "CreateOrganization")
+	if org.Status != "active" || org.Limits.MaxDoctors == 0 {
+		t.Fatalf("CreateOrganization defaults = %+v", org)
+	}
+	if _, err := repo.CreateOrganization(ctx, store.Organization{ID: orgA, Name: "Duplicada"}); err == nil {
+		t.Fatal("CreateOrganization(duplicate): expected an error")
+	}
+	org.Timezone = "America/Caracas"
+	org.RequireMFA = true
+	org.DoctorsOwnPatientsOnly = true
+	org.Roles = []store.OrgRole{{Name: "receptionist", Label: "Recepción", Permissions: []string{"patients.view", "appointments.write"}}}
+	_, err = repo.UpdateOrganization(ctx, org)
+	must(t, err, "UpdateOrganization")
+	if got, _ := repo.GetOrganization(ctx, orgA); got.Timezone != "America/Caracas" || !got.RequireMFA || !got.DoctorsOwnPatientsOnly ||
+		len(got.Roles) != 1 || got.Roles[0].Label != "Recepción" || len(got.Roles[0].Permissions) != 2 {
+		t.Fatalf("GetOrganization after Update = %+v", got)
+	}
+
+	// El email se normaliza y es único en toda la plataforma.
+	local := newID("doc")
+	email := local + "@example.com"
+	doctor, err := repo.CreateUser(ctx, store.AuthUser{ID: newID("user"), OrgID: orgA, Name: "Dra. Pérez",
+		Email: "  " + strings.ToUpper(local) + "@Example.com ", Role: "doctor", CreatedAt: baseTime})
+	must(t, err, "CreateUser")
+	if _, err := repo.CreateUser(ctx, store.AuthUser{ID: newID("user"), OrgID: orgB, Email: email}); err == nil {
+		t.Fatal("CreateUser(duplicate email): expected an error")
+	}
+	other, err := repo.CreateUser(ctx, store.AuthUser{ID: newID("user"), OrgID: orgB, Email: newID("admin") + "@example.com", CreatedAt: baseTime})
+	must(t, err, "CreateUser(org B)")
+
+	got, err := repo.GetUserByEmail(ctx, email)
+	must(t, err, "GetUserByEmail")
+	if got.ID != doctor.ID || got.Email != email || got.Role != "doctor" || got.Status != "active" {
+		t.Fatalf("GetUserByEmail = %+v", got)
+	}
+	if got, err := repo.GetUserByID(ctx, other.ID); err != nil || got.Role != "admin" {
+		t.Fatalf("GetUserByID = %+v, %v (want default role admin)", got, err)
+	}
+
+	byID := func(u store.AuthUser) string { return u.ID }
+	users, err := repo.ListUsersByOrg(ctx, orgA)
+	must(t, err, "ListUsersByOrg")
+	requireIDs(t, "ListUsersByOrg(org A)", ids(users, byID), []string{doctor.ID})
+	users, err = repo.ListUsersByOrg(ctx, orgB)
+	must(t, err, "ListUsersByOrg")
+	requireIDs(t, "ListUsersByOrg(org B)", ids(users, byID), []string{other.ID})
+
+	must(t, repo.UpdateUserPassword(ctx, doctor.ID, "hash-2"), "UpdateUserPassword")
+	if got, _ := repo.GetUserByID(ctx, doctor.ID); got.PasswordHash != "hash-2" {
+		t.Fatalf("PasswordHash = %q after UpdateUserPassword", got.PasswordHash)
+	}
+	feed := newID("feed")
+	must(t, repo.SetCalendarToken(ctx, doctor.ID, feed), "SetCalendarToken")
+	if got, err := repo.GetUserByCalendarToken(ctx, feed); err != nil || got.ID != doctor.ID {
+		t.Fatalf("GetUserByCalendarToken = %s, %v", got.ID, err)
+	}
+	_, err = repo.GetUserByCalendarToken(ctx, "")
+	requireNotFound(t, err, "GetUserByCalendarToken(empty)")
+
+	// El MFA solo cambia con SetUserMFA: UpdateUser con un usuario leído
+	// antes no lo pisa.
+	stale, _ := repo.GetUserByID(ctx, doctor.ID)
+	mfa := store.UserMFA{Secret: "JBSWY3DPEHPK3PXP", EnabledAt: baseTime, RecoveryCodes: []string{"h1", "h2"}, LastStep: 42}
+	must(t, repo.SetUserMFA(ctx, doctor.ID, mfa), "SetUserMFA")
+	stale.Phone = "+58 212 555 0000"
+	_, err = repo.UpdateUser(ctx, stale)
+	must(t, err, "UpdateUser")
+	for _, get := range []func() (store.AuthUser, error){
+		func() (store.AuthUser, error) { return repo.GetUserByID(ctx, doctor.ID) },
+		func() (store.AuthUser, error) { return repo.GetUserByEmail(ctx, email) },
+	} {
+		got, err := get()
+		if err != nil || got.MFA.Secret != mfa.Secret || !got.MFA.EnabledAt.Equal(baseTime) || len(got.MFA.RecoveryCodes) != 2 || got.MFA.LastStep != 42 || got.Phone == "" {
+			t.Fatalf("user after SetUserMFA + UpdateUser = %+v, %v", got, err)
+		}
+	}
+	must(t, repo.SetUserMFA(ctx, doctor.ID, store.UserMFA{}), "SetUserMFA(off)")
+	if got, _ := repo.GetUserByID(ctx, doctor.ID); got.MFA.Secret != "" || len(got.MFA.RecoveryCodes) != 0 {
+		t.Fatalf("MFA after clearing = %+v", got.MFA)
+	}
+	requireNotFound(t, repo.SetUserMFA(ctx, newID("user"), mfa), "SetUserMFA(missing user)")
+
+	session, err := repo.CreateSession(ctx, store.AuthSession{Token: newID("session"), UserID: doctor.ID, OrgID: orgA,
+		Role: "doctor", ExpiresAt: baseTime.Add(12 * time.Hour)})
+	must(t, err, "CreateSession")
+	if got, err := repo.GetSession(ctx, session.Token); err != nil || got.UserID != doctor.ID || got.OrgID != orgA {
+		t.Fatalf("GetSession = %+v, %v", got, err)
+	}
+	must(t, repo.DeleteSession(ctx, session.Token), "DeleteSession")
+	_, err = repo.GetSession(ctx, session.Token)
+	requireNotFound(t, err, "GetSession(deleted)")
+
+	// Una sesión con refresh token conserva su ID al renovarse: RotateSession
+	// reemplaza el registro anterior por uno con otro token, solo si el
+	// refresh token guardado es el esperado.
+	sessionID := newID("ses")
+	first, err := repo.CreateSession(ctx, store.AuthSession{Token: newID("access"), UserID: doctor.ID, OrgID: orgA, Role: "doctor",
+		ExpiresAt: baseTime.Add(15 * time.Minute), ID: sessionID, RefreshToken: newID("refresh"),
+		RefreshExpiresAt: baseTime.Add(30 * 24 * time.Hour), CreatedAt: baseTime, LastRefreshAt: baseTime, UserAgent: "Firefox", IP: "10.0.0.1"})
+	must(t, err, "CreateSession(with refresh)")
+	current, err := repo.GetSessionByID(ctx, sessionID)
+	must(t, err, "GetSessionByID")
+	if current.Token != first.Token || current.RefreshToken != first.RefreshToken || !current.RefreshExpiresAt.Equal(first.RefreshExpiresAt) || current.UserAgent != "Firefox" {
+		t.Fatalf("GetSessionByID = %+v", current)
+	}
+	second := first
+	second.Token, second.PreviousRefreshToken, second.RefreshToken = newID("access"), first.RefreshToken, newID("refresh")
+	must(t, repo.RotateSession(ctx, first.Token, first.RefreshToken, second), "RotateSession")
+	if got, err := repo.GetSessionByID(ctx, sessionID); err != nil || got.Token != second.Token || got.PreviousRefreshToken != first.RefreshToken {
+		t.Fatalf("GetSessionByID after rotation = %+v, %v", got, err)
+	}
+	_, err = repo.GetSession(ctx, first.Token)
+	requireNotFound(t, err, "GetSession(rotated)")
+	// Un segundo refresh con el mismo token pierde: no crea otro registro.
+	lost := first
+	lost.Token, lost.RefreshToken = newID("access"), newID("refresh")
+	requireErrorIs(t, repo.RotateSession(ctx, first.Token, first.RefreshToken, lost), store.ErrConflict, "RotateSession(stale)")
+	requireErrorIs(t, repo.RotateSession(ctx, second.Token, first.RefreshToken, lost), store.ErrConflict, "RotateSession(wrong refresh)")
+	_, err = repo.GetSession(ctx, lost.Token)
+	requireNotFound(t, err, "GetSession(lost rotation)")
+	older, err := repo.CreateSession(ctx, store.AuthSession{Token: newID("access"), UserID: doctor.ID, OrgID: orgA,
+		ExpiresAt: baseTime, ID: newID("ses"), CreatedAt: baseTime.Add(-time.Hour)})
+	must(t, err, "CreateSession(older)")
+	_, err = repo.CreateSession(ctx, store.AuthSession{Token: newID("access"), UserID: other.ID, OrgID: orgB, ExpiresAt: baseTime})
+	must(t, err, "CreateSession(other user)")
+	sessions, err := repo.ListSessionsByUser(ctx, doctor.ID)
+	must(t, err, "ListSessionsByUser")
+	requireIDs(t, "ListSessionsByUser", ids(sessions, func(s store.AuthSession) string { return s.Token }), []string{second.Token, older.Token})
+	must(t, repo.DeleteSession(ctx, second.Token), "DeleteSession")
+	_, err = repo.GetSessionByID(ctx, sessionID)
+	requireNotFound(t, err, "GetSessionByID(deleted)")
+	_, err = repo.GetSessionByID(ctx, "")
+	requireNotFound(t, err, "GetSessionByID(empty)")
+
+	must(t, repo.DeleteUser(ctx, orgA, doctor.ID), "DeleteUser")
+	_, err = repo.GetUserByID(ctx, doctor.ID)
+	requireNotFound(t, err, "GetUserByID(deleted)")
+	users, err = repo.ListUsersByOrg(ctx, orgA)
+	must(t, err, "ListUsersByOrg")
+	if len(users) != 0 {
+		t.Fatalf("ListUsersByOrg after DeleteUser = %d users", len(users))
+	}
+
+	must(t, repo.DeleteOrganization(ctx, orgA), "DeleteOrganization")
+	_, err = repo.GetOrganization(ctx, orgA)
+	requireNotFound(t, err, "GetOrganization(deleted)")
+}
+
+func testAuthThrottles(t *testing.T, r Repositories) {
+	repo := r.Users
+	skipIfNil(t, repo)
+	ctx := context.Background()
+	key := "login:email:" + newID("user") + "@example.com"
+	window := 15 * time.Minute
+
+	_, err := repo.GetAuthThrottle(ctx, key)
+	requireNotFound(t, err, "GetAuthThrottle(missing)")
+	for i := 1; i <= 3; i++ {
+		got, err := repo.RecordAuthFailure(ctx, key, baseTime.Add(time.Duration(i)*time.Minute), window)
+		must(t, err, "RecordAuthFailure")
+		if got.Key != key || got.Failures != i || !got.WindowStart.Equal(baseTime.Add(time.Minute)) {
+			t.Fatalf("RecordAuthFailure #%d = %+v", i, got)
+		}
+	}
+	// El bloqueo conserva la cuenta y sobrevive a una ventana nueva.
+	until := baseTime.Add(time.Hour)
+	must(t, repo.LockAuthThrottle(ctx, key, until, "hash"), "LockAuthThrottle")
+	if got, err := repo.GetAuthThrottle(ctx, key); err != nil || got.Failures != 3 || !got.LockedUntil.Equal(until) || got.UnlockTokenHash != "hash" {
+		t.Fatalf("GetAuthThrottle after lock = %+v, %v", got, err)
+	}
+	got, err := repo.RecordAuthFailure(ctx, key, baseTime.Add(30*time.Minute), window)
+	must(t, err, "RecordAuthFailure(new window)")
+	if got.Failures != 1 || !got.WindowStart.Equal(baseTime.Add(30*time.Minute)) {
+		t.Fatalf("RecordAuthFailure after window = %+v", got)
+	}
+	if got, _ := repo.GetAuthThrottle(ctx, key); !got.LockedUntil.Equal(until) || got.Failures != 1 {
+		t.Fatalf("lock lost after RecordAuthFailure: %+v", got)
+	}
+
+	// Los fallos concurrentes se cuentan todos.
+	concurrent := "login:ip:" + newID("ip")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.RecordAuthFailure(ctx, concurrent, baseTime, window); err != nil {
+				t.Errorf("RecordAuthFailure(concurrent): %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, _ := repo.GetAuthThrottle(ctx, concurrent); got.Failures != 8 {
+		t.Fatalf("concurrent failures = %d, want 8", got.Failures)
+	}
+
+	must(t, repo.DeleteAuthThrottle(ctx, key), "DeleteAuthThrottle")
+	_, err = repo.GetAuthThrottle(ctx, key)
+	requireNotFound(t, err, "GetAuthThrottle(deleted)")
+}
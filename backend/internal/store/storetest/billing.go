@@ -0,0 +1,90 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"clinical-backend/internal/domain"
+)
+
+func paymentID(p domain.PaymentRecord) string { return p.ID }
+func budgetID(b domain.Budget) string         { return b.ID }
+
+func testPayments(t *testing.T, r Repositories) {
+	repo := r.Payments
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+	patient := newID("pat")
+
+	var created []domain.PaymentRecord
+	for i := 0; i < 3; i++ {
+		p, err := repo.Create(ctxA, domain.PaymentRecord{ID: newID("pay"), PatientID: patient, Amount: float64(10 * (i + 1)),
+			PaymentMethod: "cash", CreatedAt: baseTime.Add(time.Duration(i) * time.Hour)})
+		must(t, err, "Create")
+		if p.OrgID != orgA {
+			t.Fatalf("Create: OrgID = %q, want %q", p.OrgID, orgA)
+		}
+		created = append(created, p)
+	}
+	other, err := repo.Create(ctxB, domain.PaymentRecord{ID: newID("pay"), PatientID: patient, Amount: 99, CreatedAt: baseTime})
+	must(t, err, "Create(org B)")
+
+	newestFirst := []string{created[2].ID, created[1].ID, created[0].ID}
+	list, err := repo.ListByOrg(ctxA, 0)
+	must(t, err, "ListByOrg")
+	requireIDs(t, "ListByOrg", ids(list, paymentID), newestFirst)
+	list, err = repo.ListByOrg(ctxA, 2)
+	must(t, err, "ListByOrg(limit)")
+	requireIDs(t, "ListByOrg(limit 2)", ids(list, paymentID), newestFirst[:2])
+
+	list, err = repo.ListByPatient(ctxA, patient)
+	must(t, err, "ListByPatient")
+	requireIDs(t, "ListByPatient", ids(list, paymentID), newestFirst)
+	list, err = repo.ListByPatient(ctxB, patient)
+	must(t, err, "ListByPatient(org B)")
+	requireIDs(t, "ListByPatient(org B)", ids(list, paymentID), []string{other.ID})
+}
+
+func testBudgets(t *testing.T, r Repositories) {
+	repo := r.Budgets
+	skipIfNil(t, repo)
+	ctx, _ := newOrg()
+	patient := newID("pat")
+
+	_, err := repo.GetByID(ctx, newID("budget"))
+	requireNotFound(t, err, "GetByID(missing)")
+
+	older, err := repo.Create(ctx, domain.Budget{ID: newID("budget"), PatientID: patient, Title: "Limpieza", TotalAmount: 40,
+		Status: "draft", CreatedAt: baseTime})
+	must(t, err, "Create")
+	newer, err := repo.Create(ctx, domain.Budget{ID: newID("budget"), PatientID: patient, Title: "Ortodoncia", TotalAmount: 900,
+		Status: "draft", CreatedAt: baseTime.Add(24 * time.Hour)})
+	must(t, err, "Create")
+	if older.Version != 1 {
+		t.Fatalf("Create: version = %d, want 1", older.Version)
+	}
+
+	got, err := repo.GetByID(ctx, older.ID)
+	must(t, err, "GetByID")
+	if got.Title != "Limpieza" || got.TotalAmount != 40 {
+		t.Fatalf("GetByID = %+v", got)
+	}
+
+	list, err := repo.ListByPatient(ctx, patient)
+	must(t, err, "ListByPatient")
+	requireIDs(t, "ListByPatient", ids(list, budgetID), []string{newer.ID, older.ID})
+
+	got.Status = "sent"
+	updated, err := repo.Update(ctx, got)
+	must(t, err, "Update")
+	if updated.Version != 2 {
+		t.Fatalf("Update: version = %d, want 2", updated.Version)
+	}
+	_, err = repo.Update(ctx, got)
+	requireConflict(t, err, "Update(stale)")
+
+	must(t, repo.Delete(ctx, older.ID), "Delete")
+	_, err = repo.GetByID(ctx, older.ID)
+	requireNotFound(t, err, "GetByID(deleted)")
+}
@@ -0,0 +1,345 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+func patientID(p domain.Patient) string         { return p.ID }
+func appointmentID(a domain.Appointment) string { return a.ID }
+
+func testPatients(t *testing.T, r Repositories) {
+	repo := r.Patients
+	skipIfNil(t, repo)
+	ctxA, _ := newOrg()
+	ctxB, _ := newOrg()
+	doctor := newID("doc")
+
+	_, err := repo.GetByID(ctxA, newID("pat"))
+	requireNotFound(t, err, "GetByID(missing)")
+
+	created, err := repo.Create(ctxA, domain.Patient{ID: newID("pat"), DoctorID: doctor, FirstName: "Ana", LastName: "Díaz", Email: "ana@example.com", CreatedAt: baseTime})
+	must(t, err, "Create")
+	if created.Version != 1 {
+		t.Fatalf("Create: version = %d, want 1", created.Version)
+	}
+	other, err := repo.Create(ctxB, domain.Patient{ID: newID("pat"), DoctorID: doctor, FirstName: "Luis", CreatedAt: baseTime})
+	must(t, err, "Create(org B)")
+
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID")
+	if got.FirstName != "Ana" || got.DoctorID != doctor || got.Version != 1 {
+		t.Fatalf("GetByID = %+v", got)
+	}
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+
+	list, err := repo.ListByDoctor(ctxA, doctor)
+	must(t, err, "ListByDoctor")
+	requireIDs(t, "ListByDoctor(org A)", ids(list, patientID), []string{created.ID})
+	list, err = repo.ListByDoctor(ctxB, doctor)
+	must(t, err, "ListByDoctor")
+	requireIDs(t, "ListByDoctor(org B)", ids(list, patientID), []string{other.ID})
+
+	// ListAll cruza orgs (estadísticas de plataforma).
+	list, err = repo.ListAll(store.SystemContext(ctxA))
+	must(t, err, "ListAll")
+	if all := ids(list, patientID); !contains(all, created.ID) || !contains(all, other.ID) {
+		t.Fatalf("ListAll should include every org, got %d patients", len(all))
+	}
+
+	found, err := repo.SearchByQuery(ctxA, doctor, "díaz")
+	must(t, err, "SearchByQuery")
+	requireIDs(t, "SearchByQuery", ids(found, patientID), []string{created.ID})
+	found, err = repo.SearchByQuery(ctxB, doctor, "díaz")
+	must(t, err, "SearchByQuery(org B)")
+	requireIDs(t, "SearchByQuery(org B)", ids(found, patientID), nil)
+
+	got.FirstName = "Ana María"
+	updated, err := repo.Update(ctxA, got)
+	must(t, err, "Update")
+	if updated.Version != 2 {
+		t.Fatalf("Update: version = %d, want 2", updated.Version)
+	}
+	if reread, _ := repo.GetByID(ctxA, created.ID); reread.FirstName != "Ana María" || reread.Version != 2 {
+		t.Fatalf("GetByID after Update = %+v", reread)
+	}
+	_, err = repo.Update(ctxA, got)
+	requireConflict(t, err, "Update(stale)")
+	_, err = repo.Update(ctxA, domain.Patient{ID: newID("pat"), Version: 1})
+	if err == nil {
+		t.Fatal("Update(missing): expected an error")
+	}
+
+	must(t, repo.Delete(ctxA, created.ID), "Delete")
+	_, err = repo.GetByID(ctxA, created.ID)
+	requireNotFound(t, err, "GetByID(deleted)")
+	if _, err := repo.GetByID(ctxB, other.ID); err != nil {
+		t.Fatalf("Delete removed the other org's patient: %v", err)
+	}
+}
+
+func testAppointments(t *testing.T, r Repositories) {
+	repo := r.Appointments
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, orgB := newOrg()
+	doctor, patient := newID("doc"), newID("pat")
+	day := time.Date(baseTime.Year(), baseTime.Month(), baseTime.Day(), 0, 0, 0, 0, time.UTC)
+
+	_, err := repo.GetByID(ctxA, newID("apt"))
+	requireNotFound(t, err, "GetByID(missing)")
+
+	create := func(ctx context.Context, start time.Time, status string) domain.Appointment {
+		t.Helper()
+		a, err := repo.Create(ctx, domain.Appointment{
+			ID: newID("apt"), DoctorID: doctor, PatientID: patient, Status: status,
+			StartAt: start, EndAt: start.Add(30 * time.Minute), PaymentAmount: 40,
+			ConfirmToken: newID("confirm"),
+		})
+		must(t, err, "Create")
+		return a
+	}
+	// Se crean desordenadas: los listados van por StartAt.
+	late := create(ctxA, baseTime.Add(6*time.Hour), "scheduled")
+	early := create(ctxA, baseTime, "completed")
+	mid := create(ctxA, baseTime.Add(3*time.Hour), "confirmed")
+	nextDay := create(ctxA, baseTime.Add(24*time.Hour), "scheduled")
+	otherOrg := create(ctxB, baseTime.Add(time.Hour), "completed")
+	if early.Version != 1 {
+		t.Fatalf("Create: version = %d, want 1", early.Version)
+	}
+
+	got, err := repo.GetByID(ctxA, early.ID)
+	must(t, err, "GetByID")
+	if got.DoctorID != doctor || !got.StartAt.Equal(early.StartAt) || got.Status != "completed" {
+		t.Fatalf("GetByID = %+v", got)
+	}
+	_, err = repo.GetByID(ctxB, early.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+
+	want := []string{early.ID, mid.ID, late.ID}
+	list, err := repo.ListByDoctorAndDay(ctxA, doctor, day)
+	must(t, err, "ListByDoctorAndDay")
+	requireIDs(t, "ListByDoctorAndDay", ids(list, appointmentID), want)
+	list, err = repo.ListByDoctorAndDay(ctxA, "", day)
+	must(t, err, "ListByDoctorAndDay(all doctors)")
+	requireIDs(t, "ListByDoctorAndDay(all doctors)", ids(list, appointmentID), want)
+	list, err = repo.ListByDoctorAndDay(ctxB, doctor, day)
+	must(t, err, "ListByDoctorAndDay(org B)")
+	requireIDs(t, "ListByDoctorAndDay(org B)", ids(list, appointmentID), []string{otherOrg.ID})
+
+	list, err = repo.ListByPatient(ctxA, patient)
+	must(t, err, "ListByPatient")
+	requireIDs(t, "ListByPatient", ids(list, appointmentID), append(want, nextDay.ID))
+
+	// Cuatro citas con el mismo paciente: un solo ID.
+	patientIDs, err := repo.ListPatientIDsByDoctor(ctxA, doctor)
+	must(t, err, "ListPatientIDsByDoctor")
+	requireIDs(t, "ListPatientIDsByDoctor", patientIDs, []string{patient})
+	patientIDs, err = repo.ListPatientIDsByDoctor(ctxA, newID("doc"))
+	must(t, err, "ListPatientIDsByDoctor(other doctor)")
+	requireIDs(t, "ListPatientIDsByDoctor(other doctor)", patientIDs, nil)
+
+	// El enlace público del email llega sin org: la cita trae su OrgID.
+	byToken, err := repo.GetByConfirmToken(context.Background(), mid.ConfirmToken)
+	must(t, err, "GetByConfirmToken")
+	if byToken.ID != mid.ID || byToken.OrgID != orgA {
+		t.Fatalf("GetByConfirmToken = %s in org %q, want %s in %q", byToken.ID, byToken.OrgID, mid.ID, orgA)
+	}
+	_, err = repo.GetByConfirmToken(context.Background(), newID("confirm"))
+	requireNotFound(t, err, "GetByConfirmToken(missing)")
+
+	summaries, err := repo.ScanOrgPayments(ctxA, orgA)
+	must(t, err, "ScanOrgPayments")
+	if len(summaries) != 4 {
+		t.Fatalf("ScanOrgPayments = %d rows, want 4", len(summaries))
+	}
+	for _, s := range summaries {
+		if s.OrgID != orgA {
+			t.Fatalf("ScanOrgPayments returned a row of org %q", s.OrgID)
+		}
+	}
+	all, err := repo.ScanAllPayments(store.SystemContext(ctxA))
+	must(t, err, "ScanAllPayments")
+	completed := map[string]int{}
+	for _, s := range all {
+		if s.Status != "completed" {
+			t.Fatalf("ScanAllPayments returned status %q", s.Status)
+		}
+		completed[s.OrgID]++
+	}
+	if completed[orgA] != 1 || completed[orgB] != 1 {
+		t.Fatalf("ScanAllPayments: completed per org = %v", completed)
+	}
+
+	got.Status = "no_show"
+	updated, err := repo.Update(ctxA, got)
+	must(t, err, "Update")
+	if updated.Version != 2 {
+		t.Fatalf("Update: version = %d, want 2", updated.Version)
+	}
+	_, err = repo.Update(ctxA, got)
+	requireConflict(t, err, "Update(stale)")
+	_, err = repo.Update(ctxA, domain.Appointment{ID: newID("apt"), StartAt: baseTime, Version: 1})
+	if err == nil {
+		t.Fatal("Update(missing): expected an error")
+	}
+	// Update con OrgID explícito (confirmación pública) escribe en esa org.
+	byToken.Status = "confirmed"
+	if _, err := repo.Update(context.Background(), byToken); err != nil {
+		t.Fatalf("Update(with OrgID): %v", err)
+	}
+	if reread, _ := repo.GetByID(ctxA, mid.ID); reread.Version != 2 {
+		t.Fatalf("Update(with OrgID) did not reach org A: %+v", reread)
+	}
+
+	must(t, repo.Delete(ctxA, late.ID), "Delete")
+	_, err = repo.GetByID(ctxA, late.ID)
+	requireNotFound(t, err, "GetByID(deleted)")
+}
+
+func testConsents(t *testing.T, r Repositories) {
+	repo := r.Consents
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+	appt := newID("apt")
+
+	_, err := repo.GetByID(ctxA, newID("consent"))
+	requireNotFound(t, err, "GetByID(missing)")
+
+	first, err := repo.Create(ctxA, domain.Consent{ID: newID("consent"), AppointmentID: appt, PatientID: newID("pat"),
+		Title: "Asistencia", Status: "pending", AcceptToken: newID("accept"), CreatedAt: baseTime})
+	must(t, err, "Create")
+	second, err := repo.Create(ctxA, domain.Consent{ID: newID("consent"), AppointmentID: appt, PatientID: first.PatientID,
+		Title: "Tratamiento", Status: "pending", AcceptToken: newID("accept"), CreatedAt: baseTime.Add(time.Minute)})
+	must(t, err, "Create")
+
+	got, err := repo.GetByID(ctxA, first.ID)
+	must(t, err, "GetByID")
+	if got.Title != "Asistencia" || got.AppointmentID != appt {
+		t.Fatalf("GetByID = %+v", got)
+	}
+	_, err = repo.GetByID(ctxB, first.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+
+	list, err := repo.ListByAppointmentID(ctxA, appt)
+	must(t, err, "ListByAppointmentID")
+	if got := ids(list, func(c domain.Consent) string { return c.ID }); len(got) != 2 || !contains(got, first.ID) || !contains(got, second.ID) {
+		t.Fatalf("ListByAppointmentID = %v", got)
+	}
+	list, err = repo.ListByAppointmentID(ctxB, appt)
+	must(t, err, "ListByAppointmentID(org B)")
+	if len(list) != 0 {
+		t.Fatalf("ListByAppointmentID(org B) = %d consents, want 0", len(list))
+	}
+	if _, err := repo.GetByAppointmentID(ctxA, appt); err != nil {
+		t.Fatalf("GetByAppointmentID: %v", err)
+	}
+
+	// Aceptación pública: sin org en el contexto busca en todas y trae OrgID.
+	byToken, err := repo.GetByToken(context.Background(), second.AcceptToken)
+	must(t, err, "GetByToken")
+	if byToken.ID != second.ID || byToken.OrgID != orgA {
+		t.Fatalf("GetByToken = %s in org %q, want %s in %q", byToken.ID, byToken.OrgID, second.ID, orgA)
+	}
+	_, err = repo.GetByToken(ctxB, second.AcceptToken)
+	requireNotFound(t, err, "GetByToken(other org)")
+
+	byToken.Status = "accepted"
+	_, err = repo.Update(context.Background(), byToken)
+	must(t, err, "Update(with OrgID)")
+	if reread, _ := repo.GetByID(ctxA, second.ID); reread.Status != "accepted" {
+		t.Fatalf("GetByID after Update = %+v", reread)
+	}
+}
+
+func testOdontograms(t *testing.T, r Repositories) {
+	repo := r.Odontograms
+	skipIfNil(t, repo)
+	ctx, _ := newOrg()
+	patient := newID("pat")
+
+	_, err := repo.GetByID(ctx, newID("odo"))
+	requireNotFound(t, err, "GetByID(missing)")
+	_, err = repo.GetByPatientID(ctx, patient)
+	requireNotFound(t, err, "GetByPatientID(missing)")
+
+	created, err := repo.Create(ctx, domain.Odontogram{ID: newID("odo"), PatientID: patient, DoctorID: newID("doc")})
+	must(t, err, "Create")
+	if created.Version != 1 {
+		t.Fatalf("Create: version = %d, want 1", created.Version)
+	}
+	got, err := repo.GetByPatientID(ctx, patient)
+	must(t, err, "GetByPatientID")
+	if got.ID != created.ID {
+		t.Fatalf("GetByPatientID = %s, want %s", got.ID, created.ID)
+	}
+
+	must(t, repo.AddTreatment(ctx, created.ID, domain.ToothTreatment{ID: newID("trt"), ToothNumber: 11}), "AddTreatment")
+	history, err := repo.GetTreatmentHistory(ctx, patient, 0)
+	must(t, err, "GetTreatmentHistory")
+	if len(history) != 1 {
+		t.Fatalf("GetTreatmentHistory = %d treatments, want 1", len(history))
+	}
+
+	got, err = repo.GetByID(ctx, created.ID)
+	must(t, err, "GetByID")
+	if got.Version != 2 {
+		t.Fatalf("GetByID after AddTreatment: version = %d, want 2", got.Version)
+	}
+	stale := got
+	stale.Version = 1
+	_, err = repo.Update(ctx, stale)
+	requireConflict(t, err, "Update(stale)")
+	updated, err := repo.Update(ctx, got)
+	must(t, err, "Update")
+	if updated.Version != 3 {
+		t.Fatalf("Update: version = %d, want 3", updated.Version)
+	}
+}
+
+func testTreatmentPlans(t *testing.T, r Repositories) {
+	repo := r.TreatmentPlans
+	skipIfNil(t, repo)
+	ctx, _ := newOrg()
+	patient := newID("pat")
+
+	_, err := repo.GetByID(ctx, newID("plan"))
+	requireNotFound(t, err, "GetByID(missing)")
+	plans, err := repo.GetByPatientID(ctx, patient)
+	must(t, err, "GetByPatientID(empty)")
+	if len(plans) != 0 {
+		t.Fatalf("GetByPatientID(empty) = %d plans", len(plans))
+	}
+
+	created, err := repo.Create(ctx, domain.TreatmentPlan{ID: newID("plan"), PatientID: patient, Title: "Plan",
+		Treatments: []domain.PlannedTreatment{{ToothNumber: 11, Status: domain.PlannedStatusPending}}})
+	must(t, err, "Create")
+	if created.Version != 1 {
+		t.Fatalf("Create: version = %d, want 1", created.Version)
+	}
+	plans, err = repo.GetByPatientID(ctx, patient)
+	must(t, err, "GetByPatientID")
+	requireIDs(t, "GetByPatientID", ids(plans, func(p domain.TreatmentPlan) string { return p.ID }), []string{created.ID})
+
+	must(t, repo.UpdateTreatmentStatus(ctx, created.ID, "0", domain.PlannedStatusCompleted, nil), "UpdateTreatmentStatus")
+	got, err := repo.GetByID(ctx, created.ID)
+	must(t, err, "GetByID")
+	if got.Version != 2 || got.Treatments[0].Status != domain.PlannedStatusCompleted {
+		t.Fatalf("GetByID after UpdateTreatmentStatus = %+v", got)
+	}
+	stale := got
+	stale.Version = 1
+	_, err = repo.Update(ctx, stale)
+	requireConflict(t, err, "Update(stale)")
+
+	must(t, repo.Delete(ctx, created.ID), "Delete")
+	_, err = repo.GetByID(ctx, created.ID)
+	requireNotFound(t, err, "GetByID(deleted)")
+}
@@ -0,0 +1,195 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/domain"
+)
+
+func testWaitlist(t *testing.T, r Repositories) {
+	repo := r.Waitlist
+	skipIfNil(t, repo)
+	ctx, orgID := newOrg()
+	doctor := newID("doc")
+
+	_, err := repo.GetByID(ctx, newID("wait"))
+	requireNotFound(t, err, "GetByID(missing)")
+	_, err = repo.GetByOfferToken(ctx, newID("offer"))
+	requireNotFound(t, err, "GetByOfferToken(missing)")
+
+	entry, err := repo.Create(ctx, domain.WaitlistEntry{ID: newID("wait"), OrgID: orgID, PatientID: newID("pat"), DoctorID: doctor,
+		Urgency: "high", Status: "waiting", CreatedAt: baseTime, UpdatedAt: baseTime})
+	must(t, err, "Create")
+	got, err := repo.GetByID(ctx, entry.ID)
+	must(t, err, "GetByID")
+	if got.Urgency != "high" || got.DoctorID != doctor || got.OrgID != orgID {
+		t.Fatalf("GetByID = %+v", got)
+	}
+
+	// La oferta se acepta desde un enlace público, sin org en el contexto.
+	token := newID("offer")
+	got.Status = "offered"
+	got.Offer = &domain.WaitlistOffer{Token: token, StartAt: baseTime, EndAt: baseTime.Add(30 * time.Minute),
+		FreedAppointmentID: newID("apt"), OfferedAt: baseTime, ExpiresAt: baseTime.Add(time.Hour)}
+	_, err = repo.Update(ctx, got)
+	must(t, err, "Update")
+	offered, err := repo.GetByOfferToken(context.Background(), token)
+	must(t, err, "GetByOfferToken")
+	if offered.ID != entry.ID || offered.Status != "offered" || offered.Offer == nil || offered.Offer.Token != token {
+		t.Fatalf("GetByOfferToken = %+v", offered)
+	}
+
+	list, err := repo.ListByDoctor(ctx, doctor)
+	must(t, err, "ListByDoctor")
+	requireIDs(t, "ListByDoctor", ids(list, func(e domain.WaitlistEntry) string { return e.ID }), []string{entry.ID})
+
+	must(t, repo.Delete(ctx, entry.ID), "Delete")
+	_, err = repo.GetByID(ctx, entry.ID)
+	requireNotFound(t, err, "GetByID(deleted)")
+}
+
+func testResources(t *testing.T, r Repositories) {
+	repo := r.Resources
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, orgB := newOrg()
+
+	_, err := repo.GetByID(ctxA, newID("res"))
+	requireNotFound(t, err, "GetByID(missing)")
+
+	// Sin OrgID se guarda en la org del contexto.
+	chair, err := repo.Create(ctxA, domain.Resource{ID: newID("res"), Name: "Sillón 1", Kind: "chair", Active: true, CreatedAt: baseTime})
+	must(t, err, "Create")
+	if chair.OrgID != orgA {
+		t.Fatalf("Create: OrgID = %q, want %q", chair.OrgID, orgA)
+	}
+	room, err := repo.Create(ctxB, domain.Resource{ID: newID("res"), Name: "Sala", Kind: "room", Active: true, CreatedAt: baseTime})
+	must(t, err, "Create(org B)")
+
+	got, err := repo.GetByID(ctxA, chair.ID)
+	must(t, err, "GetByID")
+	if got.Name != "Sillón 1" || !got.Active {
+		t.Fatalf("GetByID = %+v", got)
+	}
+	_, err = repo.GetByID(ctxB, chair.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+
+	byID := func(res domain.Resource) string { return res.ID }
+	list, err := repo.ListByOrg(ctxA, orgA)
+	must(t, err, "ListByOrg")
+	requireIDs(t, "ListByOrg(org A)", ids(list, byID), []string{chair.ID})
+	list, err = repo.ListByOrg(ctxB, orgB)
+	must(t, err, "ListByOrg")
+	requireIDs(t, "ListByOrg(org B)", ids(list, byID), []string{room.ID})
+
+	got.Active = false
+	_, err = repo.Update(ctxA, got)
+	must(t, err, "Update")
+	if reread, _ := repo.GetByID(ctxA, chair.ID); reread.Active {
+		t.Fatalf("GetByID after Update = %+v", reread)
+	}
+
+	must(t, repo.Delete(ctxA, chair.ID), "Delete")
+	_, err = repo.GetByID(ctxA, chair.ID)
+	requireNotFound(t, err, "GetByID(deleted)")
+	if _, err := repo.GetByID(ctxB, room.ID); err != nil {
+		t.Fatalf("Delete removed the other org's resource: %v", err)
+	}
+}
+
+func testAppointmentTypes(t *testing.T, r Repositories) {
+	repo := r.AppointmentTypes
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, orgB := newOrg()
+
+	_, err := repo.GetByID(ctxA, newID("type"))
+	requireNotFound(t, err, "GetByID(missing)")
+
+	cleaning, err := repo.Create(ctxA, domain.AppointmentType{ID: newID("type"), Name: "Limpieza", DurationMinutes: 30,
+		ConsentTemplateIDs: []string{newID("tpl")}, Active: true, CreatedAt: baseTime})
+	must(t, err, "Create")
+	if cleaning.OrgID != orgA {
+		t.Fatalf("Create: OrgID = %q, want %q", cleaning.OrgID, orgA)
+	}
+	_, err = repo.Create(ctxB, domain.AppointmentType{ID: newID("type"), Name: "Control", DurationMinutes: 20, Active: true, CreatedAt: baseTime})
+	must(t, err, "Create(org B)")
+
+	got, err := repo.GetByID(ctxA, cleaning.ID)
+	must(t, err, "GetByID")
+	if got.Name != "Limpieza" || got.DurationMinutes != 30 || len(got.ConsentTemplateIDs) != 1 {
+		t.Fatalf("GetByID = %+v", got)
+	}
+	_, err = repo.GetByID(ctxB, cleaning.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+
+	list, err := repo.ListByOrg(ctxA, orgA)
+	must(t, err, "ListByOrg")
+	requireIDs(t, "ListByOrg(org A)", ids(list, func(at domain.AppointmentType) string { return at.ID }), []string{cleaning.ID})
+	list, err = repo.ListByOrg(ctxB, orgB)
+	must(t, err, "ListByOrg(org B)")
+	if len(list) != 1 || list[0].ID == cleaning.ID {
+		t.Fatalf("ListByOrg(org B) = %+v", list)
+	}
+
+	got.DurationMinutes = 45
+	_, err = repo.Update(ctxA, got)
+	must(t, err, "Update")
+	if reread, _ := repo.GetByID(ctxA, cleaning.ID); reread.DurationMinutes != 45 {
+		t.Fatalf("GetByID after Update = %+v", reread)
+	}
+
+	must(t, repo.Delete(ctxA, cleaning.ID), "Delete")
+	_, err = repo.GetByID(ctxA, cleaning.ID)
+	requireNotFound(t, err, "GetByID(deleted)")
+}
+
+func testConsentTemplates(t *testing.T, r Repositories) {
+	repo := r.ConsentTemplates
+	skipIfNil(t, repo)
+	ctx, orgA := newOrg()
+	ctxB, orgB := newOrg()
+
+	_, err := repo.GetByID(ctx, newID("tpl"))
+	requireNotFound(t, err, "GetByID(missing)")
+
+	active, err := repo.Create(ctx, domain.ConsentTemplate{ID: newID("tpl"), OrgID: orgA, Title: "Asistencia", Content: "…",
+		IsActive: true, CreatedAt: baseTime, UpdatedAt: baseTime})
+	must(t, err, "Create")
+	inactive, err := repo.Create(ctx, domain.ConsentTemplate{ID: newID("tpl"), OrgID: orgA, Title: "Anterior", Content: "…",
+		CreatedAt: baseTime, UpdatedAt: baseTime})
+	must(t, err, "Create")
+	_, err = repo.Create(ctxB, domain.ConsentTemplate{ID: newID("tpl"), OrgID: orgB, Title: "Otra org", IsActive: true,
+		CreatedAt: baseTime, UpdatedAt: baseTime})
+	must(t, err, "Create(org B)")
+
+	got, err := repo.GetByID(ctx, active.ID)
+	must(t, err, "GetByID")
+	if got.Title != "Asistencia" || got.OrgID != orgA {
+		t.Fatalf("GetByID = %+v", got)
+	}
+
+	byID := func(c domain.ConsentTemplate) string { return c.ID }
+	list, err := repo.ListByOrg(ctx, orgA)
+	must(t, err, "ListByOrg")
+	if got := ids(list, byID); len(got) != 2 || !contains(got, active.ID) || !contains(got, inactive.ID) {
+		t.Fatalf("ListByOrg(org A) = %v", got)
+	}
+	list, err = repo.ListActiveByOrg(ctx, orgA)
+	must(t, err, "ListActiveByOrg")
+	requireIDs(t, "ListActiveByOrg", ids(list, byID), []string{active.ID})
+	if got, err := repo.GetActiveByOrg(ctx, orgA); err != nil || got.ID != active.ID {
+		t.Fatalf("GetActiveByOrg = %s, %v", got.ID, err)
+	}
+
+	got.IsActive = false
+	_, err = repo.Update(ctx, got)
+	must(t, err, "Update")
+	list, err = repo.ListActiveByOrg(ctx, orgA)
+	must(t, err, "ListActiveByOrg")
+	if len(list) != 0 {
+		t.Fatalf("ListActiveByOrg after Update = %d templates, want 0", len(list))
+	}
+}
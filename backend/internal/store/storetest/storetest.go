@@ -0,0 +1,206 @@
+// Package storetest es la batería de conformidad de los repositorios de
+// store. Cualquier backend (in-memory, archivo, DynamoDB, PostgreSQL) debe
+// pasarla para que los servicios no dependan de cuál está configurado: CRUD,
+// errores "not found", aislamiento por org, orden de los listados y control
+// de concurrencia optimista.
+//
+// Los IDs y las orgs son únicos en cada corrida (uuid), así que la batería se
+// puede ejecutar contra tablas compartidas como las de DynamoDB Local.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"clinical-backend/internal/store"
+	"clinical-backend/internal/store/postgres"
+)
+
+// Repositories are the repositories under test. A nil field skips the tests
+// of that repository.
+type Repositories struct {
+	Patients         store.PatientRepository
+	Appointments     store.AppointmentRepository
+	Consents         store.ConsentRepository
+	ConsentTemplates store.ConsentTemplateRepository
+	Users            store.AuthRepository
+	Odontograms      store.OdontogramRepository
+	TreatmentPlans   store.TreatmentPlanRepository
+	Payments         store.PaymentRepository
+	Budgets          store.BudgetRepository
+	Waitlist         store.WaitlistRepository
+	Resources        store.ResourceRepository
+	AppointmentTypes store.AppointmentTypeRepository
+}
+
+// FromInMemory adapts the in-memory repositories.
+func FromInMemory(r *store.InMemoryRepositories) Repositories {
+	return Repositories{
+		Patients:         r.Patients,
+		Appointments:     r.Appointments,
+		Consents:         r.Consents,
+		ConsentTemplates: r.ConsentTemplates,
+		Users:            r.Users,
+		Odontograms:      r.Odontograms,
+		TreatmentPlans:   r.TreatmentPlans,
+		Payments:         r.Payments,
+		Budgets:          r.Budgets,
+		Waitlist:         r.Waitlist,
+		Resources:        r.Resources,
+		AppointmentTypes: r.AppointmentTypes,
+	}
+}
+
+// FromDynamoDB adapts the DynamoDB repositories.
+func FromDynamoDB(r *store.DynamoDBRepositories) Repositories {
+	return Repositories{
+		Patients:         r.Patients,
+		Appointments:     r.Appointments,
+		Consents:         r.Consents,
+		ConsentTemplates: r.ConsentTemplates,
+		Users:            r.Users,
+		Odontograms:      r.Odontograms,
+		TreatmentPlans:   r.TreatmentPlans,
+		Payments:         r.Payments,
+		Budgets:          r.Budgets,
+		Waitlist:         r.Waitlist,
+		Resources:        r.Resources,
+		AppointmentTypes: r.AppointmentTypes,
+	}
+}
+
+// FromFile adapts the file-backed repositories.
+func FromFile(r *store.FileRepositories) Repositories {
+	return Repositories{
+		Patients:         r.Patients,
+		Appointments:     r.Appointments,
+		Consents:         r.Consents,
+		ConsentTemplates: r.ConsentTemplates,
+		Users:            r.Users,
+		Odontograms:      r.Odontograms,
+		TreatmentPlans:   r.TreatmentPlans,
+		Payments:         r.Payments,
+		Budgets:          r.Budgets,
+		Waitlist:         r.Waitlist,
+		Resources:        r.Resources,
+		AppointmentTypes: r.AppointmentTypes,
+	}
+}
+
+// FromPostgres adapts the PostgreSQL repositories.
+func FromPostgres(r *postgres.Repositories) Repositories {
+	return Repositories{
+		Patients:         r.Patients,
+		Appointments:     r.Appointments,
+		Consents:         r.Consents,
+		ConsentTemplates: r.ConsentTemplates,
+		Users:            r.Users,
+		Odontograms:      r.Odontograms,
+		TreatmentPlans:   r.TreatmentPlans,
+		Payments:         r.Payments,
+		Budgets:          r.Budgets,
+		Waitlist:         r.Waitlist,
+		Resources:        r.Resources,
+		AppointmentTypes: r.AppointmentTypes,
+	}
+}
+
+// Run runs the conformance suite. newRepos is called once per repository
+// subtest; it may return the same instance every time.
+func Run(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	suites := []struct {
+		name string
+		run  func(*testing.T, Repositories)
+	}{
+		{"Patients", testPatients},
+		{"Appointments", testAppointments},
+		{"Consents", testConsents},
+		{"ConsentTemplates", testConsentTemplates},
+		{"Users", testUsers},
+		{"AuthThrottles", testAuthThrottles},
+		{"Odontograms", testOdontograms},
+		{"TreatmentPlans", testTreatmentPlans},
+		{"Payments", testPayments},
+		{"Budgets", testBudgets},
+		{"Waitlist", testWaitlist},
+		{"Resources", testResources},
+		{"AppointmentTypes", testAppointmentTypes},
+	}
+	for _, s := range suites {
+		t.Run(s.name, func(t *testing.T) {
+			s.run(t, newRepos(t))
+		})
+	}
+}
+
+func skipIfNil(t *testing.T, repo any) {
+	t.Helper()
+	if repo == nil {
+		t.Skip("repository not provided")
+	}
+}
+
+// newID returns an ID unique to this run.
+func newID(prefix string) string {
+	return prefix + "-" + uuid.NewString()
+}
+
+// newOrg returns a context scoped to a fresh org.
+func newOrg() (context.Context, string) {
+	org := newID("org")
+	return store.ContextWithOrgID(context.Background(), org), org
+}
+
+// baseTime is a fixed UTC instant, truncated so it survives every encoding.
+var baseTime = time.Date(2030, 1, 15, 9, 0, 0, 0, time.UTC)
+
+func requireNotFound(t *testing.T, err error, op string) {
+	t.Helper()
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("%s: expected a not found error, got %v", op, err)
+	}
+}
+
+func requireConflict(t *testing.T, err error, op string) {
+	t.Helper()
+	if !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("%s: expected ErrConflict, got %v", op, err)
+	}
+}
+
+func must(t *testing.T, err error, op string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", op, err)
+	}
+}
+
+// ids returns the IDs of items in order.
+func ids[T any](items []T, id func(T) string) []string {
+	out := make([]string, 0, len(items))
+	for _, it := range items {
+		out = append(out, id(it))
+	}
+	return out
+}
+
+func requireIDs(t *testing.T, op string, got, want []string) {
+	t.Helper()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("%s = %v, want %v", op, got, want)
+	}
+}
+
+func contains(list []string, id string) bool {
+	for _, v := range list {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,537 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+)
+
+// RunStrict runs the suite against repositories built with strict tenancy
+// (store.WithStrictTenancy) and then the isolation checks: every repository
+// rejects a context without org, the cross-org methods require a system
+// context and org B can neither read nor modify what org A wrote.
+func RunStrict(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	Run(t, newRepos)
+
+	suites := []struct {
+		name string
+		run  func(*testing.T, Repositories)
+	}{
+		{"Patients", isolatePatients},
+		{"Appointments", isolateAppointments},
+		{"Consents", isolateConsents},
+		{"ConsentTemplates", isolateConsentTemplates},
+		{"Users", isolateUsers},
+		{"Odontograms", isolateOdontograms},
+		{"TreatmentPlans", isolateTreatmentPlans},
+		{"Payments", isolatePayments},
+		{"Budgets", isolateBudgets},
+		{"Waitlist", isolateWaitlist},
+		{"Resources", isolateResources},
+		{"AppointmentTypes", isolateAppointmentTypes},
+	}
+	for _, s := range suites {
+		t.Run("Isolation/"+s.name, func(t *testing.T) {
+			s.run(t, newRepos(t))
+		})
+	}
+}
+
+// noOrg is a context without org, as a request that skipped authentication.
+var noOrg = context.Background()
+
+func requireErrorIs(t *testing.T, err, target error, op string) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("%s: expected %v, got %v", op, target, err)
+	}
+}
+
+func requireError(t *testing.T, err error, op string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("%s: expected an error", op)
+	}
+}
+
+func isolatePatients(t *testing.T, r Repositories) {
+	repo := r.Patients
+	skipIfNil(t, repo)
+	ctxA, _ := newOrg()
+	ctxB, _ := newOrg()
+	doctor := newID("doc")
+
+	p, err := repo.Create(noOrg, domain.Patient{ID: newID("pat"), DoctorID: doctor, FirstName: "Ana"})
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, newID("pat"))
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.ListByDoctor(noOrg, doctor)
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByDoctor(no org)")
+	_, err = repo.SearchByQuery(noOrg, doctor, "ana")
+	requireErrorIs(t, err, store.ErrMissingOrg, "SearchByQuery(no org)")
+	_, err = repo.Update(noOrg, p)
+	requireErrorIs(t, err, store.ErrMissingOrg, "Update(no org)")
+	requireErrorIs(t, repo.Delete(noOrg, p.ID), store.ErrMissingOrg, "Delete(no org)")
+	_, err = repo.ListAll(ctxA)
+	requireErrorIs(t, err, store.ErrSystemOnly, "ListAll(org context)")
+
+	p, err = repo.Create(ctxA, domain.Patient{ID: newID("pat"), DoctorID: doctor, FirstName: "Ana", CreatedAt: baseTime})
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, p.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	list, err := repo.ListByDoctor(ctxB, doctor)
+	must(t, err, "ListByDoctor(other org)")
+	requireIDs(t, "ListByDoctor(other org)", ids(list, patientID), nil)
+
+	changed := p
+	changed.FirstName = "Intrusa"
+	_, err = repo.Update(ctxB, changed)
+	requireError(t, err, "Update(other org)")
+	_ = repo.Delete(ctxB, p.ID)
+	got, err := repo.GetByID(ctxA, p.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.FirstName != "Ana" || got.Version != 1 {
+		t.Fatalf("org B modified org A's patient: %+v", got)
+	}
+}
+
+func isolateAppointments(t *testing.T, r Repositories) {
+	repo := r.Appointments
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+	doctor, patient := newID("doc"), newID("pat")
+	day := time.Date(baseTime.Year(), baseTime.Month(), baseTime.Day(), 0, 0, 0, 0, time.UTC)
+	appt := domain.Appointment{ID: newID("apt"), DoctorID: doctor, PatientID: patient, Status: "scheduled",
+		StartAt: baseTime, EndAt: baseTime.Add(30 * time.Minute), ConfirmToken: newID("confirm")}
+
+	_, err := repo.Create(noOrg, appt)
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	withOrgA := appt
+	withOrgA.OrgID = orgA
+	_, err = repo.Create(ctxB, withOrgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "Create(OrgID of another org)")
+	_, err = repo.GetByID(noOrg, appt.ID)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.ListByDoctorAndDay(noOrg, doctor, day)
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByDoctorAndDay(no org)")
+	_, err = repo.ListByPatient(noOrg, patient)
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByPatient(no org)")
+	requireErrorIs(t, repo.Delete(noOrg, appt.ID), store.ErrMissingOrg, "Delete(no org)")
+	_, err = repo.ScanAllPayments(ctxA)
+	requireErrorIs(t, err, store.ErrSystemOnly, "ScanAllPayments(org context)")
+	_, err = repo.ScanOrgPayments(ctxB, orgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "ScanOrgPayments(other org)")
+
+	created, err := repo.Create(ctxA, appt)
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	list, err := repo.ListByDoctorAndDay(ctxB, doctor, day)
+	must(t, err, "ListByDoctorAndDay(other org)")
+	requireIDs(t, "ListByDoctorAndDay(other org)", ids(list, appointmentID), nil)
+	list, err = repo.ListByPatient(ctxB, patient)
+	must(t, err, "ListByPatient(other org)")
+	requireIDs(t, "ListByPatient(other org)", ids(list, appointmentID), nil)
+
+	// El enlace público sigue funcionando sin org y devuelve la org de la cita.
+	byToken, err := repo.GetByConfirmToken(noOrg, appt.ConfirmToken)
+	must(t, err, "GetByConfirmToken(no org)")
+	if byToken.OrgID != orgA {
+		t.Fatalf("GetByConfirmToken: OrgID = %q, want %q", byToken.OrgID, orgA)
+	}
+
+	changed := byToken
+	changed.Status = "cancelled"
+	_, err = repo.Update(ctxB, changed)
+	requireErrorIs(t, err, store.ErrCrossOrg, "Update(OrgID of another org)")
+	changed.OrgID = ""
+	_, err = repo.Update(ctxB, changed)
+	requireError(t, err, "Update(other org)")
+	_ = repo.Delete(ctxB, created.ID)
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.Status != "scheduled" || got.Version != 1 {
+		t.Fatalf("org B modified org A's appointment: %+v", got)
+	}
+}
+
+func isolateConsents(t *testing.T, r Repositories) {
+	repo := r.Consents
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+	appt := newID("apt")
+	consent := domain.Consent{ID: newID("consent"), AppointmentID: appt, PatientID: newID("pat"), Title: "Asistencia",
+		Status: "pending", AcceptToken: newID("accept"), CreatedAt: baseTime}
+
+	_, err := repo.Create(noOrg, consent)
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, consent.ID)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.GetByAppointmentID(noOrg, appt)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByAppointmentID(no org)")
+	_, err = repo.ListByAppointmentID(noOrg, appt)
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByAppointmentID(no org)")
+
+	created, err := repo.Create(ctxA, consent)
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	_, err = repo.GetByAppointmentID(ctxB, appt)
+	requireNotFound(t, err, "GetByAppointmentID(other org)")
+	list, err := repo.ListByAppointmentID(ctxB, appt)
+	must(t, err, "ListByAppointmentID(other org)")
+	if len(list) != 0 {
+		t.Fatalf("ListByAppointmentID(other org) = %d consents, want 0", len(list))
+	}
+
+	byToken, err := repo.GetByToken(noOrg, consent.AcceptToken)
+	must(t, err, "GetByToken(no org)")
+	if byToken.OrgID != orgA {
+		t.Fatalf("GetByToken: OrgID = %q, want %q", byToken.OrgID, orgA)
+	}
+	changed := byToken
+	changed.Status = "accepted"
+	_, err = repo.Update(ctxB, changed)
+	requireErrorIs(t, err, store.ErrCrossOrg, "Update(OrgID of another org)")
+	changed.OrgID = ""
+	_, err = repo.Update(ctxB, changed)
+	requireError(t, err, "Update(other org)")
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.Status != "pending" {
+		t.Fatalf("org B modified org A's consent: %+v", got)
+	}
+}
+
+func isolateConsentTemplates(t *testing.T, r Repositories) {
+	repo := r.ConsentTemplates
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+	tpl := domain.ConsentTemplate{ID: newID("tpl"), Title: "Asistencia", Content: "…", IsActive: true,
+		CreatedAt: baseTime, UpdatedAt: baseTime}
+
+	_, err := repo.Create(noOrg, tpl)
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, tpl.ID)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.ListByOrg(noOrg, "")
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByOrg(no org)")
+
+	tpl.OrgID = orgA
+	created, err := repo.Create(ctxA, tpl)
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	_, err = repo.ListByOrg(ctxB, orgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "ListByOrg(other org)")
+	_, err = repo.ListActiveByOrg(ctxB, orgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "ListActiveByOrg(other org)")
+	_, err = repo.GetActiveByOrg(ctxB, orgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "GetActiveByOrg(other org)")
+
+	changed := created
+	changed.Title = "Intrusa"
+	_, err = repo.Update(ctxB, changed)
+	requireErrorIs(t, err, store.ErrCrossOrg, "Update(OrgID of another org)")
+	changed.OrgID = ""
+	_, err = repo.Update(ctxB, changed)
+	requireError(t, err, "Update(other org)")
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.Title != "Asistencia" || got.OrgID != orgA {
+		t.Fatalf("org B modified org A's template: %+v", got)
+	}
+}
+
+func isolateUsers(t *testing.T, r Repositories) {
+	repo := r.Users
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+
+	// Las identidades (email, sesión, token de calendario) son globales: el
+	// login llega sin org. Lo que recibe una org explícita la exige.
+	user, err := repo.CreateUser(noOrg, store.AuthUser{ID: newID("user"), OrgID: orgA, Email: newID("doc") + "@example.com",
+		Role: "doctor", CreatedAt: baseTime})
+	must(t, err, "CreateUser")
+	_, err = repo.ListUsersByOrg(noOrg, "")
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListUsersByOrg(no org)")
+	_, err = repo.ListUsersByOrg(ctxB, orgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "ListUsersByOrg(other org)")
+	requireErrorIs(t, repo.DeleteUser(ctxB, orgA, user.ID), store.ErrCrossOrg, "DeleteUser(other org)")
+	if _, err := repo.GetUserByID(ctxA, user.ID); err != nil {
+		t.Fatalf("DeleteUser from another org removed the user: %v", err)
+	}
+
+	// El contexto de sistema (platform_admin) sí puede cruzar orgs.
+	users, err := repo.ListUsersByOrg(store.SystemContext(ctxB), orgA)
+	must(t, err, "ListUsersByOrg(system)")
+	requireIDs(t, "ListUsersByOrg(system)", ids(users, func(u store.AuthUser) string { return u.ID }), []string{user.ID})
+}
+
+func isolateOdontograms(t *testing.T, r Repositories) {
+	repo := r.Odontograms
+	skipIfNil(t, repo)
+	ctxA, _ := newOrg()
+	ctxB, _ := newOrg()
+	patient := newID("pat")
+
+	_, err := repo.Create(noOrg, domain.Odontogram{ID: newID("odo"), PatientID: patient})
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, newID("odo"))
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.GetByPatientID(noOrg, patient)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByPatientID(no org)")
+	_, err = repo.GetTreatmentHistory(noOrg, patient, 0)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetTreatmentHistory(no org)")
+
+	created, err := repo.Create(ctxA, domain.Odontogram{ID: newID("odo"), PatientID: patient, DoctorID: newID("doc"),
+		GeneralNotes: "Sin caries"})
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	_, err = repo.GetByPatientID(ctxB, patient)
+	requireNotFound(t, err, "GetByPatientID(other org)")
+	history, err := repo.GetTreatmentHistory(ctxB, patient, 0)
+	must(t, err, "GetTreatmentHistory(other org)")
+	if len(history) != 0 {
+		t.Fatalf("GetTreatmentHistory(other org) = %d treatments", len(history))
+	}
+
+	changed := created
+	changed.GeneralNotes = "Intrusa"
+	_, err = repo.Update(ctxB, changed)
+	requireError(t, err, "Update(other org)")
+	requireError(t, repo.AddTreatment(ctxB, created.ID, domain.ToothTreatment{ID: newID("trt"), ToothNumber: 11}),
+		"AddTreatment(other org)")
+	requireError(t, repo.UpdateToothCondition(ctxB, created.ID, 11, nil), "UpdateToothCondition(other org)")
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.GeneralNotes != "Sin caries" || got.Version != 1 || len(got.TreatmentHistory) != 0 {
+		t.Fatalf("org B modified org A's odontogram: %+v", got)
+	}
+}
+
+func isolateTreatmentPlans(t *testing.T, r Repositories) {
+	repo := r.TreatmentPlans
+	skipIfNil(t, repo)
+	ctxA, _ := newOrg()
+	ctxB, _ := newOrg()
+	patient := newID("pat")
+
+	_, err := repo.Create(noOrg, domain.TreatmentPlan{ID: newID("plan"), PatientID: patient})
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, newID("plan"))
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.GetByPatientID(noOrg, patient)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByPatientID(no org)")
+
+	created, err := repo.Create(ctxA, domain.TreatmentPlan{ID: newID("plan"), PatientID: patient, Title: "Plan",
+		Treatments: []domain.PlannedTreatment{{ToothNumber: 11, Status: domain.PlannedStatusPending}}})
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	plans, err := repo.GetByPatientID(ctxB, patient)
+	must(t, err, "GetByPatientID(other org)")
+	if len(plans) != 0 {
+		t.Fatalf("GetByPatientID(other org) = %d plans", len(plans))
+	}
+
+	changed := created
+	changed.Title = "Intrusa"
+	_, err = repo.Update(ctxB, changed)
+	requireError(t, err, "Update(other org)")
+	requireError(t, repo.UpdateTreatmentStatus(ctxB, created.ID, "0", domain.PlannedStatusCompleted, nil),
+		"UpdateTreatmentStatus(other org)")
+	_ = repo.Delete(ctxB, created.ID)
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.Title != "Plan" || got.Version != 1 || got.Treatments[0].Status != domain.PlannedStatusPending {
+		t.Fatalf("org B modified org A's plan: %+v", got)
+	}
+}
+
+func isolatePayments(t *testing.T, r Repositories) {
+	repo := r.Payments
+	skipIfNil(t, repo)
+	ctxA, _ := newOrg()
+	ctxB, _ := newOrg()
+	patient := newID("pat")
+
+	_, err := repo.Create(noOrg, domain.PaymentRecord{ID: newID("pay"), PatientID: patient, Amount: 10, CreatedAt: baseTime})
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.ListByOrg(noOrg, 0)
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByOrg(no org)")
+	_, err = repo.ListByPatient(noOrg, patient)
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByPatient(no org)")
+
+	_, err = repo.Create(ctxA, domain.PaymentRecord{ID: newID("pay"), PatientID: patient, Amount: 10, CreatedAt: baseTime})
+	must(t, err, "Create")
+	list, err := repo.ListByPatient(ctxB, patient)
+	must(t, err, "ListByPatient(other org)")
+	requireIDs(t, "ListByPatient(other org)", ids(list, paymentID), nil)
+	list, err = repo.ListByOrg(ctxB, 0)
+	must(t, err, "ListByOrg(other org)")
+	requireIDs(t, "ListByOrg(other org)", ids(list, paymentID), nil)
+}
+
+func isolateBudgets(t *testing.T, r Repositories) {
+	repo := r.Budgets
+	skipIfNil(t, repo)
+	ctxA, _ := newOrg()
+	ctxB, _ := newOrg()
+	patient := newID("pat")
+
+	_, err := repo.Create(noOrg, domain.Budget{ID: newID("budget"), PatientID: patient, Title: "Limpieza"})
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, newID("budget"))
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.ListByPatient(noOrg, patient)
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByPatient(no org)")
+
+	// La tabla de DynamoDB está particionada por paciente, no por org.
+	created, err := repo.Create(ctxA, domain.Budget{ID: newID("budget"), PatientID: patient, Title: "Limpieza",
+		TotalAmount: 40, Status: "draft", CreatedAt: baseTime})
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	list, err := repo.ListByPatient(ctxB, patient)
+	must(t, err, "ListByPatient(other org)")
+	requireIDs(t, "ListByPatient(other org)", ids(list, budgetID), nil)
+
+	changed := created
+	changed.Status = "accepted"
+	_, err = repo.Update(ctxB, changed)
+	requireError(t, err, "Update(other org)")
+	_ = repo.Delete(ctxB, created.ID)
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.Status != "draft" || got.Version != 1 {
+		t.Fatalf("org B modified org A's budget: %+v", got)
+	}
+}
+
+func isolateWaitlist(t *testing.T, r Repositories) {
+	repo := r.Waitlist
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+	doctor := newID("doc")
+	entry := domain.WaitlistEntry{ID: newID("wait"), PatientID: newID("pat"), DoctorID: doctor, Urgency: "high",
+		Status: "waiting", CreatedAt: baseTime, UpdatedAt: baseTime}
+
+	_, err := repo.Create(noOrg, entry)
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, entry.ID)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.ListByDoctor(noOrg, doctor)
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByDoctor(no org)")
+	requireErrorIs(t, repo.Delete(noOrg, entry.ID), store.ErrMissingOrg, "Delete(no org)")
+
+	created, err := repo.Create(ctxA, entry)
+	must(t, err, "Create")
+	if created.OrgID != orgA {
+		t.Fatalf("Create: OrgID = %q, want %q", created.OrgID, orgA)
+	}
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	list, err := repo.ListByDoctor(ctxB, doctor)
+	must(t, err, "ListByDoctor(other org)")
+	if len(list) != 0 {
+		t.Fatalf("ListByDoctor(other org) = %d entries", len(list))
+	}
+
+	changed := created
+	changed.Status = "cancelled"
+	_, err = repo.Update(ctxB, changed)
+	requireErrorIs(t, err, store.ErrCrossOrg, "Update(OrgID of another org)")
+	changed.OrgID = ""
+	_, err = repo.Update(ctxB, changed)
+	requireError(t, err, "Update(other org)")
+	_ = repo.Delete(ctxB, created.ID)
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.Status != "waiting" || got.OrgID != orgA {
+		t.Fatalf("org B modified org A's waitlist entry: %+v", got)
+	}
+}
+
+func isolateResources(t *testing.T, r Repositories) {
+	repo := r.Resources
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+	res := domain.Resource{ID: newID("res"), Name: "Sillón 1", Kind: "chair", Active: true, CreatedAt: baseTime}
+
+	_, err := repo.Create(noOrg, res)
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, res.ID)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.ListByOrg(noOrg, "")
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByOrg(no org)")
+	requireErrorIs(t, repo.Delete(noOrg, res.ID), store.ErrMissingOrg, "Delete(no org)")
+
+	created, err := repo.Create(ctxA, res)
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	_, err = repo.ListByOrg(ctxB, orgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "ListByOrg(other org)")
+	withOrgA := res
+	withOrgA.ID = newID("res")
+	withOrgA.OrgID = orgA
+	_, err = repo.Create(ctxB, withOrgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "Create(OrgID of another org)")
+
+	changed := created
+	changed.Name = "Intrusa"
+	_, err = repo.Update(ctxB, changed)
+	requireErrorIs(t, err, store.ErrCrossOrg, "Update(OrgID of another org)")
+	_ = repo.Delete(ctxB, created.ID)
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.Name != "Sillón 1" {
+		t.Fatalf("org B modified org A's resource: %+v", got)
+	}
+}
+
+func isolateAppointmentTypes(t *testing.T, r Repositories) {
+	repo := r.AppointmentTypes
+	skipIfNil(t, repo)
+	ctxA, orgA := newOrg()
+	ctxB, _ := newOrg()
+	at := domain.AppointmentType{ID: newID("type"), Name: "Limpieza", DurationMinutes: 30, Active: true, CreatedAt: baseTime}
+
+	_, err := repo.Create(noOrg, at)
+	requireErrorIs(t, err, store.ErrMissingOrg, "Create(no org)")
+	_, err = repo.GetByID(noOrg, at.ID)
+	requireErrorIs(t, err, store.ErrMissingOrg, "GetByID(no org)")
+	_, err = repo.ListByOrg(noOrg, "")
+	requireErrorIs(t, err, store.ErrMissingOrg, "ListByOrg(no org)")
+	requireErrorIs(t, repo.Delete(noOrg, at.ID), store.ErrMissingOrg, "Delete(no org)")
+
+	created, err := repo.Create(ctxA, at)
+	must(t, err, "Create")
+	_, err = repo.GetByID(ctxB, created.ID)
+	requireNotFound(t, err, "GetByID(other org)")
+	_, err = repo.ListByOrg(ctxB, orgA)
+	requireErrorIs(t, err, store.ErrCrossOrg, "ListByOrg(other org)")
+
+	changed := created
+	changed.DurationMinutes = 90
+	_, err = repo.Update(ctxB, changed)
+	requireErrorIs(t, err, store.ErrCrossOrg, "Update(OrgID of another org)")
+	_ = repo.Delete(ctxB, created.ID)
+	got, err := repo.GetByID(ctxA, created.ID)
+	must(t, err, "GetByID after other org's writes")
+	if got.DurationMinutes != 30 {
+		t.Fatalf("org B modified org A's appointment type: %+v", got)
+	}
+}
@@ -1,10 +1,60 @@
 package store
 
-import "context"
+import (
+	"context"
+	"errors"
+	"strings"
+)
 
 type orgIDCtxKey string
 
-const ctxOrgIDKey orgIDCtxKey = "orgId"
+const (
+	ctxOrgIDKey  orgIDCtxKey = "orgId"
+	ctxSystemKey orgIDCtxKey = "system"
+)
+
+// DefaultOrgID is the org of records written without org context when
+// strict tenancy is off.
+const DefaultOrgID = "default"
+
+// ErrMissingOrg is returned in strict tenancy mode by the org-scoped
+// repository methods when ctx carries no org.
+var ErrMissingOrg = errors.New("missing organization in context")
+
+// ErrCrossOrg is returned in strict tenancy mode when an entity or an org
+// argument names an org other than the one in ctx.
+var ErrCrossOrg = errors.New("organization does not match context")
+
+// ErrSystemOnly is returned in strict tenancy mode by the cross-org methods
+// (platform stats) when ctx is not a SystemContext.
+var ErrSystemOnly = errors.New("operation requires a system context")
+
+// Tenancy is the tenant isolation mode of a set of repositories; every
+// repository carries its own copy, set when the set is built. The zero value
+// is the lenient mode.
+//
+// Aislamiento estricto: sin org en el contexto los repositorios fallan con
+// ErrMissingOrg en lugar de usar la org "default", y las lecturas por ID de
+// las tablas que no están particionadas por org solo devuelven registros de
+// la org del contexto. Las operaciones de plataforma (que cruzan orgs) piden
+// un SystemContext explícito.
+type Tenancy struct {
+	Strict bool
+}
+
+// WithStrictTenancy turns strict tenant isolation on or off for the
+// repositories being built.
+func WithStrictTenancy(on bool) func(*Tenancy) {
+	return func(t *Tenancy) { t.Strict = on }
+}
+
+func newTenancy(opts []func(*Tenancy)) Tenancy {
+	var t Tenancy
+	for _, o := range opts {
+		o(&t)
+	}
+	return t
+}
 
 func ContextWithOrgID(ctx context.Context, orgID string) context.Context {
 	return context.WithValue(ctx, ctxOrgIDKey, orgID)
@@ -18,3 +68,57 @@ func OrgIDFromContext(ctx context.Context) string {
 	orgID, _ := v.(string)
 	return orgID
 }
+
+// SystemContext marks ctx as a platform-level operation (platform stats,
+// jobs that iterate every org). It does not select an org: org-scoped
+// methods still need ContextWithOrgID.
+func SystemContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxSystemKey, true)
+}
+
+// IsSystemContext reports whether ctx was built with SystemContext.
+func IsSystemContext(ctx context.Context) bool {
+	on, _ := ctx.Value(ctxSystemKey).(bool)
+	return on
+}
+
+// RequireOrg fails in strict mode with ErrMissingOrg when neither ctx nor
+// the entity (entityOrg, "" if none) name an org, and with ErrCrossOrg when
+// both do and they differ (a SystemContext may cross orgs). It never fails
+// otherwise.
+func (t Tenancy) RequireOrg(ctx context.Context, entityOrg string) error {
+	if !t.Strict {
+		return nil
+	}
+	org := strings.TrimSpace(OrgIDFromContext(ctx))
+	entityOrg = strings.TrimSpace(entityOrg)
+	switch {
+	case org == "" && entityOrg == "":
+		return ErrMissingOrg
+	case org != "" && entityOrg != "" && org != entityOrg && !IsSystemContext(ctx):
+		return ErrCrossOrg
+	}
+	return nil
+}
+
+// RequireSystem fails with ErrSystemOnly in strict mode unless ctx is a
+// SystemContext.
+func (t Tenancy) RequireSystem(ctx context.Context) error {
+	if t.Strict && !IsSystemContext(ctx) {
+		return ErrSystemOnly
+	}
+	return nil
+}
+
+// VisibleTo reports whether a record of entityOrg may be returned for ctx.
+// It is used by the lookups by ID of tables that are not partitioned by org.
+// Without strict mode, a ctx without org and the records written before they
+// carried an org ("") keep the old behaviour and are always visible.
+func (t Tenancy) VisibleTo(ctx context.Context, entityOrg string) bool {
+	org := strings.TrimSpace(OrgIDFromContext(ctx))
+	entityOrg = strings.TrimSpace(entityOrg)
+	if org == "" || entityOrg == "" {
+		return !t.Strict
+	}
+	return org == entityOrg
+}
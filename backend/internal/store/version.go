@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrConflict is returned by Update when the stored entity no longer has the
+// Version the caller read (someone else saved it in between).
+var ErrConflict = errors.New("the record was modified by someone else, reload and try again")
+
+// Control de concurrencia optimista: las entidades editables (citas,
+// pacientes, presupuestos, odontogramas y planes) llevan Version. Create la
+// inicia en 1 y Update solo escribe si la versión guardada sigue siendo la
+// que trae la entidad, devolviéndola con Version+1. Los registros anteriores
+// a este cambio no tienen Version y se leen como 0.
+
+// checkVersion compares the stored version with the one the caller read.
+func checkVersion(stored, expected int) error {
+	if stored != expected {
+		return ErrConflict
+	}
+	return nil
+}
+
+// initialVersion is the version of a newly created entity.
+func initialVersion(v int) int {
+	if v <= 0 {
+		return 1
+	}
+	return v
+}
+
+// putVersioned writes item only if the stored item still has version
+// expected; a missing Version attribute counts as 0. item must already carry
+// the new version.
+func putVersioned(ctx context.Context, client *dynamodb.Client, table string, item map[string]types.AttributeValue, expected int) error {
+	cond := "#version = :expected"
+	if expected == 0 {
+		cond = "attribute_exists(PK) AND (attribute_not_exists(#version) OR #version = :expected)"
+	}
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(table),
+		Item:                     item,
+		ConditionExpression:      aws.String(cond),
+		ExpressionAttributeNames: map[string]string{"#version": "Version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expected": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", expected)},
+		},
+	})
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return ErrConflict
+	}
+	return err
+}
+
+func versionAttr(v int) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", v)}
+}
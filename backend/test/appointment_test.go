@@ -0,0 +1,613 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/service"
+	"clinical-backend/internal/store"
+)
+
+func TestRescheduleAppointment(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", DoctorID: "doc-1", FirstName: "Ana", Email: "ana@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	confirmed := day
+	for _, a := range []domain.Appointment{
+		{ID: "apt-1", StartAt: day.Add(9 * time.Hour), DurationMinutes: 30, Status: "confirmed", ConfirmToken: "old", PatientConfirmedAt: &confirmed},
+		{ID: "apt-2", StartAt: day.Add(11 * time.Hour), DurationMinutes: 30, Status: "scheduled"},
+	} {
+		a.DoctorID, a.PatientID = "doc-1", "pat-1"
+		a.EndAt = a.StartAt.Add(30 * time.Minute)
+		if _, err := repos.Appointments.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	notifier := &recordingNotifier{}
+	svc := service.NewAppointmentService(repos.Appointments, notifier, service.WithPatientRepo(repos.Patients))
+
+	if _, err := svc.Reschedule(ctx, "apt-1", service.RescheduleAppointmentInput{StartAt: "2026-03-10T11:15:00Z"}); err == nil {
+		t.Fatal("expected overlap error")
+	}
+	// Moverla sobre su propio horario (solapándose consigo misma) es válido.
+	got, err := svc.Reschedule(ctx, "apt-1", service.RescheduleAppointmentInput{StartAt: "2026-03-10T09:15:00Z", DurationMinutes: 45, Reason: "pide más tarde", RescheduledBy: "usr-1"})
+	if err != nil {
+		t.Fatalf("Reschedule: %v", err)
+	}
+	if got.DurationMinutes != 45 || !got.EndAt.Equal(day.Add(10*time.Hour)) {
+		t.Errorf("unexpected slot: %s - %s (%d min)", got.StartAt, got.EndAt, got.DurationMinutes)
+	}
+	if got.Status != "scheduled" || got.PatientConfirmedAt != nil || got.ConfirmToken == "" || got.ConfirmToken == "old" {
+		t.Errorf("confirmation not reset: %+v", got)
+	}
+	if len(got.RescheduleHistory) != 1 || !got.RescheduleHistory[0].PreviousStartAt.Equal(day.Add(9*time.Hour)) || got.RescheduleHistory[0].RescheduledBy != "usr-1" {
+		t.Errorf("unexpected history: %+v", got.RescheduleHistory)
+	}
+	if len(notifier.moved) != 1 || notifier.moved[0] != "2026-03-10T09:00:00Z->2026-03-10T09:15:00Z" {
+		t.Errorf("unexpected notifications: %v", notifier.moved)
+	}
+}
+
+func TestDoctorAvailability(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", Timezone: "America/Caracas"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	// Lunes 9 de marzo de 2026, 07:00 en Caracas (UTC-4).
+	now := time.Date(2026, 3, 9, 11, 0, 0, 0, time.UTC)
+	svc := service.NewAppointmentService(repos.Appointments, nil,
+		service.WithAuthRepo(repos.Users),
+		service.WithAppointmentClock(func() time.Time { return now }),
+	)
+	if _, err := svc.SetDoctorAvailability(ctx, "doc-1", domain.DoctorAvailability{
+		Weekly: []domain.WeeklyHours{{Weekday: time.Monday, Start: "09:00", End: "12:00"}},
+		TimeOff: []domain.TimeOffBlock{{
+			StartAt: time.Date(2026, 3, 9, 14, 0, 0, 0, time.UTC), // 10:00 local
+			EndAt:   time.Date(2026, 3, 9, 15, 0, 0, 0, time.UTC), // 11:00 local
+			Reason:  "reunión",
+		}},
+	}); err != nil {
+		t.Fatalf("SetDoctorAvailability: %v", err)
+	}
+
+	// 08:00 local: fuera de horario.
+	if _, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-09T12:00:00Z"}); err == nil {
+		t.Fatal("expected out-of-hours error")
+	}
+	// 10:30 local: bloqueado.
+	if _, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-09T14:30:00Z"}); err == nil {
+		t.Fatal("expected time-off error")
+	}
+	// 09:00 local: válido.
+	if _, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-09T13:00:00Z", DurationMinutes: 30}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	res, err := svc.FindFreeSlots(ctx, service.AvailabilityQuery{DoctorID: "doc-1", From: "2026-03-09", To: "2026-03-10", DurationMinutes: 30})
+	if err != nil {
+		t.Fatalf("FindFreeSlots: %v", err)
+	}
+	var got []string
+	for _, s := range res.Slots {
+		got = append(got, s.StartAt.Format("2006-01-02 15:04"))
+	}
+	want := []string{"2026-03-09 09:30", "2026-03-09 11:00", "2026-03-09 11:30"}
+	if res.Timezone != "America/Caracas" || len(got) != len(want) {
+		t.Fatalf("slots = %v (%s), want %v", got, res.Timezone, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("slots = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAppointmentSeries(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	svc := service.NewAppointmentService(repos.Appointments, nil)
+
+	// La tercera ocurrencia (24/03) choca con una cita existente.
+	if _, err := repos.Appointments.Create(ctx, domain.Appointment{
+		ID: "apt-busy", DoctorID: "doc-1", PatientID: "pat-2", Status: "scheduled",
+		StartAt: time.Date(2026, 3, 24, 10, 0, 0, 0, time.UTC), EndAt: time.Date(2026, 3, 24, 10, 30, 0, 0, time.UTC),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := svc.CreateSeries(ctx, service.CreateAppointmentInput{
+		DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-10T10:00:00Z", DurationMinutes: 30,
+		Recurrence: &service.RecurrenceRule{Frequency: "weekly", Count: 4},
+	})
+	if err != nil {
+		t.Fatalf("CreateSeries: %v", err)
+	}
+	if len(res.Appointments) != 3 || len(res.Conflicts) != 1 || !res.Conflicts[0].StartAt.Equal(time.Date(2026, 3, 24, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected result: %d created, conflicts %+v", len(res.Appointments), res.Conflicts)
+	}
+
+	// Mover "esta y las siguientes" a partir de la segunda, una hora más tarde.
+	second := res.Appointments[1]
+	moved, err := svc.UpdateSeries(ctx, second.ID, service.SeriesScopeFollowing, service.UpdateAppointmentInput{StartAt: "2026-03-17T11:00:00Z"})
+	if err != nil {
+		t.Fatalf("UpdateSeries: %v", err)
+	}
+	if len(moved.Appointments) != 2 || len(moved.Conflicts) != 0 {
+		t.Fatalf("unexpected update: %+v", moved)
+	}
+	series, _ := svc.ListSeries(ctx, second.ID)
+	var hours []int
+	for _, a := range series {
+		hours = append(hours, a.StartAt.Hour())
+	}
+	if len(hours) != 3 || hours[0] != 10 || hours[1] != 11 || hours[2] != 11 {
+		t.Fatalf("unexpected hours after move: %v", hours)
+	}
+
+	// Cancelar toda la serie.
+	if _, err := svc.CancelSeries(ctx, second.ID, service.SeriesScopeAll); err != nil {
+		t.Fatalf("CancelSeries: %v", err)
+	}
+	series, _ = svc.ListSeries(ctx, second.ID)
+	for _, a := range series {
+		if a.Status != "cancelled" {
+			t.Fatalf("%s not cancelled: %s", a.ID, a.Status)
+		}
+	}
+}
+
+func TestPublicAppointmentSelfService(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", Timezone: "America/Caracas"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Name: "Dra. Pérez", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	// Miércoles 11 y jueves 12 de marzo de 2026 (Caracas, UTC-4).
+	for _, a := range []domain.Appointment{
+		{ID: "apt-1", StartAt: time.Date(2026, 3, 11, 14, 0, 0, 0, time.UTC), ConfirmToken: "tok"},
+		{ID: "apt-2", StartAt: time.Date(2026, 3, 12, 13, 0, 0, 0, time.UTC), ConfirmToken: "other"},
+	} {
+		a.OrgID, a.DoctorID, a.PatientID, a.Status, a.DurationMinutes = "org-1", "doc-1", "pat-1", "scheduled", 30
+		a.EndAt = a.StartAt.Add(30 * time.Minute)
+		if _, err := repos.Appointments.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clinicHours, err := service.ParseClinicHours("1-5", "09:00-12:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 9, 11, 0, 0, 0, time.UTC)
+	notifier := &recordingNotifier{}
+	svc := service.NewAppointmentService(repos.Appointments, notifier,
+		service.WithAuthRepo(repos.Users),
+		service.WithAppointmentClock(func() time.Time { return now }),
+		service.WithPublicPolicy(24*time.Hour, clinicHours),
+	)
+
+	view, err := svc.GetByToken(ctx, "tok")
+	if err != nil || !view.CanChange || view.DoctorName != "Dra. Pérez" || view.Timezone != "America/Caracas" {
+		t.Fatalf("GetByToken = %+v, %v", view, err)
+	}
+
+	slots, err := svc.PublicFreeSlots(ctx, "tok", "2026-03-12", "2026-03-12")
+	if err != nil {
+		t.Fatalf("PublicFreeSlots: %v", err)
+	}
+	// 09:00-12:00 en bloques de 30 min, menos las 09:00 ocupadas por apt-2.
+	if len(slots.Slots) != 5 || slots.Slots[0].StartAt.Format("15:04") != "09:30" {
+		t.Fatalf("unexpected slots: %+v", slots.Slots)
+	}
+
+	if _, err := svc.RescheduleByToken(ctx, "tok", "2026-03-12T09:00:00-04:00", ""); err == nil {
+		t.Fatal("expected taken slot to be rejected")
+	}
+	moved, err := svc.RescheduleByToken(ctx, "tok", "2026-03-12T09:30:00-04:00", "viaje")
+	if err != nil {
+		t.Fatalf("RescheduleByToken: %v", err)
+	}
+	if moved.ConfirmToken == "tok" || len(moved.RescheduleHistory) != 1 || moved.RescheduleHistory[0].RescheduledBy != "patient" {
+		t.Errorf("unexpected reschedule: %+v", moved)
+	}
+	if _, err := svc.GetByToken(ctx, "tok"); err == nil {
+		t.Error("old link should no longer be valid")
+	}
+
+	// Dentro de la anticipación mínima el paciente ya no puede cancelar.
+	now = time.Date(2026, 3, 11, 14, 0, 0, 0, time.UTC)
+	if _, err := svc.CancelByToken(ctx, moved.ConfirmToken, "enfermo"); err == nil {
+		t.Fatal("expected min notice error")
+	}
+	now = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	cancelled, err := svc.CancelByToken(ctx, moved.ConfirmToken, "enfermo")
+	if err != nil {
+		t.Fatalf("CancelByToken: %v", err)
+	}
+	if cancelled.Status != "cancelled" || cancelled.CancelledBy != "patient" || cancelled.CancelReason != "enfermo" || cancelled.CancelledAt == nil {
+		t.Errorf("unexpected cancellation: %+v", cancelled)
+	}
+	want := []string{"moved 2026-03-12T13:30:00Z viaje", "cancelled 2026-03-12T13:30:00Z enfermo"}
+	if len(notifier.changes) != 2 || notifier.changes[0] != want[0] || notifier.changes[1] != want[1] {
+		t.Errorf("doctor notifications = %v, want %v", notifier.changes, want)
+	}
+}
+
+func TestWaitlistOffersFreedSlot(t *testing.T) {
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", Timezone: "America/Caracas"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"pat-1", "pat-2", "pat-3", "pat-4"} {
+		if _, err := repos.Patients.Create(ctx, domain.Patient{ID: id, DoctorID: "doc-1", FirstName: id, Email: id + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Miércoles 11 de marzo de 2026, 10:00 en Caracas.
+	freed := domain.Appointment{ID: "apt-1", OrgID: "org-1", DoctorID: "doc-1", PatientID: "pat-1", Status: "scheduled",
+		StartAt: time.Date(2026, 3, 11, 14, 0, 0, 0, time.UTC), EndAt: time.Date(2026, 3, 11, 14, 30, 0, 0, time.UTC), DurationMinutes: 30}
+	if _, err := repos.Appointments.Create(ctx, freed); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 9, 11, 0, 0, 0, time.UTC)
+	notifier := &recordingNotifier{}
+	svc := service.NewAppointmentService(repos.Appointments, notifier,
+		service.WithPatientRepo(repos.Patients),
+		service.WithAuthRepo(repos.Users),
+		service.WithAppointmentClock(func() time.Time { return now }),
+		service.WithWaitlistRepo(repos.Waitlist),
+		service.WithWaitlistPolicy(time.Hour, 2),
+	)
+	for _, in := range []service.WaitlistInput{
+		{PatientID: "pat-1", Urgency: "high"},                              // quien cancela
+		{PatientID: "pat-2", PreferredDays: []time.Weekday{time.Monday}},   // otro día
+		{PatientID: "pat-3", PreferredFrom: "09:00", PreferredTo: "12:00"}, // normal
+		{PatientID: "pat-4", Urgency: "high", PreferredDays: []time.Weekday{time.Wednesday}},
+	} {
+		in.DoctorID = "doc-1"
+		if _, err := svc.AddToWaitlist(ctx, in); err != nil {
+			t.Fatalf("AddToWaitlist(%s): %v", in.PatientID, err)
+		}
+	}
+
+	if _, err := svc.UpdateAppointment(ctx, "apt-1", service.UpdateAppointmentInput{Status: "cancelled"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.offers) != 2 || notifier.offers["pat-3@example.com"] == "" || notifier.offers["pat-4@example.com"] == "" {
+		t.Fatalf("unexpected offers: %v", notifier.offers)
+	}
+
+	view, err := svc.GetWaitlistOffer(ctx, notifier.offers["pat-3@example.com"])
+	if err != nil || !view.Available || !view.StartAt.Equal(freed.StartAt) {
+		t.Fatalf("GetWaitlistOffer = %+v, %v", view, err)
+	}
+	booked, err := svc.AcceptWaitlistOffer(ctx, notifier.offers["pat-3@example.com"])
+	if err != nil {
+		t.Fatalf("AcceptWaitlistOffer: %v", err)
+	}
+	if booked.PatientID != "pat-3" || !booked.StartAt.Equal(freed.StartAt) {
+		t.Errorf("unexpected appointment: %+v", booked)
+	}
+	// El primero que acepta se queda con el hueco; la otra oferta se retira.
+	if _, err := svc.AcceptWaitlistOffer(ctx, notifier.offers["pat-4@example.com"]); err == nil {
+		t.Error("second accept should fail")
+	}
+	items, err := svc.ListWaitlist(ctx, "doc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, e := range items {
+		status[e.PatientID] = e.Status
+	}
+	if status["pat-3"] != service.WaitlistBooked || status["pat-4"] != service.WaitlistWaiting || items[0].Urgency != "high" {
+		t.Errorf("unexpected waitlist: %v", status)
+	}
+
+	// Las ofertas vencen tras el TTL.
+	if err := svc.Delete(ctx, booked.ID); err != nil {
+		t.Fatal(err)
+	}
+	token := notifier.offers["pat-4@example.com"]
+	now = now.Add(2 * time.Hour)
+	if _, err := svc.AcceptWaitlistOffer(ctx, token); err == nil {
+		t.Error("expired offer should be rejected")
+	}
+}
+
+func TestDoctorCalendarFeed(t *testing.T) {
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", Timezone: "America/Caracas"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Name: "Dra. Pérez", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", DoctorID: "doc-1", FirstName: "Ana", LastName: "Díaz"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []domain.Appointment{
+		{ID: "apt-1", Status: "confirmed", StartAt: time.Date(2026, 3, 11, 14, 0, 0, 0, time.UTC), Reason: "Control, limpieza; revisión"},
+		{ID: "apt-2", Status: "cancelled", StartAt: time.Date(2026, 3, 12, 14, 0, 0, 0, time.UTC)},
+	} {
+		a.OrgID, a.DoctorID, a.PatientID = "org-1", "doc-1", "pat-1"
+		a.EndAt = a.StartAt.Add(30 * time.Minute)
+		if _, err := repos.Appointments.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2026, 3, 9, 11, 0, 0, 0, time.UTC)
+	svc := service.NewAppointmentService(repos.Appointments, &recordingNotifier{},
+		service.WithPatientRepo(repos.Patients),
+		service.WithAuthRepo(repos.Users),
+		service.WithAppointmentClock(func() time.Time { return now }),
+	)
+
+	orgCtx := store.ContextWithOrgID(ctx, "org-1")
+	first, err := svc.RotateCalendarFeed(orgCtx, "doc-1")
+	if err != nil || first.Token == "" {
+		t.Fatalf("RotateCalendarFeed = %+v, %v", first, err)
+	}
+	feed, err := svc.CalendarFeed(ctx, first.Token)
+	if err != nil {
+		t.Fatalf("CalendarFeed: %v", err)
+	}
+	ics := string(feed)
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:America/Caracas\r\n",
+		"UID:apt-1@clinisense\r\nDTSTAMP:20260309T110000Z\r\nDTSTART;TZID=America/Caracas:20260311T100000\r\n",
+		"SUMMARY:Cita: Ana Díaz\r\n",
+		"DESCRIPTION:Motivo: Control\\, limpieza\\; revisión\r\n",
+		"UID:apt-2@clinisense",
+		"STATUS:CANCELLED\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("feed missing %q:\n%s", want, ics)
+		}
+	}
+
+	// Rotar el token invalida la URL anterior.
+	if _, err := svc.RotateCalendarFeed(orgCtx, "doc-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CalendarFeed(ctx, first.Token); err == nil {
+		t.Error("expected the old token to be rejected")
+	}
+}
+
+func TestOrgTimezone(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	org, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", Timezone: "Asia/Tokyo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	timezones := service.NewTimezoneResolver(repos.Users, service.WithFallbackTimezone("America/Caracas"))
+	svc := service.NewAppointmentService(repos.Appointments, nil,
+		service.WithAuthRepo(repos.Users),
+		service.WithTimezones(timezones),
+	)
+	// Martes 10 de marzo, 08:00 en Tokio: todavía lunes 9 en UTC.
+	appt, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-09T23:00:00Z", DurationMinutes: 30})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	items, err := svc.ListByDoctorAndDate(ctx, "doc-1", "2026-03-10")
+	if err != nil || len(items) != 1 || items[0].ID != appt.ID {
+		t.Fatalf("ListByDoctorAndDate(Tokyo) = %v, %v", items, err)
+	}
+
+	// El cambio de zona se ve tras invalidar la caché.
+	org.Timezone = "America/Caracas"
+	if _, err := repos.Users.UpdateOrganization(ctx, org); err != nil {
+		t.Fatal(err)
+	}
+	if got := timezones.ForOrg(ctx, "org-1").String(); got != "Asia/Tokyo" {
+		t.Fatalf("cached zone = %s, want Asia/Tokyo", got)
+	}
+	timezones.Invalidate("org-1")
+	if items, _ := svc.ListByDoctorAndDate(ctx, "doc-1", "2026-03-10"); len(items) != 0 {
+		t.Fatalf("ListByDoctorAndDate(Caracas, 10) = %v, want none", items)
+	}
+	if items, _ := svc.ListByDoctorAndDate(ctx, "doc-1", "2026-03-09"); len(items) != 1 {
+		t.Fatalf("ListByDoctorAndDate(Caracas, 9) = %v, want the appointment", items)
+	}
+
+	// Sin org: zona por defecto.
+	if got := timezones.ForOrg(ctx, "").String(); got != "America/Caracas" {
+		t.Fatalf("fallback = %s", got)
+	}
+}
+
+func TestResourceScheduling(t *testing.T) {
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", Timezone: "America/Caracas"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"doc-1", "doc-2"} {
+		if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: id, OrgID: "org-1", Email: id + "@example.com", Role: "doctor"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := service.NewAppointmentService(repos.Appointments, nil,
+		service.WithAuthRepo(repos.Users),
+		service.WithResourceRepo(repos.Resources),
+	)
+	chair, err := svc.CreateResource(ctx, "org-1", service.ResourceInput{Name: "Sillón 1", Kind: "chair"})
+	if err != nil {
+		t.Fatalf("CreateResource: %v", err)
+	}
+	if _, err := svc.CreateResource(ctx, "org-1", service.ResourceInput{Name: "Sillón 2", Kind: "sofa"}); err == nil {
+		t.Fatal("expected invalid kind error")
+	}
+
+	// Lunes 9 de marzo de 2026, 09:00 en Caracas.
+	first, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-09T13:00:00Z", DurationMinutes: 30, ResourceIDs: []string{chair.ID}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-2", PatientID: "pat-2", StartAt: "2026-03-09T13:15:00Z", DurationMinutes: 30, ResourceIDs: []string{chair.ID}}); err == nil || !strings.Contains(err.Error(), "Sillón 1") {
+		t.Fatalf("expected chair conflict, got %v", err)
+	}
+	second, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-2", PatientID: "pat-2", StartAt: "2026-03-09T13:30:00Z", DurationMinutes: 30, ResourceIDs: []string{chair.ID}})
+	if err != nil {
+		t.Fatalf("Create back-to-back: %v", err)
+	}
+	free, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-2", PatientID: "pat-3", StartAt: "2026-03-09T14:00:00Z", DurationMinutes: 30})
+	if err != nil {
+		t.Fatalf("Create without resource: %v", err)
+	}
+	// Mover la segunda cita encima de la primera choca con el sillón.
+	if _, err := svc.UpdateAppointment(ctx, second.ID, service.UpdateAppointmentInput{StartAt: "2026-03-09T13:00:00Z", EndAt: "2026-03-09T13:30:00Z"}); err == nil {
+		t.Fatal("expected chair conflict on update")
+	}
+
+	inactive := false
+	if _, err := svc.UpdateResource(ctx, "org-1", chair.ID, service.ResourceInput{Active: &inactive}); err != nil {
+		t.Fatalf("UpdateResource: %v", err)
+	}
+	if _, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-09T15:00:00Z", ResourceIDs: []string{chair.ID}}); err == nil {
+		t.Fatal("expected inactive resource error")
+	}
+
+	day, err := svc.GetResourceSchedule(ctx, "org-1", "2026-03-09")
+	if err != nil {
+		t.Fatalf("GetResourceSchedule: %v", err)
+	}
+	if len(day.Resources) != 1 || len(day.Resources[0].Appointments) != 2 ||
+		day.Resources[0].Appointments[0].ID != first.ID || day.Resources[0].Appointments[1].ID != second.ID {
+		t.Fatalf("resource rows = %+v", day.Resources)
+	}
+	if len(day.Unassigned) != 1 || day.Unassigned[0].ID != free.ID {
+		t.Fatalf("unassigned = %+v", day.Unassigned)
+	}
+}
+
+func TestAppointmentTypeConsents(t *testing.T) {
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", Timezone: "America/Caracas"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", DoctorID: "doc-1", FirstName: "Ana", Email: "ana@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	notifier := &recordingNotifier{}
+	consents := service.NewConsentService(repos.Consents, repos.ConsentTemplates, notifier)
+	attendance, err := consents.CreateTemplate(ctx, service.CreateConsentTemplateInput{OrgID: "org-1", Title: "Asistencia", Content: "...", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	surgery, err := consents.CreateTemplate(ctx, service.CreateConsentTemplateInput{OrgID: "org-1", Title: "Extracción", Content: "...", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewAppointmentService(repos.Appointments, notifier,
+		service.WithAuthRepo(repos.Users),
+		service.WithPatientRepo(repos.Patients),
+		service.WithConsentService(consents),
+		service.WithAppointmentTypeRepo(repos.AppointmentTypes),
+	)
+	price := 80.0
+	extraction, err := svc.CreateAppointmentType(ctx, "org-1", service.AppointmentTypeInput{
+		Name: "Extracción", DurationMinutes: 45, Color: "#FF8800", DefaultPrice: &price,
+		ConsentTemplateIDs: []string{surgery.ID},
+	})
+	if err != nil {
+		t.Fatalf("CreateAppointmentType: %v", err)
+	}
+	if _, err := svc.CreateAppointmentType(ctx, "org-1", service.AppointmentTypeInput{Name: "Limpieza", ConsentTemplateIDs: []string{"ctpl_missing"}}); err == nil {
+		t.Fatal("expected unknown consent template error")
+	}
+
+	appt, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-09T13:00:00Z", AppointmentTypeID: extraction.ID})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if appt.DurationMinutes != 45 || appt.PaymentAmount != 80 || appt.Reason != "Extracción" || appt.AppointmentTypeID != extraction.ID {
+		t.Fatalf("type defaults not applied: %+v", appt)
+	}
+	list, _ := repos.Consents.ListByAppointmentID(ctx, appt.ID)
+	if len(list) != 1 || list[0].TemplateID != surgery.ID {
+		t.Fatalf("consents = %+v, want only %s", list, surgery.ID)
+	}
+
+	// Sin tipo se envían todas las plantillas activas.
+	untyped, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-10T13:00:00Z"})
+	if err != nil {
+		t.Fatalf("Create untyped: %v", err)
+	}
+	list, _ = repos.Consents.ListByAppointmentID(ctx, untyped.ID)
+	if len(list) != 2 {
+		t.Fatalf("untyped consents = %d, want 2 (%s, %s)", len(list), attendance.ID, surgery.ID)
+	}
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	svc := service.NewAppointmentService(repos.Appointments, nil)
+
+	appt, err := svc.Create(ctx, service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: "2026-03-09T13:00:00Z", DurationMinutes: 30})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if appt.Version != 1 {
+		t.Fatalf("version after create = %d, want 1", appt.Version)
+	}
+	// Dos asistentes abren la misma cita (versión 1); el segundo en guardar pierde.
+	updated, err := svc.UpdateAppointment(ctx, appt.ID, service.UpdateAppointmentInput{Reason: "Limpieza", Version: 1})
+	if err != nil {
+		t.Fatalf("UpdateAppointment: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("version after update = %d, want 2", updated.Version)
+	}
+	if _, err := svc.UpdateAppointment(ctx, appt.ID, service.UpdateAppointmentInput{Reason: "Control", Version: 1}); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("stale update err = %v, want ErrConflict", err)
+	}
+	// Sin versión se aplica sobre la última guardada.
+	if updated, err = svc.UpdateAppointment(ctx, appt.ID, service.UpdateAppointmentInput{Reason: "Control"}); err != nil || updated.Version != 3 {
+		t.Fatalf("unversioned update = %d, %v", updated.Version, err)
+	}
+
+	// El repositorio rechaza escribir una copia leída antes de otra escritura.
+	p, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", FirstName: "Ana"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := p
+	p.Phone = "0412"
+	if p, err = repos.Patients.Update(ctx, p); err != nil || p.Version != 2 {
+		t.Fatalf("patient update = %d, %v", p.Version, err)
+	}
+	stale.Email = "ana@example.com"
+	if _, err := repos.Patients.Update(ctx, stale); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("stale patient update err = %v, want ErrConflict", err)
+	}
+}
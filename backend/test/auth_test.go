@@ -0,0 +1,571 @@
+package test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/notifications"
+	"clinical-backend/internal/service"
+	"clinical-backend/internal/store"
+)
+
+func TestLoginUpgradesLegacyPasswordHash(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	legacy := sha256.Sum256([]byte("secreto123"))
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{
+		ID: "usr-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor",
+		PasswordHash: hex.EncodeToString(legacy[:]),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	auth := service.NewAuthService(repos.Users)
+
+	if _, err := auth.Login(ctx, service.LoginInput{Email: "doc@example.com", Password: "incorrecta"}); err == nil {
+		t.Fatal("expected invalid credentials")
+	}
+	if _, err := auth.Login(ctx, service.LoginInput{Email: "doc@example.com", Password: "secreto123"}); err != nil {
+		t.Fatalf("legacy login: %v", err)
+	}
+	u, _ := repos.Users.GetUserByID(ctx, "usr-1")
+	if !strings.HasPrefix(u.PasswordHash, "$argon2id$") {
+		t.Fatalf("hash not upgraded: %q", u.PasswordHash)
+	}
+	if _, err := auth.Login(ctx, service.LoginInput{Email: "doc@example.com", Password: "secreto123"}); err != nil {
+		t.Fatalf("login after upgrade: %v", err)
+	}
+
+	if err := auth.ChangePassword(ctx, service.ChangePasswordInput{UserID: "usr-1", OldPassword: "secreto123", NewPassword: "nuevo-secreto"}); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	if _, err := auth.Login(ctx, service.LoginInput{Email: "doc@example.com", Password: "nuevo-secreto"}); err != nil {
+		t.Fatalf("login with new password: %v", err)
+	}
+}
+
+func TestLoginRejectsOutOfRangeArgon2Params(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	auth := service.NewAuthService(repos.Users)
+	salt, key := "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	for i, params := range []string{"m=19456,t=0,p=1", "m=19456,t=2,p=0", "m=4294967295,t=2,p=1", "m=19456,t=1000000,p=1"} {
+		email := fmt.Sprintf("doc%d@example.com", i)
+		if _, err := repos.Users.CreateUser(ctx, store.AuthUser{
+			ID: fmt.Sprintf("usr-%d", i), OrgID: "org-1", Email: email, Role: "doctor",
+			PasswordHash: "$argon2id$v=19$" + params + "$" + salt + "$" + key,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := auth.Login(ctx, service.LoginInput{Email: email, Password: "secreto123"}); err == nil {
+			t.Errorf("%s: expected invalid credentials", params)
+		}
+	}
+}
+
+func TestRegisterStrictTenancyCreatesOrg(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories(store.WithStrictTenancy(true))
+	auth := service.NewAuthService(repos.Users, service.WithStrictTenancy(true))
+
+	first, err := auth.Register(ctx, service.RegisterInput{Name: "Ana", Email: "ana@example.com", Password: "secreto123", OrgName: "Clínica Ana"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	second, err := auth.Register(ctx, service.RegisterInput{Name: "Luis", Email: "luis@example.com", Password: "secreto123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if first.OrgID == store.DefaultOrgID || first.OrgID == "" || first.OrgID == second.OrgID {
+		t.Fatalf("orgs = %q, %q; want a dedicated org per registration", first.OrgID, second.OrgID)
+	}
+	org, err := repos.Users.GetOrganization(ctx, first.OrgID)
+	if err != nil || org.Name != "Clínica Ana" {
+		t.Fatalf("GetOrganization = %+v, %v", org, err)
+	}
+	u, _ := repos.Users.GetUserByID(ctx, first.UserID)
+	if u.OrgID != first.OrgID || u.Role != "admin" {
+		t.Fatalf("user = %+v", u)
+	}
+
+	// Un email repetido no deja una organización huérfana.
+	orgs, _ := repos.Users.ListOrganizations(ctx)
+	if _, err := auth.Register(ctx, service.RegisterInput{Name: "Ana", Email: "ana@example.com", Password: "secreto123"}); err == nil {
+		t.Fatal("expected duplicate email error")
+	}
+	if after, _ := repos.Users.ListOrganizations(ctx); len(after) != len(orgs) {
+		t.Fatalf("organizations = %d after failed register, want %d", len(after), len(orgs))
+	}
+}
+
+func TestRefreshRotationAndRevocation(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	legacy := sha256.Sum256([]byte("secreto123"))
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{
+		ID: "usr-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor", Status: "active",
+		PasswordHash: hex.EncodeToString(legacy[:]),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	auth := service.NewAuthService(repos.Users)
+	login := func(password string) service.LoginOutput {
+		t.Helper()
+		out, err := auth.Login(ctx, service.LoginInput{Email: "doc@example.com", Password: password, UserAgent: "test"})
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		return out
+	}
+
+	// Rotación: el refresh token usado deja de valer, igual que su access token.
+	first := login("secreto123")
+	rotated, err := auth.Refresh(ctx, service.RefreshInput{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if rotated.SessionID != first.SessionID || rotated.RefreshToken == first.RefreshToken || rotated.AccessToken == first.AccessToken {
+		t.Fatalf("refresh did not rotate: %+v", rotated)
+	}
+	if _, err := auth.Authenticate(ctx, first.AccessToken); err == nil {
+		t.Fatal("old access token still valid")
+	}
+	if _, err := auth.Authenticate(ctx, rotated.AccessToken); err != nil {
+		t.Fatalf("new access token: %v", err)
+	}
+	// Reintento inmediato con el token anterior: se rechaza sin revocar.
+	if _, err := auth.Refresh(ctx, service.RefreshInput{RefreshToken: first.RefreshToken}); err == nil {
+		t.Fatal("rotated refresh token accepted")
+	}
+	if _, err := auth.Authenticate(ctx, rotated.AccessToken); err != nil {
+		t.Fatalf("retry revoked the session: %v", err)
+	}
+	// Un token que nunca fue el actual (copia robada) revoca la sesión.
+	if _, err := auth.Refresh(ctx, service.RefreshInput{RefreshToken: first.SessionID + ".forged"}); err == nil {
+		t.Fatal("forged refresh token accepted")
+	}
+	if _, err := auth.Authenticate(ctx, rotated.AccessToken); err == nil {
+		t.Fatal("session not revoked after reuse")
+	}
+
+	// Listado y revocación propia.
+	a, b := login("secreto123"), login("secreto123")
+	sessions, err := auth.ListSessions(ctx, "usr-1", b.SessionID)
+	if err != nil || len(sessions) != 2 || sessions[0].ID != b.SessionID || !sessions[0].Current || sessions[0].UserAgent != "test" {
+		t.Fatalf("list sessions = %+v, %v", sessions, err)
+	}
+	if err := auth.RevokeSession(ctx, "usr-2", a.SessionID); err == nil {
+		t.Fatal("revoked another user's session")
+	}
+	if err := auth.RevokeSession(ctx, "usr-1", a.SessionID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	if _, err := auth.Refresh(ctx, service.RefreshInput{RefreshToken: a.RefreshToken}); err == nil {
+		t.Fatal("refresh after revoke")
+	}
+
+	// Revocación de todas: por el admin, al deshabilitar y tras un reset.
+	login("secreto123")
+	if n, err := auth.RevokeOrgUserSessions(ctx, "org-1", "usr-1"); err != nil || n != 2 {
+		t.Fatalf("revoke all = %d, %v", n, err)
+	}
+	if _, err := auth.RevokeOrgUserSessions(ctx, "org-2", "usr-1"); err == nil {
+		t.Fatal("revoked sessions of a user from another org")
+	}
+	c := login("secreto123")
+	if _, err := auth.UpdateOrgUser(ctx, service.UpdateOrgUserInput{OrgID: "org-1", UserID: "usr-1", Status: "disabled"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(ctx, service.RefreshInput{RefreshToken: c.RefreshToken}); err == nil {
+		t.Fatal("refresh after disable")
+	}
+	if _, err := auth.UpdateOrgUser(ctx, service.UpdateOrgUserInput{OrgID: "org-1", UserID: "usr-1", Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+	d := login("secreto123")
+	reset, err := auth.ForgotPassword(ctx, service.ForgotPasswordInput{Email: "doc@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.ResetPassword(ctx, service.ResetPasswordInput{Token: reset.ResetToken, NewPassword: "nuevo-secreto"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Authenticate(ctx, d.AccessToken); err == nil {
+		t.Fatal("session survived password reset")
+	}
+}
+
+func TestConcurrentRefreshRotatesOnce(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	legacy := sha256.Sum256([]byte("secreto123"))
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{
+		ID: "usr-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor", Status: "active",
+		PasswordHash: hex.EncodeToString(legacy[:]),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	auth := service.NewAuthService(repos.Users)
+	first, err := auth.Login(ctx, service.LoginInput{Email: "doc@example.com", Password: "secreto123"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// El repositorio solo guarda hashes de los tokens.
+	stored, err := repos.Users.GetSessionByID(ctx, first.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Token == first.AccessToken || stored.RefreshToken == first.RefreshToken ||
+		stored.Token != storedToken(first.AccessToken) || stored.RefreshToken != storedToken(first.RefreshToken) {
+		t.Fatalf("stored session = %+v, want token hashes", stored)
+	}
+
+	const n = 8
+	results := make(chan service.LoginOutput, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if out, err := auth.Refresh(ctx, service.RefreshInput{RefreshToken: first.RefreshToken}); err == nil {
+				results <- out
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	var won []service.LoginOutput
+	for out := range results {
+		won = append(won, out)
+	}
+	if len(won) != 1 {
+		t.Fatalf("%d concurrent refreshes succeeded, want 1", len(won))
+	}
+	// Los perdedores son reintentos: la sesión sigue viva con los tokens del ganador.
+	if _, err := auth.Authenticate(ctx, won[0].AccessToken); err != nil {
+		t.Fatalf("winner access token: %v", err)
+	}
+	if _, err := auth.Refresh(ctx, service.RefreshInput{RefreshToken: won[0].RefreshToken}); err != nil {
+		t.Fatalf("winner refresh token: %v", err)
+	}
+}
+
+func TestMFAChallengeAttemptsAreBounded(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	legacy := sha256.Sum256([]byte("secreto123"))
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{
+		ID: "usr-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor", Status: "active",
+		PasswordHash: hex.EncodeToString(legacy[:]),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	if err := repos.Users.SetUserMFA(ctx, "usr-1", store.UserMFA{Secret: secret, EnabledAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	seen := map[string]int{}
+	auth := service.NewAuthService(repos.Users,
+		// Sin bloqueo de la cuenta: aquí solo cuenta el límite del desafío.
+		service.WithThrottlePolicy(service.ThrottlePolicy{MaxEmailFailures: 1000, MaxIPFailures: 1000}),
+		service.WithSecurityEvents(func(ev service.SecurityEvent) { mu.Lock(); seen[ev.Type]++; mu.Unlock() }),
+	)
+	challenge, err := auth.Login(ctx, service.LoginInput{Email: "doc@example.com", Password: "secreto123"})
+	if err != nil || challenge.MFAToken == "" {
+		t.Fatalf("login = %+v, %v", challenge, err)
+	}
+
+	// Un mfaToken erróneo cuenta como intento fallido del usuario.
+	if _, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: "usr-1.forged", Code: "000000"}); err == nil {
+		t.Fatal("forged mfa token accepted")
+	}
+	if seen[service.SecurityMFAFailed] != 1 {
+		t.Fatalf("mfa failures after a forged token = %d, want 1", seen[service.SecurityMFAFailed])
+	}
+
+	// Códigos erróneos en paralelo con el mismo mfaToken: solo 4 llegan a
+	// "invalid code", el resto encuentra el desafío agotado.
+	const n = 20
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, Code: "000000"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	invalid := 0
+	for err := range errs {
+		if err == nil {
+			t.Fatal("wrong code accepted")
+		}
+		if err.Error() == "invalid code" {
+			invalid++
+		}
+	}
+	if invalid != 4 {
+		t.Fatalf("%d guesses were checked as codes, want 4", invalid)
+	}
+	step := time.Now().Unix() / 30
+	if _, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, Code: totp(t, secret, step)}); err == nil {
+		t.Fatal("challenge still valid after too many attempts")
+	}
+}
+
+// totp calcula el código RFC 6238 del paso step (SHA1, 6 dígitos).
+func totp(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[off:off+4])&0x7fffffff)%1000000)
+}
+
+func TestMFALoginFlow(t *testing.T) {
+	// Vector del RFC 6238 (T = 59s).
+	if got := totp(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", 1); got != "287082" {
+		t.Fatalf("totp helper = %s", got)
+	}
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica"}); err != nil {
+		t.Fatal(err)
+	}
+	legacy := sha256.Sum256([]byte("secreto123"))
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{
+		ID: "usr-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor", Status: "active",
+		PasswordHash: hex.EncodeToString(legacy[:]),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	auth := service.NewAuthService(repos.Users)
+	login := func() service.LoginOutput {
+		t.Helper()
+		out, err := auth.Login(ctx, service.LoginInput{Email: "doc@example.com", Password: "secreto123"})
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		return out
+	}
+	step := time.Now().Unix() / 30
+
+	// Inscripción: el MFA se activa solo al confirmar con un código.
+	enrollment, err := auth.StartMFAEnrollment(ctx, "usr-1")
+	if err != nil || !strings.HasPrefix(enrollment.OTPAuthURI, "otpauth://totp/Clinical:doc@example.com?") {
+		t.Fatalf("enroll = %+v, %v", enrollment, err)
+	}
+	if out := login(); out.MFARequired || out.AccessToken == "" {
+		t.Fatalf("login before confirming = %+v", out)
+	}
+	if _, err := auth.ConfirmMFAEnrollment(ctx, "usr-1", service.MFACodeInput{Code: "000000"}); err == nil {
+		t.Fatal("confirmed with a wrong code")
+	}
+	codes, err := auth.ConfirmMFAEnrollment(ctx, "usr-1", service.MFACodeInput{Code: totp(t, enrollment.Secret, step)})
+	if err != nil || len(codes.RecoveryCodes) != 10 {
+		t.Fatalf("confirm = %+v, %v", codes, err)
+	}
+
+	// Login en dos pasos; un código ya usado no vale otra vez.
+	challenge := login()
+	if !challenge.MFARequired || challenge.AccessToken != "" || challenge.MFAToken == "" {
+		t.Fatalf("login with mfa = %+v", challenge)
+	}
+	if _, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, Code: totp(t, enrollment.Secret, step)}); err == nil {
+		t.Fatal("replayed totp code accepted")
+	}
+	out, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, Code: totp(t, enrollment.Secret, step+1)})
+	if err != nil || out.AccessToken == "" {
+		t.Fatalf("verify = %+v, %v", out, err)
+	}
+	if _, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, RecoveryCode: codes.RecoveryCodes[1]}); err == nil {
+		t.Fatal("mfa token reused")
+	}
+
+	// Códigos de recuperación: un solo uso.
+	challenge = login()
+	if _, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, RecoveryCode: strings.ToUpper(codes.RecoveryCodes[0])}); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	challenge = login()
+	if _, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, RecoveryCode: codes.RecoveryCodes[0]}); err == nil {
+		t.Fatal("recovery code used twice")
+	}
+	// Tras 5 códigos erróneos el desafío se descarta.
+	for i := 0; i < 4; i++ {
+		auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, Code: "000000"})
+	}
+	if _, err := auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, RecoveryCode: codes.RecoveryCodes[2]}); err == nil {
+		t.Fatal("challenge still valid after too many attempts")
+	}
+
+	// Reset del admin: sin MFA y sin sesiones.
+	if err := auth.ResetOrgUserMFA(ctx, "org-2", "usr-1"); err == nil {
+		t.Fatal("reset from another org")
+	}
+	if err := auth.ResetOrgUserMFA(ctx, "org-1", "usr-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Authenticate(ctx, out.AccessToken); err == nil {
+		t.Fatal("session survived mfa reset")
+	}
+	if out := login(); out.MFARequired {
+		t.Fatal("mfa still required after reset")
+	}
+
+	// Org que exige MFA: el login pide inscribirse y termina al verificar.
+	if _, err := auth.SetOrgMFAPolicy(ctx, "org-1", service.MFAPolicyInput{RequireMFA: true}); err != nil {
+		t.Fatal(err)
+	}
+	challenge = login()
+	if !challenge.MFARequired || !challenge.MFAEnrollmentRequired {
+		t.Fatalf("login with required mfa = %+v", challenge)
+	}
+	enrollment, err = auth.StartMFAEnrollmentForLogin(ctx, challenge.MFAToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = auth.VerifyMFA(ctx, service.VerifyMFAInput{MFAToken: challenge.MFAToken, Code: totp(t, enrollment.Secret, step)})
+	if err != nil || out.AccessToken == "" || len(out.RecoveryCodes) != 10 {
+		t.Fatalf("verify enrollment = %+v, %v", out, err)
+	}
+	if err := auth.DisableMFA(ctx, "usr-1", service.MFACodeInput{RecoveryCode: out.RecoveryCodes[0]}); err == nil {
+		t.Fatal("disabled mfa required by the org")
+	}
+}
+
+// lockNotifier guarda el enlace del email de cuenta bloqueada.
+type lockNotifier struct {
+	notifications.Notifier
+	unlockURL string
+}
+
+func (n *lockNotifier) SendAccountLocked(_ context.Context, _, unlockURL string, _ time.Time) error {
+	n.unlockURL = unlockURL
+	return nil
+}
+
+func TestLoginLockoutAndUnlock(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	legacy := sha256.Sum256([]byte("secreto123"))
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{
+		ID: "usr-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor", Status: "active",
+		PasswordHash: hex.EncodeToString(legacy[:]),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	notifier := &lockNotifier{}
+	seen := map[string]int{}
+	auth := service.NewAuthService(repos.Users,
+		service.WithNotifier(notifier),
+		service.WithFrontendBaseURL("https://app.example.com"),
+		service.WithThrottlePolicy(service.ThrottlePolicy{MaxEmailFailures: 4, MaxIPFailures: 6, MaxResetRequests: 2}),
+		service.WithSecurityEvents(func(ev service.SecurityEvent) { seen[ev.Type]++ }),
+	)
+	login := func(email, password, ip string) error {
+		_, err := auth.Login(ctx, service.LoginInput{Email: email, Password: password, IP: ip})
+		return err
+	}
+	var throttled *service.ThrottledError
+
+	// Desde el tercer fallo cada intento espera; ni el password correcto pasa antes.
+	for i := 0; i < 3; i++ {
+		if err := login("doc@example.com", "incorrecto", "10.0.0.1"); err == nil || errors.As(err, &throttled) {
+			t.Fatalf("failure %d: %v", i+1, err)
+		}
+	}
+	err := login("DOC@example.com", "secreto123", "10.0.0.2")
+	if !errors.As(err, &throttled) || throttled.Locked || throttled.RetryAfter <= 0 || throttled.RetryAfter > time.Second {
+		t.Fatalf("login during delay = %v", err)
+	}
+	time.Sleep(throttled.RetryAfter)
+
+	// El cuarto fallo bloquea la cuenta y manda el enlace de desbloqueo.
+	if err := login("doc@example.com", "incorrecto", "10.0.0.1"); err == nil || errors.As(err, &throttled) {
+		t.Fatalf("fourth failure: %v", err)
+	}
+	if err := login("doc@example.com", "secreto123", "10.0.0.2"); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("login while locked = %v", err)
+	}
+	token, ok := strings.CutPrefix(notifier.unlockURL, "https://app.example.com/unlock-account?token=")
+	if !ok {
+		t.Fatalf("unlock url = %q", notifier.unlockURL)
+	}
+	if err := auth.UnlockAccount(ctx, service.UnlockAccountInput{Token: token + "x"}); err == nil {
+		t.Fatal("unlocked with a wrong token")
+	}
+	if err := auth.UnlockAccount(ctx, service.UnlockAccountInput{Token: token}); err != nil {
+		t.Fatal(err)
+	}
+	if err := login("doc@example.com", "secreto123", "10.0.0.2"); err != nil {
+		t.Fatalf("login after unlock: %v", err)
+	}
+	if err := auth.UnlockAccount(ctx, service.UnlockAccountInput{Token: token}); err == nil {
+		t.Fatal("unlock token reused")
+	}
+
+	// Los emails desconocidos cuentan para el IP, que se bloquea para todos.
+	for _, email := range []string{"nadie@example.com", "otro@example.com"} {
+		if err := login(email, "x", "10.0.0.1"); err == nil || errors.As(err, &throttled) {
+			t.Fatalf("unknown email: %v", err)
+		}
+	}
+	if err := login("doc@example.com", "secreto123", "10.0.0.1"); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("login from locked ip = %v", err)
+	}
+
+	// forgot-password: MaxResetRequests por email y hora.
+	for i := 0; i < 2; i++ {
+		if _, err := auth.ForgotPassword(ctx, service.ForgotPasswordInput{Email: "doc@example.com", IP: "10.0.0.3"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = auth.ForgotPassword(ctx, service.ForgotPasswordInput{Email: "doc@example.com", IP: "10.0.0.4"})
+	if !errors.As(err, &throttled) || throttled.RetryAfter < 59*time.Minute {
+		t.Fatalf("third reset request = %v", err)
+	}
+
+	// Tokens de reset inválidos: el IP queda bloqueado.
+	for i := 0; i < 6; i++ {
+		if err := auth.ResetPassword(ctx, service.ResetPasswordInput{Token: fmt.Sprint("adivinado-", i), NewPassword: "nuevo12345", IP: "10.0.0.5"}); err == nil || errors.As(err, &throttled) {
+			t.Fatalf("invalid reset token %d: %v", i, err)
+		}
+	}
+	if err := auth.ResetPassword(ctx, service.ResetPasswordInput{Token: "otro", NewPassword: "nuevo12345", IP: "10.0.0.5"}); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("reset from locked ip = %v", err)
+	}
+
+	want := map[string]int{
+		service.SecurityLoginFailed: 6, service.SecurityLoginThrottled: 3, service.SecurityAccountLocked: 1,
+		service.SecurityAccountUnlocked: 1, service.SecurityIPLocked: 2, service.SecurityResetThrottled: 1,
+		service.SecurityResetInvalidToken: 6,
+	}
+	for typ, n := range want {
+		if seen[typ] != n {
+			t.Errorf("%s events = %d, want %d (all: %v)", typ, seen[typ], n, seen)
+		}
+	}
+}
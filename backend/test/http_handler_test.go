@@ -0,0 +1,68 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"clinical-backend/internal/api"
+	"clinical-backend/internal/service"
+	"clinical-backend/internal/store"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	repos := store.NewInMemoryRepositories()
+	auth := service.NewAuthService(repos.Users)
+	if _, err := auth.Register(context.Background(), service.RegisterInput{Name: "Ana", Email: "ana@example.com", Password: "secreto123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	router := api.NewRouter(nil, nil, nil, auth, nil, nil, nil, nil)
+	srv := httptest.NewServer(api.NewHTTPHandler(router,
+		api.WithMaxBodyBytes(1024),
+		api.WithCORSOrigins([]string{"https://app.example.com/"}),
+	))
+	defer srv.Close()
+
+	do := func(method, path, body string, header map[string]string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := do("POST", "/auth/login", `{"email":"ana@example.com","password":"secreto123"}`, map[string]string{"Origin": "https://app.example.com"})
+	if resp.StatusCode != 200 {
+		t.Fatalf("login = %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("allowed origin: Access-Control-Allow-Origin = %q", got)
+	}
+	var login service.LoginOutput
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil || login.AccessToken == "" {
+		t.Fatalf("login body: %+v, %v", login, err)
+	}
+
+	// El header llega al router en minúsculas, como desde API Gateway.
+	if resp := do("GET", "/users/me", "", map[string]string{"Authorization": "Bearer " + login.AccessToken}); resp.StatusCode != 200 {
+		t.Errorf("GET /users/me with token = %d", resp.StatusCode)
+	}
+
+	resp = do("GET", "/health", "", map[string]string{"Origin": "https://evil.example.com"})
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("other origin: Access-Control-Allow-Origin = %q", got)
+	}
+
+	if resp := do("POST", "/auth/login", strings.Repeat("x", 2048), nil); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body = %d, want 413", resp.StatusCode)
+	}
+}
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"clinical-backend/internal/store/postgres"
+)
+
+// migrationDB es un driver database/sql en memoria que solo entiende lo que
+// usa postgres.Migrate: la tabla schema_migrations y los scripts, que se
+// cuentan sin ejecutarse.
+type migrationDB struct {
+	mu      sync.Mutex
+	applied map[string]bool
+	order   []string // versiones registradas, en orden
+	scripts int      // scripts ejecutados
+	commits int
+	failOn  string // un script que contiene este texto falla
+}
+
+type migrationConn struct{ db *migrationDB }
+
+func (c *migrationConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *migrationConn) Close() error                        { return nil }
+func (c *migrationConn) Begin() (driver.Tx, error)           { return &migrationTx{db: c.db}, nil }
+
+func (c *migrationConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	d := c.db
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case strings.Contains(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		version := args[0].Value.(string)
+		d.applied[version] = true
+		d.order = append(d.order, version)
+	default:
+		if d.failOn != "" && strings.Contains(query, d.failOn) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d.scripts++
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *migrationConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	d := c.db
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &existsRows{value: d.applied[args[0].Value.(string)]}, nil
+}
+
+type migrationTx struct{ db *migrationDB }
+
+func (t *migrationTx) Commit() error {
+	t.db.mu.Lock()
+	t.db.commits++
+	t.db.mu.Unlock()
+	return nil
+}
+
+func (t *migrationTx) Rollback() error { return nil }
+
+// existsRows es el resultado de un SELECT EXISTS.
+type existsRows struct {
+	value bool
+	done  bool
+}
+
+func (r *existsRows) Columns() []string { return []string{"exists"} }
+func (r *existsRows) Close() error      { return nil }
+
+func (r *existsRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+var registerMigrationDB sync.Once
+
+func openMigrationDB(t *testing.T, d *migrationDB) *sql.DB {
+	t.Helper()
+	registerMigrationDB.Do(func() { sql.Register("migrationdb", migrationRegistry{}) })
+	migrationDBs.Store(t.Name(), d)
+	db, err := sql.Open("migrationdb", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close(); migrationDBs.Delete(t.Name()) })
+	return db
+}
+
+// migrationRegistry reparte cada DSN (el nombre del test) a su migrationDB.
+type migrationRegistry struct{}
+
+var migrationDBs sync.Map
+
+func (migrationRegistry) Open(name string) (driver.Conn, error) {
+	d, _ := migrationDBs.Load(name)
+	return &migrationConn{db: d.(*migrationDB)}, nil
+}
+
+// Las migraciones embebidas se aplican en orden de nombre, cada una en su
+// transacción, y una sola vez.
+func TestPostgresMigrateAppliesEmbeddedMigrationsOnce(t *testing.T) {
+	ctx := context.Background()
+	d := &migrationDB{applied: map[string]bool{}}
+	db := openMigrationDB(t, d)
+
+	if err := postgres.Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(d.order) == 0 || d.order[0] != "0001_init" || !slices.IsSorted(d.order) {
+		t.Fatalf("applied = %v, want every migration in name order from 0001_init", d.order)
+	}
+	if d.scripts != len(d.order) || d.commits != len(d.order) {
+		t.Fatalf("%d scripts and %d commits for %d migrations", d.scripts, d.commits, len(d.order))
+	}
+
+	// Re-ejecutar no vuelve a aplicar nada.
+	applied := len(d.order)
+	if err := postgres.Migrate(ctx, db); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	if len(d.order) != applied || d.scripts != applied {
+		t.Fatalf("second run applied %v", d.order[applied:])
+	}
+}
+
+// Una migración que falla no queda registrada y detiene las siguientes.
+func TestPostgresMigrateStopsOnFailure(t *testing.T) {
+	d := &migrationDB{applied: map[string]bool{"0001_init": true}, failOn: "ADD COLUMN session_id"}
+	db := openMigrationDB(t, d)
+
+	err := postgres.Migrate(context.Background(), db)
+	if err == nil || !strings.Contains(err.Error(), "migration 0002_sessions") {
+		t.Fatalf("Migrate = %v, want the 0002_sessions failure", err)
+	}
+	if len(d.order) != 0 || d.commits != 0 {
+		t.Fatalf("recorded %v with %d commits after a failed migration", d.order, d.commits)
+	}
+}
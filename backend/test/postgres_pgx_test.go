@@ -0,0 +1,4 @@
+package test
+
+// Driver "pgx" para TestStoreConformancePostgres.
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,502 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/api"
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/service"
+	"clinical-backend/internal/store"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRouterRouteTable(t *testing.T) {
+	repos := store.NewInMemoryRepositories()
+	router := api.NewRouter(nil, nil, nil, service.NewAuthService(repos.Users), nil, nil, nil, nil)
+
+	call := func(method, path string) events.APIGatewayV2HTTPResponse {
+		req := events.APIGatewayV2HTTPRequest{}
+		req.RequestContext.HTTP.Method = method
+		req.RequestContext.HTTP.Path = path
+		resp, err := router.Handle(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+
+	cases := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/health", 200},
+		{"GET", "/health/", 200},
+		{"GET", "/nope", 404},
+		{"GET", "/patients/abc/unknown", 404},
+		{"DELETE", "/payments", 405},
+		{"GET", "/patients/search", 401},
+		{"GET", "/patients/abc", 401},
+		{"PUT", "/odontograms/odo-1/tooth-condition", 401},
+		{"OPTIONS", "/anything", 204},
+	}
+	for _, c := range cases {
+		if got := call(c.method, c.path).StatusCode; got != c.want {
+			t.Errorf("%s %s = %d, want %d", c.method, c.path, got, c.want)
+		}
+	}
+	if allow := call("DELETE", "/payments").Headers["Allow"]; allow != "GET, POST, OPTIONS" {
+		t.Errorf("Allow = %q", allow)
+	}
+
+	public := map[string]bool{}
+	for _, r := range api.Routes() {
+		public[r.Method+" "+r.Pattern] = r.Public
+	}
+	if !public["POST /auth/login"] || public["GET /patients/{id}"] {
+		t.Errorf("unexpected public flags: %v", public)
+	}
+}
+
+// storedToken is how the session repositories keep an access token.
+func storedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestOrgRoleMatrix(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica"}); err != nil {
+		t.Fatal(err)
+	}
+	users := map[string]store.AuthUser{}
+	for _, role := range []string{"admin", "doctor", "assistant"} {
+		u, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "usr-" + role, OrgID: "org-1", Email: role + "@example.com", Role: role, Status: "active"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[role] = u
+		if _, err := repos.Users.CreateSession(ctx, store.AuthSession{Token: storedToken("tok-" + role), UserID: u.ID, OrgID: "org-1", Role: role, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auth := service.NewAuthService(repos.Users)
+	router := api.NewRouter(nil, nil, nil, auth, nil, nil, nil, nil)
+	call := func(role, method, path, body string) events.APIGatewayV2HTTPResponse {
+		t.Helper()
+		req := events.APIGatewayV2HTTPRequest{Body: body, Headers: map[string]string{"authorization": "Bearer tok-" + role}}
+		req.RequestContext.HTTP.Method = method
+		req.RequestContext.HTTP.Path = path
+		resp, err := router.Handle(ctx, req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+	can := func(role, perm string) bool { return auth.HasPermission(ctx, users[role], perm) }
+
+	// La matriz predeterminada es la de siempre.
+	if !can("doctor", service.PermFinancesManage) || can("assistant", service.PermFinancesManage) ||
+		!can("assistant", service.PermFinancesView) || can("doctor", service.PermUsersManage) || !can("admin", service.PermConsentTemplatesManage) {
+		t.Fatal("default matrix changed")
+	}
+	if got := call("doctor", "GET", "/org/roles", "").StatusCode; got != 403 {
+		t.Fatalf("doctor GET /org/roles = %d", got)
+	}
+	for _, c := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/org/roles/receptionist", `{"label":"Recepción","permissions":["patients.view","appointments.write"]}`, 200},
+		{"/org/roles/billing", `{"permissions":["platform.manage"]}`, 400},
+		{"/org/roles/admin", `{"permissions":[]}`, 400},
+		{"/org/roles/Bad%20Name", `{"permissions":[]}`, 400},
+		{"/org/roles/doctor", `{"permissions":["patients.view","users.manage"]}`, 200},
+	} {
+		if resp := call("admin", "PUT", c.path, c.body); resp.StatusCode != c.want {
+			t.Fatalf("PUT %s = %d %s", c.path, resp.StatusCode, resp.Body)
+		}
+	}
+	// El cambio vale en la petición siguiente.
+	if got := call("doctor", "GET", "/org/roles", "").StatusCode; got != 200 || can("doctor", service.PermTreatmentsManage) {
+		t.Fatalf("doctor after override = %d", got)
+	}
+
+	// Los roles propios se asignan como cualquier otro.
+	if _, err := auth.CreateOrgUser(ctx, service.CreateOrgUserInput{OrgID: "org-1", Name: "Ana", Email: "ana@example.com", Role: "Receptionist"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.CreateOrgUser(ctx, service.CreateOrgUserInput{OrgID: "org-1", Name: "Luis", Email: "luis@example.com", Role: "janitor"}); err == nil {
+		t.Fatal("created a user with an unknown role")
+	}
+	if resp := call("admin", "DELETE", "/org/roles/receptionist", ""); resp.StatusCode != 400 {
+		t.Fatalf("DELETE role in use = %d", resp.StatusCode)
+	}
+
+	// Borrar un rol predeterminado restaura la matriz de siempre.
+	if resp := call("admin", "DELETE", "/org/roles/doctor", ""); resp.StatusCode != 200 {
+		t.Fatalf("DELETE doctor = %d %s", resp.StatusCode, resp.Body)
+	}
+	if got := call("doctor", "GET", "/org/roles", "").StatusCode; got != 403 || !can("doctor", service.PermTreatmentsManage) {
+		t.Fatalf("doctor after reset = %d", got)
+	}
+	// Los roles con treatments.manage llevan agenda propia, como doctor.
+	if resp := call("admin", "PUT", "/org/roles/hygienist", `{"permissions":["treatments.manage"]}`); resp.StatusCode != 200 {
+		t.Fatalf("PUT hygienist = %d %s", resp.StatusCode, resp.Body)
+	}
+	for role, want := range map[string]bool{"doctor": true, "hygienist": true, "admin": false, "assistant": false, "receptionist": false} {
+		if got := auth.IsDoctor(ctx, store.AuthUser{OrgID: "org-1", Role: role}); got != want {
+			t.Errorf("IsDoctor(%s) = %t, want %t", role, got, want)
+		}
+	}
+	// Su perfil lo ve cualquier rol, con los permisos que tiene.
+	resp := call("assistant", "GET", "/users/me", "")
+	if resp.StatusCode != 200 || !strings.Contains(resp.Body, `"permissions":["patients.view","appointments.write","finances.view","chat.use"]`) {
+		t.Fatalf("GET /users/me = %d %s", resp.StatusCode, resp.Body)
+	}
+	// /users/me/... no pide patients.view (antes sí): un rol sin él y un
+	// paciente gestionan su perfil y su MFA, pero siguen sin ver pacientes.
+	if resp := call("admin", "PUT", "/org/roles/billing", `{"permissions":["finances.view"]}`); resp.StatusCode != 200 {
+		t.Fatalf("PUT billing = %d %s", resp.StatusCode, resp.Body)
+	}
+	for _, role := range []string{"billing", "patient"} {
+		u, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "usr-" + role, OrgID: "org-1", Email: role + "@example.com", Role: role, Status: "active"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Users.CreateSession(ctx, store.AuthSession{Token: storedToken("tok-" + role), UserID: u.ID, OrgID: "org-1", Role: role, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []struct {
+			method, path string
+			want         int
+		}{
+			{"GET", "/users/me", 200},
+			{"GET", "/users/me/sessions", 200},
+			{"POST", "/users/me/mfa/enroll", 200},
+			{"GET", "/patients", 403},
+		} {
+			if resp := call(role, c.method, c.path, ""); resp.StatusCode != c.want {
+				t.Errorf("%s %s %s = %d, want %d", role, c.method, c.path, resp.StatusCode, c.want)
+			}
+		}
+	}
+}
+
+// orgScopedAuthRepo only returns an organization to a context of that org,
+// as a strict backend may do.
+type orgScopedAuthRepo struct{ store.AuthRepository }
+
+func (r orgScopedAuthRepo) GetOrganization(ctx context.Context, orgID string) (store.Organization, error) {
+	if store.OrgIDFromContext(ctx) != orgID {
+		return store.Organization{}, store.ErrMissingOrg
+	}
+	return r.AuthRepository.GetOrganization(ctx, orgID)
+}
+
+// Authenticate corre antes de que la petición tenga org: la configuración de
+// la org se lee con el contexto de esa org.
+func TestOrgSettingsReadWithOrgContext(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", DoctorsOwnPatientsOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-a", OrgID: "org-1", Email: "doc@example.com", Role: "doctor", Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateSession(ctx, store.AuthSession{Token: storedToken("tok-doc"), UserID: "doc-a", OrgID: "org-1", Role: "doctor", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	auth := service.NewAuthService(orgScopedAuthRepo{repos.Users})
+	got, err := auth.Authenticate(ctx, "tok-doc")
+	if err != nil || !got.OwnPatientsOnly {
+		t.Fatalf("Authenticate = %+v, %v; want the org policy applied", got, err)
+	}
+}
+
+// La política alcanza a todo rol con agenda propia (IsDoctorRole), no solo al
+// rol doctor literal.
+func TestOwnPatientsOnlyFollowsDoctorRoles(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	org := store.Organization{ID: "org-1", Name: "Clínica", DoctorsOwnPatientsOnly: true, Roles: []store.OrgRole{
+		{Name: "hygienist", Permissions: []string{"patients.view", "treatments.manage"}},
+		{Name: "billing", Permissions: []string{"patients.view", "finances.view"}},
+	}}
+	if _, err := repos.Users.CreateOrganization(ctx, org); err != nil {
+		t.Fatal(err)
+	}
+	auth := service.NewAuthService(repos.Users)
+	for role, want := range map[string]bool{"doctor": true, "hygienist": true, "billing": false, "admin": false, "assistant": false} {
+		if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "usr-" + role, OrgID: "org-1", Email: role + "@example.com", Role: role, Status: "active"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Users.CreateSession(ctx, store.AuthSession{Token: storedToken("tok-" + role), UserID: "usr-" + role, OrgID: "org-1", Role: role, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := auth.Authenticate(ctx, "tok-"+role)
+		if err != nil || got.OwnPatientsOnly != want {
+			t.Errorf("%s: OwnPatientsOnly = %t, %v; want %t", role, got.OwnPatientsOnly, err, want)
+		}
+	}
+}
+
+func TestDoctorsOwnPatientsOnly(t *testing.T) {
+	ctx := context.Background()
+	orgCtx := store.ContextWithOrgID(ctx, "org-1")
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []struct{ id, role string }{{"admin", "admin"}, {"doc-a", "doctor"}, {"doc-b", "doctor"}} {
+		if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: u.id, OrgID: "org-1", Email: u.id + "@example.com", Role: u.role, Status: "active"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Users.CreateSession(ctx, store.AuthSession{Token: storedToken("tok-" + u.id), UserID: u.id, OrgID: "org-1", Role: u.role, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// pat-shared es de doc-b pero tiene una cita con doc-a.
+	start := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Hour)
+	for _, p := range []domain.Patient{{ID: "pat-a", DoctorID: "doc-a"}, {ID: "pat-b", DoctorID: "doc-b"}, {ID: "pat-shared", DoctorID: "doc-b"}} {
+		p.FirstName = p.ID
+		if _, err := repos.Patients.Create(orgCtx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// appt-b-for-a: cita con doc-b de un paciente de doc-a.
+	for _, a := range []domain.Appointment{{ID: "appt-b", DoctorID: "doc-b", PatientID: "pat-b"}, {ID: "appt-shared", DoctorID: "doc-a", PatientID: "pat-shared"}, {ID: "appt-b-for-a", DoctorID: "doc-b", PatientID: "pat-a"}} {
+		a.StartAt, a.EndAt, a.Status = start, start.Add(time.Hour), "scheduled"
+		if _, err := repos.Appointments.Create(orgCtx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []domain.PaymentRecord{{ID: "pay-a", PatientID: "pat-a", Amount: 10}, {ID: "pay-b", PatientID: "pat-b", Amount: 20}} {
+		p.OrgID, p.CreatedAt = "org-1", time.Now()
+		if _, err := repos.Payments.Create(orgCtx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repos.Budgets.Create(orgCtx, domain.Budget{ID: "bud-b", OrgID: "org-1", PatientID: "pat-b", Title: "Ortodoncia"}); err != nil {
+		t.Fatal(err)
+	}
+
+	access := service.NewPatientAccess(repos.Patients, repos.Appointments)
+	router := api.NewRouter(
+		service.NewAppointmentService(repos.Appointments, nil, service.WithPatientRepo(repos.Patients), service.WithAuthRepo(repos.Users), service.WithResourceRepo(repos.Resources), service.WithAppointmentAccess(access)),
+		service.NewPatientService(repos.Patients, service.WithPatientAccess(access)),
+		nil, service.NewAuthService(repos.Users), nil,
+		service.NewPaymentService(repos.Payments, service.WithPaymentPatientRepo(repos.Patients), service.WithPaymentAccess(access)),
+		service.NewBudgetService(repos.Budgets, service.WithBudgetAccess(access)),
+		nil)
+	call := func(user, method, path, body string) events.APIGatewayV2HTTPResponse {
+		t.Helper()
+		req := events.APIGatewayV2HTTPRequest{Body: body, Headers: map[string]string{"authorization": "Bearer tok-" + user}}
+		path, query, _ := strings.Cut(path, "?")
+		if q, _ := url.ParseQuery(query); len(q) > 0 {
+			req.QueryStringParameters = map[string]string{}
+			for k := range q {
+				req.QueryStringParameters[k] = q.Get(k)
+			}
+		}
+		req.RequestContext.HTTP.Method = method
+		req.RequestContext.HTTP.Path = path
+		resp, err := router.Handle(ctx, req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+
+	// Sin la política un doctor ve toda la org.
+	if got := call("doc-a", "GET", "/patients/pat-b", "").StatusCode; got != 200 {
+		t.Fatalf("policy off: GET /patients/pat-b = %d", got)
+	}
+	if resp := call("admin", "PUT", "/org/access-policy", `{"doctorsOwnPatientsOnly":true}`); resp.StatusCode != 200 {
+		t.Fatalf("PUT /org/access-policy = %d %s", resp.StatusCode, resp.Body)
+	}
+	for _, c := range []struct {
+		user, path string
+		want       int
+	}{
+		{"doc-a", "/patients/pat-a", 200},
+		{"doc-a", "/patients/pat-shared", 200},
+		{"doc-a", "/patients/pat-b", 403},
+		{"doc-a", "/patients?doctorId=doc-b", 403},
+		{"doc-a", "/appointments/appt-b", 403},
+		{"doc-a", "/appointments/appt-shared", 200},
+		{"doc-a", "/patients/pat-b/payments", 403},
+		{"doc-a", "/budgets/bud-b", 403},
+		{"doc-b", "/budgets/bud-b", 200},
+		{"admin", "/patients/pat-b", 200},
+	} {
+		if resp := call(c.user, "GET", c.path, ""); resp.StatusCode != c.want {
+			t.Fatalf("%s GET %s = %d %s", c.user, c.path, resp.StatusCode, resp.Body)
+		}
+	}
+	// Los listados se recortan a sus pacientes.
+	if resp := call("doc-a", "GET", "/patients", ""); !strings.Contains(resp.Body, `"total":1`) || !strings.Contains(resp.Body, "pat-a") {
+		t.Fatalf("doc-a GET /patients = %s", resp.Body)
+	}
+	if resp := call("doc-a", "GET", "/payments", ""); strings.Contains(resp.Body, "pay-b") || !strings.Contains(resp.Body, "pay-a") {
+		t.Fatalf("doc-a GET /payments = %s", resp.Body)
+	}
+	if resp := call("doc-a", "POST", "/payments", `{"patientId":"pat-b","amount":5}`); resp.StatusCode != 403 {
+		t.Fatalf("doc-a POST /payments for pat-b = %d", resp.StatusCode)
+	}
+	// La agenda del día por recurso también: sus citas y las de sus pacientes.
+	day := "/resources/schedule?date=" + start.Format("2006-01-02")
+	if resp := call("doc-a", "GET", day, ""); resp.StatusCode != 200 || strings.Contains(resp.Body, `"appt-b"`) ||
+		!strings.Contains(resp.Body, "appt-shared") || !strings.Contains(resp.Body, "appt-b-for-a") {
+		t.Fatalf("doc-a GET %s = %d %s", day, resp.StatusCode, resp.Body)
+	}
+	if resp := call("admin", "GET", day, ""); !strings.Contains(resp.Body, `"appt-b"`) {
+		t.Fatalf("admin GET %s = %d %s", day, resp.StatusCode, resp.Body)
+	}
+}
+
+// countingPatients cuenta las lecturas que hace PatientAccess.
+type countingPatients struct {
+	store.PatientRepository
+	gets, lists atomic.Int32
+}
+
+func (r *countingPatients) GetByID(ctx context.Context, id string) (domain.Patient, error) {
+	r.gets.Add(1)
+	return r.PatientRepository.GetByID(ctx, id)
+}
+
+func (r *countingPatients) ListByDoctor(ctx context.Context, doctorID string) ([]domain.Patient, error) {
+	r.lists.Add(1)
+	return r.PatientRepository.ListByDoctor(ctx, doctorID)
+}
+
+// Los pacientes de un doctor restringido se leen una vez por petición, no
+// una por registro del listado.
+func TestPatientAccessLoadsOncePerRequest(t *testing.T) {
+	ctx := context.Background()
+	orgCtx := store.ContextWithOrgID(ctx, "org-1")
+	repos := store.NewInMemoryRepositories()
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica", DoctorsOwnPatientsOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-a", OrgID: "org-1", Email: "doc@example.com", Role: "doctor", Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateSession(ctx, store.AuthSession{Token: storedToken("tok-doc"), UserID: "doc-a", OrgID: "org-1", Role: "doctor", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		p := domain.Patient{ID: fmt.Sprintf("pat-%d", i), FirstName: "P", DoctorID: "doc-b"}
+		if i%2 == 0 {
+			p.DoctorID = "doc-a"
+		}
+		if _, err := repos.Patients.Create(orgCtx, p); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Payments.Create(orgCtx, domain.PaymentRecord{ID: "pay-" + p.ID, OrgID: "org-1", PatientID: p.ID, Amount: 10, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	patients := &countingPatients{PatientRepository: repos.Patients}
+	access := service.NewPatientAccess(patients, repos.Appointments)
+	router := api.NewRouter(nil, nil, nil, service.NewAuthService(repos.Users), nil,
+		service.NewPaymentService(repos.Payments, service.WithPaymentAccess(access)),
+		nil, nil)
+	req := events.APIGatewayV2HTTPRequest{Headers: map[string]string{"authorization": "Bearer tok-doc"}}
+	req.RequestContext.HTTP.Method = "GET"
+	req.RequestContext.HTTP.Path = "/payments"
+	for range 2 {
+		resp, err := router.Handle(ctx, req)
+		if err != nil || resp.StatusCode != 200 || strings.Contains(resp.Body, "pay-pat-1") || !strings.Contains(resp.Body, "pay-pat-8") {
+			t.Fatalf("GET /payments = %v %d %s", err, resp.StatusCode, resp.Body)
+		}
+	}
+	if got := patients.lists.Load(); got != 2 {
+		t.Errorf("ListByDoctor calls = %d, want one per request", got)
+	}
+	if got := patients.gets.Load(); got != 0 {
+		t.Errorf("GetByID calls = %d, want 0", got)
+	}
+}
+
+// El orgId de la ruta es el del admin que llama: un admin de otra org no
+// gestiona a los usuarios de esta. platform_admin gestiona todas.
+func TestOrgUserAdminRoutesStayInCallerOrg(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewInMemoryRepositories()
+	for _, org := range []string{"org-1", "org-2"} {
+		if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: org, Name: org}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, u := range []store.AuthUser{
+		{ID: "usr-1", OrgID: "org-1", Role: "doctor"},
+		{ID: "admin-1", OrgID: "org-1", Role: "admin"},
+		{ID: "admin-2", OrgID: "org-2", Role: "admin"},
+		{ID: "root", Role: "platform_admin"},
+	} {
+		u.Email, u.Status = u.ID+"@example.com", "active"
+		if _, err := repos.Users.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Users.CreateSession(ctx, store.AuthSession{Token: storedToken("tok-" + u.ID), UserID: u.ID, OrgID: u.OrgID, Role: u.Role, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auth := service.NewAuthService(repos.Users)
+	router := api.NewRouter(nil, nil, nil, auth, nil, nil, nil, nil)
+	call := func(user, method, path string) events.APIGatewayV2HTTPResponse {
+		t.Helper()
+		req := events.APIGatewayV2HTTPRequest{Headers: map[string]string{"authorization": "Bearer tok-" + user}}
+		req.RequestContext.HTTP.Method = method
+		req.RequestContext.HTTP.Path = path
+		resp, err := router.Handle(ctx, req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+	session := func() bool {
+		_, err := auth.Authenticate(ctx, "tok-usr-1")
+		return err == nil
+	}
+
+	if resp := call("admin-2", "DELETE", "/orgs/org-1/users/usr-1/sessions"); resp.StatusCode != 403 || !session() {
+		t.Fatalf("admin of org-2 revoking org-1 sessions = %d %s", resp.StatusCode, resp.Body)
+	}
+	if resp := call("admin-1", "DELETE", "/orgs/org-1/users/usr-1/sessions"); resp.StatusCode != 200 || session() {
+		t.Fatalf("admin of org-1 revoking sessions = %d %s", resp.StatusCode, resp.Body)
+	}
+	if resp := call("root", "DELETE", "/orgs/org-1/users/usr-1/sessions"); resp.StatusCode != 200 {
+		t.Fatalf("platform_admin revoking sessions = %d %s", resp.StatusCode, resp.Body)
+	}
+
+	// Reset de MFA: quita un segundo factor, con la misma regla.
+	if err := repos.Users.SetUserMFA(ctx, "usr-1", store.UserMFA{Secret: "JBSWY3DPEHPK3PXP", EnabledAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := call("admin-2", "DELETE", "/orgs/org-1/users/usr-1/mfa"); resp.StatusCode != 403 {
+		t.Fatalf("admin of org-2 resetting org-1 mfa = %d %s", resp.StatusCode, resp.Body)
+	}
+	if u, err := repos.Users.GetUserByID(ctx, "usr-1"); err != nil || u.MFA.Secret == "" {
+		t.Fatalf("mfa after foreign reset = %+v, %v", u.MFA, err)
+	}
+	if resp := call("admin-1", "DELETE", "/orgs/org-1/users/usr-1/mfa"); resp.StatusCode != 200 {
+		t.Fatalf("admin of org-1 resetting mfa = %d %s", resp.StatusCode, resp.Body)
+	}
+	if u, _ := repos.Users.GetUserByID(ctx, "usr-1"); u.MFA.Secret != "" {
+		t.Fatal("mfa still enabled after reset")
+	}
+}
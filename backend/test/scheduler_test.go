@@ -0,0 +1,340 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/notifications"
+	"clinical-backend/internal/scheduler"
+	"clinical-backend/internal/service"
+	"clinical-backend/internal/store"
+)
+
+// recordingNotifier registra los envíos; los métodos no usados por los jobs quedan sin implementar.
+type recordingNotifier struct {
+	notifications.Notifier
+	mu        sync.Mutex
+	reminders []string
+	summaries []string
+	moved     []string
+	changes   []string          // avisos al doctor
+	offers    map[string]string // email -> token de la oferta de lista de espera
+}
+
+func (n *recordingNotifier) SendAppointmentCreated(_ context.Context, toEmail, _ string, appt domain.Appointment, _ []notifications.ConsentLink) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.reminders = append(n.reminders, appt.ID)
+	return nil
+}
+
+func (n *recordingNotifier) SendAppointmentCreatedSMS(context.Context, string, string, domain.Appointment) error {
+	return nil
+}
+
+func (n *recordingNotifier) SendDoctorDailySummary(_ context.Context, doctorID, _, _ string) error {
+	n.summaries = append(n.summaries, doctorID)
+	return nil
+}
+
+func (n *recordingNotifier) SendAppointmentRescheduled(_ context.Context, _, _, confirmToken string, prevStart, _, startAt, _ time.Time) error {
+	n.moved = append(n.moved, prevStart.UTC().Format(time.RFC3339)+"->"+startAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+func (n *recordingNotifier) SendAppointmentEvent(context.Context, string, string, string, domain.Appointment) error {
+	return nil
+}
+
+func (n *recordingNotifier) SendDoctorAppointmentChange(_ context.Context, _, _, _, eventType string, _, startAt time.Time, reason string) error {
+	n.changes = append(n.changes, eventType+" "+startAt.UTC().Format(time.RFC3339)+" "+reason)
+	return nil
+}
+
+func (n *recordingNotifier) SendWaitlistOffer(_ context.Context, toEmail, _, offerToken string, _, _, _ time.Time) error {
+	if n.offers == nil {
+		n.offers = map[string]string{}
+	}
+	n.offers[toEmail] = offerToken
+	return nil
+}
+
+func TestReminderJobs(t *testing.T) {
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	repos := store.NewInMemoryRepositories()
+	now := time.Date(2026, 3, 10, 21, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []store.AuthUser{
+		{ID: "doc-1", Email: "doc@example.com", Role: "doctor"},
+		{ID: "adm-1", Email: "admin@example.com", Role: "admin"},
+		{ID: "asi-1", Email: "asistente@example.com", Role: "assistant"},
+	} {
+		u.OrgID = "org-1"
+		if _, err := repos.Users.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", DoctorID: "doc-1", FirstName: "Ana", LastName: "Díaz", Email: "ana@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	appts := []domain.Appointment{
+		{ID: "apt-due", StartAt: now.Add(24 * time.Hour), Status: "scheduled"},
+		{ID: "apt-late", StartAt: now.Add(26 * time.Hour), Status: "scheduled"},
+		{ID: "apt-cancelled", StartAt: now.Add(24*time.Hour + 5*time.Minute), Status: "cancelled"},
+		{ID: "apt-today", StartAt: now.Add(-2 * time.Hour), Status: "confirmed"},
+	}
+	for _, a := range appts {
+		a.DoctorID, a.PatientID = "doc-1", "pat-1"
+		a.EndAt = a.StartAt.Add(30 * time.Minute)
+		if _, err := repos.Appointments.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notifier := &recordingNotifier{}
+	svc := service.NewAppointmentService(repos.Appointments, notifier,
+		service.WithPatientRepo(repos.Patients),
+		service.WithAuthRepo(repos.Users),
+		service.WithAppointmentClock(clock),
+	)
+	jobs := scheduler.NewReminderJobs(svc, repos.Appointments, repos.Users, scheduler.WithClock(clock))
+
+	report, err := jobs.Run24hReminders(ctx)
+	if err != nil {
+		t.Fatalf("Run24hReminders: %v", err)
+	}
+	if len(report.Orgs) != 1 || report.Orgs[0].Sent != 1 || report.Orgs[0].Failed != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(notifier.reminders) != 1 || notifier.reminders[0] != "apt-due" {
+		t.Fatalf("expected reminder only for apt-due, got %v", notifier.reminders)
+	}
+	due, _ := repos.Appointments.GetByID(ctx, "apt-due")
+	if due.ReminderSentAt == nil || !due.ReminderSentAt.Equal(now) {
+		t.Fatalf("ReminderSentAt not recorded: %v", due.ReminderSentAt)
+	}
+
+	// Segunda corrida: idempotente
+	report, err = jobs.Run24hReminders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Orgs[0].Sent != 0 || len(notifier.reminders) != 1 {
+		t.Fatalf("second run should not resend, report=%+v reminders=%v", report, notifier.reminders)
+	}
+
+	report, err = jobs.RunEndOfDay(ctx)
+	if err != nil {
+		t.Fatalf("RunEndOfDay: %v", err)
+	}
+	// admin y asistente no son doctores: ni se consultan ni cuentan como omitidos.
+	if report.Orgs[0].Sent != 1 || report.Orgs[0].Skipped != 0 || len(notifier.summaries) != 1 || notifier.summaries[0] != "doc-1" {
+		t.Fatalf("expected one doctor summary, report=%+v summaries=%v", report, notifier.summaries)
+	}
+}
+
+// overlappingReads hace que las dos primeras lecturas de una cita se esperen
+// entre sí: ambas corridas ven la cita sin ReminderSentAt.
+type overlappingReads struct {
+	store.AppointmentRepository
+	reads   atomic.Int32
+	arrived sync.WaitGroup
+}
+
+func (r *overlappingReads) GetByID(ctx context.Context, id string) (domain.Appointment, error) {
+	a, err := r.AppointmentRepository.GetByID(ctx, id)
+	if r.reads.Add(1) <= 2 {
+		r.arrived.Done()
+		r.arrived.Wait()
+	}
+	return a, err
+}
+
+// Dos corridas solapadas leen la cita sin ReminderSentAt; solo la que gana la
+// escritura versionada envía.
+func TestConcurrent24hRemindersSendOnce(t *testing.T) {
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	repos := store.NewInMemoryRepositories()
+	now := time.Date(2026, 3, 10, 21, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", DoctorID: "doc-1", FirstName: "Ana", Email: "ana@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	start := now.Add(24 * time.Hour)
+	if _, err := repos.Appointments.Create(ctx, domain.Appointment{ID: "apt-due", DoctorID: "doc-1", PatientID: "pat-1", StartAt: start, EndAt: start.Add(30 * time.Minute), Status: "scheduled"}); err != nil {
+		t.Fatal(err)
+	}
+
+	appointments := &overlappingReads{AppointmentRepository: repos.Appointments}
+	appointments.arrived.Add(2)
+	notifier := &recordingNotifier{}
+	svc := service.NewAppointmentService(appointments, notifier,
+		service.WithPatientRepo(repos.Patients),
+		service.WithAuthRepo(repos.Users),
+		service.WithAppointmentClock(clock),
+	)
+	jobs := scheduler.NewReminderJobs(svc, appointments, repos.Users, scheduler.WithClock(clock))
+
+	var wg sync.WaitGroup
+	reports := make([]scheduler.RunReport, 2)
+	for i := range reports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i], _ = jobs.Run24hReminders(ctx)
+		}()
+	}
+	wg.Wait()
+	if len(notifier.reminders) != 1 {
+		t.Fatalf("reminders = %v, want exactly one", notifier.reminders)
+	}
+	sent := 0
+	for _, r := range reports {
+		if r.Orgs[0].Failed != 0 {
+			t.Fatalf("a losing run should skip, not fail: %+v", r)
+		}
+		sent += r.Orgs[0].Sent
+	}
+	if sent != 1 {
+		t.Fatalf("Sent across runs = %d, want 1", sent)
+	}
+}
+
+func TestNoShowTracking(t *testing.T) {
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	repos := store.NewInMemoryRepositories()
+	now := time.Date(2026, 3, 10, 21, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	if _, err := repos.Users.CreateOrganization(ctx, store.Organization{ID: "org-1", Name: "Clínica",
+		NoShowPolicy: &domain.NoShowPolicy{WarnAfter: 1, DepositAfter: 2, DepositAmount: 20}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", DoctorID: "doc-1", FirstName: "Ana", Email: "ana@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	appts := []domain.Appointment{
+		{ID: "apt-past-1", StartAt: now.AddDate(0, 0, -1), Status: "scheduled"},
+		{ID: "apt-past-2", StartAt: now.AddDate(0, 0, -2), Status: "confirmed"},
+		{ID: "apt-closed", StartAt: now.AddDate(0, 0, -3), Status: "completed"},
+		{ID: "apt-today", StartAt: now.Add(-3 * time.Hour), Status: "completed"},
+	}
+	for _, a := range appts {
+		a.OrgID, a.DoctorID, a.PatientID = "org-1", "doc-1", "pat-1"
+		a.EndAt = a.StartAt.Add(30 * time.Minute)
+		if _, err := repos.Appointments.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notifier := &recordingNotifier{}
+	svc := service.NewAppointmentService(repos.Appointments, notifier,
+		service.WithPatientRepo(repos.Patients),
+		service.WithAuthRepo(repos.Users),
+		service.WithAppointmentClock(clock),
+	)
+	if _, err := svc.UpdateAppointment(ctx, "apt-closed", service.UpdateAppointmentInput{Status: "scheduled"}); err == nil {
+		t.Fatal("expected completed -> scheduled to be rejected")
+	}
+
+	jobs := scheduler.NewReminderJobs(svc, repos.Appointments, repos.Users, scheduler.WithClock(clock))
+	report, err := jobs.RunEndOfDay(ctx)
+	if err != nil {
+		t.Fatalf("RunEndOfDay: %v", err)
+	}
+	if report.Orgs[0].NoShows != 2 || report.Orgs[0].Failed != 0 {
+		t.Fatalf("expected two no-shows, report=%+v", report)
+	}
+	if a, _ := repos.Appointments.GetByID(ctx, "apt-past-1"); a.Status != "no_show" || a.NoShowAt == nil {
+		t.Errorf("apt-past-1 = %s, noShowAt=%v", a.Status, a.NoShowAt)
+	}
+	if p, _ := repos.Patients.GetByID(ctx, "pat-1"); p.NoShowCount != 2 {
+		t.Fatalf("patient no-show count = %d, want 2", p.NoShowCount)
+	}
+
+	in := service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: now.Add(48 * time.Hour).Format(time.RFC3339), DurationMinutes: 30}
+	if _, err := svc.Create(ctx, in); err == nil {
+		t.Fatal("expected booking without deposit to be rejected")
+	}
+	in.DepositAmount = 20
+	created, err := svc.Create(ctx, in)
+	if err != nil {
+		t.Fatalf("Create with deposit: %v", err)
+	}
+	if created.BookingNotice == nil || created.BookingNotice.Action != "deposit" {
+		t.Errorf("unexpected booking notice: %+v", created.BookingNotice)
+	}
+
+	// Corregir una inasistencia (el paciente sí vino) baja el contador al nivel de aviso.
+	if _, err := svc.UpdateAppointment(ctx, "apt-past-2", service.UpdateAppointmentInput{Status: "completed"}); err != nil {
+		t.Fatal(err)
+	}
+	notice, err := svc.BookingPolicy(ctx, "pat-1", "")
+	if err != nil || notice.Action != "warn" || notice.NoShowCount != 1 {
+		t.Fatalf("BookingPolicy = %+v, %v", notice, err)
+	}
+	// Registrar el pago de una inasistencia la cierra como completada y
+	// también baja el contador.
+	paid, err := svc.RegisterPayment(ctx, "apt-past-1", service.RegisterPaymentInput{Paid: true, PaymentAmount: 40})
+	if err != nil || paid.Status != "completed" {
+		t.Fatalf("RegisterPayment on a no-show = %s, %v", paid.Status, err)
+	}
+	if p, _ := repos.Patients.GetByID(ctx, "pat-1"); p.NoShowCount != 0 {
+		t.Fatalf("patient no-show count after payment = %d, want 0", p.NoShowCount)
+	}
+}
+
+// brokenOrgs falla al leer la organización, como una caída del store.
+type brokenOrgs struct{ store.AuthRepository }
+
+func (brokenOrgs) GetOrganization(context.Context, string) (store.Organization, error) {
+	return store.Organization{}, errors.New("dynamodb: request throttled")
+}
+
+// Si la política de inasistencias no se puede leer, la reserva falla en vez
+// de saltarse el depósito. Una org inexistente no tiene política.
+func TestBookingPolicyReadErrors(t *testing.T) {
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	repos := store.NewInMemoryRepositories()
+	now := time.Date(2026, 3, 10, 21, 0, 0, 0, time.UTC)
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "doc-1", OrgID: "org-1", Email: "doc@example.com", Role: "doctor"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", DoctorID: "doc-1", FirstName: "Ana", NoShowCount: 3}); err != nil {
+		t.Fatal(err)
+	}
+	in := service.CreateAppointmentInput{DoctorID: "doc-1", PatientID: "pat-1", StartAt: now.Add(48 * time.Hour).Format(time.RFC3339), DurationMinutes: 30}
+	newService := func(users store.AuthRepository) *service.AppointmentService {
+		return service.NewAppointmentService(repos.Appointments, &recordingNotifier{},
+			service.WithPatientRepo(repos.Patients),
+			service.WithAuthRepo(users),
+			service.WithAppointmentClock(func() time.Time { return now }),
+		)
+	}
+
+	if _, err := newService(brokenOrgs{repos.Users}).Create(ctx, in); err == nil || !strings.Contains(err.Error(), "throttled") {
+		t.Fatalf("Create with the org unreadable = %v, want the store error", err)
+	}
+	if _, err := newService(repos.Users).Create(ctx, in); err != nil {
+		t.Fatalf("Create in an org that does not exist: %v", err)
+	}
+}
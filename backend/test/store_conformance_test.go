@@ -0,0 +1,168 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"clinical-backend/internal/domain"
+	"clinical-backend/internal/store"
+	"clinical-backend/internal/store/postgres"
+	"clinical-backend/internal/store/storetest"
+)
+
+func TestStoreConformanceInMemory(t *testing.T) {
+	newRepos := func(t *testing.T) storetest.Repositories {
+		return storetest.FromInMemory(store.NewInMemoryRepositories())
+	}
+	storetest.Run(t, newRepos)
+	strictRepos := func(t *testing.T) storetest.Repositories {
+		return storetest.FromInMemory(store.NewInMemoryRepositories(store.WithStrictTenancy(true)))
+	}
+	t.Run("StrictTenancy", func(t *testing.T) { storetest.RunStrict(t, strictRepos) })
+}
+
+func TestStoreConformanceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	repos, err := store.NewFileRepositories(path)
+	if err != nil {
+		t.Fatalf("NewFileRepositories: %v", err)
+	}
+	newRepos := func(t *testing.T) storetest.Repositories { return storetest.FromFile(repos) }
+	storetest.Run(t, newRepos)
+
+	strict, err := store.NewFileRepositories(filepath.Join(t.TempDir(), "strict.json"), store.WithStrictTenancy(true))
+	if err != nil {
+		t.Fatalf("NewFileRepositories: %v", err)
+	}
+	strictRepos := func(t *testing.T) storetest.Repositories { return storetest.FromFile(strict) }
+	t.Run("StrictTenancy", func(t *testing.T) { storetest.RunStrict(t, strictRepos) })
+}
+
+// Lo escrito sobrevive a reabrir el archivo, también desde un backup.
+func TestFileStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	repos, err := store.NewFileRepositories(path)
+	if err != nil {
+		t.Fatalf("NewFileRepositories: %v", err)
+	}
+	ctx := store.ContextWithOrgID(context.Background(), "org-1")
+	if _, err := repos.Users.CreateUser(ctx, store.AuthUser{ID: "user-1", OrgID: "org-1", Email: "Dra@Example.com", Role: "doctor"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := repos.Patients.Create(ctx, domain.Patient{ID: "pat-1", FirstName: "Ana"}); err != nil {
+		t.Fatalf("Create patient: %v", err)
+	}
+	if _, err := repos.Waitlist.Create(ctx, domain.WaitlistEntry{ID: "wait-1", PatientID: "pat-1", DoctorID: "user-1",
+		Status: "offered", Offer: &domain.WaitlistOffer{Token: "offer-1"}}); err != nil {
+		t.Fatalf("Create waitlist: %v", err)
+	}
+	backup := filepath.Join(dir, "backup.json")
+	if err := repos.Backup(backup); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := repos.Patients.Delete(ctx, "pat-1"); err != nil {
+		t.Fatalf("Delete patient: %v", err)
+	}
+
+	reopened, err := store.NewFileRepositories(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if u, err := reopened.Users.GetUserByEmail(ctx, "dra@example.com"); err != nil || u.ID != "user-1" {
+		t.Fatalf("GetUserByEmail after reopen = %+v, %v", u, err)
+	}
+	if _, err := reopened.Patients.GetByID(ctx, "pat-1"); err == nil {
+		t.Fatal("deleted patient is back after reopen")
+	}
+	if e, err := reopened.Waitlist.GetByOfferToken(context.Background(), "offer-1"); err != nil || e.ID != "wait-1" {
+		t.Fatalf("GetByOfferToken after reopen = %+v, %v", e, err)
+	}
+
+	restored, err := store.NewFileRepositories(backup)
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	if p, err := restored.Patients.GetByID(ctx, "pat-1"); err != nil || p.FirstName != "Ana" {
+		t.Fatalf("GetByID from backup = %+v, %v", p, err)
+	}
+}
+
+// DYNAMODB_LOCAL_ENDPOINT=http://localhost:8000 (docker run -p 8000:8000 amazon/dynamodb-local)
+func TestStoreConformanceDynamoDBLocal(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT not set")
+	}
+	// DynamoDB Local acepta cualquier credencial.
+	for _, kv := range [][2]string{{"AWS_ACCESS_KEY_ID", "local"}, {"AWS_SECRET_ACCESS_KEY", "local"}, {"AWS_REGION", "us-east-1"}} {
+		if os.Getenv(kv[0]) == "" {
+			t.Setenv(kv[0], kv[1])
+		}
+	}
+	prefix := "conformance-" + uuid.NewString()[:8] + "-"
+	cfg := store.DynamoDBConfig{
+		PatientTableName:         prefix + "patients",
+		AppointmentTableName:     prefix + "appointments",
+		ConsentTableName:         prefix + "consents",
+		ConsentTemplateTableName: prefix + "consent-templates",
+		UserTableName:            prefix + "users",
+		OdontogramTableName:      prefix + "odontograms",
+		TreatmentPlanTableName:   prefix + "treatment-plans",
+		PaymentTableName:         prefix + "payments",
+		BudgetTableName:          prefix + "budgets",
+		WaitlistTableName:        prefix + "waitlist",
+		ResourceTableName:        prefix + "resources",
+		AppointmentTypeTableName: prefix + "appointment-types",
+		Endpoint:                 endpoint,
+	}
+	repos, err := store.NewDynamoDBRepositories(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewDynamoDBRepositories: %v", err)
+	}
+	newRepos := func(t *testing.T) storetest.Repositories { return storetest.FromDynamoDB(repos) }
+	storetest.Run(t, newRepos)
+
+	strict, err := store.NewDynamoDBRepositories(context.Background(), cfg, store.WithStrictTenancy(true))
+	if err != nil {
+		t.Fatalf("NewDynamoDBRepositories: %v", err)
+	}
+	strictRepos := func(t *testing.T) storetest.Repositories { return storetest.FromDynamoDB(strict) }
+	t.Run("StrictTenancy", func(t *testing.T) { storetest.RunStrict(t, strictRepos) })
+}
+
+// POSTGRES_TEST_DSN apunta a una base desechable. El driver pgx se registra en
+// postgres_pgx_test.go; POSTGRES_DRIVER elige otro.
+func TestStoreConformancePostgres(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+	driver := os.Getenv("POSTGRES_DRIVER")
+	if driver == "" {
+		driver = "pgx"
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Skipf("postgres driver %q not available: %v", driver, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	repos, err := postgres.NewRepositories(context.Background(), db)
+	if err != nil {
+		t.Fatalf("postgres.NewRepositories: %v", err)
+	}
+	newRepos := func(t *testing.T) storetest.Repositories { return storetest.FromPostgres(repos) }
+	storetest.Run(t, newRepos)
+
+	strict, err := postgres.NewRepositories(context.Background(), db, store.WithStrictTenancy(true))
+	if err != nil {
+		t.Fatalf("postgres.NewRepositories: %v", err)
+	}
+	strictRepos := func(t *testing.T) storetest.Repositories { return storetest.FromPostgres(strict) }
+	t.Run("StrictTenancy", func(t *testing.T) { storetest.RunStrict(t, strictRepos) })
+}
@@ -16,8 +16,25 @@ export const endpointCatalog = {
   health: "/health",
   register: "/auth/register",
   login: "/auth/login",
+  refreshSession: "/auth/refresh",
+  verifyMfa: "/auth/mfa/verify",
+  enrollMfaForLogin: "/auth/mfa/enroll",
   forgotPassword: "/auth/forgot-password",
   resetPassword: "/auth/reset-password",
+  unlockAccount: "/auth/unlock",
+  mySessions: "/users/me/sessions",
+  mySession: (sessionId: string) => `/users/me/sessions/${sessionId}`,
+  orgUserSessions: (orgId: string, userId: string) => `/orgs/${orgId}/users/${userId}/sessions`,
+  myMfaEnroll: "/users/me/mfa/enroll",
+  myMfaConfirm: "/users/me/mfa/confirm",
+  myMfaRecoveryCodes: "/users/me/mfa/recovery-codes",
+  myMfaDisable: "/users/me/mfa/disable",
+  orgUserMfa: (orgId: string, userId: string) => `/orgs/${orgId}/users/${userId}/mfa`,
+  mfaPolicy: "/org/mfa-policy",
+  accessPolicy: "/org/access-policy",
+  orgPermissions: "/org/permissions",
+  orgRoles: "/org/roles",
+  orgRole: (role: string) => `/org/roles/${role}`,
   patientOnboard: "/patients/onboard",
   listPatients: "/patients",
   searchPatients: "/patients/search",
@@ -31,10 +48,31 @@ export const endpointCatalog = {
   deleteAppointment: (appointmentId: string) => `/appointments/${appointmentId}`,
   resendAppointmentConfirmation: (appointmentId: string) => `/appointments/${appointmentId}/resend-confirmation`,
   confirmAppointment: (appointmentId: string) => `/appointments/${appointmentId}/confirm`,
+  rescheduleAppointment: (appointmentId: string) => `/appointments/${appointmentId}/reschedule`,
+  cancelAppointment: (appointmentId: string) => `/appointments/${appointmentId}/cancel`,
+  appointmentSeries: (appointmentId: string) => `/appointments/${appointmentId}/series`,
   closeAppointmentDay: (appointmentId: string) => `/appointments/${appointmentId}/close-day`,
   appointmentUploadUrl: (appointmentId: string) => `/appointments/${appointmentId}/upload-url`,
+  availability: "/availability",
+  doctorAvailability: (doctorId: string) => `/doctors/${doctorId}/availability`,
+  doctorCalendarFeed: (doctorId: string) => `/doctors/${doctorId}/calendar-feed`,
+  publicAppointment: (token: string) => `/public/appointments/${token}`,
+  publicCancelAppointment: (token: string) => `/public/appointments/${token}/cancel`,
+  publicAppointmentSlots: (token: string) => `/public/appointments/${token}/slots`,
+  publicRescheduleAppointment: (token: string) => `/public/appointments/${token}/reschedule`,
+  waitlist: "/waitlist",
+  waitlistEntry: (entryId: string) => `/waitlist/${entryId}`,
+  publicWaitlistOffer: (token: string) => `/public/waitlist-offers/${token}`,
+  acceptWaitlistOffer: (token: string) => `/public/waitlist-offers/${token}/accept`,
   platformStats: "/platform/stats",
   orgStats: "/org/stats",
+  noShowPolicy: "/org/no-show-policy",
+  appointmentTypes: "/appointment-types",
+  appointmentType: (typeId: string) => `/appointment-types/${typeId}`,
+  resources: "/resources",
+  resource: (resourceId: string) => `/resources/${resourceId}`,
+  resourceSchedule: "/resources/schedule",
+  patientBookingPolicy: (patientId: string) => `/patients/${patientId}/booking-policy`,
   createConsent: "/consents",
   verifyConsent: (consentId: string) => `/consents/verify/${consentId}`,
   createOdontogram: "/odontograms",